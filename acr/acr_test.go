@@ -2,7 +2,9 @@ package acr
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -416,3 +418,82 @@ func testCheckMap(t *testing.T, nodename string, statemap map[string]string, sta
 		t.Error(fmt.Errorf("Node %s should have states : %s but has states %s", nodename, strings.Join(states, ","), st))
 	}
 }
+
+func TestMLAcrLikelihood(t *testing.T) {
+	// Two tips, JC model: L = sum_r pi_r * P_rA(t1) * P_rB(t2)
+	treeString := "(t1:0.1,t2:0.3)root;"
+	tipstates := map[string]string{"t1": "A", "t2": "B"}
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, lnl, scaling, err := MLAcr(tr, tipstates, MODEL_JC, ALGO_ML_MAP, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if scaling != 1.0 {
+		t.Error(fmt.Errorf("Scaling factor should be 1.0 but is %f", scaling))
+	}
+	// Mu=2 for 2 states with equal frequencies
+	e1, e2 := math.Exp(-2*0.1), math.Exp(-2*0.3)
+	pAA1, pAB1 := e1+(1-e1)*0.5, (1-e1)*0.5
+	pAA2, pAB2 := e2+(1-e2)*0.5, (1-e2)*0.5
+	expected := math.Log(0.5*pAA1*pAB2 + 0.5*pAB1*pAA2)
+	if math.Abs(lnl-expected) > 1e-10 {
+		t.Error(fmt.Errorf("Log likelihood should be %f but is %f", expected, lnl))
+	}
+}
+
+func TestMLAcrMarginalJoint(t *testing.T) {
+	treeString := "(t1:0.1,(t2:0.1,((t3:0.1,(t4:0.1,t5:0.1)t6:0.1)t7:0.1,(t8:0.1,((t9:0.1,t10:0.1)t11:0.1,((t12:0.1,t13:0.1)t14:0.1,t15:0.1)t16:0.1)t17:0.1)t18:0.1)t19:0.1)t20:0.1)t21;"
+	tipstates := map[string]string{
+		"t1": "A", "t2": "A", "t3": "B", "t4": "B", "t5": "A", "t8": "B",
+		"t9": "B", "t10": "A", "t12": "A", "t13": "A", "t15": "A",
+	}
+
+	for _, algo := range []int{ALGO_ML_MAP, ALGO_ML_JOINT} {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Error(err)
+		}
+		statemap, _, _, err := MLAcr(tr, tipstates, MODEL_F81, algo, false)
+		if err != nil {
+			t.Error(err)
+		}
+		testCheckMap(t, "t14", statemap, "A")
+		testCheckMap(t, "t16", statemap, "A")
+		testCheckMap(t, "t21", statemap, "A")
+		testCheckMap(t, "t7", statemap, "B")
+	}
+
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+	}
+	if _, _, _, err = MLAcr(tr, tipstates, MODEL_F81, ALGO_ML_MPPA, true); err != nil {
+		t.Error(err)
+	}
+	// Marginal probabilities must sum to 1
+	for _, n := range tr.Nodes() {
+		if len(n.Comments()) != 2 {
+			t.Error(fmt.Errorf("Node %s should have 2 comments but has %d", n.Name(), len(n.Comments())))
+			continue
+		}
+		sum := 0.0
+		for _, kv := range strings.Split(strings.TrimPrefix(n.Comments()[1], "&"), ",") {
+			p, _ := strconv.ParseFloat(strings.Split(kv, "=")[1], 64)
+			sum += p
+		}
+		if math.Abs(sum-1.0) > 1e-5 {
+			t.Error(fmt.Errorf("Marginal probabilities of node %s should sum to 1 but sum to %f", n.Name(), sum))
+		}
+	}
+	tr, err = newick.NewParser(strings.NewReader("(t1,t2);")).Parse()
+	if err != nil {
+		t.Error(err)
+	}
+	if _, _, _, err = MLAcr(tr, map[string]string{"t1": "A", "t2": "B"}, MODEL_F81, ALGO_ML_MPPA, true); err == nil {
+		t.Error(fmt.Errorf("ML ACR should fail without branch lengths"))
+	}
+}
@@ -0,0 +1,517 @@
+package acr
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Maximum likelihood reconstruction methods
+const (
+	ALGO_ML_MPPA  = iota // Marginal posterior probabilities approximation (PastML)
+	ALGO_ML_MAP          // Maximum a posteriori (most probable marginal state)
+	ALGO_ML_JOINT        // Joint reconstruction (Pupko et al. 2000)
+)
+
+// Character evolution models
+const (
+	MODEL_JC  = iota // Mk model with equal frequencies and equal rates
+	MODEL_F81        // F81-like model, with frequencies estimated from tip states
+)
+
+// Bounds of the golden section search of the scaling factor
+// expressed relatively to the inverse of the average branch length
+const (
+	mlMinScaling = 1e-3
+	mlMaxScaling = 1e3
+	mlEpsilon    = 1e-6
+)
+
+// Model of character evolution along the branches,
+// with transition probabilities computed using F81 formula:
+// P_ij(t) = exp(-mu*r*t)*delta_ij + (1-exp(-mu*r*t))*pi_j
+// where mu is the normalizing factor, and r the scaling factor
+type mlModel struct {
+	freqs   []float64 // Equilibrium frequencies
+	mu      float64   // Normalizing factor, such that expected number of changes per unit of time is 1
+	scaling float64   // Branch length scaling factor
+}
+
+func newMLModel(freqs []float64) (m *mlModel) {
+	var sum float64 = 0.0
+	for _, f := range freqs {
+		sum += f * f
+	}
+	m = &mlModel{freqs: freqs, mu: 1.0, scaling: 1.0}
+	if sum < 1.0 {
+		m.mu = 1.0 / (1.0 - sum)
+	}
+	return
+}
+
+// Fills the given matrix with transition probabilities along a branch of length l
+func (m *mlModel) pij(l float64, p [][]float64) {
+	e := math.Exp(-m.mu * m.scaling * l)
+	for i := range p {
+		for j := range p[i] {
+			p[i][j] = (1.0 - e) * m.freqs[j]
+			if i == j {
+				p[i][j] += e
+			}
+		}
+	}
+}
+
+// Will annotate the tree nodes with ancestral characters
+// computed using maximum likelihood.
+//
+// Characters will be located in the comment field of each node
+// at the first index. If the algorithm is marginal (ALGO_ML_MPPA or ALGO_ML_MAP),
+// the marginal probabilities of all states are added as a second comment
+// of the form "&A=0.9,B=0.1".
+//
+// tipCharacters: mapping between tipnames and character state
+// model: One of MODEL_JC or MODEL_F81
+// algo: One of ALGO_ML_MPPA, ALGO_ML_MAP, ALGO_ML_JOINT : returns an error otherwise
+// If optimize is true, the branch length scaling factor is optimized by maximizing the likelihood,
+// otherwise branch lengths are taken as is.
+//
+// Branch lengths must be defined (>=0), otherwise returns an error.
+//
+// Returns a map with the states of all nodes. If a node has a name, key is its name, if a node has no name,
+// the key will be its id in the deep first traversal of the tree.
+// Also returns the log likelihood of the tree and the branch length scaling factor.
+func MLAcr(t *tree.Tree, tipCharacters map[string]string, model int, algo int, optimize bool) (nametostates map[string]string, lnl float64, scaling float64, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var partials []AncestralState = make([]AncestralState, len(nodes)) // Likelihood of the subtree below each node
+	var scales []float64 = make([]float64, len(nodes))                 // Log scaling factor of each partial
+	var states []AncestralState = make([]AncestralState, len(nodes))   // Reconstructed states / probabilities
+	var m *mlModel
+	var freqs []float64
+	var probas []string // Marginal probabilities of each node, as comments
+
+	// Initialize indices of characters
+	alphabet := make([]string, 0, 10)
+	seenState := make(map[string]bool)
+	for _, state := range tipCharacters {
+		if _, ok := seenState[state]; !ok {
+			alphabet = append(alphabet, state)
+		}
+		seenState[state] = true
+	}
+	sort.Strings(alphabet)
+	stateIndices := AncestralStateIndices(alphabet)
+
+	for i, n := range nodes {
+		n.SetId(i)
+		partials[i] = make(AncestralState, len(alphabet))
+		states[i] = make(AncestralState, len(alphabet))
+	}
+
+	for _, e := range t.Edges() {
+		if e.Length() < 0 {
+			err = fmt.Errorf("maximum likelihood acr requires branch lengths on all branches")
+			return
+		}
+	}
+
+	freqs = make([]float64, len(alphabet))
+	switch model {
+	case MODEL_JC:
+		for i := range freqs {
+			freqs[i] = 1.0 / float64(len(alphabet))
+		}
+	case MODEL_F81:
+		for _, tip := range t.Tips() {
+			if s, ok := tipCharacters[tip.Name()]; ok {
+				freqs[stateIndices[s]]++
+			}
+		}
+		total := 0.0
+		for _, f := range freqs {
+			total += f
+		}
+		for i := range freqs {
+			freqs[i] /= total
+		}
+	default:
+		err = fmt.Errorf("ML model %d unknown", model)
+		return
+	}
+	m = newMLModel(freqs)
+
+	if optimize {
+		if err = mlOptimizeScaling(t, m, tipCharacters, partials, scales, stateIndices); err != nil {
+			return
+		}
+	}
+	if lnl, err = mlLikelihood(t, m, tipCharacters, partials, scales, stateIndices); err != nil {
+		return
+	}
+
+	switch algo {
+	case ALGO_ML_MPPA, ALGO_ML_MAP:
+		mlMarginal(t, m, partials, states)
+		probas = probaComments(states, alphabet)
+		if algo == ALGO_ML_MPPA {
+			mppaSelect(states)
+		} else {
+			mapSelect(states)
+		}
+	case ALGO_ML_JOINT:
+		mlJoint(t, m, tipCharacters, states, stateIndices)
+	default:
+		err = fmt.Errorf("ML algorithm %d unknown", algo)
+		return
+	}
+
+	nametostates = buildInternalNamesToStatesMap(t, states, alphabet)
+	assignStatesToTree(t, states, alphabet)
+	if probas != nil {
+		for _, n := range nodes {
+			n.AddComment(probas[n.Id()])
+		}
+	}
+	scaling = m.scaling
+	return
+}
+
+// Computes the log likelihood of the tree given the model.
+// Fills partials and scales for all nodes
+func mlLikelihood(t *tree.Tree, m *mlModel, tipCharacters map[string]string, partials []AncestralState, scales []float64, stateIndices map[string]int) (lnl float64, err error) {
+	var root *tree.Node = t.Root()
+
+	if err = mlUPPASS(root, nil, m, tipCharacters, partials, scales, stateIndices); err != nil {
+		return
+	}
+	lik := 0.0
+	for i, p := range partials[root.Id()] {
+		lik += m.freqs[i] * p
+	}
+	lnl = math.Log(lik) + scales[root.Id()]
+	return
+}
+
+// Postorder computation of conditional likelihoods of subtrees.
+// Partials are rescaled at each internal node, the log of the scaling factors
+// being accumulated in scales.
+func mlUPPASS(cur, prev *tree.Node, m *mlModel, tipCharacters map[string]string, partials []AncestralState, scales []float64, stateIndices map[string]int) (err error) {
+	var nstates int = len(stateIndices)
+	var p [][]float64 = newProbaMatrix(nstates)
+
+	for k := range partials[cur.Id()] {
+		partials[cur.Id()][k] = 1.0
+	}
+	scales[cur.Id()] = 0.0
+
+	if cur.Tip() {
+		state, ok := tipCharacters[cur.Name()]
+		if !ok {
+			return fmt.Errorf("Tip %s does not exist in the tip/state mapping file", cur.Name())
+		}
+		for k := range partials[cur.Id()] {
+			partials[cur.Id()][k] = 0.0
+		}
+		partials[cur.Id()][stateIndices[state]] = 1.0
+		return
+	}
+
+	for i, child := range cur.Neigh() {
+		if child != prev {
+			if err = mlUPPASS(child, cur, m, tipCharacters, partials, scales, stateIndices); err != nil {
+				return
+			}
+			m.pij(cur.Edges()[i].Length(), p)
+			for r := 0; r < nstates; r++ {
+				sum := 0.0
+				for s := 0; s < nstates; s++ {
+					sum += p[r][s] * partials[child.Id()][s]
+				}
+				partials[cur.Id()][r] *= sum
+			}
+			scales[cur.Id()] += scales[child.Id()]
+		}
+	}
+	scales[cur.Id()] += rescale(partials[cur.Id()])
+	return
+}
+
+// Computes marginal posterior probabilities of each state at each node.
+// mlUPPASS must have been called before.
+func mlMarginal(t *tree.Tree, m *mlModel, partials []AncestralState, states []AncestralState) {
+	var nstates int = len(m.freqs)
+	// Probability of everything outside the subtree below each node
+	var outside []AncestralState = make([]AncestralState, len(partials))
+	// Edge connecting each node to its parent
+	var parentEdges []*tree.Edge = make([]*tree.Edge, len(partials))
+	var p [][]float64 = newProbaMatrix(nstates)
+
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		parentEdges[cur.Id()] = e
+		outside[cur.Id()] = make(AncestralState, nstates)
+		if prev == nil {
+			copy(outside[cur.Id()], m.freqs)
+		} else {
+			// Probability at parent, excluding the current subtree
+			excl := make(AncestralState, nstates)
+			copy(excl, outside[prev.Id()])
+			for i, sibling := range prev.Neigh() {
+				if sibling != cur && prev.Edges()[i] != parentEdges[prev.Id()] {
+					m.pij(prev.Edges()[i].Length(), p)
+					for r := 0; r < nstates; r++ {
+						sum := 0.0
+						for s := 0; s < nstates; s++ {
+							sum += p[r][s] * partials[sibling.Id()][s]
+						}
+						excl[r] *= sum
+					}
+				}
+			}
+			m.pij(e.Length(), p)
+			for s := 0; s < nstates; s++ {
+				sum := 0.0
+				for r := 0; r < nstates; r++ {
+					sum += excl[r] * p[r][s]
+				}
+				outside[cur.Id()][s] = sum
+			}
+			rescale(outside[cur.Id()])
+		}
+		total := 0.0
+		for s := 0; s < nstates; s++ {
+			states[cur.Id()][s] = outside[cur.Id()][s] * partials[cur.Id()][s]
+			total += states[cur.Id()][s]
+		}
+		for s := 0; s < nstates; s++ {
+			states[cur.Id()][s] /= total
+		}
+		return true
+	})
+}
+
+// Joint reconstruction using the dynamic programming algorithm of Pupko et al. 2000.
+// Selected states are set to 1 in states, others to 0.
+func mlJoint(t *tree.Tree, m *mlModel, tipCharacters map[string]string, states []AncestralState, stateIndices map[string]int) {
+	var nstates int = len(m.freqs)
+	// Log likelihood of the best reconstruction of the subtree below each node, given its state
+	var best []AncestralState = make([]AncestralState, len(states))
+	// Best state of each node given the state of its parent
+	var argbest [][]int = make([][]int, len(states))
+	var p [][]float64 = newProbaMatrix(nstates)
+
+	t.PostOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		// Log likelihood of the subtree, given the state of cur
+		sub := make([]float64, nstates)
+		if cur.Tip() {
+			for s := range sub {
+				sub[s] = math.Inf(-1)
+			}
+			sub[stateIndices[tipCharacters[cur.Name()]]] = 0.0
+		} else {
+			for _, child := range cur.Neigh() {
+				if child != prev {
+					for s := range sub {
+						sub[s] += best[child.Id()][s]
+					}
+				}
+			}
+		}
+		best[cur.Id()] = make(AncestralState, nstates)
+		argbest[cur.Id()] = make([]int, nstates)
+		if prev == nil {
+			for s := range sub {
+				best[cur.Id()][s] = sub[s] + math.Log(m.freqs[s])
+			}
+			return true
+		}
+		m.pij(e.Length(), p)
+		for r := 0; r < nstates; r++ {
+			best[cur.Id()][r] = math.Inf(-1)
+			for s := 0; s < nstates; s++ {
+				v := math.Log(p[r][s]) + sub[s]
+				if v > best[cur.Id()][r] {
+					best[cur.Id()][r] = v
+					argbest[cur.Id()][r] = s
+				}
+			}
+		}
+		return true
+	})
+
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		var sel int
+		if prev == nil {
+			max := math.Inf(-1)
+			for s, v := range best[cur.Id()] {
+				if v > max {
+					max = v
+					sel = s
+				}
+			}
+		} else {
+			parentState := 0
+			for s, v := range states[prev.Id()] {
+				if v > 0 {
+					parentState = s
+				}
+			}
+			sel = argbest[cur.Id()][parentState]
+		}
+		for s := range states[cur.Id()] {
+			states[cur.Id()][s] = 0
+		}
+		states[cur.Id()][sel] = 1
+		return true
+	})
+}
+
+// Optimizes the scaling factor of branch lengths using a golden section search
+// of the log likelihood, on a log scale.
+func mlOptimizeScaling(t *tree.Tree, m *mlModel, tipCharacters map[string]string, partials []AncestralState, scales []float64, stateIndices map[string]int) (err error) {
+	var sum float64 = 0.0
+	var nb int = 0
+	var a, b, c, d, fc, fd float64
+	var gr float64 = (math.Sqrt(5) - 1) / 2
+
+	for _, e := range t.Edges() {
+		if e.Length() > 0 {
+			sum += e.Length()
+			nb++
+		}
+	}
+	if nb == 0 {
+		return
+	}
+	avg := sum / float64(nb)
+
+	f := func(logscaling float64) float64 {
+		m.scaling = math.Exp(logscaling)
+		lnl, err2 := mlLikelihood(t, m, tipCharacters, partials, scales, stateIndices)
+		if err2 != nil {
+			err = err2
+		}
+		return lnl
+	}
+
+	a = math.Log(mlMinScaling / avg)
+	b = math.Log(mlMaxScaling / avg)
+	c = b - gr*(b-a)
+	d = a + gr*(b-a)
+	fc, fd = f(c), f(d)
+	for math.Abs(b-a) > mlEpsilon && err == nil {
+		if fc > fd {
+			b, d, fd = d, c, fc
+			c = b - gr*(b-a)
+			fc = f(c)
+		} else {
+			a, c, fc = c, d, fd
+			d = a + gr*(b-a)
+			fd = f(d)
+		}
+	}
+	m.scaling = math.Exp((a + b) / 2)
+	return
+}
+
+// Marginal posterior probabilities approximation (Ishikawa et al. 2019):
+// For each node, keeps the set of k most probable states minimizing
+// the Brier score between the marginal probabilities and the uniform
+// distribution over the k states.
+func mppaSelect(states []AncestralState) {
+	for _, st := range states {
+		sorted := make([]int, len(st))
+		for i := range sorted {
+			sorted[i] = i
+		}
+		sort.SliceStable(sorted, func(i, j int) bool { return st[sorted[i]] > st[sorted[j]] })
+		bestk, bestscore := 1, math.Inf(1)
+		for k := 1; k <= len(st); k++ {
+			score := 0.0
+			for i, idx := range sorted {
+				if i < k {
+					score += (1.0/float64(k) - st[idx]) * (1.0/float64(k) - st[idx])
+				} else {
+					score += st[idx] * st[idx]
+				}
+			}
+			if score < bestscore-mlEpsilon {
+				bestk, bestscore = k, score
+			}
+		}
+		for i, idx := range sorted {
+			if i < bestk {
+				st[idx] = 1
+			} else {
+				st[idx] = 0
+			}
+		}
+	}
+}
+
+// Keeps only the most probable state at each node
+func mapSelect(states []AncestralState) {
+	for _, st := range states {
+		max, sel := -1.0, 0
+		for i, v := range st {
+			if v > max {
+				max, sel = v, i
+			}
+		}
+		for i := range st {
+			st[i] = 0
+		}
+		st[sel] = 1
+	}
+}
+
+// Returns the marginal probabilities of each state for each node (index: node id),
+// formatted as comments of the form "&A=0.9,B=0.1"
+func probaComments(states []AncestralState, alphabet []string) (comments []string) {
+	var buffer bytes.Buffer
+
+	comments = make([]string, len(states))
+	for id, st := range states {
+		buffer.Reset()
+		buffer.WriteRune('&')
+		for i, c := range st {
+			if i > 0 {
+				buffer.WriteRune(',')
+			}
+			buffer.WriteString(alphabet[i])
+			buffer.WriteRune('=')
+			buffer.WriteString(strconv.FormatFloat(c, 'f', 6, 64))
+		}
+		comments[id] = buffer.String()
+	}
+	return
+}
+
+// Rescales the given vector such that its max is 1
+// and returns the log of the scaling factor
+func rescale(v AncestralState) float64 {
+	max := 0.0
+	for _, x := range v {
+		if x > max {
+			max = x
+		}
+	}
+	if max == 0 {
+		return 0
+	}
+	for i := range v {
+		v[i] /= max
+	}
+	return math.Log(max)
+}
+
+func newProbaMatrix(nstates int) (p [][]float64) {
+	p = make([][]float64, nstates)
+	for i := range p {
+		p[i] = make([]float64, nstates)
+	}
+	return
+}
@@ -0,0 +1,371 @@
+package asr
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/goalign/models"
+	"github.com/evolbioinfo/goalign/models/dna"
+	"github.com/evolbioinfo/goalign/models/protein"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Substitution models for maximum likelihood reconstruction
+const (
+	MODEL_JC69 = iota // Nucleotides: Jukes & Cantor 1969
+	MODEL_HKY         // Nucleotides: Hasegawa, Kishino & Yano 1985
+	MODEL_GTR         // Nucleotides: General time reversible
+	MODEL_LG          // Proteins: Le & Gascuel 2008
+	MODEL_WAG         // Proteins: Whelan & Goldman 2001
+)
+
+// Substitution model and its equilibrium frequencies
+type substModel struct {
+	model models.Model
+	pi    []float64
+}
+
+// Will annotate the tree nodes with ancestral sequences
+// computed using marginal maximum likelihood.
+//
+// The given tree branch lengths are used, and must be defined on all branches.
+// model: One of MODEL_JC69, MODEL_HKY, MODEL_GTR (nucleotides), MODEL_LG or MODEL_WAG (proteins)
+// params: Model parameters:
+//   - MODEL_HKY: [kappa] (transition/transversion ratio)
+//   - MODEL_GTR: [AC, AG, AT, CG, CT, GT] (relative substitution rates)
+//   - Other models: ignored
+//
+// Nucleotide equilibrium frequencies are computed from the tip sequences, and
+// amino acid equilibrium frequencies are those of the model.
+// Ambiguous nucleotides (IUPAC codes) at tips allow only the characters they
+// code for, while gaps and unknown characters allow all the characters.
+//
+// Sequences will be located in the comment field of each node
+// at the first index: the most probable character is given for each site
+// of internal nodes, and tips keep their sequence from the alignment.
+//
+// Returns, for each internal node, the posterior probability of the
+// reconstructed character at each site. If a node has a name, key is its name, if a node has no name,
+// the key will be its id in the deep first traversal of the tree.
+// Also returns the log likelihood of the alignment.
+func MLAsr(t *tree.Tree, a align.Alignment, model int, params []float64) (probas map[string][]float64, lnl float64, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var edges []*tree.Edge = t.Edges()
+	var alphabet []uint8 = a.AlphabetCharacters()
+	var m *substModel
+	var pijs []*models.Pij
+	var tipseqs [][]AncestralState // Tip characters: 1 if the character is possible
+	var partials []AncestralState  // Likelihood of the subtree below each node, for the current site
+	var scales []float64           // Log scaling factor of each partial, for the current site
+	var outside []AncestralState   // Probability of everything outside the subtree below each node
+	var parentEdges []*tree.Edge   // Edge connecting each node to its parent
+	var ancseqs [][]uint8          // Reconstructed sequences
+	var nodeprobas [][]float64     // Posterior probability of reconstructed characters
+	var sitelnl float64
+
+	// Initialize indices of characters
+	var charToIndex map[uint8]int = make(map[uint8]int)
+	for i, c := range alphabet {
+		charToIndex[c] = i
+	}
+
+	if m, err = newSubstModel(a, model, params, charToIndex); err != nil {
+		return
+	}
+
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	pijs = make([]*models.Pij, len(edges))
+	for i, e := range edges {
+		e.SetId(i)
+		if e.Length() < 0 {
+			err = fmt.Errorf("maximum likelihood asr requires branch lengths on all branches")
+			return
+		}
+		if pijs[i], err = models.NewPij(m.model, e.Length()); err != nil {
+			return
+		}
+	}
+
+	tipseqs = make([][]AncestralState, len(nodes))
+	partials = make([]AncestralState, len(nodes))
+	outside = make([]AncestralState, len(nodes))
+	scales = make([]float64, len(nodes))
+	parentEdges = make([]*tree.Edge, len(nodes))
+	ancseqs = make([][]uint8, len(nodes))
+	nodeprobas = make([][]float64, len(nodes))
+	for i, n := range nodes {
+		partials[i] = AncestralState{make([]float64, len(alphabet))}
+		outside[i] = AncestralState{make([]float64, len(alphabet))}
+		if n.Tip() {
+			if tipseqs[i], err = mlTipStates(n, a, alphabet, charToIndex); err != nil {
+				return
+			}
+		} else {
+			ancseqs[i] = make([]uint8, a.Length())
+			nodeprobas[i] = make([]float64, a.Length())
+		}
+	}
+
+	for site := 0; site < a.Length(); site++ {
+		mlUPPASS(t.Root(), nil, site, m, pijs, tipseqs, partials, scales)
+		sitelnl = 0.0
+		for k, p := range partials[t.Root().Id()].counts {
+			sitelnl += m.pi[k] * p
+		}
+		lnl += math.Log(sitelnl) + scales[t.Root().Id()]
+
+		t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+			parentEdges[cur.Id()] = e
+			mlDOWNPASS(cur, prev, e, m, pijs, partials, outside, parentEdges)
+			if !cur.Tip() {
+				ancseqs[cur.Id()][site], nodeprobas[cur.Id()][site] = mlMarginalState(partials[cur.Id()], outside[cur.Id()], alphabet)
+			}
+			return true
+		})
+	}
+
+	probas = make(map[string][]float64)
+	for _, n := range nodes {
+		n.ClearComments()
+		if n.Tip() {
+			seq, _ := a.GetSequence(n.Name())
+			n.AddComment(seq)
+		} else {
+			n.AddComment(string(ancseqs[n.Id()]))
+			id := fmt.Sprintf("%d", n.Id())
+			if n.Name() != "" {
+				id = n.Name()
+			}
+			probas[id] = nodeprobas[n.Id()]
+		}
+	}
+	return
+}
+
+func newSubstModel(a align.Alignment, model int, params []float64, charToIndex map[uint8]int) (m *substModel, err error) {
+	var pi []float64
+	var protmodel *protein.ProtModel
+
+	switch model {
+	case MODEL_JC69, MODEL_HKY, MODEL_GTR:
+		if a.Alphabet() != align.NUCLEOTIDS {
+			err = fmt.Errorf("nucleotide substitution model given for a protein alignment")
+			return
+		}
+		pi = mlNtFrequencies(a, charToIndex)
+	case MODEL_LG, MODEL_WAG:
+		if a.Alphabet() != align.AMINOACIDS {
+			err = fmt.Errorf("protein substitution model given for a nucleotide alignment")
+			return
+		}
+	}
+
+	switch model {
+	case MODEL_JC69:
+		m = &substModel{dna.NewJCModel(), []float64{0.25, 0.25, 0.25, 0.25}}
+	case MODEL_HKY:
+		if len(params) != 1 {
+			err = fmt.Errorf("hky model needs 1 parameter (kappa), %d given", len(params))
+			return
+		}
+		hky := dna.NewTN93Model()
+		if err = hky.InitModel(params[0], params[0], pi[0], pi[1], pi[2], pi[3]); err != nil {
+			return
+		}
+		m = &substModel{hky, pi}
+	case MODEL_GTR:
+		if len(params) != 6 {
+			err = fmt.Errorf("gtr model needs 6 parameters (AC, AG, AT, CG, CT, GT rates), %d given", len(params))
+			return
+		}
+		gtr := dna.NewGTRModel()
+		if err = gtr.InitModel(params[0], params[1], params[2], params[3], params[4], params[5], pi[0], pi[1], pi[2], pi[3]); err != nil {
+			return
+		}
+		m = &substModel{gtr, pi}
+	case MODEL_LG, MODEL_WAG:
+		protmodelcode := protein.MODEL_LG
+		if model == MODEL_WAG {
+			protmodelcode = protein.MODEL_WAG
+		}
+		if protmodel, err = protein.NewProtModel(protmodelcode, false, 0.0); err != nil {
+			return
+		}
+		if err = protmodel.InitModel(nil); err != nil {
+			return
+		}
+		pi = make([]float64, protmodel.NState())
+		for i := range pi {
+			pi[i] = protmodel.Pi(i)
+		}
+		m = &substModel{protmodel, pi}
+	default:
+		err = fmt.Errorf("substitution model %d unknown", model)
+	}
+	return
+}
+
+// Nucleotide frequencies computed on unambiguous characters of the alignment
+func mlNtFrequencies(a align.Alignment, charToIndex map[uint8]int) (pi []float64) {
+	var total float64 = 0.0
+
+	pi = make([]float64, 4)
+	a.IterateChar(func(name string, sequence []uint8) bool {
+		for _, c := range sequence {
+			if idx, ok := charToIndex[c]; ok {
+				pi[idx]++
+				total++
+			}
+		}
+		return false
+	})
+	for i := range pi {
+		if total > 0 {
+			pi[i] /= total
+		} else {
+			pi[i] = 0.25
+		}
+	}
+	return
+}
+
+// Initializes the possible characters of the given tip, for all sites
+func mlTipStates(n *tree.Node, a align.Alignment, alphabet []uint8, charToIndex map[uint8]int) (states []AncestralState, err error) {
+	seq, ok := a.GetSequenceChar(n.Name())
+	if !ok {
+		err = fmt.Errorf("sequence %s does not exist in the alignment", n.Name())
+		return
+	}
+	states = make([]AncestralState, len(seq))
+	for j, c := range seq {
+		states[j] = AncestralState{make([]float64, len(alphabet))}
+		possibilities := make([]uint8, 0)
+		if a.Alphabet() == align.NUCLEOTIDS {
+			possibilities = align.IupacCode[c]
+		} else if c != align.ALL_AMINO {
+			possibilities = append(possibilities, c)
+		}
+		nb := 0
+		for _, c2 := range possibilities {
+			if idx, ok := charToIndex[c2]; ok {
+				states[j].counts[idx] = 1
+				nb++
+			}
+		}
+		// Gaps and unknown characters: All characters are possible
+		if nb == 0 {
+			for k := range states[j].counts {
+				states[j].counts[k] = 1
+			}
+		}
+	}
+	return
+}
+
+// Postorder computation of conditional likelihoods of subtrees for the given site.
+// Partials are rescaled at each internal node, the log of the scaling factors
+// being accumulated in scales.
+func mlUPPASS(cur, prev *tree.Node, site int, m *substModel, pijs []*models.Pij, tipseqs [][]AncestralState, partials []AncestralState, scales []float64) {
+	var nstates int = len(m.pi)
+	var curpartial []float64 = partials[cur.Id()].counts
+
+	scales[cur.Id()] = 0.0
+	if cur.Tip() {
+		copy(curpartial, tipseqs[cur.Id()][site].counts)
+		return
+	}
+
+	for k := range curpartial {
+		curpartial[k] = 1.0
+	}
+	for i, child := range cur.Neigh() {
+		if child != prev {
+			mlUPPASS(child, cur, site, m, pijs, tipseqs, partials, scales)
+			pij := pijs[cur.Edges()[i].Id()]
+			for r := 0; r < nstates; r++ {
+				sum := 0.0
+				for s := 0; s < nstates; s++ {
+					sum += pij.Pij(r, s) * partials[child.Id()].counts[s]
+				}
+				curpartial[r] *= sum
+			}
+			scales[cur.Id()] += scales[child.Id()]
+		}
+	}
+	scales[cur.Id()] += mlRescale(curpartial)
+}
+
+// Preorder computation of the probability of everything outside
+// the subtree below the current node, for the site of the last call to mlUPPASS.
+func mlDOWNPASS(cur, prev *tree.Node, e *tree.Edge, m *substModel, pijs []*models.Pij, partials, outside []AncestralState, parentEdges []*tree.Edge) {
+	var nstates int = len(m.pi)
+	var curoutside []float64 = outside[cur.Id()].counts
+
+	if prev == nil {
+		copy(curoutside, m.pi)
+		return
+	}
+
+	// Probability at parent, excluding the current subtree
+	excl := make([]float64, nstates)
+	copy(excl, outside[prev.Id()].counts)
+	for i, sibling := range prev.Neigh() {
+		if sibling != cur && prev.Edges()[i] != parentEdges[prev.Id()] {
+			pij := pijs[prev.Edges()[i].Id()]
+			for r := 0; r < nstates; r++ {
+				sum := 0.0
+				for s := 0; s < nstates; s++ {
+					sum += pij.Pij(r, s) * partials[sibling.Id()].counts[s]
+				}
+				excl[r] *= sum
+			}
+		}
+	}
+	pij := pijs[e.Id()]
+	for s := 0; s < nstates; s++ {
+		sum := 0.0
+		for r := 0; r < nstates; r++ {
+			sum += excl[r] * pij.Pij(r, s)
+		}
+		curoutside[s] = sum
+	}
+	mlRescale(curoutside)
+}
+
+// Returns the most probable character given partial and outside likelihoods,
+// and its posterior probability
+func mlMarginalState(partial, outside AncestralState, alphabet []uint8) (char uint8, proba float64) {
+	var total float64 = 0.0
+	var max float64 = -1.0
+	for k := range partial.counts {
+		p := partial.counts[k] * outside.counts[k]
+		total += p
+		if p > max {
+			max = p
+			char = alphabet[k]
+		}
+	}
+	proba = max / total
+	return
+}
+
+// Rescales the given vector such that its max is 1
+// and returns the log of the scaling factor
+func mlRescale(v []float64) float64 {
+	max := 0.0
+	for _, x := range v {
+		if x > max {
+			max = x
+		}
+	}
+	if max == 0 {
+		return 0
+	}
+	for i := range v {
+		v[i] /= max
+	}
+	return math.Log(max)
+}
@@ -0,0 +1,82 @@
+package asr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/io/newick"
+)
+
+func TestMLAsrJC69(t *testing.T) {
+	// With branch lengths of 0.75*ln(2), JC69 transition probabilities
+	// are 5/8 (same character) and 1/8 (different character).
+	// At the root, per site:
+	//   site 1 (A,A,C): P(A)=25/512, P(C)=5/512, P(G)=P(T)=1/512
+	//   site 2 (T,T,T): P(T)=125/512, P(A)=P(C)=P(G)=1/512
+	//   site 3 (R,A,-): R allows A or G, - allows all characters:
+	//                   P(A)=30/64, P(C)=P(T)=2/64, P(G)=6/64
+	brlen := 0.75 * math.Log(2)
+	tr, err := newick.NewParser(strings.NewReader(fmt.Sprintf("(A:%.15f,B:%.15f,C:%.15f)root;", brlen, brlen, brlen))).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := align.NewAlign(align.NUCLEOTIDS)
+	a.AddSequence("A", "ATR", "")
+	a.AddSequence("B", "ATA", "")
+	a.AddSequence("C", "CT-", "")
+
+	probas, lnl, err := MLAsr(tr, a, MODEL_JC69, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expProbas := []float64{25.0 / 32.0, 125.0 / 128.0, 30.0 / 40.0}
+	if len(probas["root"]) != len(expProbas) {
+		t.Fatalf("expected %d posterior probabilities at the root, got %v", len(expProbas), probas)
+	}
+	for i, p := range probas["root"] {
+		if math.Abs(p-expProbas[i]) > 1e-9 {
+			t.Errorf("site %d: expected posterior %f, got %f", i, expProbas[i], p)
+		}
+	}
+	expLnl := math.Log(1.0/64.0) + math.Log(1.0/16.0) + math.Log(5.0/32.0)
+	if math.Abs(lnl-expLnl) > 1e-9 {
+		t.Errorf("expected log likelihood %f, got %f", expLnl, lnl)
+	}
+
+	for _, n := range tr.Nodes() {
+		exp := map[string]string{"root": "ATA", "A": "ATR", "B": "ATA", "C": "CT-"}[n.Name()]
+		if len(n.Comments()) != 1 || n.Comments()[0] != exp {
+			t.Errorf("expected sequence %s at node %s, got %v", exp, n.Name(), n.Comments())
+		}
+	}
+}
+
+func TestMLAsrErrors(t *testing.T) {
+	a := align.NewAlign(align.NUCLEOTIDS)
+	a.AddSequence("A", "A", "")
+	a.AddSequence("B", "A", "")
+	a.AddSequence("C", "C", "")
+
+	tr, err := newick.NewParser(strings.NewReader("(A:0.1,B,C:0.1);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = MLAsr(tr, a, MODEL_JC69, nil); err == nil {
+		t.Errorf("expected an error for a missing branch length")
+	}
+
+	tr, err = newick.NewParser(strings.NewReader("(A:0.1,B:0.1,C:0.1);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = MLAsr(tr, a, MODEL_LG, nil); err == nil {
+		t.Errorf("expected an error for a protein model on nucleotides")
+	}
+	if _, _, err = MLAsr(tr, a, MODEL_HKY, nil); err == nil {
+		t.Errorf("expected an error for a missing hky parameter")
+	}
+}
@@ -19,13 +19,16 @@ import (
 var acrstates string
 var acrrandomresolve bool // Resolve ambiguities randomly in the downpass/deltran/acctran algo
 var outstepfile string
+var acrmodel string  // Model of character evolution for ML algorithms
+var acroptimize bool // Optimize the branch length scaling factor in ML algorithms
 
 // acrCmd represents the acr command
 var acrCmd = &cobra.Command{
 	Use:   "acr",
-	Short: "Reconstructs most parsimonious ancestral characters",
-	Long: `Reconstructs most parsimonious ancestral characters.
+	Short: "Reconstructs ancestral characters using parsimony or maximum likelihood",
+	Long: `Reconstructs ancestral characters using parsimony or maximum likelihood.
 
+Parsimony algorithms (acctran, deltran, downpass, none):
 Depending on the chosen algorithm, it will run:
 1) UP-PASS and
 2) Either
@@ -39,9 +42,27 @@ Should work on multifurcated trees.
 If --random-resolve is given then, during the last pass, each time 
 a node with several possible states still exists, one state is chosen 
 randomly before going deeper in the tree.
+
+Maximum likelihood algorithms (mppa, map, joint):
+Branch lengths are required. The model of character evolution is given
+by --model:
+   a) jc : All states have the same equilibrium frequency (Mk model)
+   b) f81: Equilibrium frequencies are the frequencies of the tip states
+Unless --optimize-scaling=false is given, branch lengths are rescaled by a
+factor that maximizes the likelihood. Then:
+   a) mppa : Marginal posterior probabilities approximation (as in PastML),
+             that may keep several states per node
+   b) map  : Marginal reconstruction, keeps the most probable state
+   c) joint: Joint reconstruction
+For mppa and map, marginal probabilities of each state are added as a 
+second comment to each node (e.g. [A][&A=0.900000,B=0.100000]).
+The log likelihood is written in the --out-steps file.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var algo int
+		var model int
+		var ml bool
+		var lnl, scaling float64
 		var statemap map[string]string
 		var tipstates map[string]string
 		var resfile *os.File
@@ -60,8 +81,25 @@ randomly before going deeper in the tree.
 			algo = acr.ALGO_DOWNPASS
 		case "none":
 			algo = acr.ALGO_NONE
+		case "mppa":
+			algo, ml = acr.ALGO_ML_MPPA, true
+		case "map":
+			algo, ml = acr.ALGO_ML_MAP, true
+		case "joint":
+			algo, ml = acr.ALGO_ML_JOINT, true
+		default:
+			err = fmt.Errorf("unknown acr algorithm: %s", parsimonyAlgo)
+			io.LogError(err)
+			return
+		}
+		switch strings.ToLower(acrmodel) {
+		case "jc":
+			model = acr.MODEL_JC
+		case "f81":
+			model = acr.MODEL_F81
 		default:
-			io.LogError(fmt.Errorf("Unknown parsimony algorithm: %s", parsimonyAlgo))
+			err = fmt.Errorf("unknown acr model: %s", acrmodel)
+			io.LogError(err)
 			return
 		}
 		// Reading tip state in an input file
@@ -97,13 +135,21 @@ randomly before going deeper in the tree.
 			defer closeWriteFile(resfile, outresfile)
 		}
 		for t := range treechan {
-			statemap, nsteps, err = acr.ParsimonyAcr(t.Tree, tipstates, algo, acrrandomresolve, globalRand)
+			if ml {
+				statemap, lnl, scaling, err = acr.MLAcr(t.Tree, tipstates, model, algo, acroptimize)
+			} else {
+				statemap, nsteps, err = acr.ParsimonyAcr(t.Tree, tipstates, algo, acrrandomresolve, globalRand)
+			}
 			if err != nil {
 				io.LogError(err)
 				return
 			}
 			f.WriteString(t.Tree.Newick() + "\n")
-			fmt.Fprintf(outstepsf, "steps %d\n", nsteps)
+			if ml {
+				fmt.Fprintf(outstepsf, "lnl %f\tscaling %f\n", lnl, scaling)
+			} else {
+				fmt.Fprintf(outstepsf, "steps %d\n", nsteps)
+			}
 			if outresfile != "none" {
 				for k, v := range statemap {
 					resfile.WriteString(fmt.Sprintf("%s,%s\n", k, v))
@@ -120,8 +166,10 @@ func init() {
 	acrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	acrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
 	acrCmd.PersistentFlags().StringVar(&outresfile, "out-states", "none", "Output mapping file between node names and states")
-	acrCmd.PersistentFlags().StringVar(&outstepfile, "out-steps", "stdout", "Output file with number of parsimony steps")
-	acrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass")
+	acrCmd.PersistentFlags().StringVar(&outstepfile, "out-steps", "stdout", "Output file with number of parsimony steps (or log likelihood for ML algorithms)")
+	acrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "ACR algorithm: acctran, deltran, or downpass (parsimony), or mppa, map, or joint (maximum likelihood)")
+	acrCmd.PersistentFlags().StringVar(&acrmodel, "model", "f81", "Model of character evolution for maximum likelihood algorithms: jc or f81")
+	acrCmd.PersistentFlags().BoolVar(&acroptimize, "optimize-scaling", true, "Optimize branch length scaling factor for maximum likelihood algorithms")
 	acrCmd.PersistentFlags().BoolVar(&acrrandomresolve, "random-resolve", false, "Random resolve states when several possibilities in: acctran, deltran, or downpass")
 }
 
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	goio "io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/evolbioinfo/goalign/align"
@@ -22,14 +24,19 @@ var asrphylip bool
 var asrinputstrict bool
 var asrrandomresolve bool // Resolve ambiguities randomly in the downpass/deltran/acctran algo
 var outlogfile string
+var asrmodel string     // Substitution model for ML reconstruction
+var asrkappa float64    // HKY kappa parameter
+var asrgtrrates string  // GTR relative rates
+var asroutprobas string // Output file with posterior probabilities of ML reconstruction
 
 // asrCmd represents the asr command
 var asrCmd = &cobra.Command{
 	Use:   "asr",
-	Short: "Reconstructs most parsimonious ancestral sequences",
-	Long: `Reconstructs most parsimonious ancestral sequences.
+	Short: "Reconstructs ancestral sequences using parsimony or maximum likelihood",
+	Long: `Reconstructs ancestral sequences using parsimony or maximum likelihood.
 
-Depending on the chosen algorithm, it will run:
+For parsimony algorithms (acctran, deltran, downpass, none),
+depending on the chosen algorithm, it will run:
 1) UP-PASS and
 2) Either
    a) DOWN-PASS or
@@ -42,6 +49,22 @@ Should work on multifurcated trees
 If --random-resolve is given then, during the last pass, each time 
 a node with several possible states still exists, one state is chosen 
 randomly before going deeper in the tree.
+
+If --algo ml is given, then it will run a marginal maximum likelihood
+reconstruction, using the branch lengths of the input tree, and the 
+substitution model given by --model:
+- jc69: Nucleotides, Jukes & Cantor
+- hky : Nucleotides, with transition/transversion ratio given by --kappa
+- gtr : Nucleotides, with relative rates given by --gtr-rates (AC,AG,AT,CG,CT,GT)
+- lg  : Proteins, Le & Gascuel
+- wag : Proteins, Whelan & Goldman
+Nucleotide frequencies are computed from the alignment, amino acid frequencies
+are those of the model. The most probable character is reconstructed at each site 
+of internal nodes. Posterior probabilities of the reconstructed characters may be
+written in the --out-probas file, with one line per internal node: 
+node name (or id)\tcomma separated probabilities of each site.
+This file can be given to "gotree compute mutations --probas".
+The log likelihood is written in the --log file.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var align align.Alignment
@@ -53,6 +76,12 @@ randomly before going deeper in the tree.
 		var f *os.File
 		var logf *os.File
 		var nsteps []int
+		var ml bool
+		var model int
+		var params []float64
+		var probas map[string][]float64
+		var lnl float64
+		var probaf *os.File
 
 		switch strings.ToLower(parsimonyAlgo) {
 		case "acctran":
@@ -63,12 +92,38 @@ randomly before going deeper in the tree.
 			algo = asr.ALGO_DOWNPASS
 		case "none":
 			algo = asr.ALGO_NONE
+		case "ml":
+			ml = true
 		default:
-			err = fmt.Errorf("unknown parsimony algorithm: %s", parsimonyAlgo)
+			err = fmt.Errorf("unknown asr algorithm: %s", parsimonyAlgo)
 			io.LogError(err)
 			return
 		}
 
+		if ml {
+			switch strings.ToLower(asrmodel) {
+			case "jc69", "jc":
+				model = asr.MODEL_JC69
+			case "hky":
+				model = asr.MODEL_HKY
+				params = []float64{asrkappa}
+			case "gtr":
+				model = asr.MODEL_GTR
+				if params, err = parseFloatList(asrgtrrates); err != nil {
+					io.LogError(err)
+					return
+				}
+			case "lg":
+				model = asr.MODEL_LG
+			case "wag":
+				model = asr.MODEL_WAG
+			default:
+				err = fmt.Errorf("unknown substitution model: %s", asrmodel)
+				io.LogError(err)
+				return
+			}
+		}
+
 		// Reading the alignment
 		fi, r, err = utils.GetReader(asralign)
 		if err != nil {
@@ -108,8 +163,27 @@ randomly before going deeper in the tree.
 			return
 		}
 		defer closeWriteFile(logf, outlogfile)
+		if ml && asroutprobas != "none" {
+			if probaf, err = openWriteFile(asroutprobas); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(probaf, asroutprobas)
+		}
 
 		for t := range treechan {
+			if ml {
+				if probas, lnl, err = asr.MLAsr(t.Tree, align, model, params); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(logf, "lnl %f\n", lnl)
+				if probaf != nil {
+					writeProbas(probaf, probas)
+				}
+				f.WriteString(t.Tree.Newick() + "\n")
+				continue
+			}
 			nsteps, err = asr.ParsimonyAsr(t.Tree, align, algo, asrrandomresolve, globalRand)
 			if err != nil {
 				io.LogError(err)
@@ -134,6 +208,32 @@ func init() {
 	asrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	asrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
 	asrCmd.PersistentFlags().StringVar(&outlogfile, "log", "stdout", "Output log file")
-	asrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass")
+	asrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "ASR algorithm: acctran, deltran, or downpass (parsimony), or ml (marginal maximum likelihood)")
 	asrCmd.PersistentFlags().BoolVar(&asrrandomresolve, "random-resolve", false, "Random resolve states when several possibilities in: acctran, deltran, or downpass")
+	asrCmd.PersistentFlags().StringVar(&asrmodel, "model", "jc69", "Substitution model for --algo ml: jc69, hky, gtr (nucleotides), lg, or wag (proteins)")
+	asrCmd.PersistentFlags().Float64Var(&asrkappa, "kappa", 1.0, "Transition/transversion ratio for --model hky")
+	asrCmd.PersistentFlags().StringVar(&asrgtrrates, "gtr-rates", "1,1,1,1,1,1", "Comma separated relative rates AC,AG,AT,CG,CT,GT for --model gtr")
+	asrCmd.PersistentFlags().StringVar(&asroutprobas, "out-probas", "none", "Output file with posterior probabilities of reconstructed characters (--algo ml)")
+}
+
+// Writes posterior probabilities of ML ancestral sequences:
+// One line per node: nodename\tcomma separated probabilities
+func writeProbas(f *os.File, probas map[string][]float64) {
+	names := make([]string, 0, len(probas))
+	for name := range probas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f.WriteString(name)
+		for i, p := range probas[name] {
+			if i == 0 {
+				f.WriteString("\t")
+			} else {
+				f.WriteString(",")
+			}
+			f.WriteString(strconv.FormatFloat(p, 'f', 6, 64))
+		}
+		f.WriteString("\n")
+	}
 }
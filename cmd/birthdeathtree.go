@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var bdBirthRate float64
+var bdDeathRate float64
+var generateSamplingProba float64
+var generatePresentDate float64
+
+func birthDeathTree(nbtrees int, nbtips int, output string) error {
+	var f *os.File
+	var err error
+	var t *tree.Tree
+
+	if output != "stdout" && output != "-" {
+		f, err = os.Create(output)
+		defer f.Close()
+	} else {
+		f = os.Stdout
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < nbtrees; i++ {
+		t, err = tree.RandomBirthDeathTree(nbtips, bdBirthRate, bdDeathRate, generateSamplingProba, generatePresentDate, globalRand)
+		if err != nil {
+			return err
+		}
+		f.WriteString(t.Newick() + "\n")
+	}
+
+	return nil
+}
+
+// birthdeathtreeCmd represents the birthdeathtree command
+var birthdeathtreeCmd = &cobra.Command{
+	Use:   "birthdeathtree",
+	Short: "Generates a random constant rate birth-death tree",
+	Long: `Generates a random constant rate birth-death tree.
+
+The reconstructed tree of sampled lineages is simulated, conditioned on the
+number of sampled tips (-l). Each extant lineage is sampled with probability
+--sampling. Generated trees are always rooted, branch lengths are in time
+units, and all tips are annotated with the --present date, in the
+[&date=...] comment format.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := birthDeathTree(generateNbTrees, generateNbTips, generateOutputfile); err != nil {
+			io.LogError(err)
+			return
+		}
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(birthdeathtreeCmd)
+	birthdeathtreeCmd.PersistentFlags().IntVarP(&generateNbTips, "nbtips", "l", 10, "Number of sampled tips/leaves of the tree to generate")
+	birthdeathtreeCmd.PersistentFlags().Float64Var(&bdBirthRate, "birth", 1.0, "Birth rate")
+	birthdeathtreeCmd.PersistentFlags().Float64Var(&bdDeathRate, "death", 0.0, "Death rate")
+	birthdeathtreeCmd.PersistentFlags().Float64Var(&generateSamplingProba, "sampling", 1.0, "Sampling probability of extant lineages")
+	birthdeathtreeCmd.PersistentFlags().Float64Var(&generatePresentDate, "present", 0.0, "Date of the present, given to sampled tips")
+}
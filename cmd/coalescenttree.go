@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var generatePopSize float64
+var generateGrowthRate float64
+
+func coalescentTree(nbtrees int, nbtips int, output string) error {
+	var f *os.File
+	var err error
+	var t *tree.Tree
+
+	if output != "stdout" && output != "-" {
+		f, err = os.Create(output)
+		defer f.Close()
+	} else {
+		f = os.Stdout
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < nbtrees; i++ {
+		t, err = tree.RandomCoalescentTree(nbtips, generatePopSize, generateGrowthRate, generatePresentDate, globalRand)
+		if err != nil {
+			return err
+		}
+		f.WriteString(t.Newick() + "\n")
+	}
+
+	return nil
+}
+
+// coalescenttreeCmd represents the coalescenttree command
+var coalescenttreeCmd = &cobra.Command{
+	Use:   "coalescenttree",
+	Short: "Generates a random Kingman coalescent tree",
+	Long: `Generates a random Kingman coalescent tree.
+
+The population size (--popsize, in time units) is either constant, or
+grows exponentially with rate --growth (population size at age t:
+popsize*exp(-growth*t)). Generated trees are always rooted, branch lengths
+are in time units, and all tips are annotated with the --present date, in
+the [&date=...] comment format.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := coalescentTree(generateNbTrees, generateNbTips, generateOutputfile); err != nil {
+			io.LogError(err)
+			return
+		}
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(coalescenttreeCmd)
+	coalescenttreeCmd.PersistentFlags().IntVarP(&generateNbTips, "nbtips", "l", 10, "Number of tips/leaves of the tree to generate")
+	coalescenttreeCmd.PersistentFlags().Float64Var(&generatePopSize, "popsize", 1.0, "Effective population size at present")
+	coalescenttreeCmd.PersistentFlags().Float64Var(&generateGrowthRate, "growth", 0.0, "Exponential growth rate of the population (0: constant size)")
+	coalescenttreeCmd.PersistentFlags().Float64Var(&generatePresentDate, "present", 0.0, "Date of the present, given to sampled tips")
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// compareQuartetsCmd represents the compare quartets command
+var compareQuartetsCmd = &cobra.Command{
+	Use:   "quartets",
+	Short: "Compare quartets of a reference tree with a set of trees",
+	Long: `Compare quartets of a reference tree with a set of trees.
+
+Trees must have the same set of tips.
+
+For each tree in the compared tree file, it will print tab separated values with:
+1) The index of the compared tree in the file
+2) The total number of quartets (n choose 4)
+3) The number of quartets resolved identically in both trees
+4) The number of quartets resolved differently in both trees
+5) The number of quartets resolved in the compared tree but not in the reference tree
+6) The number of quartets resolved in the reference tree but not in the compared tree
+7) The normalized quartet distance: (4)+(5)+(6) divided by (2). Quartets unresolved
+   in both trees are considered identical.
+
+Quartets are counted without being enumerated, in O(n^2) for binary trees, so that
+trees with thousands of tips can be compared. Computations of a comparison are
+split over -t threads.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var refTree *tree.Tree
+		var stats <-chan tree.QuartetStats
+
+		if intree2file == "none" {
+			err = errors.New("You must provide a file containing compared trees")
+			io.LogError(err)
+			return
+		}
+
+		maxcpus := runtime.NumCPU()
+		if rootCpus > maxcpus {
+			rootCpus = maxcpus
+		}
+		if refTree, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intree2file); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if stats, err = tree.CompareQuartets(refTree, treechan, rootCpus); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		fmt.Printf("tree\tquartets\tshared\tdiff\tunresolved_ref\tunresolved_comp\tqdist\n")
+		for st := range stats {
+			if st.Err != nil {
+				/* We empty the channel if needed*/
+				for range stats {
+				}
+				io.LogError(st.Err)
+				return st.Err
+			}
+			fmt.Printf("%d\t%d\t%d\t%d\t%d\t%d\t%f\n", st.Id, st.Total, st.Shared, st.Diff, st.UnresolvedRef, st.UnresolvedComp, st.Distance())
+		}
+		return
+	},
+}
+
+func init() {
+	compareCmd.AddCommand(compareQuartetsCmd)
+}
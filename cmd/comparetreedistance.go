@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var comparetreedistancemetric string
+var comparetreedistancematrix bool
+
+// compareTreeDistanceCmd represents the compare treedistance command
+var compareTreeDistanceCmd = &cobra.Command{
+	Use:   "treedistance",
+	Short: "Compute distances between a reference tree and a set of trees",
+	Long: `Compute distances between a reference tree and a set of trees.
+
+Trees must have the same set of tips.
+
+Available distances (--metric):
+- rf : Robinson-Foulds distance (number of non trivial splits present in only one tree)
+- ms : Matching split distance (Bogdanowicz & Giaro, 2012)
+- kf : Kuhner-Felsenstein branch score distance (Kuhner & Felsenstein, 1994),
+       including external branches
+- pd : Path difference distance, based on topological distances between tips
+       (Steel & Penny, 1993)
+- spr: Heuristic approximation of the SPR distance, after de Oliveira Martins
+       et al. (2008). It is an estimate, neither an upper nor a lower bound
+       of the number of SPR moves.
+
+For each tree in the compared tree file, it will print tab separated values with:
+1) The index of the compared tree in the file
+2) The distance to the reference tree
+
+If --matrix is given, all the trees of the input file (-i) are compared to each other,
+and the all-vs-all distance matrix is printed, in the same format as "gotree matrix",
+trees being named by their index in the file. -c is ignored.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var refTree *tree.Tree
+		var distance int
+		var d float64
+
+		maxcpus := runtime.NumCPU()
+		if rootCpus > maxcpus {
+			rootCpus = maxcpus
+		}
+
+		if distance, err = parseTreeDistance(comparetreedistancemetric); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if comparetreedistancematrix {
+			var mat [][]float64
+			trees := make([]*tree.Tree, 0)
+			if treefile, treechan, err = readTrees(intreefile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer treefile.Close()
+			for t := range treechan {
+				if t.Err != nil {
+					io.LogError(t.Err)
+					return t.Err
+				}
+				trees = append(trees, t.Tree)
+			}
+			if mat, err = tree.TreeDistanceMatrix(trees, distance, rootCpus); err != nil {
+				io.LogError(err)
+				return
+			}
+			fmt.Printf("%d\n", len(trees))
+			for i := range trees {
+				fmt.Printf("%d", i)
+				for j := range trees {
+					fmt.Printf("\t%.12f", mat[i][j])
+				}
+				fmt.Printf("\n")
+			}
+			return
+		}
+
+		if intree2file == "none" {
+			err = errors.New("You must provide a file containing compared trees")
+			io.LogError(err)
+			return
+		}
+		if refTree, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		if treefile, treechan, err = readTrees(intree2file); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		fmt.Printf("tree\t%s\n", comparetreedistancemetric)
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if d, err = tree.TreeDistance(refTree, t.Tree, distance); err != nil {
+				io.LogError(err)
+				return
+			}
+			fmt.Printf("%d\t%f\n", t.Id, d)
+		}
+		return
+	},
+}
+
+func parseTreeDistance(metric string) (distance int, err error) {
+	switch metric {
+	case "rf":
+		distance = tree.TREE_DISTANCE_RF
+	case "ms":
+		distance = tree.TREE_DISTANCE_MS
+	case "kf":
+		distance = tree.TREE_DISTANCE_KF
+	case "pd":
+		distance = tree.TREE_DISTANCE_PD
+	case "spr":
+		distance = tree.TREE_DISTANCE_SPR
+	default:
+		err = fmt.Errorf("tree distance %s is not supported", metric)
+	}
+	return
+}
+
+func init() {
+	compareCmd.AddCommand(compareTreeDistanceCmd)
+	compareTreeDistanceCmd.Flags().StringVarP(&comparetreedistancemetric, "metric", "m", "ms", "Tree distance (rf|ms|kf|pd|spr)")
+	compareTreeDistanceCmd.Flags().BoolVar(&comparetreedistancematrix, "matrix", false, "Compute the all-vs-all distance matrix of the input trees (-i)")
+}
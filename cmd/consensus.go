@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	goio "io"
 	"os"
 
@@ -9,6 +10,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var consensusMethod string
+var consensusBrlen string
+var consensusCutoff float64
+
 // consensusCmd represents the consensus command
 var consensusCmd = &cobra.Command{
 	Use:   "consensus",
@@ -16,16 +21,25 @@ var consensusCmd = &cobra.Command{
 	Long: `Computes the consensus of a set of input trees
 Trees must have the same tip names.
 
-Two parameters:
+Parameters:
 -i : Input file containing several trees
--f : Percentage threshold to keep a bipartition in the consensus 
-     It must be >=0.5 && <=1
+-m : Consensus method:
+     - majority: Majority rule consensus (default), keeping bipartitions
+       present in more than -f of the trees (-f must be >=0.5 && <=1);
+     - strict: Strict consensus, keeping bipartitions present in all
+       the trees (-f is ignored);
+     - greedy: Greedy (extended majority rule) consensus, adding
+       bipartitions by decreasing frequency as long as they are compatible
+       with the already added ones. Only bipartitions present in at least
+       -f of the trees are considered (default 0: all bipartitions).
+-f : Percentage threshold to keep a bipartition in the consensus
+--brlen : mean|median
 
 In the output consensus tree:
 1) Branch supports are computed as the proportion of trees in which
    the bipartition is present
-2) Branch lengths are computed as the average length of the same branch
-   over all the trees where it is present
+2) Branch lengths are computed as the mean (default) or median length
+   of the same branch over all the trees where it is present
 
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
@@ -33,6 +47,34 @@ In the output consensus tree:
 		var treefile goio.Closer
 		var treechan <-chan tree.Trees
 		var consensus *tree.Tree
+		var method, brlen int
+
+		switch consensusMethod {
+		case "majority":
+			method = tree.CONSENSUS_MAJORITY
+		case "strict":
+			method = tree.CONSENSUS_STRICT
+		case "greedy":
+			method = tree.CONSENSUS_GREEDY
+			if !cmd.Flags().Changed("freq-min") {
+				consensusCutoff = 0.0
+			}
+		default:
+			err = fmt.Errorf("unknown consensus method: %s", consensusMethod)
+			io.LogError(err)
+			return
+		}
+
+		switch consensusBrlen {
+		case "mean":
+			brlen = tree.CONSENSUS_LENGTH_MEAN
+		case "median":
+			brlen = tree.CONSENSUS_LENGTH_MEDIAN
+		default:
+			err = fmt.Errorf("unknown branch length method: %s", consensusBrlen)
+			io.LogError(err)
+			return
+		}
 
 		if f, err = openWriteFile(outtreefile); err != nil {
 			io.LogError(err)
@@ -45,7 +87,7 @@ In the output consensus tree:
 			return
 		}
 		defer treefile.Close()
-		consensus, err = tree.Consensus(treechan, cutoff)
+		consensus, err = tree.ConsensusMethod(treechan, method, consensusCutoff, brlen)
 		if err != nil {
 			io.LogError(err)
 			return
@@ -59,5 +101,7 @@ func init() {
 	computeCmd.AddCommand(consensusCmd)
 	consensusCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	consensusCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
-	consensusCmd.PersistentFlags().Float64VarP(&cutoff, "freq-min", "f", 0.5, "Minimum frequency to keep the bipartitions")
+	consensusCmd.PersistentFlags().Float64VarP(&consensusCutoff, "freq-min", "f", 0.5, "Minimum frequency to keep the bipartitions")
+	consensusCmd.PersistentFlags().StringVarP(&consensusMethod, "method", "m", "majority", "Consensus method: majority|strict|greedy")
+	consensusCmd.PersistentFlags().StringVar(&consensusBrlen, "brlen", "mean", "Consensus branch lengths: mean|median")
 }
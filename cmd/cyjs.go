@@ -16,13 +16,31 @@ import (
 // pngCmd represents the png command
 var cyjsCmd = &cobra.Command{
 	Use:   "cyjs",
-	Short: "Draw trees in html file using cytoscape js",
-	Long:  `Draw trees in html file using cytoscape js.`,
+	Short: "Draw trees in self-contained html files",
+	Long: `Draw trees in self-contained html files, using cytoscape js.
+
+The cytoscape.js, dagre and cytoscape-dagre libraries are embedded in gotree
+and inlined in the html output, so the files can be opened without network
+access. Tip metadata colors (--metadata-file), branch supports
+(--with-branch-support) and node comments (--with-node-comments) are
+displayed as in svg and png outputs.
+`,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var l draw.TreeLayout
 		var treefile goio.Closer
 		var treechan <-chan tree.Trees
 		var f *os.File
+		var metaFields []string
+		var metaShapes []draw.Shape
+		var metaValues map[string][]draw.TipMetaColor
+		var metaLegend []draw.LegendEntry
+		var branchColors map[*tree.Node]draw.TipMetaColor
+		var branchLegend []draw.LegendEntry
+
+		if metaFields, metaShapes, metaValues, metaLegend, err = loadTipMetadata(); err != nil {
+			io.LogError(err)
+			return err
+		}
 
 		ntree := 0
 
@@ -45,14 +63,29 @@ var cyjsCmd = &cobra.Command{
 				}
 				fname = fmt.Sprintf(fname+"_%03d.html", ntree)
 			}
+			if branchColors, branchLegend, _, err = loadBranchColoring(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
 			if f, err = openWriteFile(fname); err != nil {
 				io.LogError(err)
 				return
 			}
 			w := bufio.NewWriter(f)
 			l = draw.NewCytoscapeLayout(w, drawSupport)
+			l.SetDisplayInternalNodes(drawInternalNodeSymbols)
+			l.SetDisplayNodeComments(drawNodeComment)
 			l.SetSupportCutoff(drawSupportCutoff)
-			l.DrawTree(t.Tree)
+			if len(metaFields) > 0 {
+				l.SetTipMetadata(metaFields, metaShapes, metaValues, metaLegend)
+			}
+			if branchColors != nil {
+				l.SetBranchColors(branchColors, branchLegend)
+			}
+			if err = l.DrawTree(t.Tree); err != nil {
+				io.LogError(err)
+				return
+			}
 			w.Flush()
 			closeWriteFile(f, fname)
 			ntree++
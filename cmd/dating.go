@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var datingDateFile string
+var datingRootSearch bool
+var datingSeqLen float64
+var datingLogFile string
+
+// datingCmd represents the dating command
+var datingCmd = &cobra.Command{
+	Use:   "dating",
+	Short: "Dates the input trees using tip dates and a strict clock",
+	Long: `Dates the input trees using tip dates and a strict clock.
+
+The substitution rate and the dates of all nodes are estimated by least
+squares, as LSD does (without temporal constraints): Branch lengths of
+the input trees (substitutions per site) are fitted with a strict clock
+given the dates of the tips.
+
+Tip dates are given either:
+- In the tree, with comments in the form [&date=2020.5] or [&date=2020-06-30];
+- With a tab separated date file (-d), with one "tip name<tab>date" per line.
+Tips without date are dated as internal nodes.
+
+If --root-search is given, the root position minimizing the least-squares
+objective is searched on all branches, and the tree is rerooted there.
+Otherwise, input trees are considered rooted.
+
+If --seqlen is given, branches are weighted by the inverse of the variance
+of their length, given the sequence length (as in LSD). Otherwise, all
+branches have the same weight.
+
+The output trees are time trees: branch lengths are in units of time, and all
+nodes have their date in the [&date=...] comment format, so that they can be
+given to gotree ltt or gotree cut date for example. Dates of internal nodes
+are made not more recent than the dates of their children.
+
+If --log is given, the estimated rate, root date and least-squares value
+of each tree are written in the log file.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, logf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var dates map[string]float64
+		var res tree.DatingResult
+
+		if datingDateFile != "none" {
+			if dates, err = readDateFile(datingDateFile); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if datingLogFile != "none" {
+			if logf, err = openWriteFile(datingLogFile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(logf, datingLogFile)
+			fmt.Fprintf(logf, "tree\trate\troot_date\tobjective\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if res, err = t.Tree.LeastSquaresDating(dates, datingRootSearch, datingSeqLen); err != nil {
+				io.LogError(err)
+				return
+			}
+			if logf != nil {
+				fmt.Fprintf(logf, "%d\t%f\t%f\t%f\n", t.Id, res.Rate, res.RootDate, res.Objective)
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+		}
+		return
+	},
+}
+
+// Reads a tab separated file with tip names and dates
+func readDateFile(file string) (dates map[string]float64, err error) {
+	var datemap map[string]string
+	var d float64
+
+	if datemap, err = readMapFile(file, false); err != nil {
+		return
+	}
+	dates = make(map[string]float64, len(datemap))
+	for name, date := range datemap {
+		if d, err = tree.ParseDate(date); err != nil {
+			return
+		}
+		dates[name] = d
+	}
+	return
+}
+
+func init() {
+	computeCmd.AddCommand(datingCmd)
+	datingCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree(s)")
+	datingCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output time tree(s)")
+	datingCmd.PersistentFlags().StringVarP(&datingDateFile, "date-file", "d", "none", "Tab separated file with tip names and dates")
+	datingCmd.PersistentFlags().BoolVar(&datingRootSearch, "root-search", false, "Searches the best root position")
+	datingCmd.PersistentFlags().Float64Var(&datingSeqLen, "seqlen", 0, "Sequence length, to weight branches by the inverse of their variance (0: all weights are 1)")
+	datingCmd.PersistentFlags().StringVar(&datingLogFile, "log", "none", "Output log file with estimated rates and root dates")
+}
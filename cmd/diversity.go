@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	goio "io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var diversityCommunityFile string
+var diversityWeighted bool
+var diversityUnifracFile string
+var diversityWUnifracFile string
+
+// diversityCmd represents the diversity command
+var diversityCmd = &cobra.Command{
+	Use:   "diversity",
+	Short: "Computes phylogenetic diversity metrics of samples",
+	Long: `Computes phylogenetic diversity metrics of the samples of a community table.
+
+The community table (--community) is a tab separated file with one line per
+sample and one column per tip. The first line gives the tip names (its first
+field is ignored), and the next lines give the sample name followed by the
+abundance of each tip in the sample (0/1 for presence/absence data):
+
+sample	A	B	C	D
+s1	1	0	3	1
+s2	0	2	1	0
+
+All the tips of the table must be present in the tree, and the tips of the
+tree that are not in the table are considered absent from all samples. All
+the branches of the tree must have a length.
+
+For each tree and sample, the output gives:
+- ntips: the number of tips present in the sample;
+- pd   : Faith's phylogenetic diversity, i.e. the sum of the lengths of the
+         branches connecting the tips of the sample to the root;
+- mpd  : the mean pairwise distance between the tips of the sample;
+- mntd : the mean distance between each tip of the sample and its nearest
+         other tip of the sample.
+If --abundance-weighted is given, mpd and mntd are weighted by the
+abundances of the tips. mpd and mntd are NaN for samples with less than 2 tips.
+
+If --unifrac (resp. --weighted-unifrac) is given, the matrix of the
+unweighted (resp. weighted, not normalized) UniFrac distances between all
+pairs of samples is written in the given file, in the same format as
+gotree matrix.
+
+Example:
+
+gotree compute diversity -i tree.nw -c community.tsv --unifrac unifrac.txt > diversity.tsv
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, uf, wuf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var names []string
+		var samples []map[string]float64
+		var div *tree.Diversity
+		var pd, mpd, mntd float64
+		var mat [][]float64
+
+		if diversityCommunityFile == "none" {
+			err = fmt.Errorf("a community table must be given with --community")
+			io.LogError(err)
+			return
+		}
+		if names, samples, err = parseCommunityFile(diversityCommunityFile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+		f.WriteString("tree\tsample\tntips\tpd\tmpd\tmntd\n")
+
+		if diversityUnifracFile != "none" {
+			if uf, err = openWriteFile(diversityUnifracFile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(uf, diversityUnifracFile)
+		}
+
+		if diversityWUnifracFile != "none" {
+			if wuf, err = openWriteFile(diversityWUnifracFile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(wuf, diversityWUnifracFile)
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if div, err = tree.NewDiversity(t.Tree); err != nil {
+				io.LogError(err)
+				return
+			}
+			for i, s := range samples {
+				ntips := 0
+				for _, a := range s {
+					if a > 0 {
+						ntips++
+					}
+				}
+				if pd, err = div.PD(s); err != nil {
+					io.LogError(err)
+					return
+				}
+				if mpd, err = div.MPD(s, diversityWeighted); err != nil {
+					io.LogError(err)
+					return
+				}
+				if mntd, err = div.MNTD(s, diversityWeighted); err != nil {
+					io.LogError(err)
+					return
+				}
+				f.WriteString(fmt.Sprintf("%d\t%s\t%d\t%g\t%g\t%g\n", t.Id, names[i], ntips, pd, mpd, mntd))
+			}
+			for _, u := range []struct {
+				f        *os.File
+				weighted bool
+			}{{uf, false}, {wuf, true}} {
+				if u.f == nil {
+					continue
+				}
+				if mat, err = div.UniFracMatrix(samples, u.weighted); err != nil {
+					io.LogError(err)
+					return
+				}
+				writeSampleMatrix(u.f, names, mat)
+			}
+		}
+		return
+	},
+}
+
+// Parses a community table: one line per sample, one column per tip,
+// with a header line giving the tip names
+func parseCommunityFile(file string) (names []string, samples []map[string]float64, err error) {
+	var f goio.Closer
+	var r *bufio.Reader
+	var line string
+	var e error
+	var header, cols []string
+	var a float64
+	var nl int = 1
+
+	if f, r, err = utils.GetReader(file); err != nil {
+		return
+	}
+	defer f.Close()
+
+	if line, e = Readln(r); e != nil {
+		err = fmt.Errorf("community table %s is empty", file)
+		return
+	}
+	header = strings.Split(line, "\t")
+	for line, e = Readln(r); e == nil; line, e = Readln(r) {
+		nl++
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if cols = strings.Split(line, "\t"); len(cols) != len(header) {
+			err = fmt.Errorf("community table does not have %d fields at line %d", len(header), nl)
+			return
+		}
+		sample := make(map[string]float64)
+		for i := 1; i < len(cols); i++ {
+			if a, err = strconv.ParseFloat(cols[i], 64); err != nil {
+				err = fmt.Errorf("wrong abundance %q at line %d of the community table", cols[i], nl)
+				return
+			}
+			if a != 0 {
+				sample[header[i]] = a
+			}
+		}
+		names = append(names, cols[0])
+		samples = append(samples, sample)
+	}
+	return
+}
+
+// Writes a sample distance matrix in the same format as gotree matrix
+func writeSampleMatrix(f *os.File, names []string, mat [][]float64) {
+	f.WriteString(fmt.Sprintf("%d\n", len(names)))
+	for i, n := range names {
+		f.WriteString(n)
+		for j := range names {
+			f.WriteString("\t" + fmt.Sprintf("%.12f", mat[i][j]))
+		}
+		f.WriteString("\n")
+	}
+}
+
+func init() {
+	computeCmd.AddCommand(diversityCmd)
+	diversityCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree(s)")
+	diversityCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file with the diversity metrics of each sample")
+	diversityCmd.PersistentFlags().StringVarP(&diversityCommunityFile, "community", "c", "none", "Tab separated community table (samples x tips abundances)")
+	diversityCmd.PersistentFlags().BoolVar(&diversityWeighted, "abundance-weighted", false, "Weights mpd and mntd by the abundances of the tips")
+	diversityCmd.PersistentFlags().StringVar(&diversityUnifracFile, "unifrac", "none", "Output file with the unweighted UniFrac distances between samples")
+	diversityCmd.PersistentFlags().StringVar(&diversityWUnifracFile, "weighted-unifrac", "none", "Output file with the weighted UniFrac distances between samples")
+}
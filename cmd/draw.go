@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/evolbioinfo/gotree/draw"
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -20,6 +23,16 @@ var drawInternalNodeSymbols bool
 var drawNodeComment bool
 var metadataFile string
 var metadataColorsFile string
+var drawBranchColors string
+var drawHighlightClades []string
+var drawCollapseClades string
+var drawCladeLabelStyle string
+var drawTimeAxis bool
+var drawRootDate string
+var drawDateFormat string
+var drawScaleBar bool
+var drawHeatmapFile string
+var drawBarFile string
 
 // drawCmd represents the draw command
 var drawCmd = &cobra.Command{
@@ -40,7 +53,17 @@ func init() {
 	drawCmd.PersistentFlags().BoolVar(&drawSupport, "with-branch-support", false, "Highlight highly supported branches")
 	drawCmd.PersistentFlags().Float64Var(&drawSupportCutoff, "support-cutoff", 0.7, "Cutoff for highlithing supported branches")
 	drawCmd.PersistentFlags().BoolVar(&drawNodeComment, "with-node-comments", false, "Draw the tree with internal node comments (if --with-node-labels is not set)")
-	drawCmd.PersistentFlags().StringVarP(&metadataFile, "metadata-file", "m", "", "Tab separated metadata file to add colored circles to tip nodes (svg & png): tip name in the first column (header ignored), then one column per metadata field (header = field name). Values are auto-detected as discrete or continuous; colors are auto-assigned unless overridden with --metadata-colors. Empty cells draw an unfilled grey circle.")
+	drawCmd.PersistentFlags().StringVarP(&metadataFile, "metadata-file", "m", "", "Tab separated metadata file to add colored circles to tip nodes (svg, png, pdf & cyjs): tip name in the first column (header ignored), then one column per metadata field (header = field name). Values are auto-detected as discrete or continuous; colors are auto-assigned unless overridden with --metadata-colors. Empty cells draw an unfilled grey circle.")
+	drawCmd.PersistentFlags().StringVar(&drawBranchColors, "branch-colors", "", "Color branches by the value of a node annotation (svg, png, pdf & cyjs): name, comment (first node comment, e.g. gotree acr states), support, length, or the key of a node/branch attribute ([&key=value] or [&&NHX:key=value]). Discrete or continuous colors are auto-assigned unless overridden with --metadata-colors, using the annotation as field name")
+	drawCmd.PersistentFlags().StringArrayVar(&drawHighlightClades, "highlight-clade", nil, "Draw a background shade behind the clade rooted at the named node (svg, png & pdf): name or name=#rrggbb[aa]. Can be given several times")
+	drawCmd.PersistentFlags().StringVar(&drawCollapseClades, "collapse-clades", "", "File of clades to draw as triangles, with a label (svg, png & pdf, normal and circular layouts): one clade per line, either an internal node name, or a label and a comma separated list of tips (tab separated), the clade being the smallest one containing these tips")
+	drawCmd.PersistentFlags().StringVar(&drawCladeLabelStyle, "clade-label-style", "side", "Style of the labels of collapsed clades: side (next to the triangle) or bracket (after a bracket aligned beyond the tree)")
+	drawCmd.PersistentFlags().BoolVar(&drawTimeAxis, "time-axis", false, "Draw a time axis below the tree, or concentric circles in circular layout (svg, png & pdf, normal and circular layouts), branch lengths being in years")
+	drawCmd.PersistentFlags().StringVar(&drawRootDate, "root-date", "", "Date of the root for --time-axis, yyyy.xxx or yyyy-mm-dd (default: computed from the [&date=] node comments)")
+	drawCmd.PersistentFlags().StringVar(&drawDateFormat, "date-format", "decimal", "Format of the --time-axis dates: decimal (yyyy.xxx) or iso (yyyy-mm-dd)")
+	drawCmd.PersistentFlags().BoolVar(&drawScaleBar, "scale-bar", false, "Draw a scale bar below the tree (svg, png & pdf)")
+	drawCmd.PersistentFlags().StringVar(&drawHeatmapFile, "heatmap-file", "", "Tab separated file of numeric values drawn as a heatmap next to the tip labels (svg, png & pdf, normal and circular layouts): tip name in the first column, then one column per heatmap column (header = column name). Colors are overridden in --metadata-colors with field name heatmap")
+	drawCmd.PersistentFlags().StringVar(&drawBarFile, "bar-file", "", "Tab separated file of numeric values drawn as bar charts next to the tip labels, one per column (svg, png & pdf, normal and circular layouts), same format as --heatmap-file. Colors are overridden in --metadata-colors with field name bars")
 	drawCmd.PersistentFlags().StringVar(&metadataColorsFile, "metadata-colors", "", "Optional YAML file overriding the color scheme and/or marker shape of one or more --metadata-file fields (discrete value->color map, or continuous low/high/min/max; shape: circle|square|triangle|diamond|star)")
 }
 
@@ -50,13 +73,14 @@ func init() {
 // in row order (for deterministic first-appearance color assignment), and
 // values indexed as values[tipName][fieldName].
 func parseMetadataTSV(filepath string) (fields []string, tipOrder []string, values map[string]map[string]string, err error) {
-	var file *os.File
-	if file, err = os.Open(filepath); err != nil {
+	var file io.Closer
+	var r *bufio.Reader
+	if file, r, err = utils.GetReader(filepath); err != nil {
 		return
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(r)
 	reader.Comma = '\t'
 
 	var header []string
@@ -184,3 +208,108 @@ func loadTipMetadata() (fields []string, shapes []draw.Shape, values map[string]
 	values, legend, err = draw.ResolveTipMetadata(fields, tipOrder, raw, overrides, shapes)
 	return
 }
+
+// loadTipPanels reads --heatmap-file and --bar-file, and resolves the colors
+// of their cells (overridden in --metadata-colors by the heatmap and bars
+// fields). Returns the panels, heatmap first, and their legend entries.
+func loadTipPanels() (panels []draw.TipPanel, legend []draw.LegendEntry, err error) {
+	var columns, tipOrder []string
+	var raw map[string]map[string]string
+	var panel draw.TipPanel
+
+	if drawHeatmapFile == "" && drawBarFile == "" {
+		return
+	}
+	overrides := map[string]draw.FieldColorSpec{}
+	if metadataColorsFile != "" {
+		if overrides, err = parseMetadataColorsYAML(metadataColorsFile); err != nil {
+			return
+		}
+	}
+	for _, f := range []struct {
+		file, name string
+		kind       draw.PanelType
+	}{{drawHeatmapFile, "heatmap", draw.PanelHeatmap}, {drawBarFile, "bars", draw.PanelBars}} {
+		if f.file == "" {
+			continue
+		}
+		if columns, tipOrder, raw, err = parseMetadataTSV(f.file); err != nil {
+			return
+		}
+		if panel, err = draw.ResolveTipPanel(f.kind, f.name, columns, tipOrder, raw, overrides[f.name]); err != nil {
+			return
+		}
+		panels = append(panels, panel)
+		legend = append(legend, panel.Legend)
+	}
+	return
+}
+
+// loadBranchColoring resolves --branch-colors and --highlight-clade for the
+// given tree: branch colors and their legend entry (nil if --branch-colors
+// is not set), and clade highlights.
+func loadBranchColoring(t *tree.Tree) (colors map[*tree.Node]draw.TipMetaColor, legend []draw.LegendEntry, highlights []draw.CladeHighlight, err error) {
+	if drawBranchColors != "" {
+		var entry draw.LegendEntry
+		overrides := map[string]draw.FieldColorSpec{}
+		if metadataColorsFile != "" {
+			if overrides, err = parseMetadataColorsYAML(metadataColorsFile); err != nil {
+				return
+			}
+		}
+		if colors, entry, err = draw.ResolveBranchColors(t, drawBranchColors, overrides); err != nil {
+			return
+		}
+		legend = []draw.LegendEntry{entry}
+	}
+	highlights, err = draw.ResolveCladeHighlights(t, drawHighlightClades)
+	return
+}
+
+// loadCollapsedClades resolves --collapse-clades and --clade-label-style
+// for the given tree (nil clades if --collapse-clades is not set).
+func loadCollapsedClades(t *tree.Tree) (clades []draw.CollapsedClade, style draw.CladeLabelStyle, err error) {
+	var reader *bufio.Reader
+	var file io.Closer
+	var line string
+	var specs []string
+
+	if style, err = draw.ParseCladeLabelStyle(drawCladeLabelStyle); err != nil || drawCollapseClades == "" {
+		return
+	}
+	if file, reader, err = utils.GetReader(drawCollapseClades); err != nil {
+		return
+	}
+	defer file.Close()
+	for line, err = Readln(reader); err == nil; line, err = Readln(reader) {
+		specs = append(specs, line)
+	}
+	if err != io.EOF {
+		return
+	}
+	clades, err = draw.ResolveCollapsedClades(t, specs)
+	return
+}
+
+// loadTimeAxis resolves --time-axis, --root-date and --date-format for the
+// given tree: the root date is --root-date if given, otherwise it is computed
+// from the dates of the nodes (see tree.RootDate).
+func loadTimeAxis(t *tree.Tree) (timeAxis bool, rootDate float64, format draw.DateFormat, err error) {
+	if (drawTimeAxis || drawScaleBar) && drawNoBranchLengths {
+		err = fmt.Errorf("time axes and scale bars can not be drawn with --no-branch-lengths")
+		return
+	}
+	if !drawTimeAxis {
+		return
+	}
+	if format, err = draw.ParseDateFormat(drawDateFormat); err != nil {
+		return
+	}
+	if drawRootDate != "" {
+		rootDate, err = tree.ParseDate(drawRootDate)
+	} else if rootDate, err = t.RootDate(); err != nil {
+		err = fmt.Errorf("cannot compute the root date (give it with --root-date): %v", err)
+	}
+	timeAxis = err == nil
+	return
+}
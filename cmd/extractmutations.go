@@ -5,6 +5,7 @@ import (
 	"fmt"
 	goio "io"
 	"os"
+	"strings"
 
 	"github.com/evolbioinfo/goalign/align"
 	"github.com/evolbioinfo/goalign/io/fasta"
@@ -21,6 +22,8 @@ var mutationsphylip bool
 var mutationsinputstrict bool
 var mutationseems bool
 var outfile string
+var mutationsprobas string
+var mutationsminproba float64
 
 // mutationsCmd represents the mutations command
 var mutationsCmd = &cobra.Command{
@@ -52,6 +55,10 @@ var mutationsCmd = &cobra.Command{
 	5. Parent character
 	6. Child character
 	7. Number of emergence
+
+	If --probas is given (output of "gotree asr --algo ml --out-probas"), then mutations
+	for which the parent or the child character has a posterior probability < --min-proba
+	are discarded (not compatible with --eems).
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var align align.Alignment
@@ -61,6 +68,19 @@ var mutationsCmd = &cobra.Command{
 		var treechan <-chan tree.Trees
 		var f *os.File
 		var muts *mutations.MutationList
+		var probas map[string][]float64
+
+		if mutationsprobas != "none" {
+			if mutationseems {
+				err = fmt.Errorf("--probas is not compatible with --eems")
+				io.LogError(err)
+				return
+			}
+			if probas, err = parseProbasFile(mutationsprobas); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
 
 		// Reading the alignment
 		if fi, r, err = utils.GetReader(mutationsalign); err != nil {
@@ -112,6 +132,12 @@ var mutationsCmd = &cobra.Command{
 					io.LogError(err)
 					return
 				}
+				if probas != nil {
+					if muts, err = muts.FilterProbas(probas, mutationsminproba); err != nil {
+						io.LogError(err)
+						return
+					}
+				}
 				for _, m := range muts.Mutations {
 					fmt.Fprintf(f, "%d\t%d\t%d\t%s\t%c\t%c\t%d\t%d\n", t.Id, m.AlignmentSite, m.BranchIndex, m.ChildNodeName, m.ParentCharacter, m.ChildCharacter, m.NumTips, m.NumTipsWithChildCharacter)
 				}
@@ -129,4 +155,37 @@ func init() {
 	mutationsCmd.PersistentFlags().BoolVar(&mutationseems, "eems", false, "If true, extracts mutations that goes to tips, with their number of emergence (see https://doi.org/10.1101/2021.06.30.450558)")
 	mutationsCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	mutationsCmd.PersistentFlags().StringVarP(&outfile, "output", "o", "stdout", "Output file")
+	mutationsCmd.PersistentFlags().StringVar(&mutationsprobas, "probas", "none", "Posterior probabilities of ancestral characters (output of gotree asr --algo ml --out-probas)")
+	mutationsCmd.PersistentFlags().Float64Var(&mutationsminproba, "min-proba", 0.9, "Minimum posterior probability of parent and child characters to keep a mutation (only with --probas)")
+}
+
+// Parses the posterior probabilities file given by gotree asr --out-probas
+// One line per node: nodename\tcomma separated probabilities of each site
+func parseProbasFile(file string) (probas map[string][]float64, err error) {
+	var f goio.Closer
+	var r *bufio.Reader
+	var line string
+	var e error
+	var cols []string
+	var nl int = 1
+
+	probas = make(map[string][]float64)
+	if f, r, err = utils.GetReader(file); err != nil {
+		return
+	}
+	defer f.Close()
+
+	line, e = Readln(r)
+	for e == nil {
+		if cols = strings.Split(line, "\t"); len(cols) != 2 {
+			err = fmt.Errorf("probas file does not have 2 fields at line %d", nl)
+			return
+		}
+		if probas[cols[0]], err = parseFloatList(cols[1]); err != nil {
+			return
+		}
+		line, e = Readln(r)
+		nl++
+	}
+	return
 }
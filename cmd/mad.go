@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// madCmd represents the reroot mad command
+var madCmd = &cobra.Command{
+	Use:   "mad",
+	Short: "Reroot trees using minimal ancestor deviation rooting",
+	Long: `Reroot trees using minimal ancestor deviation rooting (MAD, Tria et al. 2017).
+
+The root is placed at the position of the tree minimizing the mean
+relative deviation of the last common ancestors of all pairs of tips
+from the middle of their path (root mean square). Branch lengths are
+required.
+
+If --log is given, the score of the chosen root of each tree is written
+in the log file. If --out-scores is given, the best root position and
+the score of every branch of the unrooted tree are written in the given
+file.
+
+Example:
+
+gotree reroot mad -i tree.nw --out-scores scores.tsv > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		return rerootScores(func(t *tree.Tree) (tree.RootScore, []tree.RootScore, error) {
+			return t.RerootMAD()
+		}, "score", formatRootScore, nil)
+	},
+}
+
+func init() {
+	rerootCmd.AddCommand(madCmd)
+	madCmd.PersistentFlags().StringVar(&rerootlogfile, "log", "none", "Output log file with the score of the chosen root")
+	madCmd.PersistentFlags().StringVar(&rerootscorefile, "out-scores", "none", "Output file with the best root position (distance from the left node) and score of each branch of the unrooted tree")
+}
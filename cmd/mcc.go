@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var mccBurnin float64
+var mccHeights string
+var mccNexus bool
+
+// mccCmd represents the mcc command
+var mccCmd = &cobra.Command{
+	Use:   "mcc",
+	Short: "Computes the maximum clade credibility tree of a set of trees",
+	Long: `Computes the maximum clade credibility (MCC) tree of a set of rooted trees.
+
+Input trees are typically a posterior sample of trees (BEAST, MrBayes, etc.), and
+must have the same tip names. After discarding the --burnin first fraction of
+the trees, the MCC tree is the sampled tree maximizing the product of the
+posterior probabilities (frequencies) of its clades.
+
+Nodes of the output tree are annotated with BEAST-style comments:
+[&posterior=...,height=...,height_median=...,height_95%_HPD={...,...},...]
+Heights are distances to the most recent tip. Numeric node comments of the
+input trees ([&rate=0.1,...]) are summarized the same way (mean, median and
+95% HPD over the trees having the same clade).
+
+--heights sets the node heights of the output tree:
+- keep  : heights of the selected tree (default)
+- mean  : mean heights of the clades
+- median: median heights of the clades
+
+If --nexus is given, the tree is written in Nexus format.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var mcc *tree.Tree
+		var heights int
+
+		switch mccHeights {
+		case "keep":
+			heights = tree.MCC_HEIGHTS_KEEP
+		case "mean":
+			heights = tree.MCC_HEIGHTS_MEAN
+		case "median":
+			heights = tree.MCC_HEIGHTS_MEDIAN
+		default:
+			err = fmt.Errorf("unknown height method: %s", mccHeights)
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if mcc, err = tree.MaxCladeCredibility(treechan, mccBurnin, heights); err != nil {
+			io.LogError(err)
+			return
+		}
+		if mccNexus {
+			f.WriteString(mcc.Nexus())
+		} else {
+			f.WriteString(mcc.Newick() + "\n")
+		}
+		return
+	},
+}
+
+func init() {
+	computeCmd.AddCommand(mccCmd)
+	mccCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input trees")
+	mccCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
+	mccCmd.PersistentFlags().Float64VarP(&mccBurnin, "burnin", "b", 0.0, "Fraction of the first trees to discard (>=0 and <1)")
+	mccCmd.PersistentFlags().StringVar(&mccHeights, "heights", "keep", "Node heights of the mcc tree: keep|mean|median")
+	mccCmd.PersistentFlags().BoolVar(&mccNexus, "nexus", false, "Writes the mcc tree in Nexus format")
+}
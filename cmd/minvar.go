@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// minvarCmd represents the reroot minvar command
+var minvarCmd = &cobra.Command{
+	Use:   "minvar",
+	Short: "Reroot trees using minimum variance rooting",
+	Long: `Reroot trees using minimum variance rooting (MinVar, Mai et al. 2017).
+
+The root is placed at the position of the tree minimizing the variance
+of the root-to-tip distances. Branch lengths are required.
+
+If --log is given, the score (variance) of the chosen root of each tree
+is written in the log file. If --out-scores is given, the best root
+position and the score of every branch of the unrooted tree are written
+in the given file.
+
+Example:
+
+gotree reroot minvar -i tree.nw --out-scores scores.tsv > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		return rerootScores(func(t *tree.Tree) (tree.RootScore, []tree.RootScore, error) {
+			return t.RerootMinVar()
+		}, "score", formatRootScore, nil)
+	},
+}
+
+// Reroots the input trees with the given method, and writes the
+// rerooted trees and their root scores.
+//
+// columns gives the tab separated names of the score columns, and
+// format returns, for a score, its branch, the root position along
+// the branch, and the tab separated values of these columns. If info
+// is not nil and no log file is given, the score of the chosen root of
+// each tree is written to stderr as returned by info.
+func rerootScores[S any](reroot func(t *tree.Tree) (S, []S, error), columns string, format func(s S) (*tree.Edge, float64, string), info func(s S) string) (err error) {
+	var f, logf, scoref *os.File
+	var treefile goio.Closer
+	var treechan <-chan tree.Trees
+	var best S
+	var scores []S
+
+	if f, err = openWriteFile(outtreefile); err != nil {
+		io.LogError(err)
+		return
+	}
+	defer closeWriteFile(f, outtreefile)
+
+	if rerootlogfile != "none" {
+		if logf, err = openWriteFile(rerootlogfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(logf, rerootlogfile)
+		fmt.Fprintf(logf, "tree\t%s\n", columns)
+	}
+
+	if rerootscorefile != "none" {
+		if scoref, err = openWriteFile(rerootscorefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(scoref, rerootscorefile)
+		scoref.WriteString(fmt.Sprintf("tree\tbrid\tlength\tposition\t%s\tchosen\trightname\n", columns))
+	}
+
+	if treefile, treechan, err = readTrees(intreefile); err != nil {
+		io.LogError(err)
+		return
+	}
+	defer treefile.Close()
+
+	for t := range treechan {
+		if t.Err != nil {
+			io.LogError(t.Err)
+			return t.Err
+		}
+		if best, scores, err = reroot(t.Tree); err != nil {
+			io.LogError(err)
+			return
+		}
+		bestEdge, _, bestValues := format(best)
+		if logf != nil {
+			fmt.Fprintf(logf, "%d\t%s\n", t.Id, bestValues)
+		} else if info != nil {
+			io.LogInfo(fmt.Sprintf("tree %d: %s", t.Id, info(best)))
+		}
+		if scoref != nil {
+			for i, s := range scores {
+				e, position, values := format(s)
+				scoref.WriteString(fmt.Sprintf("%d\t%d\t%g\t%g\t%s\t%t\t%s\n", t.Id, i, e.Length(), position, values, e == bestEdge, e.Right().Name()))
+			}
+		}
+		f.WriteString(t.Tree.Newick() + "\n")
+	}
+	return
+}
+
+// Columns of the single score of the minvar and mad rootings
+func formatRootScore(s tree.RootScore) (*tree.Edge, float64, string) {
+	return s.Edge, s.Position, fmt.Sprintf("%g", s.Score)
+}
+
+func init() {
+	rerootCmd.AddCommand(minvarCmd)
+	minvarCmd.PersistentFlags().StringVar(&rerootlogfile, "log", "none", "Output log file with the score of the chosen root")
+	minvarCmd.PersistentFlags().StringVar(&rerootscorefile, "out-scores", "none", "Output file with the best root position (distance from the left node) and score of each branch of the unrooted tree")
+}
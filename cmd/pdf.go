@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/evolbioinfo/gotree/draw"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var pdfwidth int
+var pdfheight int
+var pdfradial bool
+var pdfcircular bool
+var pdfdaylight bool
+
+// pdfCmd represents the pdf command
+var pdfCmd = &cobra.Command{
+	Use:   "pdf",
+	Short: "Draw trees in pdf files",
+	Long: `Draw trees in pdf files.
+
+Pdf files are vector images whose fonts are embedded, with the same layouts
+and options as svg images (1 pixel being 1 point).
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var d draw.TreeDrawer
+		var l draw.TreeLayout
+		var metaFields []string
+		var metaShapes []draw.Shape
+		var metaValues map[string][]draw.TipMetaColor
+		var metaLegend []draw.LegendEntry
+		var branchColors map[*tree.Node]draw.TipMetaColor
+		var branchLegend []draw.LegendEntry
+		var highlights []draw.CladeHighlight
+		var collapsed []draw.CollapsedClade
+		var labelStyle draw.CladeLabelStyle
+		var panels []draw.TipPanel
+		var panelLegend []draw.LegendEntry
+		var timeAxis bool
+		var rootDate float64
+		var dateFormat draw.DateFormat
+
+		if metaFields, metaShapes, metaValues, metaLegend, err = loadTipMetadata(); err != nil {
+			io.LogError(err)
+			return err
+		}
+		if panels, panelLegend, err = loadTipPanels(); err != nil {
+			io.LogError(err)
+			return err
+		}
+		if len(panels) > 0 && (pdfradial || pdfdaylight) {
+			err = errors.New("heatmaps and bar charts are only drawn in normal and circular layouts")
+			io.LogError(err)
+			return err
+		}
+
+		ntree := 0
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			fname := outtreefile
+			if ntree > 0 {
+				extension := filepath.Ext(fname)
+				if extension == ".pdf" {
+					fname = fname[0 : len(fname)-len(extension)]
+				}
+				fname = fmt.Sprintf(fname+"_%03d.pdf", ntree)
+			}
+
+			if branchColors, branchLegend, highlights, err = loadBranchColoring(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if collapsed, labelStyle, err = loadCollapsedClades(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if len(collapsed) > 0 && (pdfradial || pdfdaylight) {
+				err = errors.New("collapsed clades are only drawn in normal and circular layouts")
+				io.LogError(err)
+				return err
+			}
+			if timeAxis, rootDate, dateFormat, err = loadTimeAxis(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if timeAxis && (pdfradial || pdfdaylight) {
+				err = errors.New("time axes are only drawn in normal and circular layouts")
+				io.LogError(err)
+				return err
+			}
+			legendW, legendH := draw.LegendSize(slices.Concat(metaLegend, branchLegend, panelLegend), draw.PdfTextWidth)
+
+			if f, err = openWriteFile(fname); err != nil {
+				io.LogError(err)
+				return
+			}
+
+			margin := 30
+
+			if pdfradial || pdfdaylight || pdfcircular {
+				// Radial/circular tip labels fan out in every direction (not
+				// just rightward like the normal layout), so the margin must
+				// be wide enough on all four sides for the longest one,
+				// wherever it ends up pointing.
+				maxTipNameWidth := 0.0
+				for _, n := range t.Tree.AllTipNames() {
+					if w := draw.PdfTextWidth(n, false); w > maxTipNameWidth {
+						maxTipNameWidth = w
+					}
+				}
+				for _, c := range collapsed {
+					if w := draw.PdfTextWidth(c.Label, false); w > maxTipNameWidth {
+						maxTipNameWidth = w
+					}
+				}
+				if radial := draw.RadialLabelMargin(maxTipNameWidth, !drawNoTipLabels, len(metaFields)); radial > margin {
+					margin = radial
+				}
+			}
+
+			if pdfradial || pdfdaylight {
+				if err = t.Tree.ReinitIndexes(); err != nil {
+					io.LogError(err)
+					return
+				}
+
+				d = draw.NewPdfTreeDrawer(f, min(pdfwidth, pdfheight), min(pdfwidth, pdfheight), margin, margin, margin, margin, legendW, legendH)
+				if pdfdaylight {
+					l = draw.NewDaylightLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				} else {
+					l = draw.NewRadialLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				}
+				l.SetDisplayInternalNodes(drawInternalNodeSymbols)
+			} else if pdfcircular {
+				d = draw.NewPdfTreeDrawer(f, min(pdfwidth, pdfheight), min(pdfwidth, pdfheight), margin, margin, margin, margin, legendW, legendH)
+				l = draw.NewCircularLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+			} else {
+				d = draw.NewPdfTreeDrawer(f, pdfwidth, pdfheight, 30, margin, 30, 30, legendW, legendH)
+				l = draw.NewNormalLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+			}
+			l.SetDisplayInternalNodes(drawInternalNodeSymbols)
+			l.SetDisplayNodeComments(drawNodeComment)
+			l.SetSupportCutoff(drawSupportCutoff)
+			if len(metaFields) > 0 {
+				l.SetTipMetadata(metaFields, metaShapes, metaValues, metaLegend)
+			}
+			if branchColors != nil {
+				l.SetBranchColors(branchColors, branchLegend)
+			}
+			l.SetCladeHighlights(highlights)
+			l.SetCollapsedClades(collapsed, labelStyle)
+			if timeAxis {
+				l.SetTimeAxis(rootDate, dateFormat)
+			}
+			l.SetScaleBar(drawScaleBar)
+			l.SetTipPanels(panels)
+			err = l.DrawTree(t.Tree)
+			closeWriteFile(f, fname)
+			if err != nil {
+				io.LogError(err)
+				return
+			}
+			ntree++
+		}
+		return
+	},
+}
+
+func init() {
+	drawCmd.AddCommand(pdfCmd)
+	pdfCmd.PersistentFlags().IntVarP(&pdfwidth, "width", "w", 200, "Width of pdf image in points")
+	pdfCmd.PersistentFlags().IntVarP(&pdfheight, "height", "H", 200, "Height of pdf image in points")
+	pdfCmd.PersistentFlags().BoolVarP(&pdfradial, "radial", "r", false, "Radial layout (default : normal)")
+	pdfCmd.PersistentFlags().BoolVar(&pdfdaylight, "daylight", false, "Equal-daylight unrooted layout: radial layout whose subtrees are rotated to even out the gaps between them (default : normal)")
+	pdfCmd.PersistentFlags().BoolVarP(&pdfcircular, "circular", "c", false, "Circular/Polar layout (default : normal)")
+}
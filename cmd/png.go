@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	goio "io"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/evolbioinfo/gotree/draw"
 	"github.com/evolbioinfo/gotree/io"
@@ -16,6 +18,7 @@ var pngwidth int
 var pngheight int
 var pngradial bool
 var pngcircular bool
+var pngdaylight bool
 var pngfillbackground bool
 
 // pngCmd represents the png command
@@ -35,12 +38,30 @@ var pngCmd = &cobra.Command{
 		var metaShapes []draw.Shape
 		var metaValues map[string][]draw.TipMetaColor
 		var metaLegend []draw.LegendEntry
+		var branchColors map[*tree.Node]draw.TipMetaColor
+		var branchLegend []draw.LegendEntry
+		var highlights []draw.CladeHighlight
+		var collapsed []draw.CollapsedClade
+		var labelStyle draw.CladeLabelStyle
+		var panels []draw.TipPanel
+		var panelLegend []draw.LegendEntry
+		var timeAxis bool
+		var rootDate float64
+		var dateFormat draw.DateFormat
 
 		if metaFields, metaShapes, metaValues, metaLegend, err = loadTipMetadata(); err != nil {
 			io.LogError(err)
 			return err
 		}
-		legendW, legendH := draw.LegendSize(metaLegend, draw.PngTextWidth)
+		if panels, panelLegend, err = loadTipPanels(); err != nil {
+			io.LogError(err)
+			return err
+		}
+		if len(panels) > 0 && (pngradial || pngdaylight) {
+			err = errors.New("heatmaps and bar charts are only drawn in normal and circular layouts")
+			io.LogError(err)
+			return err
+		}
 
 		ntree := 0
 		if treefile, treechan, err = readTrees(intreefile); err != nil {
@@ -62,13 +83,38 @@ var pngCmd = &cobra.Command{
 				}
 				fname = fmt.Sprintf(fname+"_%03d.png", ntree)
 			}
+
+			if branchColors, branchLegend, highlights, err = loadBranchColoring(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if collapsed, labelStyle, err = loadCollapsedClades(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if len(collapsed) > 0 && (pngradial || pngdaylight) {
+				err = errors.New("collapsed clades are only drawn in normal and circular layouts")
+				io.LogError(err)
+				return err
+			}
+			if timeAxis, rootDate, dateFormat, err = loadTimeAxis(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if timeAxis && (pngradial || pngdaylight) {
+				err = errors.New("time axes are only drawn in normal and circular layouts")
+				io.LogError(err)
+				return err
+			}
+			legendW, legendH := draw.LegendSize(slices.Concat(metaLegend, branchLegend, panelLegend), draw.PngTextWidth)
+
 			if f, err = openWriteFile(fname); err != nil {
 				io.LogError(err)
 				return
 			}
 			margin := 30
 
-			if pngradial || pngcircular {
+			if pngradial || pngdaylight || pngcircular {
 				// Radial/circular tip labels fan out in every direction (not
 				// just rightward like the normal layout), so the margin must
 				// be wide enough on all four sides for the longest one,
@@ -79,19 +125,28 @@ var pngCmd = &cobra.Command{
 						maxTipNameWidth = w
 					}
 				}
+				for _, c := range collapsed {
+					if w := draw.PngTextWidth(c.Label, false); w > maxTipNameWidth {
+						maxTipNameWidth = w
+					}
+				}
 				if radial := draw.RadialLabelMargin(maxTipNameWidth, !drawNoTipLabels, len(metaFields)); radial > margin {
 					margin = radial
 				}
 			}
 
-			if pngradial {
+			if pngradial || pngdaylight {
 				if err = t.Tree.ReinitIndexes(); err != nil {
 					io.LogError(err)
 					return
 				}
 
 				d = draw.NewPngTreeDrawer(f, min(pngwidth, pngheight), min(pngwidth, pngheight), margin, margin, margin, margin, pngfillbackground, legendW, legendH)
-				l = draw.NewRadialLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				if pngdaylight {
+					l = draw.NewDaylightLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				} else {
+					l = draw.NewRadialLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				}
 			} else if pngcircular {
 				d = draw.NewPngTreeDrawer(f, min(pngwidth, pngheight), min(pngwidth, pngheight), margin, margin, margin, margin, pngfillbackground, legendW, legendH)
 				l = draw.NewCircularLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
@@ -105,8 +160,22 @@ var pngCmd = &cobra.Command{
 			if len(metaFields) > 0 {
 				l.SetTipMetadata(metaFields, metaShapes, metaValues, metaLegend)
 			}
-			l.DrawTree(t.Tree)
+			if branchColors != nil {
+				l.SetBranchColors(branchColors, branchLegend)
+			}
+			l.SetCladeHighlights(highlights)
+			l.SetCollapsedClades(collapsed, labelStyle)
+			if timeAxis {
+				l.SetTimeAxis(rootDate, dateFormat)
+			}
+			l.SetScaleBar(drawScaleBar)
+			l.SetTipPanels(panels)
+			err = l.DrawTree(t.Tree)
 			closeWriteFile(f, fname)
+			if err != nil {
+				io.LogError(err)
+				return
+			}
 			ntree++
 		}
 		return
@@ -118,6 +187,7 @@ func init() {
 	pngCmd.PersistentFlags().IntVarP(&pngwidth, "width", "w", 200, "Width of png image in pixels")
 	pngCmd.PersistentFlags().IntVarP(&pngheight, "height", "H", 200, "Height of png image in pixels")
 	pngCmd.PersistentFlags().BoolVarP(&pngradial, "radial", "r", false, "Radial layout (default : normal)")
+	pngCmd.PersistentFlags().BoolVar(&pngdaylight, "daylight", false, "Equal-daylight unrooted layout: radial layout whose subtrees are rotated to even out the gaps between them (default : normal)")
 	pngCmd.PersistentFlags().BoolVar(&pngfillbackground, "fill-background", false, "If true, then background is white, otherwise transparent")
 	pngCmd.PersistentFlags().BoolVarP(&pngcircular, "circular", "c", false, "Circular/Polar layout (default : normal)")
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	goio "io"
 	"os"
 
@@ -9,6 +10,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var newickAttributes string
+var newickPrecision int
+var newickNoSupports bool
+var newickNoInternalNames bool
+var newickQuoteNames bool
+
 // newickCmd represents the newick command
 var newickCmd = &cobra.Command{
 	Use:   "newick",
@@ -16,11 +23,41 @@ var newickCmd = &cobra.Command{
 	Long: `Reformats an input tree file into Newick format.
 
 - Input formats: Newick, Nexus,
-- Output format: Newick.`,
+- Output format: Newick.
+
+Several options control the Newick dialect of the output:
+- --attributes: Format of node/edge attributes:
+    - raw: comments are written as they are (default);
+    - beast: attributes are merged into [&key=value,...] comments (FigTree);
+    - nhx: attributes of nodes and edges are merged into [&&NHX:key=value:...]
+      comments written after branch lengths (ETE);
+- --precision: Number of decimals of branch lengths and supports 
+  (-1: as many as needed);
+- --no-supports: Branch supports are not written;
+- --no-internal-names: Internal node names are not written;
+- --quote: All node names are quoted (otherwise, only names with special characters).`,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var f *os.File
 		var treefile goio.Closer
 		var treechan <-chan tree.Trees
+		var opts tree.NewickOptions = tree.DefaultNewickOptions()
+
+		switch newickAttributes {
+		case "raw":
+			opts.Attributes = tree.NEWICK_ATTRIBUTES_RAW
+		case "beast":
+			opts.Attributes = tree.NEWICK_ATTRIBUTES_BEAST
+		case "nhx":
+			opts.Attributes = tree.NEWICK_ATTRIBUTES_NHX
+		default:
+			err = fmt.Errorf("unknown attribute format: %s", newickAttributes)
+			io.LogError(err)
+			return
+		}
+		opts.Precision = newickPrecision
+		opts.NoSupports = newickNoSupports
+		opts.NoInternalNames = newickNoInternalNames
+		opts.QuoteNames = newickQuoteNames
 
 		if f, err = openWriteFile(outtreefile); err != nil {
 			io.LogError(err)
@@ -39,7 +76,7 @@ var newickCmd = &cobra.Command{
 				io.LogError(t.Err)
 				return t.Err
 			}
-			f.WriteString(t.Tree.Newick() + "\n")
+			f.WriteString(t.Tree.NewickWithOptions(opts) + "\n")
 		}
 		return
 	},
@@ -47,4 +84,9 @@ var newickCmd = &cobra.Command{
 
 func init() {
 	reformatCmd.AddCommand(newickCmd)
+	newickCmd.Flags().StringVar(&newickAttributes, "attributes", "raw", "Format of node/edge attributes (raw, beast, or nhx)")
+	newickCmd.Flags().IntVar(&newickPrecision, "precision", -1, "Number of decimals of branch lengths and supports (-1: as many as needed)")
+	newickCmd.Flags().BoolVar(&newickNoSupports, "no-supports", false, "Do not write branch supports")
+	newickCmd.Flags().BoolVar(&newickNoInternalNames, "no-internal-names", false, "Do not write internal node names")
+	newickCmd.Flags().BoolVar(&newickQuoteNames, "quote", false, "Quote all node names")
 }
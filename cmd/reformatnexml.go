@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/nexml"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// nexmlCmd represents the nexml command
+var nexmlCmd = &cobra.Command{
+	Use:   "nexml",
+	Short: "Reformats an input tree file into NeXML format",
+	Long: `Reformats an input tree file into NeXML format.
+
+- Input formats: Newick, Nexus, PhyloXML, Nextstrain, NeXML
+- Output format: NeXML.
+
+Tip names are written as otu labels. Node and edge attributes 
+(e.g. [&key=value,...] comments) are written as meta annotations,
+and branch supports as "support" edge annotations.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var xml string
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+		if xml, err = nexml.WriteNeXML(treechan); err != nil {
+			io.LogError(err)
+			return
+		}
+		f.WriteString(xml)
+		return
+	},
+}
+
+func init() {
+	reformatCmd.AddCommand(nexmlCmd)
+}
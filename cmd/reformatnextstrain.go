@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+	"time"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/nextstrain"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var nextstrainMetadata string
+var nextstrainTitle string
+
+// nextstrainCmd represents the nextstrain command
+var nextstrainCmd = &cobra.Command{
+	Use:   "nextstrain",
+	Short: "Reformats an input tree file into Nextstrain (Auspice v2) JSON format",
+	Long: `Reformats an input tree file into Nextstrain (Auspice v2) JSON format.
+
+- Input formats: Newick, Nexus, PhyloXML, Nextstrain
+- Output format: Nextstrain JSON.
+
+Each node is given:
+- its divergence from the root (div);
+- its date (num_date), if it has a [&date=...] comment, in decimal 
+  (yyyy.xxx) or yyyy-mm-dd format;
+- one attribute per other key=value field of its comments 
+  (e.g. [&country=France,age=32]);
+- its branch support, if any (support).
+
+Additional per-tip attributes may be given in a tab separated metadata
+file (--metadata) whose first line is a header, and whose first column 
+contains tip names. Other columns are added as attributes of the tips.
+Dates of the date column that can not be parsed are ignored, with a warning.
+
+Only the first tree of the input file is written.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var metadata map[string]map[string]string
+		var json string
+		var first *tree.Tree
+
+		if nextstrainMetadata != "none" {
+			if metadata, err = readNextstrainMetadata(nextstrainMetadata); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if first != nil {
+				io.LogWarning(errors.New("only the first tree is written in nextstrain format"))
+				for range treechan {
+				}
+				break
+			}
+			first = t.Tree
+		}
+		if first == nil {
+			err = errors.New("no tree in the input file")
+			io.LogError(err)
+			return
+		}
+
+		if json, err = nextstrain.WriteNextstrain(first, metadata, nextstrainTitle, time.Now().Format("2006-01-02")); err != nil {
+			io.LogError(err)
+			return
+		}
+		f.WriteString(json)
+		return
+	},
+}
+
+// Reads the tab separated metadata file, and returns for each tip
+// its non empty values indexed by field. Dates that can not be parsed
+// are skipped with a warning.
+func readNextstrainMetadata(file string) (metadata map[string]map[string]string, err error) {
+	var values map[string]map[string]string
+
+	if _, _, values, err = parseMetadataTSV(file); err != nil {
+		return
+	}
+	metadata = make(map[string]map[string]string, len(values))
+	for tip, fields := range values {
+		metadata[tip] = make(map[string]string, len(fields))
+		for k, v := range fields {
+			if v == "" {
+				continue
+			}
+			if k == "date" || k == "num_date" {
+				if _, e := tree.ParseDate(v); e != nil {
+					io.LogWarning(fmt.Errorf("tip %s: date %q is ignored: %v", tip, v, e))
+					continue
+				}
+			}
+			metadata[tip][k] = v
+		}
+	}
+	return
+}
+
+func init() {
+	reformatCmd.AddCommand(nextstrainCmd)
+	nextstrainCmd.Flags().StringVar(&nextstrainMetadata, "metadata", "none", "Tab separated file with per-tip attributes (header line, first column: tip names)")
+	nextstrainCmd.Flags().StringVar(&nextstrainTitle, "title", "", "Title of the dataset")
+}
@@ -7,8 +7,10 @@ import (
 // rerootCmd represents the reroot command
 var rerootCmd = &cobra.Command{
 	Use:   "reroot",
-	Short: "Reroot trees using an outgroup or at midpoint",
-	Long: `Reroot trees using an outgroup or at midpoint.
+	Short: "Reroot trees using an outgroup, at midpoint, by minimum variance or MAD rooting, or by root-to-tip regression",
+	Long: `Reroot trees using an outgroup, at midpoint, by minimum variance (MinVar)
+or minimal ancestor deviation (MAD) rooting, or at the best root-to-tip
+regression against tip dates.
 `,
 }
 
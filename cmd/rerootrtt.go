@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var rerootrttcriterion string
+
+// rerootRttCmd represents the reroot rtt command
+var rerootRttCmd = &cobra.Command{
+	Use:   "rtt",
+	Short: "Reroot trees using the best root-to-tip regression",
+	Long: `Reroot trees at the position giving the best root-to-tip regression.
+
+As TempEst does, every branch, and every position along it, is tried as
+root, and the root-to-tip distances are regressed against the tip dates,
+given in the tips comments ([&date=yyyy.xxx] or [&date=yyyy-mm-dd]). The
+root is placed at the position:
+- maximizing R² with a positive rate (--criterion r2, default), or
+- minimizing the residual mean squares (--criterion rms).
+
+The statistics of the regression of the chosen root of each tree (rate,
+date of the root, R² and residual mean squares) are written to stderr,
+or in the log file if --log is given. If --out-scores is given, the best root position
+and the statistics of every branch of the unrooted tree are written in
+the given file.
+
+Example:
+
+gotree reroot rtt -i tree.nw --out-scores scores.tsv > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var criterion int
+
+		switch rerootrttcriterion {
+		case "r2":
+			criterion = tree.RTT_CRITERION_R2
+		case "rms":
+			criterion = tree.RTT_CRITERION_RMS
+		default:
+			err = fmt.Errorf("unknown criterion %q (r2 or rms)", rerootrttcriterion)
+			io.LogError(err)
+			return
+		}
+
+		return rerootScores(func(t *tree.Tree) (tree.RTTRootScore, []tree.RTTRootScore, error) {
+			return t.RerootRTT(criterion)
+		}, "rate\troot_date\tr2\trms", func(s tree.RTTRootScore) (*tree.Edge, float64, string) {
+			return s.Edge, s.Position, fmt.Sprintf("%g\t%g\t%g\t%g", s.Rate, s.RootDate, s.R2, s.RMS)
+		}, func(s tree.RTTRootScore) string {
+			return fmt.Sprintf("rate=%g, root date=%g, R²=%g, rms=%g", s.Rate, s.RootDate, s.R2, s.RMS)
+		})
+	},
+}
+
+func init() {
+	rerootCmd.AddCommand(rerootRttCmd)
+	rerootRttCmd.PersistentFlags().StringVar(&rerootrttcriterion, "criterion", "r2", "Criterion to choose the root: r2 (maximum R² with a positive rate) or rms (minimum residual mean squares)")
+	rerootRttCmd.PersistentFlags().StringVar(&rerootlogfile, "log", "none", "Output log file with the regression statistics of the chosen root")
+	rerootRttCmd.PersistentFlags().StringVar(&rerootscorefile, "out-scores", "none", "Output file with the best root position (distance from the left node) and regression statistics of each branch of the unrooted tree")
+}
@@ -43,6 +43,8 @@ var rootCpus int
 var rootInputFormat string
 var removeoutgroup bool
 var rerootstrict bool
+var rerootscorefile string
+var rerootlogfile string
 
 var globalRand *rand.Rand
 
@@ -80,6 +82,8 @@ Different usages are implemented:
 			treeformat = utils.FORMAT_PHYLOXML
 		case "nextstrain":
 			treeformat = utils.FORMAT_NEXTSTRAIN
+		case "nexml":
+			treeformat = utils.FORMAT_NEXML
 		default:
 			treeformat = utils.FORMAT_NEWICK
 		}
@@ -119,7 +123,7 @@ func init() {
 
 	RootCmd.PersistentFlags().Int64Var(&seed, "seed", -1, "Random Seed: -1 = nano seconds since 1970/01/01 00:00:00")
 	RootCmd.PersistentFlags().IntVarP(&rootCpus, "threads", "t", 1, "Number of threads (Max="+strconv.Itoa(maxcpus)+")")
-	RootCmd.PersistentFlags().StringVar(&rootInputFormat, "format", "newick", "Input tree format (newick, nexus, phyloxml, or nextstrain)")
+	RootCmd.PersistentFlags().StringVar(&rootInputFormat, "format", "newick", "Input tree format (newick, nexus, phyloxml, nextstrain, or nexml)")
 	RootCmd.SetHelpTemplate(helptemplate)
 }
 
@@ -165,7 +169,7 @@ func readTrees(infile string) (treefile goio.Closer, treeChannel <-chan tree.Tre
 	var treereader *bufio.Reader
 
 	if treefile, treereader, err = utils.GetReader(infile); err == nil {
-		treeChannel = utils.ReadMultiTrees(treereader, treeformat)
+		treeChannel = utils.ReadMultiTreesThreads(treereader, treeformat, rootCpus)
 	}
 
 	return
@@ -228,6 +232,20 @@ func parseIntFile(file string) (islice []int, err error) {
 	return
 }
 
+// Parse a comma separated list of floats
+func parseFloatList(list string) (fslice []float64, err error) {
+	var tempf float64
+
+	fslice = make([]float64, 0, 10)
+	for _, v := range strings.Split(list, ",") {
+		if tempf, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+			return
+		}
+		fslice = append(fslice, tempf)
+	}
+	return
+}
+
 // Parse a file with one tip name per line
 func parseTipsFile(file string) (tips []string, err error) {
 	tips, err = parseStringFile(file)
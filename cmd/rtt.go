@@ -35,7 +35,8 @@ var rttCmd = &cobra.Command{
 	Short: "Root To Tip regression",
 	Long: `Compute Root To Tip regression.
 
-It considers input tree as rooted.
+It considers input tree as rooted. To find the root giving the best
+regression, see gotree reroot rtt.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var f *os.File
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var sbdBirthRate float64
+var sbdDeathRate float64
+var generateSamplingRate float64
+var generateRemovalProba float64
+
+func serialBirthDeathTree(nbtrees int, nbtips int, output string) error {
+	var f *os.File
+	var err error
+	var t *tree.Tree
+
+	if output != "stdout" && output != "-" {
+		f, err = os.Create(output)
+		defer f.Close()
+	} else {
+		f = os.Stdout
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < nbtrees; i++ {
+		t, err = tree.RandomSerialBirthDeathTree(nbtips, sbdBirthRate, sbdDeathRate, generateSamplingRate, generateRemovalProba, generatePresentDate, globalRand)
+		if err != nil {
+			return err
+		}
+		f.WriteString(t.Newick() + "\n")
+	}
+
+	return nil
+}
+
+// serialbirthdeathtreeCmd represents the serialbirthdeathtree command
+var serialbirthdeathtreeCmd = &cobra.Command{
+	Use:   "serialbirthdeathtree",
+	Short: "Generates a random birth-death tree with serial sampling",
+	Long: `Generates a random birth-death tree with serial sampling (fossilized birth-death).
+
+Lineages are sampled through time with rate --sampling, and are removed
+upon sampling with probability --removal. If --removal is < 1, sampled
+ancestors may be generated, as tips attached by a branch of length 0.
+
+The process is simulated forward in time until -l lineages are sampled, and
+unsampled lineages are pruned. Generated trees are always rooted, branch lengths
+are in time units, and each tip is annotated with its sampling date, in the
+[&date=...] comment format. The last sampled tip has the --present date.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := serialBirthDeathTree(generateNbTrees, generateNbTips, generateOutputfile); err != nil {
+			io.LogError(err)
+			return
+		}
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(serialbirthdeathtreeCmd)
+	serialbirthdeathtreeCmd.PersistentFlags().IntVarP(&generateNbTips, "nbtips", "l", 10, "Number of sampled tips/leaves of the tree to generate")
+	serialbirthdeathtreeCmd.PersistentFlags().Float64Var(&sbdBirthRate, "birth", 1.0, "Birth rate")
+	serialbirthdeathtreeCmd.PersistentFlags().Float64Var(&sbdDeathRate, "death", 0.5, "Death rate")
+	serialbirthdeathtreeCmd.PersistentFlags().Float64Var(&generateSamplingRate, "sampling", 0.1, "Sampling rate through time")
+	serialbirthdeathtreeCmd.PersistentFlags().Float64Var(&generateRemovalProba, "removal", 1.0, "Probability for a lineage to be removed upon sampling")
+	serialbirthdeathtreeCmd.PersistentFlags().Float64Var(&generatePresentDate, "present", 0.0, "Date of the last sampling event")
+}
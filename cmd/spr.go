@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	goio "io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var rearrangeradius int
+var rearrangesample int
+var rearrangeunique bool
+
+// sprCmd represents the spr command
+var sprCmd = &cobra.Command{
+	Use:   "spr",
+	Short: "Generates SPR neighbors from a given tree",
+	Long: `Generates SPR (Subtree Pruning and Regrafting) neighbors from a given tree.
+
+Each subtree is pruned and regrafted on every branch of the remaining tree
+located at most at --radius branches from the pruning point (0: no limit).
+
+If --sample is > 0, then only the given number of neighbors are randomly
+sampled (uniformly among all generated neighbors) and written.
+
+If --unique is given, then the same neighbor topology is written only once
+(it may be generated several times by different moves).
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err = writeRearrangements(&tree.SPRRearranger{MaxRadius: rearrangeradius}); err != nil {
+			io.LogError(err)
+		}
+		return
+	},
+}
+
+// tbrCmd represents the tbr command
+var tbrCmd = &cobra.Command{
+	Use:   "tbr",
+	Short: "Generates TBR neighbors from a given tree",
+	Long: `Generates TBR (Tree Bisection and Reconnection) neighbors from a given tree.
+
+The tree is bisected on each branch, and the two subtrees are reconnected by
+every pair of branches (one in each subtree) located at most at --radius
+branches from the bisection point (0: no limit).
+
+If --sample is > 0, then only the given number of neighbors are randomly
+sampled (uniformly among all generated neighbors) and written.
+
+If --unique is given, then the same neighbor topology is written only once
+(it may be generated several times by different moves).
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err = writeRearrangements(&tree.TBRRearranger{MaxRadius: rearrangeradius}); err != nil {
+			io.LogError(err)
+		}
+		return
+	},
+}
+
+// Writes all (or a random sample of) neighbors of input trees
+// given by the rearranger
+func writeRearrangements(r tree.Rearranger) (err error) {
+	var f *os.File
+	var treefile goio.Closer
+	var treechan <-chan tree.Trees
+
+	if treefile, treechan, err = readTrees(intreefile); err != nil {
+		return
+	}
+	defer treefile.Close()
+
+	if f, err = openWriteFile(outtreefile); err != nil {
+		return
+	}
+	defer closeWriteFile(f, outtreefile)
+
+	for t := range treechan {
+		if t.Err != nil {
+			return t.Err
+		}
+		if rearrangeunique {
+			if err = t.Tree.ReinitIndexes(); err != nil {
+				return
+			}
+		}
+		seen := make(map[string]bool)
+		// Applies the rearrangement, and writes the tree
+		// if it has not been already seen
+		write := func(re tree.Rearrangement) (err error) {
+			if err = re.Apply(); err != nil {
+				return
+			}
+			if err = t.Tree.CheckTreePostOrder(); err != nil {
+				return
+			}
+			if !rearrangeunique {
+				f.WriteString(t.Tree.Newick() + "\n")
+			} else {
+				if err = t.Tree.UpdateBitSet(); err != nil {
+					return
+				}
+				key := topologyKey(t.Tree)
+				if !seen[key] {
+					seen[key] = true
+					f.WriteString(t.Tree.Newick() + "\n")
+				}
+			}
+			return re.Undo()
+		}
+
+		if rearrangesample > 0 {
+			// Reservoir sampling of the rearrangements
+			sample := make([]tree.Rearrangement, 0, rearrangesample)
+			nb := 0
+			r.Rearrange(t.Tree, func(re tree.Rearrangement) bool {
+				nb++
+				if len(sample) < rearrangesample {
+					sample = append(sample, re)
+				} else if j := globalRand.Intn(nb); j < rearrangesample {
+					sample[j] = re
+				}
+				return true
+			})
+			for _, re := range sample {
+				if err = write(re); err != nil {
+					return
+				}
+			}
+		} else {
+			r.Rearrange(t.Tree, func(re tree.Rearrangement) bool {
+				err = write(re)
+				return err == nil
+			})
+		}
+		if err != nil {
+			return
+		}
+		if rearrangeunique {
+			if err = t.Tree.UpdateBitSet(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Key identifying the unrooted topology of the tree: sorted list of
+// its bipartitions. Bitsets must be up to date.
+func topologyKey(t *tree.Tree) string {
+	keys := make([]string, 0)
+	for _, e := range t.Edges() {
+		// The side of the bipartition not containing the first tip
+		bs := e.Bitset()
+		first := bs.Test(0)
+		var sb strings.Builder
+		for i := uint(0); i < bs.Len(); i++ {
+			if bs.Test(i) != first {
+				sb.WriteByte('1')
+			} else {
+				sb.WriteByte('0')
+			}
+		}
+		keys = append(keys, sb.String())
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func init() {
+	RootCmd.AddCommand(sprCmd)
+	RootCmd.AddCommand(tbrCmd)
+	for _, c := range []*cobra.Command{sprCmd, tbrCmd} {
+		c.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input Tree")
+		c.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Neighbor output tree file")
+		c.PersistentFlags().IntVar(&rearrangeradius, "radius", 0, "Maximum regrafting radius (number of branches from the pruning point, 0: no limit)")
+		c.PersistentFlags().IntVar(&rearrangesample, "sample", 0, "Number of neighbors to randomly sample (0: all neighbors)")
+		c.PersistentFlags().BoolVar(&rearrangeunique, "unique", false, "Write each neighbor topology only once")
+	}
+}
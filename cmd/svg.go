@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	goio "io"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/evolbioinfo/gotree/draw"
 	"github.com/evolbioinfo/gotree/io"
@@ -16,6 +18,7 @@ var svgwidth int
 var svgheight int
 var svgradial bool
 var svgcircular bool
+var svgdaylight bool
 
 // svgCmd represents the svg command
 var svgCmd = &cobra.Command{
@@ -32,12 +35,30 @@ var svgCmd = &cobra.Command{
 		var metaShapes []draw.Shape
 		var metaValues map[string][]draw.TipMetaColor
 		var metaLegend []draw.LegendEntry
+		var branchColors map[*tree.Node]draw.TipMetaColor
+		var branchLegend []draw.LegendEntry
+		var highlights []draw.CladeHighlight
+		var collapsed []draw.CollapsedClade
+		var labelStyle draw.CladeLabelStyle
+		var panels []draw.TipPanel
+		var panelLegend []draw.LegendEntry
+		var timeAxis bool
+		var rootDate float64
+		var dateFormat draw.DateFormat
 
 		if metaFields, metaShapes, metaValues, metaLegend, err = loadTipMetadata(); err != nil {
 			io.LogError(err)
 			return err
 		}
-		legendW, legendH := draw.LegendSize(metaLegend, draw.SvgTextWidth)
+		if panels, panelLegend, err = loadTipPanels(); err != nil {
+			io.LogError(err)
+			return err
+		}
+		if len(panels) > 0 && (svgradial || svgdaylight) {
+			err = errors.New("heatmaps and bar charts are only drawn in normal and circular layouts")
+			io.LogError(err)
+			return err
+		}
 
 		ntree := 0
 		if treefile, treechan, err = readTrees(intreefile); err != nil {
@@ -58,6 +79,31 @@ var svgCmd = &cobra.Command{
 				}
 				fname = fmt.Sprintf(fname+"_%03d.svg", ntree)
 			}
+
+			if branchColors, branchLegend, highlights, err = loadBranchColoring(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if collapsed, labelStyle, err = loadCollapsedClades(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if len(collapsed) > 0 && (svgradial || svgdaylight) {
+				err = errors.New("collapsed clades are only drawn in normal and circular layouts")
+				io.LogError(err)
+				return err
+			}
+			if timeAxis, rootDate, dateFormat, err = loadTimeAxis(t.Tree); err != nil {
+				io.LogError(err)
+				return err
+			}
+			if timeAxis && (svgradial || svgdaylight) {
+				err = errors.New("time axes are only drawn in normal and circular layouts")
+				io.LogError(err)
+				return err
+			}
+			legendW, legendH := draw.LegendSize(slices.Concat(metaLegend, branchLegend, panelLegend), draw.SvgTextWidth)
+
 			if f, err = openWriteFile(fname); err != nil {
 				io.LogError(err)
 				return
@@ -65,7 +111,7 @@ var svgCmd = &cobra.Command{
 
 			margin := 30
 
-			if svgradial || svgcircular {
+			if svgradial || svgdaylight || svgcircular {
 				// Radial/circular tip labels fan out in every direction (not
 				// just rightward like the normal layout), so the margin must
 				// be wide enough on all four sides for the longest one,
@@ -76,19 +122,28 @@ var svgCmd = &cobra.Command{
 						maxTipNameWidth = w
 					}
 				}
+				for _, c := range collapsed {
+					if w := draw.SvgTextWidth(c.Label, false); w > maxTipNameWidth {
+						maxTipNameWidth = w
+					}
+				}
 				if radial := draw.RadialLabelMargin(maxTipNameWidth, !drawNoTipLabels, len(metaFields)); radial > margin {
 					margin = radial
 				}
 			}
 
-			if svgradial {
+			if svgradial || svgdaylight {
 				if err = t.Tree.ReinitIndexes(); err != nil {
 					io.LogError(err)
 					return
 				}
 
 				d = draw.NewSvgTreeDrawer(f, min(svgwidth, svgheight), min(svgwidth, svgheight), margin, margin, margin, margin, legendW, legendH)
-				l = draw.NewRadialLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				if svgdaylight {
+					l = draw.NewDaylightLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				} else {
+					l = draw.NewRadialLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
+				}
 				l.SetDisplayInternalNodes(drawInternalNodeSymbols)
 			} else if svgcircular {
 				d = draw.NewSvgTreeDrawer(f, min(svgwidth, svgheight), min(svgwidth, svgheight), margin, margin, margin, margin, legendW, legendH)
@@ -103,8 +158,22 @@ var svgCmd = &cobra.Command{
 			if len(metaFields) > 0 {
 				l.SetTipMetadata(metaFields, metaShapes, metaValues, metaLegend)
 			}
-			l.DrawTree(t.Tree)
+			if branchColors != nil {
+				l.SetBranchColors(branchColors, branchLegend)
+			}
+			l.SetCladeHighlights(highlights)
+			l.SetCollapsedClades(collapsed, labelStyle)
+			if timeAxis {
+				l.SetTimeAxis(rootDate, dateFormat)
+			}
+			l.SetScaleBar(drawScaleBar)
+			l.SetTipPanels(panels)
+			err = l.DrawTree(t.Tree)
 			closeWriteFile(f, fname)
+			if err != nil {
+				io.LogError(err)
+				return
+			}
 			ntree++
 		}
 		return
@@ -116,5 +185,6 @@ func init() {
 	svgCmd.PersistentFlags().IntVarP(&svgwidth, "width", "w", 200, "Width of svg image in pixels")
 	svgCmd.PersistentFlags().IntVarP(&svgheight, "height", "H", 200, "Height of svg image in pixels")
 	svgCmd.PersistentFlags().BoolVarP(&svgradial, "radial", "r", false, "Radial layout (default : normal)")
+	svgCmd.PersistentFlags().BoolVar(&svgdaylight, "daylight", false, "Equal-daylight unrooted layout: radial layout whose subtrees are rotated to even out the gaps between them (default : normal)")
 	svgCmd.PersistentFlags().BoolVarP(&svgcircular, "circular", "c", false, "Circular/Polar layout (default : normal)")
 }
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/draw"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var tanglegramformat string
+var tanglegramwidth int
+var tanglegramheight int
+var tanglegramnountangle bool
+var tanglegramhighlight bool
+var tanglegramfillbackground bool
+
+// tanglegramCmd represents the tanglegram command
+var tanglegramCmd = &cobra.Command{
+	Use:   "tanglegram",
+	Short: "Draw two trees face to face, linking their common tips",
+	Long: `Draw two trees face to face, linking their common tips.
+
+The first tree is given with -i and the second with -c. If -c is not given,
+the first two trees of the -i file are drawn.
+
+Tips are linked by name. By default, internal nodes of both trees are rotated
+so that links cross as little as possible (--no-untangle to disable).
+With --highlight-diff, branches defining splits that are not in the other tree
+are highlighted by a circle (trees must have the same tips).
+
+Output format is svg (default) or png (--output-format png).
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var t1, t2 *tree.Tree
+		var d draw.TreeDrawer
+		var widthFn draw.TextWidthFunc
+
+		if t1, t2, err = readTanglegramTrees(); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		switch tanglegramformat {
+		case "svg":
+			d = draw.NewSvgTreeDrawer(f, tanglegramwidth, tanglegramheight, 30, 30, 30, 30, 0, 0)
+			widthFn = draw.SvgTextWidth
+		case "png":
+			d = draw.NewPngTreeDrawer(f, tanglegramwidth, tanglegramheight, 30, 30, 30, 30, tanglegramfillbackground, 0, 0)
+			widthFn = draw.PngTextWidth
+		default:
+			err = fmt.Errorf("unknown output format %s (must be svg or png)", tanglegramformat)
+			io.LogError(err)
+			return
+		}
+
+		l := draw.NewTanglegramLayout(d, widthFn, !drawNoBranchLengths, !drawNoTipLabels)
+		l.SetUntangle(!tanglegramnountangle)
+		l.SetHighlightDifferences(tanglegramhighlight)
+		if err = l.DrawTrees(t1, t2); err != nil {
+			io.LogError(err)
+		}
+		return
+	},
+}
+
+// Reads the two trees of the tanglegram: the first tree of -i and
+// the first tree of -c, or the first two trees of -i if -c is not given
+func readTanglegramTrees() (t1, t2 *tree.Tree, err error) {
+	if intree2file != "none" {
+		if t1, err = readTree(intreefile); err != nil {
+			return
+		}
+		t2, err = readTree(intree2file)
+		return
+	}
+
+	var treefile goio.Closer
+	var treechan <-chan tree.Trees
+	if treefile, treechan, err = readTrees(intreefile); err != nil {
+		return
+	}
+	defer treefile.Close()
+	for t := range treechan {
+		if t.Err != nil {
+			err = t.Err
+			return
+		}
+		if t1 == nil {
+			t1 = t.Tree
+		} else if t2 == nil {
+			t2 = t.Tree
+		}
+	}
+	if t2 == nil {
+		err = errors.New("two trees are needed to draw a tanglegram")
+	}
+	return
+}
+
+func init() {
+	drawCmd.AddCommand(tanglegramCmd)
+	tanglegramCmd.PersistentFlags().StringVarP(&intree2file, "compared", "c", "none", "Second tree input file (default: second tree of the -i file)")
+	tanglegramCmd.PersistentFlags().StringVar(&tanglegramformat, "output-format", "svg", "Output format: svg or png")
+	tanglegramCmd.PersistentFlags().IntVarP(&tanglegramwidth, "width", "w", 600, "Width of the image in pixels")
+	tanglegramCmd.PersistentFlags().IntVarP(&tanglegramheight, "height", "H", 400, "Height of the image in pixels")
+	tanglegramCmd.PersistentFlags().BoolVar(&tanglegramnountangle, "no-untangle", false, "Do not rotate internal nodes to minimize crossing links")
+	tanglegramCmd.PersistentFlags().BoolVar(&tanglegramhighlight, "highlight-diff", false, "Highlight branches whose splits are not in the other tree")
+	tanglegramCmd.PersistentFlags().BoolVar(&tanglegramfillbackground, "fill-background", false, "If true, then background is white, otherwise transparent (png)")
+}
@@ -0,0 +1,251 @@
+package draw
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// defaultHighlightAlpha is the opacity given to clade highlight colors
+// specified without an alpha channel (#rrggbb), so that branches remain
+// visible on top of the shade.
+const defaultHighlightAlpha = 0x40
+
+// CladeHighlight is a background shade drawn behind the subtree rooted at Node.
+type CladeHighlight struct {
+	Node       *tree.Node
+	R, G, B, A uint8
+}
+
+// ResolveBranchColors computes, for each node of the tree, the color of the
+// branch above it, from the value of the given node annotation, and the
+// legend entry describing the color scheme.
+//
+// The annotation is one of:
+//   - "name": the node name,
+//   - "comment": the first node comment (e.g. ancestral states from gotree acr),
+//   - "support" or "length": the support or the length of the branch above the node,
+//   - any other key: the node attribute of that key (see tree.Node.Attribute),
+//     or if absent, the attribute of the branch above the node.
+//
+// Values are auto-detected as discrete or continuous, and colors are
+// assigned exactly as for tip metadata fields (see ResolveTipMetadata):
+// overrides is indexed by annotation name and may be nil. Nodes without
+// value get an Empty color and are drawn in black.
+func ResolveBranchColors(t *tree.Tree, annotation string, overrides map[string]FieldColorSpec) (map[*tree.Node]TipMetaColor, LegendEntry, error) {
+	nodes := t.Nodes()
+	keys := make([]string, len(nodes))
+	raw := make(map[string]map[string]string, len(nodes))
+	for i, n := range nodes {
+		keys[i] = strconv.Itoa(i)
+		raw[keys[i]] = map[string]string{annotation: nodeAnnotation(n, annotation)}
+	}
+	fields := []string{annotation}
+	shapes := ResolveFieldShapes(fields, overrides)
+	if override, ok := overrides[annotation]; !ok || !override.HasShape {
+		shapes[0] = ShapeSquare
+	}
+
+	values, legend, err := ResolveTipMetadata(fields, keys, raw, overrides, shapes)
+	if err != nil {
+		return nil, LegendEntry{}, err
+	}
+	colors := make(map[*tree.Node]TipMetaColor, len(nodes))
+	for i, n := range nodes {
+		colors[n] = values[keys[i]][0]
+	}
+	return colors, legend[0], nil
+}
+
+// Value of the annotation for the given node, "" if it has none
+func nodeAnnotation(n *tree.Node, annotation string) string {
+	var e *tree.Edge
+	if p, err := n.ParentEdge(); err == nil {
+		e = p
+	}
+	switch annotation {
+	case "name":
+		return n.Name()
+	case "comment":
+		if c := n.Comments(); len(c) > 0 {
+			return c[0]
+		}
+		return ""
+	case "support":
+		if e != nil && e.Support() != tree.NIL_SUPPORT {
+			return e.SupportString()
+		}
+		return ""
+	case "length":
+		if e != nil && e.Length() != tree.NIL_LENGTH {
+			return e.LengthString()
+		}
+		return ""
+	}
+	if a, ok := n.Attribute(annotation); ok {
+		return a.Value
+	}
+	if e != nil {
+		if a, ok := e.Attribute(annotation); ok {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// ResolveCladeHighlights parses clade highlight specifications, each of the
+// form "name" or "name=#rrggbb[aa]", name being the name of the node at the
+// root of the clade. Colors without alpha channel are made semi-transparent,
+// and clades without color are given colors of the default palette.
+func ResolveCladeHighlights(t *tree.Tree, specs []string) (highlights []CladeHighlight, err error) {
+	nodes := make(map[string]*tree.Node)
+	for _, n := range t.Nodes() {
+		if n.Name() != "" {
+			nodes[n.Name()] = n
+		}
+	}
+	for i, spec := range specs {
+		name, color, hasColor := strings.Cut(spec, "=")
+		n, ok := nodes[name]
+		if !ok {
+			return nil, fmt.Errorf("clade highlight: no node named %q in the tree", name)
+		}
+		if !hasColor {
+			color = defaultPalette[i%len(defaultPalette)]
+		}
+		h := CladeHighlight{Node: n}
+		if h.R, h.G, h.B, h.A, err = parseHexColor(color); err != nil {
+			return nil, fmt.Errorf("clade highlight %q: %w", spec, err)
+		}
+		if len(color) == 7 {
+			h.A = defaultHighlightAlpha
+		}
+		highlights = append(highlights, h)
+	}
+	return
+}
+
+// Color of the branch above n, and whether it is colored
+func branchColor(colors map[*tree.Node]TipMetaColor, n *tree.Node) (c TipMetaColor, ok bool) {
+	if c, ok = colors[n]; ok && !c.Empty {
+		return c, true
+	}
+	return c, false
+}
+
+// Sets the drawer line color to the color of the branch above n,
+// or to black if it has no color
+func setBranchColor(d TreeDrawer, colors map[*tree.Node]TipMetaColor, n *tree.Node) {
+	if c, ok := branchColor(colors, n); ok {
+		d.SetLineColor(c.R, c.G, c.B, c.A)
+	} else {
+		d.SetLineColor(0, 0, 0, 0xff)
+	}
+}
+
+// Draws the clade highlight polygons, translated by the given offsets
+func drawHighlights(d TreeDrawer, highlights []*layoutPolygon, xoffset, yoffset float64) {
+	for _, p := range highlights {
+		xs := make([]float64, len(p.xs))
+		ys := make([]float64, len(p.ys))
+		for i := range p.xs {
+			xs[i], ys[i] = p.xs[i]+xoffset, p.ys[i]+yoffset
+		}
+		d.DrawPolygon(xs, ys, p.r, p.g, p.b, p.a)
+	}
+}
+
+// Legend entries of the tip metadata followed by the branch colors
+// (and the tip panels)
+func legendEntries(groups ...[]LegendEntry) []LegendEntry {
+	entries := make([]LegendEntry, 0)
+	for _, g := range groups {
+		entries = append(entries, g...)
+	}
+	return entries
+}
+
+// Returns the layout points of the nodes of the subtree rooted at n,
+// and the corners of its collapsed clade triangles, the tree being
+// traversed from its root
+func subtreePoints(t *tree.Tree, n *tree.Node, cache *layoutCache) (sub []*layoutPoint) {
+	var recur func(cur, prev *tree.Node, inside bool)
+	recur = func(cur, prev *tree.Node, inside bool) {
+		inside = inside || cur == n
+		if p, ok := cache.points[cur]; ok && inside {
+			sub = append(sub, p)
+		}
+		for _, tr := range cache.triangles {
+			if tr.node == cur && inside {
+				sub = append(sub, tr.corners...)
+			}
+		}
+		for _, c := range cur.Neigh() {
+			if c != prev {
+				recur(c, cur, inside)
+			}
+		}
+	}
+	recur(t.Root(), nil, false)
+	return
+}
+
+// Convex hull of the points (monotone chain), in counter clockwise order
+func convexHull(points []*layoutPoint) (xs, ys []float64) {
+	pts := make([]*layoutPoint, len(points))
+	copy(pts, points)
+	sort.Slice(pts, func(i, j int) bool {
+		return pts[i].x < pts[j].x || (pts[i].x == pts[j].x && pts[i].y < pts[j].y)
+	})
+	if len(pts) < 3 {
+		for _, p := range pts {
+			xs = append(xs, p.x)
+			ys = append(ys, p.y)
+		}
+		return
+	}
+	cross := func(o, a, b *layoutPoint) float64 {
+		return (a.x-o.x)*(b.y-o.y) - (a.y-o.y)*(b.x-o.x)
+	}
+	hull := make([]*layoutPoint, 0, 2*len(pts))
+	for _, p := range pts {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(pts) - 2; i >= 0; i-- {
+		p := pts[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	for _, p := range hull[:len(hull)-1] {
+		xs = append(xs, p.x)
+		ys = append(ys, p.y)
+	}
+	return
+}
+
+// Annular sector between the two radii and the two angles,
+// approximated by a polygon, centered on (0,0)
+func annularSector(r1, r2, angle1, angle2 float64) (xs, ys []float64) {
+	nsteps := int(math.Ceil((angle2-angle1)/(math.Pi/90))) + 1
+	for i := 0; i <= nsteps; i++ {
+		a := angle1 + (angle2-angle1)*float64(i)/float64(nsteps)
+		xs = append(xs, r2*math.Cos(a))
+		ys = append(ys, r2*math.Sin(a))
+	}
+	for i := nsteps; i >= 0; i-- {
+		a := angle1 + (angle2-angle1)*float64(i)/float64(nsteps)
+		xs = append(xs, r1*math.Cos(a))
+		ys = append(ys, r1*math.Sin(a))
+	}
+	return
+}
@@ -0,0 +1,153 @@
+package draw
+
+import (
+	"testing"
+)
+
+const branchColorTree = "((A[&state=X]:1,B[&state=X]:1)AB[&state=X]:1,((C[&state=Y]:1,D[&state=Y]:0.5)CD[&state=Y]:1,E:2)CDE[&state=Y]:1)root[&state=X];"
+
+func TestResolveBranchColors_Discrete(t *testing.T) {
+	tr := parseTestTree(t, branchColorTree)
+	colors, legend, err := ResolveBranchColors(tr, "state", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nodes := make(map[string]TipMetaColor)
+	for n, c := range colors {
+		nodes[n.Name()] = c
+	}
+	if nodes["A"] != nodes["AB"] || nodes["A"].Empty {
+		t.Errorf("expected A and AB to share the same state color, got %+v vs %+v", nodes["A"], nodes["AB"])
+	}
+	if nodes["C"] == nodes["A"] {
+		t.Errorf("expected different colors for states X and Y")
+	}
+	if !nodes["E"].Empty {
+		t.Errorf("expected E (no state) to have an empty color, got %+v", nodes["E"])
+	}
+	if legend.Field != "state" || len(legend.Values) != 2 || legend.Shape != ShapeSquare {
+		t.Errorf("unexpected legend: %+v", legend)
+	}
+}
+
+func TestResolveBranchColors_Continuous(t *testing.T) {
+	tr := parseTestTree(t, branchColorTree)
+	colors, legend, err := ResolveBranchColors(tr, "length", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(legend.Values) != 2 || legend.Values[0].Label != "0.5" || legend.Values[1].Label != "2" {
+		t.Errorf("expected a min/max legend for branch lengths, got %+v", legend.Values)
+	}
+	low := mustParseHex(t, defaultContinuousLow)
+	high := mustParseHex(t, defaultContinuousHigh)
+	for n, c := range colors {
+		switch n.Name() {
+		case "D":
+			if c.R != low.R || c.G != low.G || c.B != low.B {
+				t.Errorf("expected shortest branch to get the low color, got %+v", c)
+			}
+		case "E":
+			if c.R != high.R || c.G != high.G || c.B != high.B {
+				t.Errorf("expected longest branch to get the high color, got %+v", c)
+			}
+		case "root":
+			if !c.Empty {
+				t.Errorf("expected root (no branch) to have an empty color, got %+v", c)
+			}
+		}
+	}
+}
+
+func TestResolveCladeHighlights(t *testing.T) {
+	tr := parseTestTree(t, branchColorTree)
+	h, err := ResolveCladeHighlights(tr, []string{"CD=#ff0000", "AB=#00ff0080", "CDE"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(h) != 3 {
+		t.Fatalf("expected 3 highlights, got %d", len(h))
+	}
+	if h[0].Node.Name() != "CD" || h[0].R != 0xff || h[0].A != defaultHighlightAlpha {
+		t.Errorf("unexpected first highlight: %+v", h[0])
+	}
+	if h[1].G != 0xff || h[1].A != 0x80 {
+		t.Errorf("expected explicit alpha to be kept, got %+v", h[1])
+	}
+	want := mustParseHex(t, defaultPalette[2])
+	if h[2].R != want.R || h[2].G != want.G || h[2].B != want.B || h[2].A != defaultHighlightAlpha {
+		t.Errorf("expected default palette color, got %+v", h[2])
+	}
+
+	if _, err = ResolveCladeHighlights(tr, []string{"XYZ"}); err == nil {
+		t.Errorf("expected an error for an unknown node")
+	}
+	if _, err = ResolveCladeHighlights(tr, []string{"CD=red"}); err == nil {
+		t.Errorf("expected an error for a malformed color")
+	}
+}
+
+func TestBranchColors_Draw(t *testing.T) {
+	for _, layout := range []string{"normal", "circular", "radial"} {
+		tr := parseTestTree(t, branchColorTree)
+		// Required by the radial layout
+		if err := tr.ReinitIndexes(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		colors, legend, err := ResolveBranchColors(tr, "state", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		h, err := ResolveCladeHighlights(tr, []string{"CD=#ff0000"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d := newRecordingDrawer(400, 400)
+		var l TreeLayout
+		switch layout {
+		case "normal":
+			l = NewNormalLayout(d, true, true, false, false)
+		case "circular":
+			l = NewCircularLayout(d, true, true, false, false)
+		default:
+			l = NewRadialLayout(d, true, true, false, false)
+		}
+		l.SetBranchColors(colors, []LegendEntry{legend})
+		l.SetCladeHighlights(h)
+		if err = l.DrawTree(tr); err != nil {
+			t.Fatalf("%s: unexpected error: %v", layout, err)
+		}
+
+		// Branches of the tips, colored with their state, or black
+		branches := make(map[string]recordedLine)
+		for _, n := range tr.Tips() {
+			p := d.name(t, n.Name())
+			branches[n.Name()] = d.lineAt(t, p.x, p.y)
+			want := recordedColor{0, 0, 0, 0xff}
+			if c := colors[n]; !c.Empty {
+				want = colorOf(c)
+			}
+			if branches[n.Name()].color != want {
+				t.Errorf("%s: expected the branch of %s to be drawn with color %v, got %v", layout, n.Name(), want, branches[n.Name()].color)
+			}
+		}
+		if branches["A"].color == branches["C"].color {
+			t.Errorf("%s: expected the branches of A and C to have different colors", layout)
+		}
+
+		// Highlight drawn behind the branches of C and D only
+		if len(d.polygons) != 1 || d.polygons[0].color != (recordedColor{0xff, 0, 0, defaultHighlightAlpha}) {
+			t.Fatalf("%s: expected a semi-transparent red highlight, got %v", layout, d.polygons)
+		}
+		for name, b := range branches {
+			in := d.polygons[0].contains((b.x1+b.x2)/2, (b.y1+b.y2)/2)
+			if in != (name == "C" || name == "D") {
+				t.Errorf("%s: unexpected highlight of the branch of %s: %t", layout, name, in)
+			}
+		}
+
+		if len(d.legend) != 1 || d.legend[0].Field != "state" {
+			t.Errorf("%s: expected a legend for the branch colors, got %+v", layout, d.legend)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package draw
 
 import (
 	"math"
+
+	"github.com/evolbioinfo/gotree/tree"
 )
 
 /* Cache for lines and points to draw the tree */
@@ -12,6 +14,9 @@ type layoutCache struct {
 	curvePaths      []*layoutCurve
 	verticalPaths   []*layoutVLine
 	horizontalPaths []*layoutHLine
+	highlights      []*layoutPolygon
+	triangles       []*layoutTriangle
+	points          map[*tree.Node]*layoutPoint // Position of each node
 }
 
 type layoutPoint struct {
@@ -26,18 +31,21 @@ type layoutLine struct {
 	p1      *layoutPoint
 	p2      *layoutPoint
 	support float64
+	node    *tree.Node // node whose color is used to draw the line
 }
 
 type layoutVLine struct {
 	x       float64
 	y1, y2  float64
 	support float64
+	node    *tree.Node
 }
 
 type layoutHLine struct {
 	x1, x2  float64
 	y       float64
 	support float64
+	node    *tree.Node
 }
 
 type layoutCurve struct {
@@ -46,6 +54,21 @@ type layoutCurve struct {
 	radius      float64      // radius of the circle
 	startAngle  float64
 	endAngle    float64
+	node        *tree.Node
+}
+
+// Filled background polygon (e.g. clade highlight)
+type layoutPolygon struct {
+	xs, ys     []float64
+	r, g, b, a uint8
+}
+
+// Collapsed clade triangle (a fan in circular layout) and its label
+type layoutTriangle struct {
+	corners    []*layoutPoint // apex, then the points of the base
+	start, end float64        // extent of the base: y (normal) or angle (circular)
+	label      *layoutPoint   // position of the side label
+	node       *tree.Node     // root of the collapsed clade
 }
 
 func newLayoutCache() *layoutCache {
@@ -56,6 +79,9 @@ func newLayoutCache() *layoutCache {
 		make([]*layoutCurve, 0),
 		make([]*layoutVLine, 0),
 		make([]*layoutHLine, 0),
+		make([]*layoutPolygon, 0),
+		make([]*layoutTriangle, 0),
+		make(map[*tree.Node]*layoutPoint),
 	}
 }
 
@@ -80,5 +106,13 @@ func (cache *layoutCache) borders() (xmin, ymin, xmax, ymax float64) {
 		ymax = math.Max(math.Max(ymax, line.y1), line.y2)
 		xmax = math.Max(xmax, line.x)
 	}
+	for _, tr := range cache.triangles {
+		for _, p := range tr.corners {
+			xmin = math.Min(xmin, p.x)
+			ymin = math.Min(ymin, p.y)
+			xmax = math.Max(xmax, p.x)
+			ymax = math.Max(ymax, p.y)
+		}
+	}
 	return
 }
@@ -1,6 +1,7 @@
 package draw
 
 import (
+	"fmt"
 	"log"
 	"math"
 
@@ -21,6 +22,16 @@ type circularLayout struct {
 	metaShapes             []Shape
 	metaValues             map[string][]TipMetaColor
 	metaLegend             []LegendEntry
+	branchColors           map[*tree.Node]TipMetaColor
+	branchLegend           []LegendEntry
+	highlights             []CladeHighlight
+	collapsed              []CollapsedClade
+	cladeLabelStyle        CladeLabelStyle
+	timeAxis               bool
+	rootDate               float64
+	dateFormat             DateFormat
+	scaleBar               bool
+	panels                 []TipPanel
 }
 
 /*
@@ -61,16 +72,55 @@ func (layout *circularLayout) SetTipMetadata(fields []string, shapes []Shape, va
 	layout.metaLegend = legend
 }
 
+func (layout *circularLayout) SetBranchColors(colors map[*tree.Node]TipMetaColor, legend []LegendEntry) {
+	layout.branchColors = colors
+	layout.branchLegend = legend
+}
+
+func (layout *circularLayout) SetCladeHighlights(highlights []CladeHighlight) {
+	layout.highlights = highlights
+}
+
+func (layout *circularLayout) SetCollapsedClades(clades []CollapsedClade, style CladeLabelStyle) {
+	layout.collapsed = clades
+	layout.cladeLabelStyle = style
+}
+
+func (layout *circularLayout) SetTimeAxis(rootDate float64, format DateFormat) {
+	layout.timeAxis = true
+	layout.rootDate = rootDate
+	layout.dateFormat = format
+}
+
+func (layout *circularLayout) SetScaleBar(s bool) {
+	layout.scaleBar = s
+}
+
+func (layout *circularLayout) SetTipPanels(panels []TipPanel) {
+	layout.panels = panels
+}
+
 /*
 Draw the tree on the specific drawer. Does not close the file. The caller must do it.
 */
 func (layout *circularLayout) DrawTree(t *tree.Tree) error {
 	var err error = nil
 	root := t.Root()
-	ntips := len(t.Tips())
+	collapsed := collapsedMap(layout.collapsed)
+	nslots := layoutSlots(root, nil, collapsed)
 	curNbTips := 0
-	layout.drawTreeRecur(root, nil, tree.NIL_SUPPORT, 0, 0, &curNbTips, ntips)
-	layout.drawTree()
+	layout.drawTreeRecur(root, nil, tree.NIL_SUPPORT, 0, 0, &curNbTips, nslots, collapsed)
+	layout.highlightClades(t, nslots)
+	// Pixels per unit of branch length: the panels are drawn around the
+	// tree, the whole drawing taking the width of the image
+	scale := 0.0
+	if len(layout.panels) > 0 {
+		width, _ := layout.drawer.Bounds()
+		if scale = (float64(width)/2.0 - tipPanelsWidth(layout.panels)) / layout.maxRadius(); scale <= 0 {
+			return fmt.Errorf("image too small to draw the tip panels")
+		}
+	}
+	layout.drawTree(nslots, scale)
 	layout.drawer.Write()
 	return err
 }
@@ -78,7 +128,7 @@ func (layout *circularLayout) DrawTree(t *tree.Tree) error {
 /*
 Recursive function that draws the tree. Returns the angle of the current node
 */
-func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, support, prevDistToRoot, distToRoot float64, curtip *int, nbtips int) float64 {
+func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, support, prevDistToRoot, distToRoot float64, curtip *int, nbtips int, collapsed map[*tree.Node]string) float64 {
 	angle := 0.0
 	if n.Tip() {
 		angle = float64(*curtip)*2*math.Pi/float64(nbtips) + math.Pi/2
@@ -86,7 +136,22 @@ func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, suppo
 		y3 := distToRoot * math.Sin(angle)
 		node := &layoutPoint{x3, y3, angle, n.Name(), n.CommentsString()}
 		layout.cache.tipLabelPoints = append(layout.cache.tipLabelPoints, node)
+		layout.cache.points[n] = node
 		*curtip++
+	} else if label, ok := collapsed[n]; ok {
+		ntips, depth := cladeSize(n, prev, layout.hasBranchLengths)
+		slots := collapsedSlots(ntips)
+		step := 2 * math.Pi / float64(nbtips)
+		angle1 := float64(*curtip)*step + math.Pi/2 - (0.5-collapsedMargin)*step
+		angle2 := float64(*curtip+slots-1)*step + math.Pi/2 + (0.5-collapsedMargin)*step
+		angle = (angle1 + angle2) / 2.0
+		r := distToRoot + depth
+		node := &layoutPoint{distToRoot * math.Cos(angle), distToRoot * math.Sin(angle), angle, n.Name(), n.CommentsString()}
+		layout.cache.points[n] = node
+		// Fan: the apex, then an arc of circle at the depth of the clade
+		layout.cache.triangles = append(layout.cache.triangles, &layoutTriangle{
+			append([]*layoutPoint{node}, arcPoints(r, angle1, angle2)...), angle1, angle2, &layoutPoint{r * math.Cos(angle), r * math.Sin(angle), angle, label, ""}, n})
+		*curtip += slots
 	} else {
 		minangle := -1.0
 		maxangle := -1.0
@@ -97,7 +162,7 @@ func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, suppo
 				if !layout.hasBranchLengths || len == tree.NIL_LENGTH {
 					len = 1.0
 				}
-				tempangle := layout.drawTreeRecur(child, n, supp, distToRoot, distToRoot+len, curtip, nbtips)
+				tempangle := layout.drawTreeRecur(child, n, supp, distToRoot, distToRoot+len, curtip, nbtips, collapsed)
 				if minangle == -1 || minangle > tempangle {
 					minangle = tempangle
 				}
@@ -112,18 +177,123 @@ func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, suppo
 		y4 := distToRoot * math.Sin(angle)
 		inode := &layoutPoint{x4, y4, angle, n.Name(), n.CommentsString()}
 		layout.cache.nodePoints = append(layout.cache.nodePoints, inode)
-		curve := &layoutCurve{&layoutPoint{0, 0, 0.0, "", ""}, inode, distToRoot, minangle, maxangle}
+		layout.cache.points[n] = inode
+		curve := &layoutCurve{&layoutPoint{0, 0, 0.0, "", ""}, inode, distToRoot, minangle, maxangle, n}
 		layout.cache.curvePaths = append(layout.cache.curvePaths, curve)
 	}
 	x1 := prevDistToRoot * math.Cos(angle)
 	y1 := prevDistToRoot * math.Sin(angle)
 	x2 := distToRoot * math.Cos(angle)
 	y2 := distToRoot * math.Sin(angle)
-	line := &layoutLine{&layoutPoint{x1, y1, angle, "", ""}, &layoutPoint{x2, y2, angle, "", ""}, support}
+	line := &layoutLine{&layoutPoint{x1, y1, angle, "", ""}, &layoutPoint{x2, y2, angle, "", ""}, support, n}
 	layout.cache.branchPaths = append(layout.cache.branchPaths, line)
 	return angle
 }
 
+// Computes the background sectors of the highlighted clades, from the
+// middle of the branch above the clade root to the farthest node of the clade
+func (layout *circularLayout) highlightClades(t *tree.Tree, nslots int) {
+	halfstep := math.Pi / float64(nslots)
+	for _, h := range layout.highlights {
+		root, ok := layout.cache.points[h.Node]
+		if !ok {
+			continue
+		}
+		r1, r2 := math.Hypot(root.x, root.y), 0.0
+		minangle, maxangle := math.Inf(1), math.Inf(-1)
+		for _, p := range subtreePoints(t, h.Node, layout.cache) {
+			r2 = math.Max(r2, math.Hypot(p.x, p.y))
+			minangle = math.Min(minangle, p.brAngle)
+			maxangle = math.Max(maxangle, p.brAngle)
+		}
+		for _, l := range layout.cache.branchPaths {
+			if l.node == h.Node {
+				r1 = (math.Hypot(l.p1.x, l.p1.y) + math.Hypot(l.p2.x, l.p2.y)) / 2.0
+			}
+		}
+		xs, ys := annularSector(r1, r2, minangle-halfstep, maxangle+halfstep)
+		layout.cache.highlights = append(layout.cache.highlights, &layoutPolygon{xs, ys, h.R, h.G, h.B, h.A})
+	}
+}
+
+// Draws the labels of the collapsed clades, either next to the triangles,
+// or after arcs of circle drawn beyond the farthest node of the tree
+func (layout *circularLayout) drawCladeLabels(xoffset, yoffset float64) {
+	rmax := layout.maxRadius()
+	bracketr := rmax * 1.02
+	tick := rmax * 0.01
+	for _, tr := range layout.cache.triangles {
+		if layout.cladeLabelStyle == CladeLabelBracket {
+			middle := (tr.start + tr.end) / 2.0
+			layout.drawer.DrawCurve(xoffset, yoffset, bracketr*math.Cos(middle)+xoffset, bracketr*math.Sin(middle)+yoffset, bracketr, tr.start, tr.end)
+			for _, a := range []float64{tr.start, tr.end} {
+				layout.drawer.DrawLine((bracketr-tick)*math.Cos(a)+xoffset, (bracketr-tick)*math.Sin(a)+yoffset, bracketr*math.Cos(a)+xoffset, bracketr*math.Sin(a)+yoffset)
+			}
+			layout.drawer.DrawName(bracketr*math.Cos(middle)+xoffset, bracketr*math.Sin(middle)+yoffset, tr.label.name, middle)
+		} else {
+			layout.drawer.DrawName(tr.label.x+xoffset, tr.label.y+yoffset, tr.label.name, tr.label.brAngle)
+		}
+	}
+}
+
+// Distance from the root to the farthest node or collapsed clade of the tree
+func (layout *circularLayout) maxRadius() (rmax float64) {
+	for _, p := range layout.cache.points {
+		rmax = math.Max(rmax, math.Hypot(p.x, p.y))
+	}
+	for _, tr := range layout.cache.triangles {
+		rmax = math.Max(rmax, math.Hypot(tr.corners[1].x, tr.corners[1].y)) // base points are at the depth of the clade
+	}
+	return
+}
+
+// Draws the time axis as grey circles centered on the root, one per date,
+// with horizontal labels where the circles cross the gap between the last
+// and the first tips (nslots tip slots)
+func (layout *circularLayout) drawTimeCircles(dates []float64, labels []string, nslots int, xoffset, yoffset float64) {
+	angle := math.Pi/2 - math.Pi/float64(nslots)
+	layout.drawer.SetLineColor(timeCircleColor.R, timeCircleColor.G, timeCircleColor.B, timeCircleColor.A)
+	for _, d := range dates {
+		r := d - layout.rootDate
+		// Two half circles, an arc can not start and end at the same point
+		layout.drawer.DrawCurve(xoffset, yoffset, xoffset, r+yoffset, r, 0, math.Pi)
+		layout.drawer.DrawCurve(xoffset, yoffset, xoffset, -r+yoffset, r, math.Pi, 2*math.Pi)
+	}
+	layout.drawer.SetLineColor(0, 0, 0, 0xff)
+	for i, d := range dates {
+		r := d - layout.rootDate
+		layout.drawer.DrawName(r*math.Cos(angle)+xoffset, r*math.Sin(angle)+yoffset, labels[i], 0.0)
+	}
+}
+
+// Draws the tip panels as rings around the tree, from radius r, scale being
+// the number of pixels per unit of radius (nslots tip slots)
+func (layout *circularLayout) drawPanels(r, scale float64, nslots int, xoffset, yoffset float64) {
+	halfstep := math.Pi / float64(nslots)
+	for _, tr := range panelTracks(layout.panels) {
+		r1 := r + tr.offset/scale
+		m := tr.margin() * 2 * halfstep
+		for _, p := range layout.cache.tipLabelPoints {
+			if frac, c, ok := tr.cell(p.name); ok {
+				xs, ys := annularSector(r1, r1+frac*tr.width/scale, p.brAngle-halfstep+m, p.brAngle+halfstep-m)
+				for i := range xs {
+					xs[i], ys[i] = xs[i]+xoffset, ys[i]+yoffset
+				}
+				layout.drawer.DrawPolygon(xs, ys, c.R, c.G, c.B, c.A)
+			}
+		}
+	}
+}
+
+// Position of the label of the tip: the tip itself, or the point at
+// radius r in the direction of the tip if r > 0 (tip panels)
+func tipAnchor(p *layoutPoint, r float64) (x, y float64) {
+	if r > 0 {
+		return r * math.Cos(p.brAngle), r * math.Sin(p.brAngle)
+	}
+	return p.x, p.y
+}
+
 // drawTree renders the accumulated geometry. It does not shrink the
 // drawable area for tip labels the way normalLayout does (via
 // TreeDrawer.SetMaxValues' maxNameLength/maxNameHeight): circular labels
@@ -131,8 +301,30 @@ func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, suppo
 // buffer can't keep them inside the image. Instead, the caller is
 // expected to have sized this drawer's margins (via RadialLabelMargin)
 // generously enough on all four sides before construction.
-func (layout *circularLayout) drawTree() {
+func (layout *circularLayout) drawTree(nslots int, scale float64) {
 	xmin, ymin, xmax, ymax := layout.cache.borders()
+	rtips := layout.maxRadius()
+	// Outer radius of the tip panels, where tip labels start
+	rlabels := 0.0
+	if scale > 0 {
+		rlabels = rtips + tipPanelsWidth(layout.panels)/scale
+		xmin, ymin = math.Min(xmin, -rlabels), math.Min(ymin, -rlabels)
+		xmax, ymax = math.Max(xmax, rlabels), math.Max(ymax, rlabels)
+	}
+	var dates []float64
+	var labels []string
+	if layout.timeAxis {
+		dates, labels = timeTicks(layout.rootDate, layout.rootDate+layout.maxRadius(), layout.dateFormat, timeAxisTicks)
+		// Dates at the root would give empty circles
+		if len(dates) > 0 && dates[0]-layout.rootDate < 1e-9 {
+			dates, labels = dates[1:], labels[1:]
+		}
+		if len(dates) > 0 {
+			r := dates[len(dates)-1] - layout.rootDate
+			xmin, ymin = math.Min(xmin, -r), math.Min(ymin, -r)
+			xmax, ymax = math.Max(xmax, r), math.Max(ymax, r)
+		}
+	}
 	xoffset := 0.0
 	if xmin < 0 {
 		xoffset = -xmin
@@ -145,12 +337,24 @@ func (layout *circularLayout) drawTree() {
 	max := math.Max(xmax+xoffset, ymax+yoffset)
 	layout.drawer.SetMaxValues(max, max, 0, 0)
 
+	drawHighlights(layout.drawer, layout.cache.highlights, xoffset, yoffset)
+	if len(dates) > 0 {
+		layout.drawTimeCircles(dates, labels, nslots, xoffset, yoffset)
+	}
 	for _, l := range layout.cache.branchPaths {
+		setBranchColor(layout.drawer, layout.branchColors, l.node)
 		layout.drawer.DrawLine(l.p1.x+xoffset, l.p1.y+yoffset, l.p2.x+xoffset, l.p2.y+yoffset)
 	}
 	for _, c := range layout.cache.curvePaths {
+		setBranchColor(layout.drawer, layout.branchColors, c.node)
 		layout.drawer.DrawCurve(c.center.x+xoffset, c.center.y+yoffset, c.middlepoint.x+xoffset, c.middlepoint.y+yoffset, c.radius, c.startAngle, c.endAngle)
 	}
+	layout.drawer.SetLineColor(0, 0, 0, 0xff)
+	drawTriangles(layout.drawer, layout.cache.triangles, layout.branchColors, xoffset, yoffset)
+	layout.drawCladeLabels(xoffset, yoffset)
+	if scale > 0 {
+		layout.drawPanels(rtips, scale, nslots, xoffset, yoffset)
+	}
 
 	if len(layout.metaFields) > 0 {
 		layout.drawer.SetTipLabelOffset(metaLabelOffset(len(layout.metaFields)))
@@ -158,10 +362,11 @@ func (layout *circularLayout) drawTree() {
 
 	if layout.hasTipLabels {
 		for _, p := range layout.cache.tipLabelPoints {
+			x, y := tipAnchor(p, rlabels)
 			if layout.hasNodeComments {
-				layout.drawer.DrawName(p.x+xoffset, p.y+yoffset, p.name+p.comment, p.brAngle)
+				layout.drawer.DrawName(x+xoffset, y+yoffset, p.name+p.comment, p.brAngle)
 			} else {
-				layout.drawer.DrawName(p.x+xoffset, p.y+yoffset, p.name, p.brAngle)
+				layout.drawer.DrawName(x+xoffset, y+yoffset, p.name, p.brAngle)
 			}
 		}
 	}
@@ -184,9 +389,10 @@ func (layout *circularLayout) drawTree() {
 	if len(layout.metaFields) > 0 {
 		for _, p := range layout.cache.tipLabelPoints {
 			if vals, ok := layout.metaValues[p.name]; ok {
+				x, y := tipAnchor(p, rlabels)
 				for i, v := range vals {
 					offset := metaBaseGap + metaCircleSpacing*float64(i)
-					layout.drawer.DrawColoredShapeAtOffset(p.x+xoffset, p.y+yoffset, p.brAngle, offset, metaShapeAt(layout.metaShapes, i), v.R, v.G, v.B, v.A, !v.Empty)
+					layout.drawer.DrawColoredShapeAtOffset(x+xoffset, y+yoffset, p.brAngle, offset, metaShapeAt(layout.metaShapes, i), v.R, v.G, v.B, v.A, !v.Empty)
 				}
 			}
 		}
@@ -200,7 +406,11 @@ func (layout *circularLayout) drawTree() {
 		}
 	}
 
-	if len(layout.metaLegend) > 0 {
-		layout.drawer.DrawLegend(layout.metaLegend)
+	if legend := legendEntries(layout.metaLegend, layout.branchLegend, panelLegends(layout.panels)); len(legend) > 0 {
+		layout.drawer.DrawLegend(legend)
+	}
+	if layout.scaleBar {
+		length := scaleBarLength(rtips)
+		layout.drawer.DrawScaleBar(length, scaleBarLabel(length))
 	}
 }
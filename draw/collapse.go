@@ -0,0 +1,192 @@
+package draw
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// CladeLabelStyle is the way labels of collapsed clades are drawn
+type CladeLabelStyle int
+
+const (
+	CladeLabelSide    CladeLabelStyle = iota // Next to the base of the triangle
+	CladeLabelBracket                        // After a bracket spanning the triangle, beyond the farthest node of the tree
+)
+
+// Fraction of a tip slot left empty on each side of a collapsed clade triangle
+const collapsedMargin = 0.1
+
+// Color of collapsed clade triangles whose branch is not colored
+var collapsedFill = TipMetaColor{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}
+
+// CollapsedClade is a clade drawn as a triangle instead of its subtree.
+type CollapsedClade struct {
+	Node  *tree.Node
+	Label string
+}
+
+// ParseCladeLabelStyle returns the label style corresponding to
+// the given name: "side" or "bracket".
+func ParseCladeLabelStyle(style string) (CladeLabelStyle, error) {
+	switch strings.ToLower(style) {
+	case "side":
+		return CladeLabelSide, nil
+	case "bracket":
+		return CladeLabelBracket, nil
+	}
+	return CladeLabelSide, fmt.Errorf("unknown clade label style %q (side or bracket)", style)
+}
+
+// ResolveCollapsedClades parses collapsed clade specifications, each of
+// the form:
+//   - "name": the clade rooted at the internal node with that name,
+//     labeled with its name,
+//   - "label<TAB>tip1,tip2,...": the smallest clade containing the given
+//     tips (the tree being considered rooted), labeled with label. A single
+//     name may also be given in place of the tips.
+//
+// Empty lines and lines starting with # are ignored.
+func ResolveCollapsedClades(t *tree.Tree, specs []string) (clades []CollapsedClade, err error) {
+	nodes := make(map[string]*tree.Node)
+	for _, n := range t.Nodes() {
+		if n.Name() != "" {
+			nodes[n.Name()] = n
+		}
+	}
+	for _, spec := range specs {
+		spec = strings.TrimRight(spec, "\r\n")
+		if strings.TrimSpace(spec) == "" || strings.HasPrefix(spec, "#") {
+			continue
+		}
+		label, members, hasMembers := strings.Cut(spec, "\t")
+		if !hasMembers {
+			members = label
+		}
+		names := strings.Split(members, ",")
+		var n *tree.Node
+		if len(names) == 1 {
+			var ok bool
+			if n, ok = nodes[strings.TrimSpace(names[0])]; !ok {
+				return nil, fmt.Errorf("collapsed clade %q: no node named %q in the tree", label, names[0])
+			}
+		} else {
+			for i, name := range names {
+				names[i] = strings.TrimSpace(name)
+				if tip, ok := nodes[names[i]]; !ok || !tip.Tip() {
+					return nil, fmt.Errorf("collapsed clade %q: no tip named %q in the tree", label, names[i])
+				}
+			}
+			if n, _, _, err = t.LeastCommonAncestorRooted(nil, names...); err != nil {
+				return nil, fmt.Errorf("collapsed clade %q: %w", label, err)
+			}
+		}
+		if n.Tip() {
+			return nil, fmt.Errorf("collapsed clade %q: %q is a tip", label, n.Name())
+		}
+		if n == t.Root() {
+			return nil, fmt.Errorf("collapsed clade %q: the whole tree can not be collapsed", label)
+		}
+		clades = append(clades, CollapsedClade{Node: n, Label: label})
+	}
+	return
+}
+
+// Map of collapsed clade roots to their labels
+func collapsedMap(clades []CollapsedClade) map[*tree.Node]string {
+	collapsed := make(map[*tree.Node]string, len(clades))
+	for _, c := range clades {
+		collapsed[c.Node] = c.Label
+	}
+	return collapsed
+}
+
+// Number of tip slots taken by a collapsed clade of ntips tips:
+// grows with the logarithm of the number of tips, so that large clades
+// look larger without taking the space of all their tips
+func collapsedSlots(ntips int) int {
+	return 1 + int(math.Round(math.Log2(float64(ntips))))
+}
+
+// Number of tips and depth (distance to the farthest tip)
+// of the clade rooted at n
+func cladeSize(n, prev *tree.Node, hasBranchLengths bool) (ntips int, depth float64) {
+	if n.Tip() {
+		return 1, 0
+	}
+	for i, child := range n.Neigh() {
+		if child != prev {
+			len := n.Edges()[i].Length()
+			if !hasBranchLengths || len == tree.NIL_LENGTH {
+				len = 1.0
+			}
+			nt, d := cladeSize(child, n, hasBranchLengths)
+			ntips += nt
+			depth = math.Max(depth, d+len)
+		}
+	}
+	return
+}
+
+// Total number of tip slots of the tree, collapsed clades
+// taking collapsedSlots slots
+func layoutSlots(n, prev *tree.Node, collapsed map[*tree.Node]string) (slots int) {
+	if n.Tip() {
+		return 1
+	}
+	if _, ok := collapsed[n]; ok {
+		ntips, _ := cladeSize(n, prev, false)
+		return collapsedSlots(ntips)
+	}
+	for _, child := range n.Neigh() {
+		if child != prev {
+			slots += layoutSlots(child, n, collapsed)
+		}
+	}
+	return
+}
+
+// Maximum length of collapsed clade labels
+func maxCladeLabel(clades []CollapsedClade) (max int) {
+	for _, c := range clades {
+		if len(c.Label) > max {
+			max = len(c.Label)
+		}
+	}
+	return
+}
+
+// Points of the arc of circle of radius r between the two angles,
+// centered on (0,0), every 2 degrees at most
+func arcPoints(r, angle1, angle2 float64) (points []*layoutPoint) {
+	nsteps := int(math.Ceil((angle2-angle1)/(math.Pi/90))) + 1
+	for i := 0; i <= nsteps; i++ {
+		a := angle1 + (angle2-angle1)*float64(i)/float64(nsteps)
+		points = append(points, &layoutPoint{r * math.Cos(a), r * math.Sin(a), a, "", ""})
+	}
+	return
+}
+
+// Draws the collapsed clade triangles or fans (filled with the color of their
+// branch, or grey), translated by the given offsets
+func drawTriangles(d TreeDrawer, triangles []*layoutTriangle, colors map[*tree.Node]TipMetaColor, xoffset, yoffset float64) {
+	for _, tr := range triangles {
+		xs := make([]float64, len(tr.corners))
+		ys := make([]float64, len(tr.corners))
+		for i, p := range tr.corners {
+			xs[i], ys[i] = p.x+xoffset, p.y+yoffset
+		}
+		fill, ok := branchColor(colors, tr.node)
+		if !ok {
+			fill = collapsedFill
+		}
+		d.DrawPolygon(xs, ys, fill.R, fill.G, fill.B, fill.A)
+		setBranchColor(d, colors, tr.node)
+		for i := range xs {
+			d.DrawLine(xs[i], ys[i], xs[(i+1)%len(xs)], ys[(i+1)%len(ys)])
+		}
+	}
+	d.SetLineColor(0, 0, 0, 0xff)
+}
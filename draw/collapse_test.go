@@ -0,0 +1,134 @@
+package draw
+
+import (
+	"math"
+	"testing"
+)
+
+const collapseTree = "(((A:1,B:1)AB:1,(C:1,(D:1,E:1)DE:1)CDE:1)ABCDE:1,(F:1,G:1)FG:1,H:1)root;"
+
+func TestCollapsedSlots(t *testing.T) {
+	for ntips, want := range map[int]int{2: 2, 3: 3, 4: 3, 8: 4, 16: 5} {
+		if got := collapsedSlots(ntips); got != want {
+			t.Errorf("expected %d slots for %d tips, got %d", want, ntips, got)
+		}
+	}
+}
+
+func TestResolveCollapsedClades(t *testing.T) {
+	tr := parseTestTree(t, collapseTree)
+	clades, err := ResolveCollapsedClades(tr, []string{"AB", "", "# comment", "Clade CDE\tC,E", "Group\tFG"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(clades) != 3 {
+		t.Fatalf("expected 3 collapsed clades, got %d", len(clades))
+	}
+	want := [][2]string{{"AB", "AB"}, {"CDE", "Clade CDE"}, {"FG", "Group"}}
+	for i, c := range clades {
+		if c.Node.Name() != want[i][0] || c.Label != want[i][1] {
+			t.Errorf("expected clade %s labeled %q, got %s labeled %q", want[i][0], want[i][1], c.Node.Name(), c.Label)
+		}
+	}
+
+	for _, spec := range []string{"XYZ", "X\tA,XYZ", "A", "root", "X\tA,H"} {
+		if _, err = ResolveCollapsedClades(tr, []string{spec}); err == nil {
+			t.Errorf("expected an error for clade %q", spec)
+		}
+	}
+
+	if _, err = ParseCladeLabelStyle("bracket"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = ParseCladeLabelStyle("arrow"); err == nil {
+		t.Errorf("expected an error for an unknown label style")
+	}
+}
+
+func TestLayoutSlots(t *testing.T) {
+	tr := parseTestTree(t, collapseTree)
+	clades, err := ResolveCollapsedClades(tr, []string{"CDE", "FG"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A, B, CDE (3 tips: 3 slots), FG (2 tips: 2 slots), H
+	if s := layoutSlots(tr.Root(), nil, collapsedMap(clades)); s != 8 {
+		t.Errorf("expected 8 slots, got %d", s)
+	}
+	ntips, depth := cladeSize(clades[0].Node, clades[0].Node.Neigh()[0], true)
+	if ntips != 3 || depth != 2 {
+		t.Errorf("expected CDE to have 3 tips and depth 2, got %d and %f", ntips, depth)
+	}
+}
+
+func TestCollapsedClades_Draw(t *testing.T) {
+	for _, layout := range []string{"normal", "circular"} {
+		for _, style := range []CladeLabelStyle{CladeLabelSide, CladeLabelBracket} {
+			tr := parseTestTree(t, collapseTree)
+			clades, err := ResolveCollapsedClades(tr, []string{"Clade CDE\tC,D,E"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d := newRecordingDrawer(400, 400)
+			var l TreeLayout
+			if layout == "normal" {
+				l = NewNormalLayout(d, true, true, false, false)
+			} else {
+				l = NewCircularLayout(d, true, true, false, false)
+			}
+			l.SetCollapsedClades(clades, style)
+			if err = l.DrawTree(tr); err != nil {
+				t.Fatalf("%s: unexpected error: %v", layout, err)
+			}
+
+			// Distances from the root: along x in normal layout,
+			// from the center in circular layout
+			radial := layout == "circular"
+			cx, cy := 0.0, 0.0
+			if radial {
+				cx, cy = d.center(t)
+			}
+			depth := func(x, y float64) float64 {
+				if radial {
+					return math.Hypot(x-cx, y-cy)
+				}
+				return x - cx
+			}
+
+			// Triangle from CDE (depth 2) to its deepest tips (depth 4)
+			if len(d.polygons) != 1 || d.polygons[0].color != colorOf(collapsedFill) {
+				t.Fatalf("%s: expected a grey collapsed clade, got %v", layout, d.polygons)
+			}
+			if min, max := d.polygons[0].depths(cx, cy, radial); math.Abs(min-2) > 1e-9 || math.Abs(max-4) > 1e-9 {
+				t.Errorf("%s: expected the collapsed clade to span depths 2 to 4, got %f to %f", layout, min, max)
+			}
+
+			label := d.name(t, "Clade CDE")
+			if ld := depth(label.x, label.y); ld < 4-1e-9 || (style == CladeLabelBracket) != (ld > 4+1e-9) {
+				t.Errorf("%s: unexpected depth of the clade label: %f", layout, ld)
+			}
+			// The bracket is drawn beyond the triangle
+			bracket := false
+			for _, line := range d.lines {
+				bracket = bracket || math.Min(depth(line.x1, line.y1), depth(line.x2, line.y2)) > 4+1e-9
+			}
+			for _, c := range d.curves {
+				bracket = bracket || c.radius > 4+1e-9
+			}
+			if bracket != (style == CladeLabelBracket) {
+				t.Errorf("%s: unexpected bracket: %t", layout, bracket)
+			}
+
+			for _, name := range []string{"C", "D", "E"} {
+				if d.hasName(name) {
+					t.Errorf("%s: expected collapsed tip %s not to be drawn", layout, name)
+				}
+			}
+			for _, name := range []string{"A", "B", "F", "G", "H"} {
+				if !d.hasName(name) {
+					t.Errorf("%s: expected tip %s to be drawn", layout, name)
+				}
+			}
+		}
+	}
+}
@@ -2,19 +2,59 @@ package draw
 
 import (
 	"bufio"
+	_ "embed"
+	"encoding/json"
 	"fmt"
+	"html"
+	"strings"
 
 	"github.com/evolbioinfo/gotree/tree"
 )
 
+// Javascript libraries inlined in the html output, so that the generated
+// files do not depend on any external resource (see js/README.md)
+var (
+	//go:embed js/cytoscape.min.js
+	cytoscapeJs string
+	//go:embed js/dagre.min.js
+	dagreJs string
+	//go:embed js/cytoscape-dagre.js
+	cytoscapeDagreJs string
+)
+
 type cytoscapeLayout struct {
-	supportCutoff float64
-	hasSupport    bool
-	writer        *bufio.Writer
+	supportCutoff   float64
+	hasSupport      bool
+	hasNodeComments bool
+	writer          *bufio.Writer
+	metaFields      []string
+	metaShapes      []Shape
+	metaValues      map[string][]TipMetaColor
+	metaLegend      []LegendEntry
+	branchColors    map[*tree.Node]TipMetaColor
+	branchLegend    []LegendEntry
+}
+
+// Elements of the tree, in the cytoscape js format
+type cyElements struct {
+	Nodes []cyElement `json:"nodes"`
+	Edges []cyElement `json:"edges"`
 }
 
+type cyElement struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// NewCytoscapeLayout draws trees in html files using cytoscape.js
+// and the dagre layout. The javascript libraries are embedded in the
+// gotree binary and inlined in the output, so the html files can be
+// opened without network access.
 func NewCytoscapeLayout(writer *bufio.Writer, hasSupport bool) TreeLayout {
-	return &cytoscapeLayout{0.7, hasSupport, writer}
+	return &cytoscapeLayout{
+		supportCutoff: 0.7,
+		hasSupport:    hasSupport,
+		writer:        writer,
+	}
 }
 
 func (layout *cytoscapeLayout) SetSupportCutoff(c float64) {
@@ -25,9 +65,39 @@ func (layout *cytoscapeLayout) SetDisplayInternalNodes(s bool) {
 }
 
 func (layout *cytoscapeLayout) SetDisplayNodeComments(s bool) {
+	layout.hasNodeComments = s
 }
 
 func (layout *cytoscapeLayout) SetTipMetadata(fields []string, shapes []Shape, values map[string][]TipMetaColor, legend []LegendEntry) {
+	layout.metaFields = fields
+	layout.metaShapes = shapes
+	layout.metaValues = values
+	layout.metaLegend = legend
+}
+
+func (layout *cytoscapeLayout) SetBranchColors(colors map[*tree.Node]TipMetaColor, legend []LegendEntry) {
+	layout.branchColors = colors
+	layout.branchLegend = legend
+}
+
+// Clade highlights are not drawn in html output
+func (layout *cytoscapeLayout) SetCladeHighlights(highlights []CladeHighlight) {
+}
+
+// Collapsed clades are not drawn in html output
+func (layout *cytoscapeLayout) SetCollapsedClades(clades []CollapsedClade, style CladeLabelStyle) {
+}
+
+// Time axes are not drawn in html output
+func (layout *cytoscapeLayout) SetTimeAxis(rootDate float64, format DateFormat) {
+}
+
+// Scale bars are not drawn in html output
+func (layout *cytoscapeLayout) SetScaleBar(s bool) {
+}
+
+// Tip panels are not drawn in html output
+func (layout *cytoscapeLayout) SetTipPanels(panels []TipPanel) {
 }
 
 /*
@@ -35,23 +105,52 @@ Draw the tree on the specific drawer. Does not close the file. The caller must d
 */
 func (layout *cytoscapeLayout) DrawTree(t *tree.Tree) error {
 	var err error = nil
-	_, err = layout.writer.WriteString(`
+	var elements []byte
+
+	// json.Marshal escapes <, > and &, so the data can not close the script tag
+	if elements, err = json.Marshal(cyElements{layout.drawNodes(t), layout.drawEdges(t)}); err != nil {
+		return err
+	}
+
+	layout.writer.WriteString(`<!DOCTYPE html>
 <html>
 <head>
-  <script src="https://cdnjs.cloudflare.com/ajax/libs/cytoscape/3.2.3/cytoscape.min.js"></script>
-  <script src="https://cdn.rawgit.com/cpettitt/dagre/v0.7.4/dist/dagre.min.js"></script>
-  <script src="https://cdn.rawgit.com/cytoscape/cytoscape.js-dagre/1.5.0/cytoscape-dagre.js"></script>
-  <style media="screen" type="text/css">
+  <meta charset="utf-8">
+`)
+	for _, js := range []string{cytoscapeJs, dagreJs, cytoscapeDagreJs} {
+		layout.writer.WriteString("  <script>\n")
+		layout.writer.WriteString(strings.ReplaceAll(js, "</script", "<\\/script"))
+		layout.writer.WriteString("\n  </script>\n")
+	}
+	layout.writer.WriteString(`  <style media="screen" type="text/css">
     #cy {
     width: 100%;
     height: 100%;
     display: block;
     }
+    #legend, #info {
+    position: absolute;
+    font-family: sans-serif;
+    font-size: 12px;
+    background-color: rgba(255,255,255,0.8);
+    }
+    #legend {
+    top: 10px;
+    right: 10px;
+    }
+    #info {
+    bottom: 10px;
+    left: 10px;
+    }
   </style>
 </head>
 <body>
   <div id="cy">
   </div>
+`)
+	layout.drawLegend()
+	_, err = layout.writer.WriteString(`  <div id="info">
+  </div>
     <script>
     var cy = cytoscape({
     container: document.getElementById("cy"),
@@ -67,34 +166,60 @@ func (layout *cytoscapeLayout) DrawTree(t *tree.Tree) error {
         'text-halign': 'right',
         'background-color': '#11479e'
       }
+    },{
+      selector: 'node[?meta]',
+      style: {
+        'shape': 'data(shape)',
+        'border-width': 1,
+        'border-color': '#999999',
+        'pie-size': '100%',
+`)
+	for i := range layout.metaFields {
+		layout.writer.WriteString(fmt.Sprintf("        'pie-%d-background-color': 'data(pie%d)',\n", i+1, i+1))
+		layout.writer.WriteString(fmt.Sprintf("        'pie-%d-background-size': %f,\n", i+1, 100.0/float64(len(layout.metaFields))))
+	}
+	layout.writer.WriteString(`        'background-color': '#ffffff'
+      }
     },{
       selector: 'edge',
       style: {
 `)
 	if layout.hasSupport {
 		layout.writer.WriteString("'width': 'mapData(support, 0, 100, 4, 50)',\n")
+		layout.writer.WriteString("'label': 'data(supportlabel)',\n")
+		layout.writer.WriteString("'font-size': 8,\n")
+		layout.writer.WriteString("'text-rotation': 'autorotate',\n")
 	} else {
 		layout.writer.WriteString("'width': 4,\n")
 	}
 	layout.writer.WriteString("'curve-style': 'bezier',\n")
 	if t.Rooted() {
-		_, err = layout.writer.WriteString("   'target-arrow-shape': 'triangle',\n")
+		layout.writer.WriteString("   'target-arrow-shape': 'triangle',\n")
 	}
-	_, err = layout.writer.WriteString(`
+	layout.writer.WriteString(`
         'line-color': '#9dbaea',
         'target-arrow-color': '#9dbaea'
       }
+    },{
+      selector: 'edge[?highlight]',
+      style: {
+        'line-color': '#11479e',
+        'target-arrow-color': '#11479e'
+      }
+    },{
+      selector: 'edge[color]',
+      style: {
+        'line-color': 'data(color)',
+        'target-arrow-color': 'data(color)'
+      }
     }],
-    elements: {
-`)
-	layout.drawNodes(t)
-	layout.drawEdges(t)
-	if err != nil {
-		return err
-	}
-
-	_, err = layout.writer.WriteString(`
-    }});
+    elements: `)
+	layout.writer.Write(elements)
+	_, err = layout.writer.WriteString(`});
+    cy.on('tap', 'node', function(evt) {
+      var info = document.getElementById("info");
+      info.textContent = evt.target.data('details') || '';
+    });
   </script>
 </body>
 </html>
@@ -102,29 +227,98 @@ func (layout *cytoscapeLayout) DrawTree(t *tree.Tree) error {
 	return err
 }
 
-func (layout *cytoscapeLayout) drawNodes(t *tree.Tree) {
-	layout.writer.WriteString("    nodes: [\n")
+// Nodes, labelled with their names (and comments), tips being
+// drawn as pie charts of their metadata colors
+func (layout *cytoscapeLayout) drawNodes(t *tree.Tree) (nodes []cyElement) {
 	for i, n := range t.Nodes() {
 		label := n.Name()
 		if label == "" {
 			label = fmt.Sprintf("n%d", i)
 		}
 		n.SetId(i)
-		layout.writer.WriteString(fmt.Sprintf("{ data: { id: 'n%d' , label: '%s'}},\n", i, label))
+		details := label
+		data := map[string]interface{}{"id": fmt.Sprintf("n%d", i)}
+		if len(n.Comments()) > 0 {
+			if layout.hasNodeComments {
+				label += " " + n.CommentsString()
+			}
+			details += " " + n.CommentsString()
+		}
+		if vals, ok := layout.metaValues[n.Name()]; ok && n.Tip() && len(vals) > 0 {
+			data["meta"] = true
+			data["shape"] = cyShapeName(metaShapeAt(layout.metaShapes, 0))
+			for j, v := range vals {
+				color := "rgba(0,0,0,0)"
+				if !v.Empty {
+					color = cyColor(v.R, v.G, v.B, v.A)
+				}
+				data[fmt.Sprintf("pie%d", j+1)] = color
+			}
+		}
+		data["label"] = label
+		data["details"] = details
+		nodes = append(nodes, cyElement{data})
 	}
-	layout.writer.WriteString("    ],\n")
-
+	return
 }
 
-func (layout *cytoscapeLayout) drawEdges(t *tree.Tree) {
-	layout.writer.WriteString("    edges: [\n")
+// Edges, with their supports and colors
+func (layout *cytoscapeLayout) drawEdges(t *tree.Tree) (edges []cyElement) {
 	for _, e := range t.Edges() {
 		support := 0
+		data := map[string]interface{}{
+			"source": fmt.Sprintf("n%d", e.Left().Id()),
+			"target": fmt.Sprintf("n%d", e.Right().Id()),
+		}
 		if e.Support() != tree.NIL_SUPPORT {
 			support = int(e.Support() * 100)
+			data["supportlabel"] = e.SupportString()
+			data["highlight"] = layout.hasSupport && e.Support() >= layout.supportCutoff
 		}
-		layout.writer.WriteString(fmt.Sprintf("{data: {source: 'n%d', target: 'n%d',  support: %d}},\n", e.Left().Id(), e.Right().Id(), support))
+		data["support"] = support
+		if c, ok := branchColor(layout.branchColors, e.Right()); ok {
+			data["color"] = cyColor(c.R, c.G, c.B, c.A)
+		}
+		edges = append(edges, cyElement{data})
+	}
+	return
+}
+
+// Legend of the tip metadata and branch colors, as an html block
+func (layout *cytoscapeLayout) drawLegend() {
+	entries := legendEntries(layout.metaLegend, layout.branchLegend)
+	if len(entries) == 0 {
+		return
 	}
-	layout.writer.WriteString("    ],\n")
+	layout.writer.WriteString("  <div id=\"legend\">\n")
+	for _, e := range entries {
+		layout.writer.WriteString(fmt.Sprintf("    <div><b>%s</b></div>\n", html.EscapeString(e.Field)))
+		for _, v := range e.Values {
+			layout.writer.WriteString(fmt.Sprintf("    <div><span style=\"color:%s\">&#9632;</span> %s</div>\n", cyColor(v.R, v.G, v.B, v.A), html.EscapeString(v.Label)))
+		}
+		if e.Truncated {
+			layout.writer.WriteString("    <div>...</div>\n")
+		}
+	}
+	layout.writer.WriteString("  </div>\n")
+}
+
+// Name of the cytoscape node shape corresponding to the marker shape
+func cyShapeName(s Shape) string {
+	switch s {
+	case ShapeSquare:
+		return "rectangle"
+	case ShapeTriangle:
+		return "triangle"
+	case ShapeDiamond:
+		return "diamond"
+	case ShapeStar:
+		return "star"
+	default:
+		return "ellipse"
+	}
+}
 
+func cyColor(r, g, b, a uint8) string {
+	return fmt.Sprintf("rgba(%d,%d,%d,%.3f)", r, g, b, float64(a)/255.0)
 }
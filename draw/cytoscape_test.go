@@ -0,0 +1,75 @@
+package draw
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCytoscapeLayout_SelfContained(t *testing.T) {
+	tr := parseTestTree(t, "((A[&country=\"FR\"]:1,B:1)0.9:1,(C:1,D:1)0.2:1);")
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	l := NewCytoscapeLayout(w, true)
+	l.SetDisplayNodeComments(true)
+	l.SetTipMetadata([]string{"age"}, []Shape{ShapeSquare},
+		map[string][]TipMetaColor{"A": {{R: 255, G: 0, B: 0, A: 255}}, "B": {{Empty: true}}},
+		[]LegendEntry{{Field: "age", Shape: ShapeSquare, Values: []LegendValue{{Label: "min", R: 255, A: 255}}}})
+	if err := l.DrawTree(tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Flush()
+	html := buf.String()
+
+	for _, forbidden := range []string{"<script src", "https://", "cdnjs", "rawgit"} {
+		if strings.Contains(html, forbidden) {
+			t.Errorf("html output should not reference external resources, found %q", forbidden)
+		}
+	}
+	for _, js := range []string{cytoscapeJs, dagreJs, cytoscapeDagreJs} {
+		if !strings.Contains(html, "<script>\n"+js) {
+			t.Errorf("html output should inline the embedded javascript libraries")
+		}
+	}
+	for _, expected := range []string{
+		"name: 'dagre'",
+		"'pie-1-background-color': 'data(pie1)'",
+		`"pie1":"rgba(255,0,0,1.000)"`,
+		`"pie1":"rgba(0,0,0,0)"`,
+		`"shape":"rectangle"`,
+		`"supportlabel":"0.9"`,
+		`"highlight":true`,
+		"mapData(support, 0, 100, 4, 50)",
+		`<div><b>age</b></div>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("html output should contain %q", expected)
+		}
+	}
+	// Comments are escaped by the json encoder (& is written \u0026)
+	if !strings.Contains(html, `"label":"A [\u0026country=\"FR\"]"`) {
+		t.Errorf("html output should contain the comment of tip A in its label")
+	}
+}
+
+// The libraries must be the vendored builds (see js/vendor.sh), with their
+// licences, not the placeholders throwing an error when the page is opened
+func TestCytoscapeLayout_Vendored(t *testing.T) {
+	for _, lib := range []struct {
+		file, js, licence string
+	}{
+		{"cytoscape.min.js", cytoscapeJs, "LICENSE.cytoscape"},
+		{"dagre.min.js", dagreJs, "LICENSE.dagre"},
+		{"cytoscape-dagre.js", cytoscapeDagreJs, "LICENSE.cytoscape-dagre"},
+	} {
+		if strings.Contains(lib.js, "is not vendored in this build of gotree") {
+			t.Errorf("js/%s is a placeholder, run draw/js/vendor.sh", lib.file)
+		}
+		if _, err := os.Stat("js/" + lib.licence); err != nil {
+			t.Errorf("missing licence of js/%s: %v", lib.file, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package draw
+
+import (
+	"math"
+	"sort"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+const (
+	// daylightIterations is the maximum number of passes of the
+	// equal-daylight algorithm over the internal nodes.
+	daylightIterations = 20
+	// daylightMinChange is the mean rotation (in radians) per internal
+	// node under which the equal-daylight algorithm stops.
+	daylightMinChange = 1e-3
+)
+
+/*
+NewDaylightLayout returns an unrooted equal-daylight layout: the tree is
+first drawn with the equal-angle radial layout, then the subtrees around
+each internal node are iteratively rotated so that the angular gaps
+("daylight") between them are all equal, as in Splitstree and ggtree
+(Felsenstein's drawtree).
+Tree indexes must have been set with t.ReinitIndexes()
+*/
+func NewDaylightLayout(td TreeDrawer, withBranchLengths, withTipLabels, withInternalNodeLabels, withSuppportCircles bool) TreeLayout {
+	layout := NewRadialLayout(td, withBranchLengths, withTipLabels, withInternalNodeLabels, withSuppportCircles).(*radialLayout)
+	layout.daylight = true
+	return layout
+}
+
+// angular sector occupied by a subtree, seen from one of its neighbors
+type daylightSector struct {
+	nodes      []*tree.Node
+	start, end float64 // counter-clockwise, end-start < 2π
+}
+
+// equalDaylight redistributes the angles of the cached points until the
+// daylight around every internal node is equalized, or until it does not
+// change anymore.
+func (layout *radialLayout) equalDaylight(t *tree.Tree) {
+	internals := make([]*tree.Node, 0)
+	parents := make(map[*tree.Node]*tree.Node)
+	t.PreOrder(func(cur, prev *tree.Node, e *tree.Edge) (keep bool) {
+		parents[cur] = prev
+		if !cur.Tip() {
+			internals = append(internals, cur)
+		}
+		return true
+	})
+	if len(internals) == 0 {
+		return
+	}
+
+	for i := 0; i < daylightIterations; i++ {
+		change := 0.0
+		for _, n := range internals {
+			change += layout.equalizeDaylight(n, parents[n])
+		}
+		if change/float64(len(internals)) < daylightMinChange {
+			break
+		}
+	}
+	for _, p := range layout.cache.points {
+		p.brAngle = normalizeAngle(p.brAngle)
+	}
+}
+
+// equalizeDaylight rotates the subtrees around node n, except the one
+// containing prev (or the first one at the root), so that the angular
+// gaps between consecutive subtrees, seen from n, are all equal.
+// Returns the mean absolute rotation of the subtrees.
+func (layout *radialLayout) equalizeDaylight(n, prev *tree.Node) float64 {
+	center := layout.cache.points[n]
+	var fixed *daylightSector
+	others := make([]*daylightSector, 0, n.Nneigh())
+	for _, neigh := range n.Neigh() {
+		s, ok := layout.sector(center, neighborSubtree(neigh, n))
+		if !ok {
+			continue
+		}
+		if fixed == nil && (neigh == prev || prev == nil) {
+			fixed = s
+		} else {
+			others = append(others, s)
+		}
+	}
+	if fixed == nil || len(others) == 0 {
+		return 0
+	}
+
+	// Subtrees in counter-clockwise order, starting after the fixed one
+	sort.Slice(others, func(i, j int) bool {
+		return normalizeAngle(others[i].start-fixed.start) < normalizeAngle(others[j].start-fixed.start)
+	})
+	daylight := 2*math.Pi - (fixed.end - fixed.start)
+	for _, s := range others {
+		daylight -= s.end - s.start
+	}
+	gap := daylight / float64(len(others)+1)
+
+	change := 0.0
+	cur := fixed.end
+	for _, s := range others {
+		target := cur + gap
+		rot := normalizeAngle(target-s.start+math.Pi) - math.Pi
+		layout.rotate(center, s.nodes, rot)
+		change += math.Abs(rot)
+		cur = target + s.end - s.start
+	}
+	return change / float64(len(others))
+}
+
+// sector computes the angular sector occupied by the given nodes, seen
+// from center. The reference direction is the one of the first node not
+// located at center; ok is false if all the nodes are located at center.
+func (layout *radialLayout) sector(center *layoutPoint, nodes []*tree.Node) (s *daylightSector, ok bool) {
+	ref, min, max := 0.0, 0.0, 0.0
+	for _, n := range nodes {
+		p := layout.cache.points[n]
+		dx, dy := p.x-center.x, p.y-center.y
+		if math.Hypot(dx, dy) < 1e-12 {
+			continue
+		}
+		a := math.Atan2(dy, dx)
+		if !ok {
+			ref, ok = a, true
+			continue
+		}
+		d := normalizeAngle(a-ref+math.Pi) - math.Pi
+		min, max = math.Min(min, d), math.Max(max, d)
+	}
+	if ok {
+		s = &daylightSector{nodes, ref + min, ref + max}
+	}
+	return
+}
+
+// rotate rotates the points of the given nodes around center
+func (layout *radialLayout) rotate(center *layoutPoint, nodes []*tree.Node, angle float64) {
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	for _, n := range nodes {
+		p := layout.cache.points[n]
+		dx, dy := p.x-center.x, p.y-center.y
+		p.x = center.x + dx*cos - dy*sin
+		p.y = center.y + dx*sin + dy*cos
+		p.brAngle += angle
+	}
+}
+
+// Nodes of the subtree containing n, when removing the branch between n and from
+func neighborSubtree(n, from *tree.Node) (nodes []*tree.Node) {
+	var recur func(cur, prev *tree.Node)
+	recur = func(cur, prev *tree.Node) {
+		nodes = append(nodes, cur)
+		for _, c := range cur.Neigh() {
+			if c != prev {
+				recur(c, cur)
+			}
+		}
+	}
+	recur(n, from)
+	return
+}
+
+// Angle modulo 2π, in [0, 2π)
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a
+}
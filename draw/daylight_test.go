@@ -0,0 +1,86 @@
+package draw
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+const daylightTree = "(((((A:1,B:1):1,C:1):1,D:1):1,(E:1,(F:1,(G:1,H:1):0.5):0.5):1):1,I:1,(J:1,K:2):0.5);"
+
+// Angular gaps between the consecutive subtrees around node n
+func daylightGaps(layout *radialLayout, n *tree.Node) (gaps []float64) {
+	center := layout.cache.points[n]
+	sectors := make([]*daylightSector, 0)
+	for _, neigh := range n.Neigh() {
+		if s, ok := layout.sector(center, neighborSubtree(neigh, n)); ok {
+			sectors = append(sectors, s)
+		}
+	}
+	sort.Slice(sectors, func(i, j int) bool {
+		return normalizeAngle(sectors[i].start) < normalizeAngle(sectors[j].start)
+	})
+	for i, s := range sectors {
+		next := sectors[(i+1)%len(sectors)]
+		gaps = append(gaps, normalizeAngle(next.start-s.end))
+	}
+	return
+}
+
+func drawDaylightTree(t *testing.T, daylight bool) (*tree.Tree, *radialLayout) {
+	tr := parseTestTree(t, daylightTree)
+	if err := tr.ReinitIndexes(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	d := NewSvgTreeDrawer(&buf, 400, 400, 30, 30, 30, 30, 0, 0)
+	var l TreeLayout
+	if daylight {
+		l = NewDaylightLayout(d, true, true, false, false)
+	} else {
+		l = NewRadialLayout(d, true, true, false, false)
+	}
+	if err := l.DrawTree(tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return tr, l.(*radialLayout)
+}
+
+func TestDaylight_EqualGaps(t *testing.T) {
+	tr, radial := drawDaylightTree(t, false)
+	tr2, daylight := drawDaylightTree(t, true)
+	nodes := tr2.Nodes()
+	minRadial, minDaylight := math.Inf(1), math.Inf(1)
+	for i, n := range tr.Nodes() {
+		if n.Tip() {
+			continue
+		}
+		minRadial = math.Min(minRadial, slices.Min(daylightGaps(radial, n)))
+		// Both trees are parsed from the same newick string
+		gaps := daylightGaps(daylight, nodes[i])
+		if spread := slices.Max(gaps) - slices.Min(gaps); spread > 0.05 {
+			t.Errorf("expected equal gaps around node %d, got %v", i, gaps)
+		}
+		minDaylight = math.Min(minDaylight, slices.Min(gaps))
+	}
+	if minDaylight <= minRadial {
+		t.Errorf("expected the smallest gap to increase, got %f (radial: %f)", minDaylight, minRadial)
+	}
+}
+
+func TestDaylight_BranchLengths(t *testing.T) {
+	tr, layout := drawDaylightTree(t, true)
+	for _, e := range tr.Edges() {
+		l, r := layout.cache.points[e.Left()], layout.cache.points[e.Right()]
+		if d := math.Hypot(l.x-r.x, l.y-r.y); math.Abs(d-e.Length()) > 1e-9 {
+			t.Errorf("expected branch %s to be drawn with length %f, got %f", e.Right().Name(), e.Length(), d)
+		}
+		if a := math.Atan2(r.y-l.y, r.x-l.x); math.Abs(normalizeAngle(a-r.brAngle+math.Pi)-math.Pi) > 1e-9 {
+			t.Errorf("expected branch %s to be drawn with angle %f, got %f", e.Right().Name(), r.brAngle, a)
+		}
+	}
+}
@@ -95,6 +95,11 @@ type TreeDrawer interface {
 	DrawLine(x1, y1, x2, y2 float64)
 	DrawCurve(centerx, centery float64, middlex, middley float64, radius float64, startAngle, endAngle float64)
 	DrawCircle(x, y float64)
+	/* Sets the color of the lines and curves drawn afterwards (black by default) */
+	SetLineColor(r, g, b, a uint8)
+	/* Draws a filled polygon without border (e.g. clade highlight),
+	   in the same coordinates as lines */
+	DrawPolygon(xs, ys []float64, r, g, b, a uint8)
 	/* angle : angle of the tip incoming branch. offsetPixels : distance
 	   (in pixels) from (x,y) along angle at which the shape is centered.
 	   filled : colored fill + black stroke if true, else unfilled with a
@@ -110,6 +115,12 @@ type TreeDrawer interface {
 	   in absolute pixel coordinates independent of the tree's data-space
 	   transform. No-op if entries is empty. */
 	DrawLegend(entries []LegendEntry)
+	/* Draws a vertical tick of length pixels below (x,y), with the label
+	   horizontally centered below it (e.g. time axis ticks) */
+	DrawTick(x, y float64, length float64, label string)
+	/* Draws a scale bar of the given length (in tree units) and its label,
+	   in the bottom margin, below the left of the tree area */
+	DrawScaleBar(length float64, label string)
 	Write()
 	Bounds() (int, int) /* width, height*/
 }
@@ -126,6 +137,21 @@ type TreeLayout interface {
 	SetDisplayInternalNodes(bool)
 	SetDisplayNodeComments(bool)
 	SetTipMetadata(fields []string, shapes []Shape, values map[string][]TipMetaColor, legend []LegendEntry)
+	/* Colors of the branches above the nodes (see ResolveBranchColors),
+	   and their legend, drawn after the tip metadata legend */
+	SetBranchColors(colors map[*tree.Node]TipMetaColor, legend []LegendEntry)
+	/* Background shades drawn behind clades (see ResolveCladeHighlights) */
+	SetCladeHighlights(highlights []CladeHighlight)
+	/* Clades drawn as triangles instead of their subtrees
+	   (see ResolveCollapsedClades), with labels of the given style */
+	SetCollapsedClades(clades []CollapsedClade, style CladeLabelStyle)
+	/* Draws a time axis (concentric circles in circular layout), the root
+	   being at rootDate, with dates written in the given format */
+	SetTimeAxis(rootDate float64, format DateFormat)
+	/* Draws a scale bar below the tree */
+	SetScaleBar(bool)
+	/* Heatmaps and bar charts drawn next to the tips (see ResolveTipPanel) */
+	SetTipPanels(panels []TipPanel)
 }
 
 // metaShapeAt returns shapes[i], defaulting to ShapeCircle if shapes is too short.
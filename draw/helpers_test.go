@@ -0,0 +1,191 @@
+package draw
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func parseTestTree(t *testing.T, nw string) *tree.Tree {
+	tr, err := newick.NewParser(strings.NewReader(nw)).Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return tr
+}
+
+// Color of a recorded element
+type recordedColor [4]uint8
+
+func colorOf(c TipMetaColor) recordedColor {
+	return recordedColor{c.R, c.G, c.B, c.A}
+}
+
+type recordedLine struct {
+	x1, y1, x2, y2 float64
+	color          recordedColor
+}
+
+type recordedCurve struct {
+	cx, cy, radius float64
+	color          recordedColor
+}
+
+type recordedPolygon struct {
+	xs, ys []float64
+	color  recordedColor
+}
+
+type recordedText struct {
+	x, y  float64
+	label string
+}
+
+// TreeDrawer recording the elements drawn by the layouts,
+// in the coordinates of the layouts
+type recordingDrawer struct {
+	width, height int
+	color         recordedColor
+	lines         []recordedLine
+	curves        []recordedCurve
+	polygons      []recordedPolygon
+	names         []recordedText
+	ticks         []recordedText
+	scaleBars     []recordedText
+	legend        []LegendEntry
+}
+
+func newRecordingDrawer(width, height int) *recordingDrawer {
+	return &recordingDrawer{width: width, height: height, color: recordedColor{0, 0, 0, 0xff}}
+}
+
+func (d *recordingDrawer) SetMaxValues(maxObjectWidth, maxObjectHeight float64, maxNameLength, maxNameHeight int) {
+}
+
+func (d *recordingDrawer) DrawHLine(x1, x2, y float64) {
+	d.DrawLine(x1, y, x2, y)
+}
+
+func (d *recordingDrawer) DrawVLine(x, y1, y2 float64) {
+	d.DrawLine(x, y1, x, y2)
+}
+
+func (d *recordingDrawer) DrawLine(x1, y1, x2, y2 float64) {
+	d.lines = append(d.lines, recordedLine{x1, y1, x2, y2, d.color})
+}
+
+func (d *recordingDrawer) DrawCurve(centerx, centery float64, middlex, middley float64, radius float64, startAngle, endAngle float64) {
+	d.curves = append(d.curves, recordedCurve{centerx, centery, radius, d.color})
+}
+
+func (d *recordingDrawer) DrawCircle(x, y float64) {
+}
+
+func (d *recordingDrawer) SetLineColor(r, g, b, a uint8) {
+	d.color = recordedColor{r, g, b, a}
+}
+
+func (d *recordingDrawer) DrawPolygon(xs, ys []float64, r, g, b, a uint8) {
+	d.polygons = append(d.polygons, recordedPolygon{append([]float64{}, xs...), append([]float64{}, ys...), recordedColor{r, g, b, a}})
+}
+
+func (d *recordingDrawer) DrawColoredShapeAtOffset(x, y float64, angle, offsetPixels float64, shape Shape, r, g, b, a uint8, filled bool) {
+}
+
+func (d *recordingDrawer) SetTipLabelOffset(px float64) {
+}
+
+func (d *recordingDrawer) DrawName(x, y float64, name string, angle float64) {
+	d.names = append(d.names, recordedText{x, y, name})
+}
+
+func (d *recordingDrawer) DrawLegend(entries []LegendEntry) {
+	d.legend = append(d.legend, entries...)
+}
+
+func (d *recordingDrawer) DrawTick(x, y float64, length float64, label string) {
+	d.ticks = append(d.ticks, recordedText{x, y, label})
+}
+
+func (d *recordingDrawer) DrawScaleBar(length float64, label string) {
+	d.scaleBars = append(d.scaleBars, recordedText{length, 0, label})
+}
+
+func (d *recordingDrawer) Write() {
+}
+
+func (d *recordingDrawer) Bounds() (int, int) {
+	return d.width, d.height
+}
+
+// Position of the name drawn with the given label
+func (d *recordingDrawer) name(t *testing.T, label string) recordedText {
+	for _, n := range d.names {
+		if n.label == label {
+			return n
+		}
+	}
+	t.Fatalf("expected %s to be drawn", label)
+	return recordedText{}
+}
+
+func (d *recordingDrawer) hasName(label string) bool {
+	for _, n := range d.names {
+		if n.label == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Line ending (or starting) at the given point, i.e. the
+// branch of the node drawn at this point
+func (d *recordingDrawer) lineAt(t *testing.T, x, y float64) recordedLine {
+	for _, l := range d.lines {
+		if (closeTo(l.x2, x) && closeTo(l.y2, y)) || (closeTo(l.x1, x) && closeTo(l.y1, y)) {
+			return l
+		}
+	}
+	t.Fatalf("expected a line ending at (%f,%f)", x, y)
+	return recordedLine{}
+}
+
+// Center of the circular and radial layouts: the center of
+// the curves drawn around the root
+func (d *recordingDrawer) center(t *testing.T) (x, y float64) {
+	if len(d.curves) == 0 {
+		t.Fatalf("expected curves centered on the root")
+	}
+	return d.curves[0].cx, d.curves[0].cy
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// Whether (x,y) is inside the polygon (ray casting)
+func (p recordedPolygon) contains(x, y float64) (in bool) {
+	for i, j := 0, len(p.xs)-1; i < len(p.xs); j, i = i, i+1 {
+		if (p.ys[i] > y) != (p.ys[j] > y) && x < (p.xs[j]-p.xs[i])*(y-p.ys[i])/(p.ys[j]-p.ys[i])+p.xs[i] {
+			in = !in
+		}
+	}
+	return
+}
+
+// Minimum and maximum distance of the corners of the polygon to
+// (cx,cy), along x only if radial is false
+func (p recordedPolygon) depths(cx, cy float64, radial bool) (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for i := range p.xs {
+		d := p.xs[i] - cx
+		if radial {
+			d = math.Hypot(p.xs[i]-cx, p.ys[i]-cy)
+		}
+		min, max = math.Min(min, d), math.Max(max, d)
+	}
+	return
+}
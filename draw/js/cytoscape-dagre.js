@@ -0,0 +1,2 @@
+/* Placeholder for cytoscape-dagre 1.5.0 (cytoscape-dagre.js): run draw/js/vendor.sh to vendor it */
+throw new Error("cytoscape-dagre.js is not vendored in this build of gotree, see draw/js/vendor.sh");
@@ -0,0 +1,2 @@
+/* Placeholder for cytoscape 3.2.3 (cytoscape.min.js): run draw/js/vendor.sh to vendor it */
+throw new Error("cytoscape.min.js is not vendored in this build of gotree, see draw/js/vendor.sh");
@@ -0,0 +1,2 @@
+/* Placeholder for dagre 0.7.4 (dagre.min.js): run draw/js/vendor.sh to vendor it */
+throw new Error("dagre.min.js is not vendored in this build of gotree, see draw/js/vendor.sh");
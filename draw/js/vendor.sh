@@ -0,0 +1,26 @@
+#!/usr/bin/env bash
+# Vendors the javascript libraries inlined in gotree draw cyjs html
+# outputs (see draw/cytoscape.go), with their licences, from the npm
+# registry. Versions are the ones the html page was written for.
+set -euo pipefail
+
+CYTOSCAPE_VERSION=3.2.3
+DAGRE_VERSION=0.7.4
+CYTOSCAPE_DAGRE_VERSION=1.5.0
+
+DIR=$(cd "$(dirname "$0")" && pwd)
+TMP=$(mktemp -d)
+trap 'rm -rf "${TMP}"' EXIT
+
+# fetch <package> <version> <file in package> <output file>
+fetch() {
+    mkdir -p "${TMP}/$1"
+    curl -fsSL "https://registry.npmjs.org/$1/-/$1-$2.tgz" | tar -xz -C "${TMP}/$1"
+    cp "${TMP}/$1/package/$3" "${DIR}/$4"
+    cp "${TMP}/$1/package/LICENSE" "${DIR}/LICENSE.$1"
+    echo "$1 $2: $(sha256sum "${DIR}/$4" | cut -d ' ' -f 1)"
+}
+
+fetch cytoscape ${CYTOSCAPE_VERSION} dist/cytoscape.min.js cytoscape.min.js
+fetch dagre ${DAGRE_VERSION} dist/dagre.min.js dagre.min.js
+fetch cytoscape-dagre ${CYTOSCAPE_DAGRE_VERSION} cytoscape-dagre.js cytoscape-dagre.js
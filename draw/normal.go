@@ -1,6 +1,8 @@
 package draw
 
 import (
+	"math"
+
 	"github.com/evolbioinfo/gotree/tree"
 )
 
@@ -18,6 +20,16 @@ type normalLayout struct {
 	metaShapes             []Shape
 	metaValues             map[string][]TipMetaColor
 	metaLegend             []LegendEntry
+	branchColors           map[*tree.Node]TipMetaColor
+	branchLegend           []LegendEntry
+	highlights             []CladeHighlight
+	collapsed              []CollapsedClade
+	cladeLabelStyle        CladeLabelStyle
+	timeAxis               bool
+	rootDate               float64
+	dateFormat             DateFormat
+	scaleBar               bool
+	panels                 []TipPanel
 }
 
 func NewNormalLayout(td TreeDrawer, withBranchLengths, withTipLabels, withInternalNodeLabel, withSupportCircles bool) TreeLayout {
@@ -51,76 +63,277 @@ func (layout *normalLayout) SetTipMetadata(fields []string, shapes []Shape, valu
 	layout.metaLegend = legend
 }
 
+func (layout *normalLayout) SetBranchColors(colors map[*tree.Node]TipMetaColor, legend []LegendEntry) {
+	layout.branchColors = colors
+	layout.branchLegend = legend
+}
+
+func (layout *normalLayout) SetCladeHighlights(highlights []CladeHighlight) {
+	layout.highlights = highlights
+}
+
+func (layout *normalLayout) SetCollapsedClades(clades []CollapsedClade, style CladeLabelStyle) {
+	layout.collapsed = clades
+	layout.cladeLabelStyle = style
+}
+
+func (layout *normalLayout) SetTimeAxis(rootDate float64, format DateFormat) {
+	layout.timeAxis = true
+	layout.rootDate = rootDate
+	layout.dateFormat = format
+}
+
+func (layout *normalLayout) SetScaleBar(s bool) {
+	layout.scaleBar = s
+}
+
+func (layout *normalLayout) SetTipPanels(panels []TipPanel) {
+	layout.panels = panels
+}
+
 /*
 Draw the tree on the specific drawer. Does not close the file. The caller must do it.
 */
 func (layout *normalLayout) DrawTree(t *tree.Tree) error {
 	var err error = nil
 	root := t.Root()
-	ntips := len(t.Tips())
-	curNbTips := 0
+	collapsed := collapsedMap(layout.collapsed)
+	nslots := layoutSlots(root, nil, collapsed)
 	maxLength, maxName := maxLength(t, layout.hasBranchLengths, layout.hasTipLabels, layout.hasNodeComments)
 	maxName += metaExtraNameChars(len(layout.metaFields))
-	layout.drawer.SetMaxValues(maxLength, float64(ntips), maxName, 0)
-	layout.drawTreeRecur(root, nil, tree.NIL_SUPPORT, 0, 0, &curNbTips)
-	layout.drawTree()
+	if len(layout.collapsed) > 0 {
+		maxName = max(maxName, maxCladeLabel(layout.collapsed)+2)
+	}
+	labelsName := maxName
+	axisHeight := 0
+	if layout.timeAxis {
+		axisHeight = timeAxisHeight
+	}
+	if len(layout.panels) > 0 {
+		// Panels after the tip labels, and their column names below the tree
+		maxName += int(math.Ceil(tipPanelsWidth(layout.panels) / 5.0))
+		axisHeight = max(axisHeight, maxPanelColumn(layout.panels)+1)
+	}
+	layout.drawer.SetMaxValues(maxLength, float64(nslots), maxName, axisHeight)
+	newNormalPositions(layout.cache, layout.hasBranchLengths, collapsed).compute(root)
+	layout.highlightClades(t)
+	// Pixels per unit of branch length, to place the panels after the tip labels
+	width, _ := layout.drawer.Bounds()
+	scale := float64(width-5*maxName) / maxLength
+	layout.drawTree(maxLength+5*float64(labelsName)/scale, scale, float64(nslots)-0.5)
+	if layout.timeAxis {
+		layout.drawTimeAxis(maxLength, float64(nslots)-0.5)
+	}
+	if layout.scaleBar {
+		length := scaleBarLength(maxLength)
+		layout.drawer.DrawScaleBar(length, scaleBarLabel(length))
+	}
 	layout.drawer.Write()
 	return err
 }
 
+// normalPositions computes the positions of the nodes of a tree in the
+// normal (rectangular) layout, and stores its lines and points in the cache.
+// Tips are placed from top to bottom, ystep apart, and each node at
+// x = origin + direction*scale*(distance to the root).
+type normalPositions struct {
+	cache            *layoutCache
+	hasBranchLengths bool
+	collapsed        map[*tree.Node]string
+	origin           float64
+	direction        float64 // 1: root on the left, -1: root on the right
+	scale            float64
+	ystep            float64
+	// Children of a node in drawing order (nil: order of the neighbors)
+	children func(n, prev *tree.Node) []*tree.Node
+}
+
+// Normal positions from left to right, in tree units
+func newNormalPositions(cache *layoutCache, hasBranchLengths bool, collapsed map[*tree.Node]string) *normalPositions {
+	return &normalPositions{
+		cache:            cache,
+		hasBranchLengths: hasBranchLengths,
+		collapsed:        collapsed,
+		direction:        1.0,
+		scale:            1.0,
+		ystep:            1.0,
+	}
+}
+
+func (pos *normalPositions) compute(root *tree.Node) {
+	curNbTips := 0
+	pos.computeRecur(root, nil, nil, 0, 0, &curNbTips)
+}
+
+func (pos *normalPositions) x(distToRoot float64) float64 {
+	return pos.origin + pos.direction*pos.scale*distToRoot
+}
+
 /*
-Recursive function that draws the tree. Returns the yposition of the current node
+Recursive function that computes the positions. Returns the yposition of the current node
 */
-func (layout *normalLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, support, prevDistToRoot, distToRoot float64, curtip *int) float64 {
+func (pos *normalPositions) computeRecur(n *tree.Node, prev *tree.Node, e *tree.Edge, prevDistToRoot, distToRoot float64, curtip *int) float64 {
 	ypos := 0.0
 	nbchild := 0.0
+	x := pos.x(distToRoot)
 	if n.Tip() {
-		ypos = float64(*curtip)
+		ypos = float64(*curtip) * pos.ystep
 		nbchild = 1.0
-		node := &layoutPoint{distToRoot, ypos, 0.0, n.Name(), n.CommentsString()}
-		layout.cache.tipLabelPoints = append(layout.cache.tipLabelPoints, node)
+		node := &layoutPoint{x, ypos, 0.0, n.Name(), n.CommentsString()}
+		pos.cache.tipLabelPoints = append(pos.cache.tipLabelPoints, node)
+		pos.cache.points[n] = node
 		*curtip++
+	} else if label, ok := pos.collapsed[n]; ok {
+		ntips, depth := cladeSize(n, prev, pos.hasBranchLengths)
+		slots := collapsedSlots(ntips)
+		y1 := (float64(*curtip) - 0.5 + collapsedMargin) * pos.ystep
+		y2 := (float64(*curtip+slots-1) + 0.5 - collapsedMargin) * pos.ystep
+		ypos = (y1 + y2) / 2.0
+		node := &layoutPoint{x, ypos, 0.0, n.Name(), n.CommentsString()}
+		pos.cache.points[n] = node
+		xbase := pos.x(distToRoot + depth)
+		pos.cache.triangles = append(pos.cache.triangles, &layoutTriangle{
+			[]*layoutPoint{node, {xbase, y1, 0.0, "", ""}, {xbase, y2, 0.0, "", ""}},
+			y1, y2, &layoutPoint{xbase, ypos, 0.0, label, ""}, n})
+		*curtip += slots
 	} else {
 		minpos := -1.0
 		maxpos := -1.0
-		for i, child := range n.Neigh() {
-			if child != prev {
-				len := n.Edges()[i].Length()
-				supp := n.Edges()[i].Support()
-				if !layout.hasBranchLengths || len == tree.NIL_LENGTH {
-					len = 1.0
-				}
-				temppos := layout.drawTreeRecur(child, n, supp, distToRoot, distToRoot+len, curtip)
-				if minpos == -1 || minpos > temppos {
-					minpos = temppos
-				}
-				if maxpos == -1 || maxpos < temppos {
-					maxpos = temppos
-				}
-				ypos += temppos
-				nbchild += 1.0
+		for _, child := range pos.childrenOf(n, prev) {
+			i, _ := n.NodeIndex(child)
+			childEdge := n.Edges()[i]
+			len := childEdge.Length()
+			if !pos.hasBranchLengths || len == tree.NIL_LENGTH {
+				len = 1.0
 			}
+			temppos := pos.computeRecur(child, n, childEdge, distToRoot, distToRoot+len, curtip)
+			if minpos == -1 || minpos > temppos {
+				minpos = temppos
+			}
+			if maxpos == -1 || maxpos < temppos {
+				maxpos = temppos
+			}
+			ypos += temppos
+			nbchild += 1.0
 		}
 		ypos /= nbchild
-		line := &layoutVLine{distToRoot, minpos, maxpos, tree.NIL_SUPPORT}
-		layout.cache.verticalPaths = append(layout.cache.verticalPaths, line)
+		line := &layoutVLine{x, minpos, maxpos, tree.NIL_SUPPORT, n}
+		pos.cache.verticalPaths = append(pos.cache.verticalPaths, line)
 
-		inode := &layoutPoint{distToRoot, ypos, 0.0, n.Name(), n.CommentsString()}
-		layout.cache.nodePoints = append(layout.cache.nodePoints, inode)
+		inode := &layoutPoint{x, ypos, 0.0, n.Name(), n.CommentsString()}
+		pos.cache.nodePoints = append(pos.cache.nodePoints, inode)
+		pos.cache.points[n] = inode
 	}
 
-	line := &layoutHLine{prevDistToRoot, distToRoot, ypos, support}
-	layout.cache.horizontalPaths = append(layout.cache.horizontalPaths, line)
+	support := tree.NIL_SUPPORT
+	if e != nil {
+		support = e.Support()
+	}
+	line := &layoutHLine{pos.x(prevDistToRoot), x, ypos, support, n}
+	pos.cache.horizontalPaths = append(pos.cache.horizontalPaths, line)
 	return ypos
 }
 
-func (layout *normalLayout) drawTree() {
-	for _, l := range layout.cache.horizontalPaths {
-		layout.drawer.DrawHLine(l.x1, l.x2, l.y)
+func (pos *normalPositions) childrenOf(n, prev *tree.Node) (children []*tree.Node) {
+	if pos.children != nil {
+		return pos.children(n, prev)
+	}
+	for _, child := range n.Neigh() {
+		if child != prev {
+			children = append(children, child)
+		}
+	}
+	return
+}
+
+// Draws the branches and the collapsed clades stored in the cache by normalPositions
+func drawNormalBranches(d TreeDrawer, cache *layoutCache, colors map[*tree.Node]TipMetaColor) {
+	for _, l := range cache.horizontalPaths {
+		setBranchColor(d, colors, l.node)
+		d.DrawHLine(math.Min(l.x1, l.x2), math.Max(l.x1, l.x2), l.y)
+	}
+	for _, l := range cache.verticalPaths {
+		setBranchColor(d, colors, l.node)
+		d.DrawVLine(l.x, l.y1, l.y2)
 	}
-	for _, l := range layout.cache.verticalPaths {
-		layout.drawer.DrawVLine(l.x, l.y1, l.y2)
+	d.SetLineColor(0, 0, 0, 0xff)
+	drawTriangles(d, cache.triangles, colors, 0, 0)
+}
+
+// Computes the background rectangles of the highlighted clades, from the
+// middle of the branch above the clade root to the farthest node of the clade
+func (layout *normalLayout) highlightClades(t *tree.Tree) {
+	for _, h := range layout.highlights {
+		root, ok := layout.cache.points[h.Node]
+		if !ok {
+			continue
+		}
+		x1, x2, y1, y2 := root.x, root.x, root.y, root.y
+		for _, p := range subtreePoints(t, h.Node, layout.cache) {
+			x2 = math.Max(x2, p.x)
+			y1 = math.Min(y1, p.y)
+			y2 = math.Max(y2, p.y)
+		}
+		for _, l := range layout.cache.horizontalPaths {
+			if l.node == h.Node {
+				x1 = (l.x1 + l.x2) / 2.0
+			}
+		}
+		y1, y2 = y1-0.5, y2+0.5
+		layout.cache.highlights = append(layout.cache.highlights, &layoutPolygon{[]float64{x1, x2, x2, x1}, []float64{y1, y1, y2, y2}, h.R, h.G, h.B, h.A})
 	}
+}
+
+// Draws the labels of the collapsed clades, either next to the triangles,
+// or after brackets aligned beyond the farthest node of the tree
+func (layout *normalLayout) drawCladeLabels() {
+	_, _, xmax, _ := layout.cache.borders()
+	bracketx := xmax * 1.02
+	tick := xmax * 0.01
+	for _, tr := range layout.cache.triangles {
+		if layout.cladeLabelStyle == CladeLabelBracket {
+			layout.drawer.DrawVLine(bracketx, tr.start, tr.end)
+			layout.drawer.DrawHLine(bracketx-tick, bracketx, tr.start)
+			layout.drawer.DrawHLine(bracketx-tick, bracketx, tr.end)
+			layout.drawer.DrawName(bracketx, tr.label.y, tr.label.name, 0.0)
+		} else {
+			layout.drawer.DrawName(tr.label.x, tr.label.y, tr.label.name, 0.0)
+		}
+	}
+}
+
+// Draws the time axis at y, from the root to maxLength, with ticks
+// labeled with the dates
+func (layout *normalLayout) drawTimeAxis(maxLength, y float64) {
+	layout.drawer.DrawHLine(0, maxLength, y)
+	dates, labels := timeTicks(layout.rootDate, layout.rootDate+maxLength, layout.dateFormat, timeAxisTicks)
+	for i, d := range dates {
+		layout.drawer.DrawTick(d-layout.rootDate, y, tickLength, labels[i])
+	}
+}
+
+// Draws the tip panels starting at x, scale being the number of pixels per
+// unit of x, and their column names below y
+func (layout *normalLayout) drawPanels(x, scale, y float64) {
+	for _, tr := range panelTracks(layout.panels) {
+		x1 := x + tr.offset/scale
+		m := tr.margin()
+		for _, p := range layout.cache.tipLabelPoints {
+			if frac, c, ok := tr.cell(p.name); ok {
+				x2 := x1 + frac*tr.width/scale
+				layout.drawer.DrawPolygon([]float64{x1, x2, x2, x1}, []float64{p.y - 0.5 + m, p.y - 0.5 + m, p.y + 0.5 - m, p.y + 0.5 - m}, c.R, c.G, c.B, c.A)
+			}
+		}
+		layout.drawer.DrawName(x1+tr.width/scale/2.0, y, tr.panel.Columns[tr.col], math.Pi/2.0)
+	}
+}
+
+// Draws the tree, and the tip panels starting at panelsx (see drawPanels)
+func (layout *normalLayout) drawTree(panelsx, scale, bottom float64) {
+	drawHighlights(layout.drawer, layout.cache.highlights, 0, 0)
+	drawNormalBranches(layout.drawer, layout.cache, layout.branchColors)
+	layout.drawCladeLabels()
+	layout.drawPanels(panelsx, scale, bottom)
 	if len(layout.metaFields) > 0 {
 		layout.drawer.SetTipLabelOffset(metaLabelOffset(len(layout.metaFields)))
 	}
@@ -169,7 +382,7 @@ func (layout *normalLayout) drawTree() {
 		}
 	}
 
-	if len(layout.metaLegend) > 0 {
-		layout.drawer.DrawLegend(layout.metaLegend)
+	if legend := legendEntries(layout.metaLegend, layout.branchLegend, panelLegends(layout.panels)); len(legend) > 0 {
+		layout.drawer.DrawLegend(legend)
 	}
 }
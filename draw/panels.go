@@ -0,0 +1,195 @@
+package draw
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// PanelType is the way the values of a tip panel are drawn
+type PanelType int
+
+const (
+	PanelHeatmap PanelType = iota // One colored cell per tip and column
+	PanelBars                     // One bar chart per column, bar lengths proportional to the values
+)
+
+const (
+	// panelGap is the pixel gap before each panel, and between bar charts.
+	panelGap = 6.0
+	// heatmapCellWidth is the pixel width of a heatmap column.
+	heatmapCellWidth = 12.0
+	// barChartWidth is the pixel width of a bar chart (length of the longest bar).
+	barChartWidth = 50.0
+	// heatmapCellMargin is the fraction of a tip slot left empty on each side of a heatmap cell.
+	heatmapCellMargin = 0.02
+	// barMargin is the fraction of a tip slot left empty on each side of a bar.
+	barMargin = 0.15
+)
+
+// TipPanel is a matrix of numeric values (one row per tip, one column
+// per field), drawn next to the tips as a heatmap or as bar charts,
+// with a color scale common to all its columns.
+type TipPanel struct {
+	Type    PanelType
+	Columns []string
+	Values  map[string][]float64      // Tip name -> value of each column (NaN if missing)
+	Colors  map[string][]TipMetaColor // Tip name -> color of each column (Empty if missing)
+	Max     float64                   // Value of the longest bar
+	Legend  LegendEntry
+}
+
+// ResolveTipPanel computes the colors of the cells of a tip panel named
+// name (used as legend field name), from the raw values indexed as
+// raw[tipName][column] ("" meaning missing). All values must be numbers.
+// Colors go from the low to the high color (see FieldColorSpec) between
+// the minimum and the maximum value of the whole matrix, unless
+// overridden.
+func ResolveTipPanel(kind PanelType, name string, columns []string, tipOrder []string, raw map[string]map[string]string, override FieldColorSpec) (panel TipPanel, err error) {
+	if len(columns) == 0 {
+		err = fmt.Errorf("%s: no column to draw", name)
+		return
+	}
+	panel = TipPanel{
+		Type:    kind,
+		Columns: columns,
+		Values:  make(map[string][]float64, len(tipOrder)),
+		Colors:  make(map[string][]TipMetaColor, len(tipOrder)),
+	}
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, tip := range tipOrder {
+		panel.Values[tip] = make([]float64, len(columns))
+		panel.Colors[tip] = make([]TipMetaColor, len(columns))
+		for i, c := range columns {
+			v := math.NaN()
+			if s := raw[tip][c]; s != "" {
+				if v, err = strconv.ParseFloat(s, 64); err != nil {
+					err = fmt.Errorf("%s: value %q of tip %q, column %q is not a number", name, s, tip, c)
+					return
+				}
+				min, max = math.Min(min, v), math.Max(max, v)
+			}
+			panel.Values[tip][i] = v
+		}
+	}
+	if math.IsInf(min, 1) {
+		min, max = 0, 0
+	}
+	// Common scale for all the columns
+	if override.Min == nil {
+		override.Min = &min
+	}
+	if override.Max == nil {
+		override.Max = &max
+	}
+	panel.Max = *override.Max
+
+	for i, c := range columns {
+		colors := make(map[string][]TipMetaColor, len(tipOrder))
+		for _, tip := range tipOrder {
+			colors[tip] = make([]TipMetaColor, 1)
+		}
+		if panel.Legend, err = resolveContinuousField(c, 0, nil, override, ShapeSquare, tipOrder, raw, colors); err != nil {
+			return
+		}
+		for _, tip := range tipOrder {
+			panel.Colors[tip][i] = colors[tip][0]
+		}
+	}
+	panel.Legend.Field = name
+	return
+}
+
+// Pixel width of each column of the panel
+func (p *TipPanel) columnWidth() float64 {
+	if p.Type == PanelBars {
+		return barChartWidth
+	}
+	return heatmapCellWidth
+}
+
+// Pixel gap before each column of the panel
+func (p *TipPanel) columnGap(col int) float64 {
+	if col == 0 || p.Type == PanelBars {
+		return panelGap
+	}
+	return 0
+}
+
+// Total pixel width of the panels, gaps included
+func tipPanelsWidth(panels []TipPanel) (width float64) {
+	for i := range panels {
+		for c := range panels[i].Columns {
+			width += panels[i].columnGap(c) + panels[i].columnWidth()
+		}
+	}
+	return
+}
+
+// panelTrack is one column of a tip panel, at a pixel offset from the
+// start of the panels
+type panelTrack struct {
+	panel  *TipPanel
+	col    int
+	offset float64
+	width  float64
+}
+
+// Columns of all the panels, in drawing order
+func panelTracks(panels []TipPanel) (tracks []panelTrack) {
+	offset := 0.0
+	for i := range panels {
+		for c := range panels[i].Columns {
+			offset += panels[i].columnGap(c)
+			tracks = append(tracks, panelTrack{&panels[i], c, offset, panels[i].columnWidth()})
+			offset += panels[i].columnWidth()
+		}
+	}
+	return
+}
+
+// Fraction of the track width filled by the cell of the tip (1 for
+// heatmaps, proportional to the value for bar charts), and its color.
+// ok is false if there is nothing to draw (no value, or empty bar)
+func (tr panelTrack) cell(tip string) (frac float64, c TipMetaColor, ok bool) {
+	vals, found := tr.panel.Values[tip]
+	if !found || math.IsNaN(vals[tr.col]) {
+		return
+	}
+	c = tr.panel.Colors[tip][tr.col]
+	if tr.panel.Type == PanelHeatmap {
+		return 1, c, true
+	}
+	if tr.panel.Max > 0 {
+		frac = math.Max(0, math.Min(1, vals[tr.col]/tr.panel.Max))
+	}
+	return frac, c, frac > 0
+}
+
+// Fraction of a tip slot left empty on each side of the cells of the track
+func (tr panelTrack) margin() float64 {
+	if tr.panel.Type == PanelBars {
+		return barMargin
+	}
+	return heatmapCellMargin
+}
+
+// Legend entries of the panels
+func panelLegends(panels []TipPanel) (legend []LegendEntry) {
+	for _, p := range panels {
+		legend = append(legend, p.Legend)
+	}
+	return
+}
+
+// Length of the longest column name of the panels
+func maxPanelColumn(panels []TipPanel) (max int) {
+	for _, p := range panels {
+		for _, c := range p.Columns {
+			if len(c) > max {
+				max = len(c)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,173 @@
+package draw
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"testing"
+)
+
+const panelTree = "((A:1,B:1):1,(C:1,D:1):1);"
+
+var panelValues = map[string]map[string]string{
+	"A": {"R1": "0", "R2": "2"},
+	"B": {"R1": "2", "R2": ""},
+	"C": {"R1": "1", "R2": "0"},
+}
+
+func TestResolveTipPanel(t *testing.T) {
+	p, err := ResolveTipPanel(PanelHeatmap, "heatmap", []string{"R1", "R2"}, []string{"A", "B", "C"}, panelValues, FieldColorSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Common scale for all the columns
+	if p.Colors["A"][0] != p.Colors["C"][1] || p.Colors["A"][1] != p.Colors["B"][0] {
+		t.Errorf("expected equal values to have equal colors in all columns: %+v", p.Colors)
+	}
+	if !p.Colors["B"][1].Empty || !math.IsNaN(p.Values["B"][1]) {
+		t.Errorf("expected a missing value for B/R2, got %v (%+v)", p.Values["B"][1], p.Colors["B"][1])
+	}
+	if p.Legend.Field != "heatmap" || len(p.Legend.Values) != 2 || p.Legend.Values[0].Label != "0" || p.Legend.Values[1].Label != "2" {
+		t.Errorf("unexpected legend: %+v", p.Legend)
+	}
+
+	max := 4.0
+	if p, err = ResolveTipPanel(PanelBars, "bars", []string{"R1"}, []string{"A", "B", "C"}, panelValues, FieldColorSpec{Max: &max}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr := panelTracks([]TipPanel{p})[0]
+	if frac, _, ok := tr.cell("B"); !ok || frac != 0.5 {
+		t.Errorf("expected the bar of B to be half the chart width, got %f", frac)
+	}
+	if _, _, ok := tr.cell("D"); ok {
+		t.Errorf("expected no bar for D")
+	}
+
+	bad := map[string]map[string]string{"A": {"R1": "resistant"}}
+	if _, err = ResolveTipPanel(PanelHeatmap, "heatmap", []string{"R1"}, []string{"A"}, bad, FieldColorSpec{}); err == nil {
+		t.Errorf("expected an error for a non numeric value")
+	}
+}
+
+func TestPanelTracks(t *testing.T) {
+	panels := []TipPanel{{Type: PanelHeatmap, Columns: []string{"R1", "R2"}}, {Type: PanelBars, Columns: []string{"MIC", "size"}}}
+	want := []float64{panelGap, panelGap + heatmapCellWidth, 2*panelGap + 2*heatmapCellWidth, 3*panelGap + 2*heatmapCellWidth + barChartWidth}
+	for i, tr := range panelTracks(panels) {
+		if tr.offset != want[i] {
+			t.Errorf("expected track %d at offset %f, got %f", i, want[i], tr.offset)
+		}
+	}
+	if w := tipPanelsWidth(panels); w != 3*panelGap+2*heatmapCellWidth+2*barChartWidth {
+		t.Errorf("unexpected panels width: %f", w)
+	}
+}
+
+func TestTipPanels_Draw(t *testing.T) {
+	for _, layout := range []string{"normal", "circular"} {
+		heatmap, err := ResolveTipPanel(PanelHeatmap, "heatmap", []string{"R1", "R2"}, []string{"A", "B", "C"}, panelValues, FieldColorSpec{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		bars, err := ResolveTipPanel(PanelBars, "bars", []string{"R1"}, []string{"A", "B", "C"}, panelValues, FieldColorSpec{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tr := parseTestTree(t, panelTree)
+		d := newRecordingDrawer(400, 400)
+		var l TreeLayout
+		if layout == "normal" {
+			l = NewNormalLayout(d, true, true, false, false)
+		} else {
+			l = NewCircularLayout(d, true, true, false, false)
+		}
+		l.SetTipPanels([]TipPanel{heatmap, bars})
+		if err = l.DrawTree(tr); err != nil {
+			t.Fatalf("%s: unexpected error: %v", layout, err)
+		}
+
+		// Cells are in the slot of their tip: same y in normal
+		// layout, same angle around the center in circular layout
+		radial := layout == "circular"
+		cx, cy := 0.0, 0.0
+		if radial {
+			cx, cy = d.center(t)
+		}
+		slot := func(x, y float64) float64 {
+			if radial {
+				return math.Atan2(y-cy, x-cx)
+			}
+			return y
+		}
+		cells := make(map[string][]recordedPolygon)
+		for _, p := range d.polygons {
+			x, y := 0.0, 0.0
+			for i := range p.xs {
+				x, y = x+p.xs[i]/float64(len(p.xs)), y+p.ys[i]/float64(len(p.ys))
+			}
+			for _, tip := range []string{"A", "B", "C", "D"} {
+				n := d.name(t, tip)
+				if math.Abs(slot(x, y)-slot(n.x, n.y)) < 1e-6 {
+					cells[tip] = append(cells[tip], p)
+				}
+			}
+		}
+		for _, c := range cells {
+			sort.Slice(c, func(i, j int) bool {
+				mi, _ := c[i].depths(cx, cy, radial)
+				mj, _ := c[j].depths(cx, cy, radial)
+				return mi < mj
+			})
+		}
+
+		// Heatmap cells, then bars (the bar of A has length 0, B/R2 is missing)
+		want := map[string][]TipMetaColor{
+			"A": {heatmap.Colors["A"][0], heatmap.Colors["A"][1]},
+			"B": {heatmap.Colors["B"][0], bars.Colors["B"][0]},
+			"C": {heatmap.Colors["C"][0], heatmap.Colors["C"][1], bars.Colors["C"][0]},
+		}
+		for _, tip := range []string{"A", "B", "C", "D"} {
+			if len(cells[tip]) != len(want[tip]) {
+				t.Errorf("%s: expected %d cells for tip %s, got %d", layout, len(want[tip]), tip, len(cells[tip]))
+				continue
+			}
+			for i, c := range cells[tip] {
+				if c.color != colorOf(want[tip][i]) {
+					t.Errorf("%s: expected cell %d of tip %s to have color %v, got %v", layout, i, tip, want[tip][i], c.color)
+				}
+			}
+		}
+		if t.Failed() {
+			continue
+		}
+		// Columns are aligned, beyond the tips (depth 2)
+		a1, _ := cells["A"][0].depths(cx, cy, radial)
+		c1, _ := cells["C"][0].depths(cx, cy, radial)
+		a2, _ := cells["A"][1].depths(cx, cy, radial)
+		c2, _ := cells["C"][1].depths(cx, cy, radial)
+		if a1 <= 2 || math.Abs(a1-c1) > 1e-9 || math.Abs(a2-c2) > 1e-9 || a2 <= a1 {
+			t.Errorf("%s: expected aligned heatmap columns beyond the tips, got %f/%f and %f/%f", layout, a1, c1, a2, c2)
+		}
+		// Bars proportional to the values: B (2) twice as long as C (1)
+		bmin, bmax := cells["B"][1].depths(cx, cy, radial)
+		cmin, cmax := cells["C"][2].depths(cx, cy, radial)
+		if math.Abs(bmin-cmin) > 1e-9 || math.Abs((bmax-bmin)-2*(cmax-cmin)) > 1e-9 {
+			t.Errorf("%s: expected the bar of B to be twice as long as the bar of C, got %f and %f", layout, bmax-bmin, cmax-cmin)
+		}
+
+		if len(d.legend) != 2 || d.legend[0].Field != "heatmap" || d.legend[1].Field != "bars" {
+			t.Errorf("%s: expected legends for the panels, got %+v", layout, d.legend)
+		}
+		if hasNames := d.hasName("R2"); hasNames != (layout == "normal") {
+			t.Errorf("%s: unexpected column names: %t", layout, hasNames)
+		}
+	}
+
+	// Panels wider than the image
+	heatmap, _ := ResolveTipPanel(PanelHeatmap, "heatmap", []string{"R1", "R2"}, []string{"A"}, panelValues, FieldColorSpec{})
+	var buf bytes.Buffer
+	l := NewCircularLayout(NewSvgTreeDrawer(&buf, 20, 20, 30, 30, 30, 30, 0, 0), true, true, false, false)
+	l.SetTipPanels([]TipPanel{heatmap})
+	if err := l.DrawTree(parseTestTree(t, panelTree)); err == nil {
+		t.Errorf("expected an error for panels wider than the image")
+	}
+}
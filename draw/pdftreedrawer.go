@@ -0,0 +1,342 @@
+package draw
+
+import (
+	"io"
+	"math"
+	"sync"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Font size (in points) of the texts of pdf images
+const pdfFontSize = 8.0
+
+/*
+PdfTreeDrawer initializer. Draws trees in a single page pdf document, 1 pixel
+being 1 point. Fonts (go regular and go bold) are embedded in the document.
+
+legendWidth/legendHeight (from LegendSize, with PdfTextWidth) reserve extra
+page space below/beside the tree area for DrawLegend, instead of overlaying
+it on the tree. Pass 0, 0 when there is no legend.
+*/
+func NewPdfTreeDrawer(w io.Writer, width, height int, leftmargin, rightmargin, topmargin, bottommargin int, legendWidth, legendHeight int) TreeDrawer {
+	pdftd := &pdfTreeDrawer{
+		outwriter:    w,
+		width:        width,
+		height:       height,
+		leftmargin:   leftmargin,
+		rightmargin:  rightmargin,
+		topmargin:    topmargin,
+		bottommargin: bottommargin,
+		legendWidth:  legendWidth,
+		legendHeight: legendHeight,
+		dTip:         2.0,
+		lineColor:    [4]uint8{0x00, 0x00, 0x00, 0xff},
+	}
+	totalW := width + leftmargin + rightmargin
+	if legendWidth > totalW {
+		totalW = legendWidth
+	}
+	totalH := height + topmargin + bottommargin
+	if legendHeight > 0 {
+		totalH += int(legendGap) + legendHeight
+	}
+	pdftd.pdf = newPdf(float64(totalW), float64(totalH))
+	pdftd.pdf.AddPage()
+	return pdftd
+}
+
+/*
+Draw a tree in a pdf file.
+*/
+type pdfTreeDrawer struct {
+	outwriter     io.Writer  // Output Writer
+	width         int        // Width of the pdf page (without margins)
+	height        int        // Height of the pdf page (without margins)
+	leftmargin    int        // Left margin of the page (in addition to the width)
+	rightmargin   int        // Right margin of the page (in addition to the width)
+	topmargin     int        // Top margin of the page (in addition to the height)
+	bottommargin  int        // Bottom margin of the page (in addition to the height)
+	legendWidth   int        // Width reserved for the legend box (0 if none), from LegendSize
+	legendHeight  int        // Height reserved for the legend box (0 if none), from LegendSize
+	pdf           *fpdf.Fpdf // Pdf document
+	dTip          float64    // Distance from tip to label
+	maxHeight     float64    // Maximum height of object to draw (in original scale)
+	maxLength     float64    // Maximum length of object to draw (in original scale)
+	maxNameLength int        // Maximum length of species names / horitzontal
+	maxNameHeight int        // Maximum length of species names / vertical
+	lineColor     [4]uint8   // Color of lines and curves (r, g, b, a)
+}
+
+// Initializes a pdf document of the given size (in points), with embedded fonts
+func newPdf(width, height float64) *fpdf.Fpdf {
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "pt",
+		Size:    fpdf.SizeType{Wd: width, Ht: height},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddUTF8FontFromBytes("goregular", "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes("goregular", "B", gobold.TTF)
+	pdf.SetFont("goregular", "", pdfFontSize)
+	return pdf
+}
+
+func (pdftd *pdfTreeDrawer) SetMaxValues(maxLength, maxHeight float64, maxNameLength, maxNameHeight int) {
+	pdftd.maxLength = maxLength
+	pdftd.maxHeight = maxHeight
+	pdftd.maxNameLength = 5 * maxNameLength
+	pdftd.maxNameHeight = 5 * maxNameHeight
+}
+
+// Position on the page of the given x coordinate
+func (pdftd *pdfTreeDrawer) xpos(x float64) float64 {
+	return float64(pdftd.width-pdftd.maxNameLength)*x/pdftd.maxLength + float64(pdftd.leftmargin)
+}
+
+// Position on the page of the given y coordinate
+func (pdftd *pdfTreeDrawer) ypos(y float64) float64 {
+	return float64(pdftd.height-pdftd.maxNameHeight)*y/pdftd.maxHeight + float64(pdftd.topmargin)
+}
+
+// Sets the draw color and the opacity to the line color
+func (pdftd *pdfTreeDrawer) setLineStyle() {
+	c := pdftd.lineColor
+	pdftd.pdf.SetDrawColor(int(c[0]), int(c[1]), int(c[2]))
+	pdftd.pdf.SetAlpha(float64(c[3])/255.0, "Normal")
+	pdftd.pdf.SetLineWidth(2)
+}
+
+func (pdftd *pdfTreeDrawer) DrawHLine(x1, x2, y float64) {
+	pdftd.DrawLine(x1, y, x2, y)
+}
+
+func (pdftd *pdfTreeDrawer) DrawVLine(x, y1, y2 float64) {
+	pdftd.DrawLine(x, y1, x, y2)
+}
+
+func (pdftd *pdfTreeDrawer) DrawLine(x1, y1, x2, y2 float64) {
+	pdftd.setLineStyle()
+	pdftd.pdf.Line(pdftd.xpos(x1), pdftd.ypos(y1), pdftd.xpos(x2), pdftd.ypos(y2))
+	pdftd.pdf.SetAlpha(1.0, "Normal")
+}
+
+func (pdftd *pdfTreeDrawer) DrawCurve(centerx, centery float64, middlex, middley float64, radius float64, startAngle, endAngle float64) {
+	centerx2, centery2 := pdftd.xpos(centerx), pdftd.ypos(centery)
+	radiusscaled := math.Hypot(pdftd.xpos(middlex)-centerx2, pdftd.ypos(middley)-centery2)
+	pdftd.setLineStyle()
+	// Pdf angles are counter-clockwise, with y axis pointing down
+	pdftd.pdf.Arc(centerx2, centery2, radiusscaled, radiusscaled, 0, -endAngle*180.0/math.Pi, -startAngle*180.0/math.Pi, "D")
+	pdftd.pdf.SetAlpha(1.0, "Normal")
+}
+
+func (pdftd *pdfTreeDrawer) DrawCircle(x, y float64) {
+	pdftd.pdf.SetFillColor(0x77, 0xca, 0xff)
+	pdftd.pdf.SetDrawColor(0x00, 0x00, 0x00)
+	pdftd.pdf.SetLineWidth(1)
+	pdftd.pdf.Circle(pdftd.xpos(x), pdftd.ypos(y), 5, "FD")
+}
+
+func (pdftd *pdfTreeDrawer) SetLineColor(r, g, b, a uint8) {
+	pdftd.lineColor = [4]uint8{r, g, b, a}
+}
+
+func (pdftd *pdfTreeDrawer) DrawPolygon(xs, ys []float64, r, g, b, a uint8) {
+	if len(xs) < 3 {
+		return
+	}
+	points := make([]fpdf.PointType, len(xs))
+	for i := range xs {
+		points[i] = fpdf.PointType{X: pdftd.xpos(xs[i]), Y: pdftd.ypos(ys[i])}
+	}
+	pdftd.pdf.SetFillColor(int(r), int(g), int(b))
+	pdftd.pdf.SetAlpha(float64(a)/255.0, "Normal")
+	pdftd.pdf.Polygon(points, "F")
+	pdftd.pdf.SetAlpha(1.0, "Normal")
+}
+
+func (pdftd *pdfTreeDrawer) SetTipLabelOffset(px float64) {
+	pdftd.dTip = px
+}
+
+/* angle : incoming branch angle. offsetPixels : distance from (x,y) along angle */
+func (pdftd *pdfTreeDrawer) DrawColoredShapeAtOffset(x, y float64, angle, offsetPixels float64, shape Shape, r, g, b, a uint8, filled bool) {
+	xpos, ypos := pdftd.xpos(x), pdftd.ypos(y)
+
+	style := "D"
+	if filled {
+		pdftd.pdf.SetFillColor(int(r), int(g), int(b))
+		pdftd.pdf.SetDrawColor(0x00, 0x00, 0x00)
+		pdftd.pdf.SetAlpha(float64(a)/255.0, "Normal")
+		style = "FD"
+	} else {
+		pdftd.pdf.SetDrawColor(0x99, 0x99, 0x99)
+	}
+	pdftd.pdf.SetLineWidth(1)
+
+	pdftd.pdf.TransformBegin()
+	if angle < 3*math.Pi/2.0 && angle > math.Pi/2.0 {
+		pdftd.pdf.TransformRotate(-(angle-math.Pi)*180.0/math.Pi, xpos, ypos)
+		pdftd.drawShape(xpos-offsetPixels, ypos, shape, style)
+	} else {
+		pdftd.pdf.TransformRotate(-angle*180.0/math.Pi, xpos, ypos)
+		pdftd.drawShape(xpos+offsetPixels, ypos, shape, style)
+	}
+	pdftd.pdf.TransformEnd()
+	pdftd.pdf.SetAlpha(1.0, "Normal")
+}
+
+// drawShape draws shape centered at (x, y), with the given fpdf style
+// ("D" for outlined only, "FD" for outlined and filled).
+func (pdftd *pdfTreeDrawer) drawShape(x, y float64, shape Shape, style string) {
+	switch shape {
+	case ShapeSquare:
+		pdftd.pdf.Rect(x-4, y-4, 8, 8, style)
+	case ShapeTriangle:
+		pdftd.pdf.Polygon([]fpdf.PointType{{X: x, Y: y - 5}, {X: x - 5, Y: y + 4}, {X: x + 5, Y: y + 4}}, style)
+	case ShapeDiamond:
+		pdftd.pdf.Polygon([]fpdf.PointType{{X: x, Y: y - 5}, {X: x + 5, Y: y}, {X: x, Y: y + 5}, {X: x - 5, Y: y}}, style)
+	case ShapeStar:
+		xs, ys := starPointsF(x, y, 5, 2)
+		points := make([]fpdf.PointType, len(xs))
+		for i := range xs {
+			points[i] = fpdf.PointType{X: xs[i], Y: ys[i]}
+		}
+		pdftd.pdf.Polygon(points, style)
+	default: // ShapeCircle
+		pdftd.pdf.Circle(x, y, 4, style)
+	}
+}
+
+/* angle:  incoming branch angle */
+func (pdftd *pdfTreeDrawer) DrawName(x, y float64, name string, angle float64) {
+	xpos, ypos := pdftd.xpos(x), pdftd.ypos(y)
+	// Baseline such that the text is vertically centered on (x,y)
+	baseline := ypos + pdfFontSize*0.35
+
+	pdftd.pdf.SetTextColor(0x00, 0x00, 0x00)
+	pdftd.pdf.TransformBegin()
+	// We rotate the other way (text not upside down)
+	if angle < 3*math.Pi/2.0 && angle > math.Pi/2.0 {
+		pdftd.pdf.TransformRotate(-(angle-math.Pi)*180.0/math.Pi, xpos, ypos)
+		pdftd.pdf.Text(xpos-pdftd.pdf.GetStringWidth(name)-pdftd.dTip, baseline, name)
+	} else {
+		pdftd.pdf.TransformRotate(-angle*180.0/math.Pi, xpos, ypos)
+		pdftd.pdf.Text(xpos+pdftd.dTip, baseline, name)
+	}
+	pdftd.pdf.TransformEnd()
+}
+
+func (pdftd *pdfTreeDrawer) DrawTick(x, y float64, length float64, label string) {
+	xpos, ypos := pdftd.xpos(x), pdftd.ypos(y)
+	if length > 0 {
+		pdftd.drawThinLine(xpos, ypos, xpos, ypos+length)
+	}
+	if label != "" {
+		pdftd.drawCenteredText(xpos, ypos+length+tickLabelGap, label)
+	}
+}
+
+// DrawScaleBar draws the scale bar in the bottom margin, starting
+// at the left of the tree area
+func (pdftd *pdfTreeDrawer) DrawScaleBar(length float64, label string) {
+	x1 := float64(pdftd.leftmargin)
+	x2 := x1 + float64(pdftd.width-pdftd.maxNameLength)*length/pdftd.maxLength
+	y := float64(pdftd.topmargin+pdftd.height) + scaleBarGap
+	pdftd.drawThinLine(x1, y, x2, y)
+	for _, x := range []float64{x1, x2} {
+		pdftd.drawThinLine(x, y-scaleBarTick, x, y+scaleBarTick)
+	}
+	pdftd.drawCenteredText((x1+x2)/2, y+scaleBarTick+tickLabelGap, label)
+}
+
+// Draws a black line of width 1, in page coordinates
+func (pdftd *pdfTreeDrawer) drawThinLine(x1, y1, x2, y2 float64) {
+	pdftd.pdf.SetDrawColor(0x00, 0x00, 0x00)
+	pdftd.pdf.SetLineWidth(1)
+	pdftd.pdf.Line(x1, y1, x2, y2)
+}
+
+// Draws the text horizontally centered on x, its top at y, in page coordinates
+func (pdftd *pdfTreeDrawer) drawCenteredText(x, y float64, text string) {
+	pdftd.pdf.SetTextColor(0x00, 0x00, 0x00)
+	pdftd.pdf.Text(x-pdftd.pdf.GetStringWidth(text)/2.0, y+pdfFontSize*0.75, text)
+}
+
+// DrawLegend draws a legend box in the bottom-left corner of the page,
+// in absolute coordinates (independent of the tree's data-space
+// transform), listing each metadata field's name, marker shape, and
+// color-coded values.
+func (pdftd *pdfTreeDrawer) DrawLegend(entries []LegendEntry) {
+	cols := legendColumns(entries)
+	if len(cols) == 0 || pdftd.legendHeight == 0 {
+		return
+	}
+
+	totalH := float64(pdftd.height + pdftd.topmargin + pdftd.bottommargin + int(legendGap) + pdftd.legendHeight)
+	legendH := float64(pdftd.legendHeight)
+
+	x0 := legendPadding
+	y0 := totalH - legendH
+
+	pdftd.pdf.SetFillColor(0xff, 0xff, 0xff)
+	pdftd.pdf.SetDrawColor(0x99, 0x99, 0x99)
+	pdftd.pdf.SetLineWidth(1)
+	pdftd.pdf.Rect(x0, y0, float64(pdftd.legendWidth), legendH, "FD")
+
+	colX := x0 + legendPadding
+	for _, col := range cols {
+		for i, r := range col {
+			rowY := y0 + legendPadding + float64(i)*legendRowHeight + legendRowHeight/2.0
+			textX := colX
+			if r.hasSwatch {
+				pdftd.pdf.SetFillColor(int(r.r), int(r.g), int(r.b))
+				pdftd.pdf.SetDrawColor(0x00, 0x00, 0x00)
+				pdftd.pdf.SetAlpha(float64(r.a)/255.0, "Normal")
+				pdftd.drawShape(colX+4, rowY, r.shape, "FD")
+				pdftd.pdf.SetAlpha(1.0, "Normal")
+				textX = colX + legendSwatchGap
+			}
+			style := ""
+			if r.isHeader {
+				style = "B"
+			}
+			pdftd.pdf.SetFont("goregular", style, pdfFontSize)
+			pdftd.pdf.SetTextColor(0x00, 0x00, 0x00)
+			pdftd.pdf.Text(textX, rowY+pdfFontSize*0.35, r.text)
+		}
+		colX += legendColumnWidth(col, PdfTextWidth) + legendColumnGap
+	}
+	pdftd.pdf.SetFont("goregular", "", pdfFontSize)
+}
+
+func (pdftd *pdfTreeDrawer) Write() {
+	_ = pdftd.pdf.Output(pdftd.outwriter)
+}
+
+func (pdftd *pdfTreeDrawer) Bounds() (width, height int) {
+	width, height = pdftd.width, pdftd.height
+	return
+}
+
+var (
+	measurePdfOnce sync.Once
+	measurePdf     *fpdf.Fpdf
+)
+
+// PdfTextWidth returns the width (in points) of text drawn by
+// pdfTreeDrawer (exact, via the metrics of the embedded fonts), bold
+// being used for legend headers.
+func PdfTextWidth(text string, bold bool) float64 {
+	measurePdfOnce.Do(func() {
+		measurePdf = newPdf(100, 100)
+	})
+	style := ""
+	if bold {
+		style = "B"
+	}
+	measurePdf.SetFont("goregular", style, pdfFontSize)
+	return measurePdf.GetStringWidth(text)
+}
@@ -0,0 +1,78 @@
+package draw
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPdfTextWidth(t *testing.T) {
+	if w := PdfTextWidth("", false); w != 0 {
+		t.Errorf("expected empty text to have no width, got %f", w)
+	}
+	regular, bold := PdfTextWidth("Tip1", false), PdfTextWidth("Tip1", true)
+	if regular <= 0 || bold <= regular {
+		t.Errorf("expected bold text to be wider than regular text, got %f and %f", bold, regular)
+	}
+	if w := PdfTextWidth("Tip1Tip1", false); w <= regular*1.99 || w >= regular*2.01 {
+		t.Errorf("expected text width to be additive, got %f for twice %f", w, regular)
+	}
+}
+
+func TestPdfTreeDrawer_Draw(t *testing.T) {
+	for _, layout := range []string{"normal", "circular", "radial"} {
+		tr := parseTestTree(t, branchColorTree)
+		// Required by the radial layout
+		if err := tr.ReinitIndexes(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		colors, legend, err := ResolveBranchColors(tr, "state", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var buf bytes.Buffer
+		legendW, legendH := LegendSize([]LegendEntry{legend}, PdfTextWidth)
+		d := NewPdfTreeDrawer(&buf, 400, 400, 30, 30, 30, 30, legendW, legendH)
+		d.(*pdfTreeDrawer).pdf.SetCompression(false)
+		var l TreeLayout
+		switch layout {
+		case "normal":
+			l = NewNormalLayout(d, true, true, false, true)
+		case "circular":
+			l = NewCircularLayout(d, true, true, false, true)
+		default:
+			l = NewRadialLayout(d, true, true, false, true)
+		}
+		l.SetBranchColors(colors, []LegendEntry{legend})
+		if err = l.DrawTree(tr); err != nil {
+			t.Fatalf("%s: unexpected error: %v", layout, err)
+		}
+		pdf := buf.String()
+		if !strings.HasPrefix(pdf, "%PDF-") {
+			t.Fatalf("%s: expected a pdf document", layout)
+		}
+		// Page: tree area, margins, and legend below
+		if !strings.Contains(pdf, "/MediaBox [0 0 460.00 ") {
+			t.Errorf("%s: expected a 460 points wide page", layout)
+		}
+		if c := strings.Count(pdf, "/FontFile2"); c != 2 {
+			t.Errorf("%s: expected 2 embedded fonts, got %d", layout, c)
+		}
+		// Text drawing operators: 5 tip names, legend header and 2 values
+		if c := strings.Count(pdf, " Tj"); c != 8 {
+			t.Errorf("%s: expected 8 texts, got %d", layout, c)
+		}
+		// Branches stroked with the colors of their states,
+		// and legend markers filled with the same colors
+		for _, v := range legend.Values {
+			rgb := fmt.Sprintf("%.3f %.3f %.3f", float64(v.R)/255, float64(v.G)/255, float64(v.B)/255)
+			if !strings.Contains(pdf, rgb+" RG") {
+				t.Errorf("%s: expected branches stroked with the color of %s (%s)", layout, v.Label, rgb)
+			}
+			if !strings.Contains(pdf, rgb+" rg") {
+				t.Errorf("%s: expected a legend marker filled with the color of %s (%s)", layout, v.Label, rgb)
+			}
+		}
+	}
+}
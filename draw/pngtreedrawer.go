@@ -40,6 +40,7 @@ func NewPngTreeDrawer(w io.Writer, width, height int, leftmargin, rightmargin, t
 		legendWidth:  legendWidth,
 		legendHeight: legendHeight,
 		dTip:         2.0,
+		lineColor:    color.RGBA{0x00, 0x00, 0x00, 0xff},
 	}
 	totalW := width + leftmargin + rightmargin
 	if legendWidth > totalW {
@@ -82,6 +83,7 @@ type pngTreeDrawer struct {
 	maxLength     float64                   // Maximum length of object to draw (in original scale)
 	maxNameLength int                       // Maximum length of species names / horitzontal
 	maxNameHeight int                       // Maximum length of species names / vertical
+	lineColor     color.Color               // Color of lines and curves
 }
 
 func (ptd *pngTreeDrawer) SetMaxValues(maxLength, maxHeight float64, maxNameLength, maxNameHeight int) {
@@ -95,8 +97,8 @@ func (ptd *pngTreeDrawer) DrawHLine(x1, x2, y float64) {
 	min := float64(ptd.width-ptd.maxNameLength)*x1/ptd.maxLength + float64(ptd.leftmargin)
 	max := float64(ptd.width-ptd.maxNameLength)*x2/ptd.maxLength + float64(ptd.leftmargin)
 	ypos := float64(ptd.height-ptd.maxNameHeight)*y/ptd.maxHeight + float64(ptd.topmargin)
-	ptd.gc.SetFillColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
-	ptd.gc.SetStrokeColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	ptd.gc.SetFillColor(ptd.lineColor)
+	ptd.gc.SetStrokeColor(ptd.lineColor)
 	ptd.gc.SetLineWidth(2)
 	ptd.gc.MoveTo(min, ypos)
 	ptd.gc.LineTo(max, ypos)
@@ -108,8 +110,8 @@ func (ptd *pngTreeDrawer) DrawVLine(x, y1, y2 float64) {
 	min := float64(ptd.height-ptd.maxNameHeight)*y1/ptd.maxHeight + float64(ptd.topmargin)
 	max := float64(ptd.height-ptd.maxNameHeight)*y2/ptd.maxHeight + float64(ptd.topmargin)
 	xpos := float64(ptd.width-ptd.maxNameLength)*x/ptd.maxLength + float64(ptd.leftmargin)
-	ptd.gc.SetFillColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
-	ptd.gc.SetStrokeColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	ptd.gc.SetFillColor(ptd.lineColor)
+	ptd.gc.SetStrokeColor(ptd.lineColor)
 	ptd.gc.SetLineWidth(2)
 	ptd.gc.MoveTo(xpos, min)
 	ptd.gc.LineTo(xpos, max)
@@ -123,8 +125,8 @@ func (ptd *pngTreeDrawer) DrawLine(x1, y1, x2, y2 float64) {
 	x1pos := float64(ptd.width-ptd.maxNameLength)*x1/ptd.maxLength + float64(ptd.leftmargin)
 	x2pos := float64(ptd.width-ptd.maxNameLength)*x2/ptd.maxLength + float64(ptd.leftmargin)
 
-	ptd.gc.SetFillColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
-	ptd.gc.SetStrokeColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	ptd.gc.SetFillColor(ptd.lineColor)
+	ptd.gc.SetStrokeColor(ptd.lineColor)
 	ptd.gc.SetLineWidth(2)
 	ptd.gc.MoveTo(x1pos, y1pos)
 	ptd.gc.LineTo(x2pos, y2pos)
@@ -140,7 +142,7 @@ func (ptd *pngTreeDrawer) DrawCurve(centerx, centery float64, middlex, middley f
 	radiusscaled := math.Sqrt(math.Pow((middley2-centery2), 2) + math.Pow((middlex2-centerx2), 2))
 
 	ptd.gc.SetFillColor(color.RGBA{0x00, 0x00, 0x00, 0x00})
-	ptd.gc.SetStrokeColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	ptd.gc.SetStrokeColor(ptd.lineColor)
 	ptd.gc.SetLineWidth(2)
 	ptd.gc.ArcTo(centerx2, centery2, radiusscaled, radiusscaled, startAngle, endAngle-startAngle)
 	ptd.gc.Stroke()
@@ -158,6 +160,28 @@ func (ptd *pngTreeDrawer) DrawCircle(x, y float64) {
 	ptd.gc.FillStroke()
 }
 
+func (ptd *pngTreeDrawer) SetLineColor(r, g, b, a uint8) {
+	ptd.lineColor = color.NRGBA{r, g, b, a}
+}
+
+func (ptd *pngTreeDrawer) DrawPolygon(xs, ys []float64, r, g, b, a uint8) {
+	if len(xs) < 3 {
+		return
+	}
+	for i := range xs {
+		xpos := float64(ptd.width-ptd.maxNameLength)*xs[i]/ptd.maxLength + float64(ptd.leftmargin)
+		ypos := float64(ptd.height-ptd.maxNameHeight)*ys[i]/ptd.maxHeight + float64(ptd.topmargin)
+		if i == 0 {
+			ptd.gc.MoveTo(xpos, ypos)
+		} else {
+			ptd.gc.LineTo(xpos, ypos)
+		}
+	}
+	ptd.gc.Close()
+	ptd.gc.SetFillColor(color.NRGBA{r, g, b, a})
+	ptd.gc.Fill()
+}
+
 func (ptd *pngTreeDrawer) SetTipLabelOffset(px float64) {
 	ptd.dTip = px
 }
@@ -266,6 +290,46 @@ func (ptd *pngTreeDrawer) DrawName(x, y float64, name string, angle float64) {
 	}
 }
 
+func (ptd *pngTreeDrawer) DrawTick(x, y float64, length float64, label string) {
+	ypos := float64(ptd.height-ptd.maxNameHeight)*y/ptd.maxHeight + float64(ptd.topmargin)
+	xpos := float64(ptd.width-ptd.maxNameLength)*x/ptd.maxLength + float64(ptd.leftmargin)
+	if length > 0 {
+		ptd.drawThinLine(xpos, ypos, xpos, ypos+length)
+	}
+	if label != "" {
+		ptd.drawCenteredText(xpos, ypos+length+tickLabelGap, label)
+	}
+}
+
+// DrawScaleBar draws the scale bar in the bottom margin, starting
+// at the left of the tree area
+func (ptd *pngTreeDrawer) DrawScaleBar(length float64, label string) {
+	x1 := float64(ptd.leftmargin)
+	x2 := x1 + float64(ptd.width-ptd.maxNameLength)*length/ptd.maxLength
+	y := float64(ptd.topmargin+ptd.height) + scaleBarGap
+	ptd.drawThinLine(x1, y, x2, y)
+	for _, x := range []float64{x1, x2} {
+		ptd.drawThinLine(x, y-scaleBarTick, x, y+scaleBarTick)
+	}
+	ptd.drawCenteredText((x1+x2)/2, y+scaleBarTick+tickLabelGap, label)
+}
+
+// Draws a black line of width 1, in pixel coordinates
+func (ptd *pngTreeDrawer) drawThinLine(x1, y1, x2, y2 float64) {
+	ptd.gc.SetStrokeColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	ptd.gc.SetLineWidth(1)
+	ptd.gc.MoveTo(x1, y1)
+	ptd.gc.LineTo(x2, y2)
+	ptd.gc.Stroke()
+}
+
+// Draws the text horizontally centered on x, its top at y, in pixel coordinates
+func (ptd *pngTreeDrawer) drawCenteredText(x, y float64, text string) {
+	ptd.gc.SetFillColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	left, top, right, bottom := ptd.gc.GetStringBounds(text)
+	ptd.gc.FillStringAt(text, x-(right-left)/2.0, y+(bottom-top))
+}
+
 // DrawLegend draws a legend box in the bottom-left corner of the image, in
 // absolute pixel coordinates (independent of the tree's data-space
 // transform), listing each metadata field's name, marker shape, and
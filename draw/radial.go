@@ -21,6 +21,11 @@ type radialLayout struct {
 	metaShapes             []Shape
 	metaValues             map[string][]TipMetaColor
 	metaLegend             []LegendEntry
+	branchColors           map[*tree.Node]TipMetaColor
+	branchLegend           []LegendEntry
+	highlights             []CladeHighlight
+	scaleBar               bool
+	daylight               bool // Equal-daylight layout (see NewDaylightLayout)
 }
 
 func NewRadialLayout(td TreeDrawer, withBranchLengths, withTipLabels, withInternalNodeLabels, withSuppportCircles bool) TreeLayout {
@@ -53,6 +58,31 @@ func (layout *radialLayout) SetTipMetadata(fields []string, shapes []Shape, valu
 	layout.metaLegend = legend
 }
 
+func (layout *radialLayout) SetBranchColors(colors map[*tree.Node]TipMetaColor, legend []LegendEntry) {
+	layout.branchColors = colors
+	layout.branchLegend = legend
+}
+
+func (layout *radialLayout) SetCladeHighlights(highlights []CladeHighlight) {
+	layout.highlights = highlights
+}
+
+// Collapsed clades are not drawn in radial layout
+func (layout *radialLayout) SetCollapsedClades(clades []CollapsedClade, style CladeLabelStyle) {
+}
+
+// Time axes are not drawn in radial layout
+func (layout *radialLayout) SetTimeAxis(rootDate float64, format DateFormat) {
+}
+
+func (layout *radialLayout) SetScaleBar(s bool) {
+	layout.scaleBar = s
+}
+
+// Tip panels are not drawn in radial layout
+func (layout *radialLayout) SetTipPanels(panels []TipPanel) {
+}
+
 /*
 Draw the tree on the specific drawer. Does not close the file. The caller must do it.
 This layout is an adaptation in Go of the figtree radial layout : figtree/treeviewer/treelayouts/RadialTreeLayout.java
@@ -63,6 +93,10 @@ func (layout *radialLayout) DrawTree(t *tree.Tree) error {
 	root := t.Root()
 	layout.spread = 0.0
 	layout.constructNode(t, root, nil, 0.0, 0.0, math.Pi*2, 0.0, 0.0, 0.0)
+	if layout.daylight {
+		layout.equalDaylight(t)
+	}
+	layout.highlightClades(t)
 	layout.drawTree()
 	layout.drawer.Write()
 	return nil
@@ -79,6 +113,7 @@ func (layout *radialLayout) constructNode(t *tree.Tree, node *tree.Node, prev *t
 	//fmt.Fprintf(os.Stderr, "Node %s: angleStart: %f, angleFinish: %f, branchAngle: %f, directionX: %f, directionY: %f, newXPosition: %f, newYPosition: %f, length: %f, xPosition: %f, yPosition: %f\n", node.Name(), angleStart, angleFinish, branchAngle, directionX, directionY, newXPosition, newYPosition, length, xPosition, yPosition)
 
 	nodePoint := &layoutPoint{newXPosition, newYPosition, branchAngle, node.Name(), node.CommentsString()}
+	layout.cache.points[node] = nodePoint
 
 	if !node.Tip() {
 		leafCounts := make([]int, 0)
@@ -118,7 +153,7 @@ func (layout *radialLayout) constructNode(t *tree.Tree, node *tree.Node, prev *t
 				a1 := a2
 				a2 = a1 + (span * float64(leafCounts[index]) / float64(sumLeafCount))
 				childPoint := layout.constructNode(t, child, node, supp, a1, a2, nodePoint.x, nodePoint.y, brLen)
-				branchLine := &layoutLine{childPoint, nodePoint, supp}
+				branchLine := &layoutLine{childPoint, nodePoint, supp, child}
 				//add the branchLine to the map of branch paths
 				layout.cache.branchPaths = append(layout.cache.branchPaths, branchLine)
 				i++
@@ -131,6 +166,21 @@ func (layout *radialLayout) constructNode(t *tree.Tree, node *tree.Node, prev *t
 	return nodePoint
 }
 
+// Computes the background polygons of the highlighted clades: convex hull
+// of the nodes of the clade and of the middle of the branch above it
+func (layout *radialLayout) highlightClades(t *tree.Tree) {
+	for _, h := range layout.highlights {
+		pts := subtreePoints(t, h.Node, layout.cache)
+		for _, l := range layout.cache.branchPaths {
+			if l.node == h.Node {
+				pts = append(pts, &layoutPoint{(l.p1.x + l.p2.x) / 2.0, (l.p1.y + l.p2.y) / 2.0, 0.0, "", ""})
+			}
+		}
+		xs, ys := convexHull(pts)
+		layout.cache.highlights = append(layout.cache.highlights, &layoutPolygon{xs, ys, h.R, h.G, h.B, h.A})
+	}
+}
+
 // drawTree renders the accumulated geometry. It does not shrink the
 // drawable area for tip labels the way normalLayout does (via
 // TreeDrawer.SetMaxValues' maxNameLength/maxNameHeight): radial labels fan
@@ -163,9 +213,12 @@ func (layout *radialLayout) drawTree() {
 
 	layout.drawer.SetMaxValues(xmax+xoffset, ymax+yoffset, 0, 0)
 
+	drawHighlights(layout.drawer, layout.cache.highlights, xoffset, yoffset)
 	for _, l := range layout.cache.branchPaths {
+		setBranchColor(layout.drawer, layout.branchColors, l.node)
 		layout.drawer.DrawLine(l.p1.x+xoffset, l.p1.y+yoffset, l.p2.x+xoffset, l.p2.y+yoffset)
 	}
+	layout.drawer.SetLineColor(0, 0, 0, 0xff)
 	if len(layout.metaFields) > 0 {
 		layout.drawer.SetTipLabelOffset(metaLabelOffset(len(layout.metaFields)))
 	}
@@ -215,7 +268,11 @@ func (layout *radialLayout) drawTree() {
 		}
 	}
 
-	if len(layout.metaLegend) > 0 {
-		layout.drawer.DrawLegend(layout.metaLegend)
+	if legend := legendEntries(layout.metaLegend, layout.branchLegend); len(legend) > 0 {
+		layout.drawer.DrawLegend(legend)
+	}
+	if layout.scaleBar {
+		length := scaleBarLength((xmax + xoffset) / 2.0)
+		layout.drawer.DrawScaleBar(length, scaleBarLabel(length))
 	}
 }
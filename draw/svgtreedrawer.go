@@ -29,6 +29,8 @@ func NewSvgTreeDrawer(w io.Writer, width, height int,
 		legendWidth:  legendWidth,
 		legendHeight: legendHeight,
 		dTip:         1.0,
+		lineStyle:    svgDefaultLineStyle,
+		curveStyle:   svgDefaultCurveStyle,
 	}
 	svgtd.canvas = svg.New(w)
 	totalW := width + leftmargin + rightmargin
@@ -69,20 +71,28 @@ type svgTreeDrawer struct {
 	maxHeight     float64   // Maximum height of object to draw (in original scale)
 	maxNameLength int       // Maximum length of species names / horitzontal
 	maxNameHeight int       // Maximum length of species names / vertical
+	lineStyle     string    // Style of lines
+	curveStyle    string    // Style of curves
 }
 
+const (
+	svgDefaultLineStyle  = "stroke-width:2; fill:black; stroke: black;"
+	svgDefaultCurveStyle = "stroke-width:2; fill:none;stroke: black;"
+	svgTickStyle         = "stroke-width:1; stroke: black;"
+)
+
 func (svgtd *svgTreeDrawer) DrawHLine(x1, x2, y float64) {
 	min := int(float64(svgtd.width-svgtd.maxNameLength)*x1/svgtd.maxLength + float64(svgtd.leftmargin))
 	max := int(float64(svgtd.width-svgtd.maxNameLength)*x2/svgtd.maxLength + float64(svgtd.leftmargin))
 	ypos := int(float64(svgtd.height-svgtd.maxNameHeight)*y/svgtd.maxHeight + float64(svgtd.topmargin))
-	svgtd.canvas.Line(min, ypos, max, ypos, "stroke-width:2; fill:black; stroke: black;")
+	svgtd.canvas.Line(min, ypos, max, ypos, svgtd.lineStyle)
 }
 
 func (svgtd *svgTreeDrawer) DrawVLine(x, y1, y2 float64) {
 	min := int(float64(svgtd.height-svgtd.maxNameHeight)*y1/svgtd.maxHeight + float64(svgtd.topmargin))
 	max := int(float64(svgtd.height-svgtd.maxNameHeight)*y2/svgtd.maxHeight + float64(svgtd.topmargin))
 	xpos := int(float64(svgtd.width-svgtd.maxNameLength)*x/svgtd.maxLength + float64(svgtd.leftmargin))
-	svgtd.canvas.Line(xpos, min, xpos, max, "stroke-width:2; fill:black; stroke: black;")
+	svgtd.canvas.Line(xpos, min, xpos, max, svgtd.lineStyle)
 }
 
 func (svgtd *svgTreeDrawer) DrawLine(x1, y1, x2, y2 float64) {
@@ -90,7 +100,7 @@ func (svgtd *svgTreeDrawer) DrawLine(x1, y1, x2, y2 float64) {
 	y2pos := int(float64(svgtd.height-svgtd.maxNameHeight)*y2/svgtd.maxHeight + float64(svgtd.topmargin))
 	x1pos := int(float64(svgtd.width-svgtd.maxNameLength)*x1/svgtd.maxLength + float64(svgtd.leftmargin))
 	x2pos := int(float64(svgtd.width-svgtd.maxNameLength)*x2/svgtd.maxLength + float64(svgtd.leftmargin))
-	svgtd.canvas.Line(x1pos, y1pos, x2pos, y2pos, "stroke-width:2; fill:black; stroke: black;")
+	svgtd.canvas.Line(x1pos, y1pos, x2pos, y2pos, svgtd.lineStyle)
 }
 
 func (svgtd *svgTreeDrawer) DrawCurve(centerx, centery, middlex, middley float64, radius float64, startAngle, endAngle float64) {
@@ -107,7 +117,7 @@ func (svgtd *svgTreeDrawer) DrawCurve(centerx, centery, middlex, middley float64
 	if endAngle-startAngle < math.Pi {
 		largeArcFlag = false
 	}
-	svgtd.canvas.Arc(round(x1), round(y1), radiusscaled, radiusscaled, 0, largeArcFlag, true, round(x2), round(y2), "stroke-width:2; fill:none;stroke: black;")
+	svgtd.canvas.Arc(round(x1), round(y1), radiusscaled, radiusscaled, 0, largeArcFlag, true, round(x2), round(y2), svgtd.curveStyle)
 }
 
 func (svgtd *svgTreeDrawer) DrawCircle(x, y float64) {
@@ -116,6 +126,31 @@ func (svgtd *svgTreeDrawer) DrawCircle(x, y float64) {
 	svgtd.canvas.Circle(round(centerx2), round(centery2), 5, "stroke-width:1; fill:orange;stroke: black;")
 }
 
+func (svgtd *svgTreeDrawer) SetLineColor(r, g, b, a uint8) {
+	if r == 0 && g == 0 && b == 0 && a == 0xff {
+		svgtd.lineStyle = svgDefaultLineStyle
+		svgtd.curveStyle = svgDefaultCurveStyle
+		return
+	}
+	color := fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	opacity := float64(a) / 255.0
+	svgtd.lineStyle = fmt.Sprintf("stroke-width:2; fill:%s; stroke: %s;stroke-opacity:%.3f;", color, color, opacity)
+	svgtd.curveStyle = fmt.Sprintf("stroke-width:2; fill:none;stroke: %s;stroke-opacity:%.3f;", color, opacity)
+}
+
+func (svgtd *svgTreeDrawer) DrawPolygon(xs, ys []float64, r, g, b, a uint8) {
+	if len(xs) < 3 {
+		return
+	}
+	xpos := make([]int, len(xs))
+	ypos := make([]int, len(ys))
+	for i := range xs {
+		xpos[i] = round(float64(svgtd.width-svgtd.maxNameLength)*xs[i]/svgtd.maxLength + float64(svgtd.leftmargin))
+		ypos[i] = round(float64(svgtd.height-svgtd.maxNameHeight)*ys[i]/svgtd.maxHeight + float64(svgtd.topmargin))
+	}
+	svgtd.canvas.Polygon(xpos, ypos, fmt.Sprintf("stroke:none;fill:#%02x%02x%02x;fill-opacity:%.3f;", r, g, b, float64(a)/255.0))
+}
+
 func (svgtd *svgTreeDrawer) SetTipLabelOffset(px float64) {
 	svgtd.dTip = px
 }
@@ -208,6 +243,30 @@ func (svgtd *svgTreeDrawer) DrawName(x, y float64, name string, angle float64) {
 	svgtd.canvas.Gend()
 }
 
+func (svgtd *svgTreeDrawer) DrawTick(x, y float64, length float64, label string) {
+	ypos := float64(svgtd.height-svgtd.maxNameHeight)*y/svgtd.maxHeight + float64(svgtd.topmargin)
+	xpos := float64(svgtd.width-svgtd.maxNameLength)*x/svgtd.maxLength + float64(svgtd.leftmargin)
+	if length > 0 {
+		svgtd.canvas.Line(round(xpos), round(ypos), round(xpos), round(ypos+length), svgTickStyle)
+	}
+	if label != "" {
+		svgtd.canvas.Text(round(xpos), round(ypos+length+tickLabelGap+8), label, "text-anchor:middle;font-family:sans-serif;font-size:8px;")
+	}
+}
+
+// DrawScaleBar draws the scale bar in the bottom margin, starting
+// at the left of the tree area
+func (svgtd *svgTreeDrawer) DrawScaleBar(length float64, label string) {
+	x1 := float64(svgtd.leftmargin)
+	x2 := x1 + float64(svgtd.width-svgtd.maxNameLength)*length/svgtd.maxLength
+	y := float64(svgtd.topmargin+svgtd.height) + scaleBarGap
+	svgtd.canvas.Line(round(x1), round(y), round(x2), round(y), svgTickStyle)
+	for _, x := range []float64{x1, x2} {
+		svgtd.canvas.Line(round(x), round(y-scaleBarTick), round(x), round(y+scaleBarTick), svgTickStyle)
+	}
+	svgtd.canvas.Text(round((x1+x2)/2), round(y+scaleBarTick+tickLabelGap+8), label, "text-anchor:middle;font-family:sans-serif;font-size:8px;")
+}
+
 // DrawLegend draws a legend box in the bottom-left corner of the image, in
 // absolute pixel coordinates (independent of the tree's data-space
 // transform), listing each metadata field's name, marker shape, and
@@ -0,0 +1,367 @@
+package draw
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+const (
+	// tanglegramMaxRounds is the maximum number of untangling rounds
+	// (each round reorders both trees once).
+	tanglegramMaxRounds = 20
+	// tanglegramLinkRatio is the fraction of the image width reserved
+	// for the links between the two trees.
+	tanglegramLinkRatio = 0.15
+	// tanglegramLabelGap is the pixel gap between a tip label and its link.
+	tanglegramLabelGap = 4.0
+)
+
+// TanglegramLayout draws two trees face to face: the first one from left to
+// right, the second one mirrored from right to left, and tips having the same
+// name in both trees are linked.
+type TanglegramLayout interface {
+	DrawTrees(t1, t2 *tree.Tree) error
+	// If true (default), internal nodes are rotated so that links cross as little as possible
+	SetUntangle(bool)
+	// If true, branches defining splits that are not in the other tree are highlighted
+	SetHighlightDifferences(bool)
+}
+
+type tanglegramLayout struct {
+	drawer           TreeDrawer
+	textWidth        TextWidthFunc
+	hasBranchLengths bool
+	hasTipLabels     bool
+	untangle         bool
+	highlightDiffs   bool
+	linkx1, linkx2   float64 // Horizontal bounds of the link band
+}
+
+// A tree with the drawing order of the children of its nodes
+type tangleTree struct {
+	t        *tree.Tree
+	children map[*tree.Node][]*tree.Node
+	diffs    map[*tree.Node]bool // true if the split above the node is not in the other tree
+	cache    *layoutCache        // Lines and points, computed by layout
+}
+
+// NewTanglegramLayout draws tanglegrams on the given drawer.
+// textWidth measures tip labels the way the drawer renders them
+// (SvgTextWidth or PngTextWidth), to place the links after the labels.
+func NewTanglegramLayout(td TreeDrawer, textWidth TextWidthFunc, withBranchLengths, withTipLabels bool) TanglegramLayout {
+	return &tanglegramLayout{
+		drawer:           td,
+		textWidth:        textWidth,
+		hasBranchLengths: withBranchLengths,
+		hasTipLabels:     withTipLabels,
+		untangle:         true,
+	}
+}
+
+func (layout *tanglegramLayout) SetUntangle(u bool) {
+	layout.untangle = u
+}
+
+func (layout *tanglegramLayout) SetHighlightDifferences(h bool) {
+	layout.highlightDiffs = h
+}
+
+/*
+Draw the two trees on the drawer. Does not close the file. The caller must do it.
+*/
+func (layout *tanglegramLayout) DrawTrees(t1, t2 *tree.Tree) (err error) {
+	tt1 := newTangleTree(t1)
+	tt2 := newTangleTree(t2)
+
+	if layout.highlightDiffs {
+		if err = tangleDifferences(tt1, tt2); err != nil {
+			return
+		}
+	}
+	if layout.untangle {
+		untangle(tt1, tt2)
+	}
+
+	// Drawing coordinates are in pixels horizontally,
+	// and in tip indices vertically
+	width, _ := layout.drawer.Bounds()
+	namew1, namew2 := layout.maxNameWidth(tt1), layout.maxNameWidth(tt2)
+	linkw := math.Max(30.0, tanglegramLinkRatio*float64(width))
+	treew := (float64(width) - namew1 - namew2 - linkw) / 2.0
+	if treew <= 0 {
+		return errors.New("image is too narrow to draw the tanglegram")
+	}
+	ntips := math.Max(float64(len(t1.Tips())), float64(len(t2.Tips())))
+	layout.drawer.SetMaxValues(float64(width), ntips, 0, 0)
+	layout.linkx1 = treew + namew1
+	layout.linkx2 = float64(width) - treew - namew2
+
+	layout.layoutTree(tt1, treew, ntips, 0, 1)
+	layout.layoutTree(tt2, treew, ntips, float64(width), -1)
+	layout.drawTrees(tt1, tt2)
+	layout.drawer.Write()
+	return
+}
+
+// Maximum width of the tip labels of the tree, in pixels, including the gap
+// between the labels and the links
+func (layout *tanglegramLayout) maxNameWidth(tt *tangleTree) (max float64) {
+	if !layout.hasTipLabels {
+		return tanglegramLabelGap
+	}
+	for _, n := range tt.t.AllTipNames() {
+		if w := layout.textWidth(n, false); w > max {
+			max = w
+		}
+	}
+	return max + 2*tanglegramLabelGap
+}
+
+// Computes the branches of the tree and the tip positions with the normal layout
+// logic. The root is at x=origin, and the tree grows in the given direction
+// (1: right, -1: left).
+func (layout *tanglegramLayout) layoutTree(tt *tangleTree, treew, ntips, origin, direction float64) {
+	maxdist, _ := maxLength(tt.t, layout.hasBranchLengths, false, false)
+	tt.cache = newLayoutCache()
+	pos := newNormalPositions(tt.cache, layout.hasBranchLengths, nil)
+	pos.origin = origin
+	pos.direction = direction
+	pos.scale = treew
+	if maxdist > 0 {
+		pos.scale = treew / maxdist
+	}
+	if n := len(tt.t.Tips()); n > 1 {
+		pos.ystep = (ntips - 1) / float64(n-1)
+	}
+	pos.children = func(n, prev *tree.Node) []*tree.Node {
+		return tt.children[n]
+	}
+	pos.compute(tt.t.Root())
+}
+
+func (layout *tanglegramLayout) drawTrees(tt1, tt2 *tangleTree) {
+	drawNormalBranches(layout.drawer, tt1.cache, nil)
+	drawNormalBranches(layout.drawer, tt2.cache, nil)
+
+	// Links go horizontally from the tip labels to the link band,
+	// in which they join the matching tip of the other tree
+	right := make(map[string]*layoutPoint, len(tt2.cache.tipLabelPoints))
+	for _, p := range tt2.cache.tipLabelPoints {
+		right[p.name] = p
+	}
+	for _, p := range tt1.cache.tipLabelPoints {
+		if p2, ok := right[p.name]; ok {
+			x1, x2 := p.x+tanglegramLabelGap, p2.x-tanglegramLabelGap
+			if layout.hasTipLabels {
+				x1 += layout.textWidth(p.name, false) + tanglegramLabelGap
+				x2 -= layout.textWidth(p2.name, false) + tanglegramLabelGap
+			}
+			if x1 < layout.linkx1 {
+				layout.drawer.DrawLine(x1, p.y, layout.linkx1, p.y)
+			}
+			layout.drawer.DrawLine(layout.linkx1, p.y, layout.linkx2, p2.y)
+			if x2 > layout.linkx2 {
+				layout.drawer.DrawLine(layout.linkx2, p2.y, x2, p2.y)
+			}
+		}
+	}
+
+	if layout.hasTipLabels {
+		for _, p := range tt1.cache.tipLabelPoints {
+			layout.drawer.DrawName(p.x, p.y, p.name, 0.0)
+		}
+		for _, p := range tt2.cache.tipLabelPoints {
+			layout.drawer.DrawName(p.x, p.y, p.name, math.Pi)
+		}
+	}
+
+	// Splits that are not in the other tree are highlighted like
+	// highly supported branches in the other layouts
+	for _, tt := range []*tangleTree{tt1, tt2} {
+		for _, l := range tt.cache.horizontalPaths {
+			if tt.diffs[l.node] {
+				layout.drawer.DrawCircle((l.x1+l.x2)/2.0, l.y)
+			}
+		}
+	}
+}
+
+func newTangleTree(t *tree.Tree) *tangleTree {
+	tt := &tangleTree{
+		t:        t,
+		children: make(map[*tree.Node][]*tree.Node),
+		diffs:    make(map[*tree.Node]bool),
+	}
+	t.PreOrder(func(cur, prev *tree.Node, e *tree.Edge) bool {
+		if prev != nil {
+			tt.children[prev] = append(tt.children[prev], cur)
+		}
+		return true
+	})
+	return tt
+}
+
+// Flags the internal branches of both trees whose splits
+// are not in the other tree. Trees must have the same tips.
+func tangleDifferences(tt1, tt2 *tangleTree) (err error) {
+	if err = tt1.t.ReinitIndexes(); err != nil {
+		return
+	}
+	if err = tt2.t.ReinitIndexes(); err != nil {
+		return
+	}
+	if err = tt1.t.CompareTipIndexes(tt2.t); err != nil {
+		return
+	}
+	flag := func(tt, other *tangleTree) {
+		edges := other.t.Edges()
+		index := tree.NewEdgeIndex(uint64(len(edges)*2), 0.75)
+		for _, e := range edges {
+			index.PutEdgeValue(e, 1, e.Length())
+		}
+		tt.t.PreOrder(func(cur, prev *tree.Node, e *tree.Edge) bool {
+			if e != nil && !cur.Tip() {
+				if _, ok := index.Value(e); !ok {
+					tt.diffs[cur] = true
+				}
+			}
+			return true
+		})
+	}
+	flag(tt1, tt2)
+	flag(tt2, tt1)
+	return
+}
+
+// Rotates internal nodes of both trees, alternately ordering the children of
+// each node by the mean position of their tips in the other tree (barycenter
+// heuristic), and keeps the orders giving the fewest crossing links.
+func untangle(tt1, tt2 *tangleTree) {
+	best := tangleCrossings(tt1, tt2)
+	best1, best2 := tt1.copyOrder(), tt2.copyOrder()
+	for round := 0; round < tanglegramMaxRounds && best > 0; round++ {
+		tt2.reorder(tt1.tipPositions())
+		tt1.reorder(tt2.tipPositions())
+		c := tangleCrossings(tt1, tt2)
+		if c >= best {
+			break
+		}
+		best = c
+		best1, best2 = tt1.copyOrder(), tt2.copyOrder()
+	}
+	tt1.children, tt2.children = best1, best2
+}
+
+func (tt *tangleTree) copyOrder() map[*tree.Node][]*tree.Node {
+	order := make(map[*tree.Node][]*tree.Node, len(tt.children))
+	for n, c := range tt.children {
+		order[n] = append([]*tree.Node(nil), c...)
+	}
+	return order
+}
+
+// Tips in drawing order
+func (tt *tangleTree) orderedTips() (tips []*tree.Node) {
+	var recur func(n *tree.Node)
+	recur = func(n *tree.Node) {
+		if n.Tip() {
+			tips = append(tips, n)
+		}
+		for _, c := range tt.children[n] {
+			recur(c)
+		}
+	}
+	recur(tt.t.Root())
+	return
+}
+
+// Position of each tip in drawing order, normalized between 0 and 1
+func (tt *tangleTree) tipPositions() map[string]float64 {
+	tips := tt.orderedTips()
+	pos := make(map[string]float64, len(tips))
+	for i, t := range tips {
+		pos[t.Name()] = 0
+		if len(tips) > 1 {
+			pos[t.Name()] = float64(i) / float64(len(tips)-1)
+		}
+	}
+	return pos
+}
+
+// Orders the children of each node by the mean position of their tips in
+// the other tree. Tips that are not in the other tree keep their own position.
+func (tt *tangleTree) reorder(other map[string]float64) {
+	own := tt.tipPositions()
+	var recur func(n *tree.Node) (sum float64, count int)
+	recur = func(n *tree.Node) (sum float64, count int) {
+		if n.Tip() {
+			if p, ok := other[n.Name()]; ok {
+				return p, 1
+			}
+			return own[n.Name()], 1
+		}
+		children := tt.children[n]
+		keys := make(map[*tree.Node]float64, len(children))
+		for _, c := range children {
+			s, k := recur(c)
+			keys[c] = s / float64(k)
+			sum += s
+			count += k
+		}
+		sort.SliceStable(children, func(i, j int) bool {
+			return keys[children[i]] < keys[children[j]]
+		})
+		return
+	}
+	recur(tt.t.Root())
+}
+
+// Number of pairs of links that cross
+func tangleCrossings(tt1, tt2 *tangleTree) int {
+	pos2 := make(map[string]int)
+	for i, t := range tt2.orderedTips() {
+		pos2[t.Name()] = i
+	}
+	seq := make([]int, 0, len(pos2))
+	for _, t := range tt1.orderedTips() {
+		if p, ok := pos2[t.Name()]; ok {
+			seq = append(seq, p)
+		}
+	}
+	return countInversions(seq)
+}
+
+// Counts the inversions of the sequence by merge sort
+func countInversions(seq []int) int {
+	if len(seq) < 2 {
+		return 0
+	}
+	mid := len(seq) / 2
+	left := append([]int(nil), seq[:mid]...)
+	right := append([]int(nil), seq[mid:]...)
+	inv := countInversions(left) + countInversions(right)
+	i, j, k := 0, 0, 0
+	for i < len(left) && j < len(right) {
+		if left[i] <= right[j] {
+			seq[k] = left[i]
+			i++
+		} else {
+			seq[k] = right[j]
+			inv += len(left) - i
+			j++
+		}
+		k++
+	}
+	for ; i < len(left); i++ {
+		seq[k] = left[i]
+		k++
+	}
+	for ; j < len(right); j++ {
+		seq[k] = right[j]
+		k++
+	}
+	return inv
+}
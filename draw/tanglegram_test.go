@@ -0,0 +1,76 @@
+package draw
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCountInversions(t *testing.T) {
+	if c := countInversions([]int{0, 1, 2, 3}); c != 0 {
+		t.Errorf("expected 0 inversions, got %d", c)
+	}
+	if c := countInversions([]int{3, 2, 1, 0}); c != 6 {
+		t.Errorf("expected 6 inversions, got %d", c)
+	}
+	if c := countInversions([]int{1, 0, 3, 2}); c != 2 {
+		t.Errorf("expected 2 inversions, got %d", c)
+	}
+}
+
+func TestTanglegram_UntangleRotations(t *testing.T) {
+	// Same topology, with all internal nodes rotated
+	tt1 := newTangleTree(parseTestTree(t, "(((A,B),(C,D)),((E,F),(G,H)));"))
+	tt2 := newTangleTree(parseTestTree(t, "(((H,G),(F,E)),((D,C),(B,A)));"))
+
+	if c := tangleCrossings(tt1, tt2); c != 28 {
+		t.Fatalf("expected 28 crossings before untangling, got %d", c)
+	}
+	untangle(tt1, tt2)
+	if c := tangleCrossings(tt1, tt2); c != 0 {
+		t.Errorf("expected no crossing after untangling, got %d", c)
+	}
+}
+
+func TestTanglegram_HighlightDifferences(t *testing.T) {
+	tt1 := newTangleTree(parseTestTree(t, "((A,B),(C,D),E);"))
+	tt2 := newTangleTree(parseTestTree(t, "((A,C),(B,D),E);"))
+	if err := tangleDifferences(tt1, tt2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tt1.diffs) != 2 || len(tt2.diffs) != 2 {
+		t.Errorf("expected 2 different splits in each tree, got %d and %d", len(tt1.diffs), len(tt2.diffs))
+	}
+
+	tt3 := newTangleTree(parseTestTree(t, "((A,B),(C,D),E);"))
+	tt4 := newTangleTree(parseTestTree(t, "((A,B),(C,D),F);"))
+	if err := tangleDifferences(tt3, tt4); err == nil {
+		t.Errorf("expected an error for trees with different tips")
+	}
+}
+
+func TestTanglegram_Draw(t *testing.T) {
+	var buf bytes.Buffer
+	d := NewSvgTreeDrawer(&buf, 400, 200, 30, 30, 30, 30, 0, 0)
+	l := NewTanglegramLayout(d, SvgTextWidth, true, true)
+	l.SetHighlightDifferences(true)
+	if err := l.DrawTrees(parseTestTree(t, "((A:1,B:1):1,(C:1,D:1):1,E:1);"), parseTestTree(t, "((A:1,C:1):1,(B:1,D:1):1,E:1);")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svg := buf.String()
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		if strings.Count(svg, ">"+name+"</text>") != 2 {
+			t.Errorf("expected tip %s to be drawn in both trees", name)
+		}
+	}
+	if c := strings.Count(svg, "<circle"); c != 4 {
+		t.Errorf("expected 4 highlighted branches, got %d", c)
+	}
+
+	buf.Reset()
+	d = NewSvgTreeDrawer(&buf, 20, 200, 30, 30, 30, 30, 0, 0)
+	l = NewTanglegramLayout(d, SvgTextWidth, true, true)
+	if err := l.DrawTrees(parseTestTree(t, "((A,B),(C,D),E);"), parseTestTree(t, "((A,B),(C,D),E);")); err == nil {
+		t.Errorf("expected an error for a too narrow image")
+	}
+}
@@ -97,6 +97,14 @@ func (ttd *textTreeDrawer) DrawCircle(x, y float64) {
 	ttd.textCanvas[int(ypos)][int(xpos)] = '*'
 }
 
+func (ttd *textTreeDrawer) SetLineColor(r, g, b, a uint8) {
+	// Colors are not supported in ascii rendering.
+}
+
+func (ttd *textTreeDrawer) DrawPolygon(xs, ys []float64, r, g, b, a uint8) {
+	// Polygons are not supported in ascii rendering.
+}
+
 func (ttd *textTreeDrawer) SetTipLabelOffset(px float64) {
 	// Not applicable to ascii rendering.
 }
@@ -109,6 +117,14 @@ func (ttd *textTreeDrawer) DrawLegend(entries []LegendEntry) {
 	// Legends are not supported in ascii rendering.
 }
 
+func (ttd *textTreeDrawer) DrawTick(x, y float64, length float64, label string) {
+	// Axes are not supported in ascii rendering.
+}
+
+func (ttd *textTreeDrawer) DrawScaleBar(length float64, label string) {
+	// Scale bars are not supported in ascii rendering.
+}
+
 func (ttd *textTreeDrawer) DrawName(x, y float64, name string, angle float64) {
 	ypos := float64(ttd.height-ttd.maxNameHeight) * y / ttd.maxHeight
 	xpos := float64(ttd.width-ttd.maxNameLength) * x / ttd.maxLength
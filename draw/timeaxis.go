@@ -0,0 +1,156 @@
+package draw
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFormat is the way dates are written on time axes
+type DateFormat int
+
+const (
+	DateDecimal DateFormat = iota // Decimal years (e.g. 2019.5)
+	DateISO                       // Calendar dates (e.g. 2019-07-02)
+)
+
+const (
+	// timeAxisTicks is the maximum number of ticks of a time axis.
+	timeAxisTicks = 6
+	// timeAxisHeight is the space (in name units of 5px, see
+	// TreeDrawer.SetMaxValues' maxNameHeight) reserved below the tree for the axis.
+	timeAxisHeight = 4
+	// tickLength is the pixel length of axis ticks.
+	tickLength = 4.0
+	// tickLabelGap is the pixel gap between the end of a tick and the top of its label.
+	tickLabelGap = 2.0
+	// scaleBarGap is the pixel gap between the tree area and the scale bar.
+	scaleBarGap = 8.0
+	// scaleBarTick is the pixel half-length of the ticks at both ends of the scale bar.
+	scaleBarTick = 3.0
+)
+
+// Color of the concentric time circles of the circular layout
+var timeCircleColor = TipMetaColor{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}
+
+// ParseDateFormat returns the date format corresponding to
+// the given name: "decimal" or "iso".
+func ParseDateFormat(format string) (DateFormat, error) {
+	switch strings.ToLower(format) {
+	case "decimal":
+		return DateDecimal, nil
+	case "iso":
+		return DateISO, nil
+	}
+	return DateDecimal, fmt.Errorf("unknown date format %q (decimal or iso)", format)
+}
+
+// Smallest number of the form {1,2,5}x10^k greater than or equal to x
+func niceStep(x float64) float64 {
+	pow := math.Pow(10, math.Floor(math.Log10(x)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*pow >= x*(1-1e-9) {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+// Length of the scale bar of a tree whose drawing spans the given
+// extent: the largest number of the form {1,2,5}x10^k, at most a fifth of it
+func scaleBarLength(extent float64) float64 {
+	x := extent / 5.0
+	pow := math.Pow(10, math.Floor(math.Log10(x)))
+	switch m := x / pow; {
+	case m >= 5:
+		return 5 * pow
+	case m >= 2:
+		return 2 * pow
+	}
+	return pow
+}
+
+// Label of the scale bar of the given length
+func scaleBarLabel(length float64) string {
+	return strconv.FormatFloat(length, 'g', 6, 64)
+}
+
+// Decimal year of the given time, computed as tree.ParseDate does
+func decimalYear(t time.Time) float64 {
+	year := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	nextyear := time.Date(t.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	return float64(t.Year()) + t.Sub(year).Hours()/nextyear.Sub(year).Hours()
+}
+
+// Time corresponding to the given decimal year
+func yearTime(date float64) time.Time {
+	y := int(math.Floor(date))
+	year := time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextyear := time.Date(y+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	return year.Add(time.Duration((date - float64(y)) * float64(nextyear.Sub(year))))
+}
+
+// Dates (decimal years) and labels of the ticks of a time axis going
+// from start to end, at most maxTicks. Decimal ticks are round numbers;
+// ISO ticks are the first days of years, of months, or days, depending
+// on the time span.
+func timeTicks(start, end float64, format DateFormat, maxTicks int) (dates []float64, labels []string) {
+	if end <= start {
+		return
+	}
+	if format == DateDecimal {
+		step := niceStep((end - start) / float64(maxTicks))
+		decimals := max(0, -int(math.Floor(math.Log10(step)+1e-9)))
+		for i := math.Ceil(start / step); i*step <= end+1e-9*step; i++ {
+			dates = append(dates, i*step)
+			labels = append(labels, strconv.FormatFloat(i*step, 'f', decimals, 64))
+		}
+		return
+	}
+
+	span := end - start
+	var t time.Time
+	var next func(time.Time) time.Time
+	switch {
+	case span >= 2:
+		step := int(math.Max(1, niceStep(span/float64(maxTicks))))
+		y := int(math.Ceil(start/float64(step))) * step
+		t = time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+		next = func(t time.Time) time.Time { return t.AddDate(step, 0, 0) }
+	case span*12 >= 2:
+		step := 12
+		for _, m := range []int{1, 2, 3, 6} {
+			if span*12/float64(m) < float64(maxTicks) {
+				step = m
+				break
+			}
+		}
+		s := yearTime(start)
+		t = time.Date(s.Year(), s.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for decimalYear(t) < start-1e-9 || (int(t.Month())-1)%step != 0 {
+			t = t.AddDate(0, 1, 0)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(0, step, 0) }
+	default:
+		step := 14
+		for _, d := range []int{1, 2, 5, 7} {
+			if span*365/float64(d) < float64(maxTicks) {
+				step = d
+				break
+			}
+		}
+		s := yearTime(start)
+		t = time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, time.UTC)
+		if decimalYear(t) < start-1e-9 {
+			t = t.AddDate(0, 0, 1)
+		}
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, step) }
+	}
+	for ; decimalYear(t) <= end+1e-9; t = next(t) {
+		dates = append(dates, decimalYear(t))
+		labels = append(labels, t.Format("2006-01-02"))
+	}
+	return
+}
@@ -0,0 +1,135 @@
+package draw
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+const timeTree = "((A[&date=2019.5]:1.5,B[&date=2020.0]:2.0):1.0,(C[&date=2020.5]:2.0,(D[&date=2020.25]:1.0,E[&date=2019.75]:0.5):0.75):1.5)root;"
+
+func TestTimeTicks_Decimal(t *testing.T) {
+	dates, labels := timeTicks(2017.0, 2020.5, DateDecimal, 6)
+	if !slices.Equal(labels, []string{"2017", "2018", "2019", "2020"}) {
+		t.Errorf("unexpected tick labels: %v", labels)
+	}
+	if len(dates) != 4 || dates[0] != 2017.0 {
+		t.Errorf("unexpected tick dates: %v", dates)
+	}
+	_, labels = timeTicks(2019.93, 2020.31, DateDecimal, 6)
+	if !slices.Equal(labels, []string{"2020.0", "2020.1", "2020.2", "2020.3"}) {
+		t.Errorf("unexpected tick labels: %v", labels)
+	}
+}
+
+func TestTimeTicks_ISO(t *testing.T) {
+	_, labels := timeTicks(2012.3, 2020.5, DateISO, 6)
+	if !slices.Equal(labels, []string{"2014-01-01", "2016-01-01", "2018-01-01", "2020-01-01"}) {
+		t.Errorf("unexpected yearly tick labels: %v", labels)
+	}
+	dates, labels := timeTicks(2020.0, 2020.5, DateISO, 6)
+	if !slices.Equal(labels, []string{"2020-01-01", "2020-03-01", "2020-05-01", "2020-07-01"}) {
+		t.Errorf("unexpected monthly tick labels: %v", labels)
+	}
+	if math.Abs(dates[1]-(2020+60.0/366.0)) > 1e-9 {
+		t.Errorf("expected 2020-03-01 to be the 61st day of the year, got %f", dates[1])
+	}
+	_, labels = timeTicks(2020.0, 2020.05, DateISO, 6)
+	if !slices.Equal(labels, []string{"2020-01-01", "2020-01-06", "2020-01-11", "2020-01-16"}) {
+		t.Errorf("unexpected daily tick labels: %v", labels)
+	}
+}
+
+func TestScaleBarLength(t *testing.T) {
+	for extent, want := range map[float64]float64{3.5: 0.5, 1: 0.2, 0.04: 0.005, 30: 5} {
+		if got := scaleBarLength(extent); math.Abs(got-want) > 1e-12 {
+			t.Errorf("expected a scale bar of %g for extent %g, got %g", want, extent, got)
+		}
+	}
+	if l := scaleBarLabel(scaleBarLength(1.4)); l != "0.2" {
+		t.Errorf("expected scale bar label 0.2, got %s", l)
+	}
+}
+
+func TestParseDateFormat(t *testing.T) {
+	if f, err := ParseDateFormat("ISO"); err != nil || f != DateISO {
+		t.Errorf("expected iso date format, got %v (%v)", f, err)
+	}
+	if _, err := ParseDateFormat("julian"); err == nil {
+		t.Errorf("expected an error for an unknown date format")
+	}
+}
+
+func TestTimeAxis_Draw(t *testing.T) {
+	dates := map[string]float64{"A": 2019.5, "B": 2020.0, "C": 2020.5, "D": 2020.25, "E": 2019.75}
+	for _, layout := range []string{"normal", "circular"} {
+		tr := parseTestTree(t, timeTree)
+		d := newRecordingDrawer(400, 400)
+		var l TreeLayout
+		if layout == "normal" {
+			l = NewNormalLayout(d, true, true, false, false)
+		} else {
+			l = NewCircularLayout(d, true, true, false, false)
+		}
+		l.SetTimeAxis(2017.0, DateDecimal)
+		l.SetScaleBar(true)
+		if err := l.DrawTree(tr); err != nil {
+			t.Fatalf("%s: unexpected error: %v", layout, err)
+		}
+
+		// Position of each date along the axis: x in normal layout,
+		// radius of a circle centered on the root in circular layout
+		axis := make(map[string]float64)
+		cx, cy := 0.0, 0.0
+		if layout == "normal" {
+			for _, tick := range d.ticks {
+				axis[tick.label] = tick.x
+			}
+		} else {
+			cx, cy = d.center(t)
+			for _, label := range []string{"2018", "2019", "2020"} {
+				n := d.name(t, label)
+				axis[label] = math.Hypot(n.x-cx, n.y-cy)
+				circles := 0
+				for _, c := range d.curves {
+					if c.color == colorOf(timeCircleColor) && closeTo(c.radius, axis[label]) && closeTo(c.cx, cx) && closeTo(c.cy, cy) {
+						circles++
+					}
+				}
+				if circles != 2 {
+					t.Errorf("%s: expected a circle through the label %s, got %d half circles", layout, label, circles)
+				}
+			}
+			// The root date is a tick of the axis, but not a circle
+			if d.hasName("2017") {
+				t.Errorf("%s: unexpected root date label", layout)
+			}
+		}
+		labels := []string{"2018", "2019", "2020"}
+		if layout == "normal" {
+			labels = append(labels, "2017")
+		}
+		for _, label := range labels {
+			year, _ := strconv.ParseFloat(label, 64)
+			if pos, ok := axis[label]; !ok || math.Abs(pos-(year-2017)) > 1e-9 {
+				t.Errorf("%s: expected date %s at distance %f from the root, got %f", layout, label, year-2017, pos)
+			}
+		}
+		// The tips are drawn at their dates
+		for tip, date := range dates {
+			n := d.name(t, tip)
+			pos := n.x
+			if layout == "circular" {
+				pos = math.Hypot(n.x-cx, n.y-cy)
+			}
+			if math.Abs(2017+pos-date) > 1e-9 {
+				t.Errorf("%s: expected tip %s at date %f, got %f", layout, tip, date, 2017+pos)
+			}
+		}
+
+		if len(d.scaleBars) != 1 || d.scaleBars[0].x != 0.5 || d.scaleBars[0].label != "0.5" {
+			t.Errorf("%s: expected a scale bar of length 0.5, got %+v", layout, d.scaleBars)
+		}
+	}
+}
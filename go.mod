@@ -8,6 +8,7 @@ require (
 	github.com/fredericlemoine/bitset v1.2.0
 	github.com/fredericlemoine/cobrashell v0.0.0-20180921081141-49c72f93426c
 	github.com/fredericlemoine/gostats v0.2.0
+	github.com/go-pdf/fpdf v0.8.0
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/jlaffaye/ftp v0.0.0-20210307004419-5d4190119067
 	github.com/llgcode/draw2d v0.0.0-20210313082411-577c1ead272a
@@ -28,12 +29,13 @@ require (
 	github.com/flynn-archive/go-shlex v0.0.0-20150515145356-3f9db97f8568 // indirect
 	github.com/go-fonts/liberation v0.3.1 // indirect
 	github.com/go-latex/latex v0.0.0-20230307184459-12ec69307ad9 // indirect
-	github.com/go-pdf/fpdf v0.8.0 // indirect
 	github.com/inconshreveable/mousetrap v1.0.1 // indirect
 	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/mattn/go-isatty v0.0.12 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/exp v0.0.0-20230801115018-d63ba01acd4b // indirect
 	golang.org/x/sys v0.11.0 // indirect
 	golang.org/x/text v0.12.0 // indirect
+	gonum.org/v1/gonum v0.14.0 // indirect
 )
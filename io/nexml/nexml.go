@@ -0,0 +1,401 @@
+package nexml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Structs for representation of the NeXML document
+type NeXML struct {
+	XMLName xml.Name     `xml:"nexml"`
+	Otus    []Otus       `xml:"otus"`
+	Trees   []TreesBlock `xml:"trees"`
+}
+
+type Otus struct {
+	Id   string `xml:"id,attr"`
+	Otus []Otu  `xml:"otu"`
+}
+
+type Otu struct {
+	Id    string `xml:"id,attr"`
+	Label string `xml:"label,attr"`
+	Metas []Meta `xml:"meta"`
+}
+
+type TreesBlock struct {
+	Id    string   `xml:"id,attr"`
+	Otus  string   `xml:"otus,attr"`
+	Trees []NxTree `xml:"tree"`
+}
+
+type NxTree struct {
+	Id    string   `xml:"id,attr"`
+	Label string   `xml:"label,attr"`
+	Type  string   `xml:"type,attr"`
+	Metas []Meta   `xml:"meta"`
+	Nodes []NxNode `xml:"node"`
+	Edges []NxEdge `xml:"edge"`
+}
+
+type NxNode struct {
+	Id    string `xml:"id,attr"`
+	Label string `xml:"label,attr"`
+	Otu   string `xml:"otu,attr"`
+	Root  bool   `xml:"root,attr"`
+	Metas []Meta `xml:"meta"`
+}
+
+type NxEdge struct {
+	Id     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Length string `xml:"length,attr"`
+	Metas  []Meta `xml:"meta"`
+}
+
+// Meta annotation: either a LiteralMeta (property/content)
+// or a ResourceMeta (rel/href)
+type Meta struct {
+	Type     string `xml:"type,attr"`
+	Property string `xml:"property,attr"`
+	Content  string `xml:"content,attr"`
+	Datatype string `xml:"datatype,attr"`
+	Rel      string `xml:"rel,attr"`
+	Href     string `xml:"href,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// Parser represents a parser.
+type Parser struct {
+	reader io.Reader
+}
+
+// NewParser returns a new instance of Parser.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{reader: r}
+}
+
+func (p *Parser) Parse() (nx *NeXML, err error) {
+	nx = &NeXML{}
+	dec := xml.NewDecoder(p.reader)
+	dec.CharsetReader = charsetReader
+	err = dec.Decode(nx)
+	return
+}
+
+// Supports ISO-8859-1 encoded documents (e.g. from TreeBASE)
+// in addition to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii":
+		return input, nil
+	case "iso-8859-1", "latin1":
+		var out bytes.Buffer
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(input)
+		for _, b := range buf.Bytes() {
+			out.WriteRune(rune(b))
+		}
+		return &out, nil
+	}
+	return nil, fmt.Errorf("unsupported charset: %s", charset)
+}
+
+func (nx *NeXML) IterateTrees(it func(*tree.Tree, error)) {
+	otus := nx.otuIndex()
+	for _, block := range nx.Trees {
+		for _, nt := range block.Trees {
+			t := tree.NewTree()
+			err := nxTreeToTree(&nt, otus, t)
+			it(t, err)
+		}
+	}
+}
+
+func (nx *NeXML) FirstTree() (t *tree.Tree, err error) {
+	otus := nx.otuIndex()
+	for _, block := range nx.Trees {
+		for _, nt := range block.Trees {
+			t = tree.NewTree()
+			err = nxTreeToTree(&nt, otus, t)
+			return
+		}
+	}
+	return
+}
+
+// Index of all otus of the document by their id
+func (nx *NeXML) otuIndex() map[string]*Otu {
+	otus := make(map[string]*Otu)
+	for i := range nx.Otus {
+		for j := range nx.Otus[i].Otus {
+			otus[nx.Otus[i].Otus[j].Id] = &nx.Otus[i].Otus[j]
+		}
+	}
+	return otus
+}
+
+func nxTreeToTree(nt *NxTree, otus map[string]*Otu, t *tree.Tree) (err error) {
+	var nedges, nnodes int = 0, 0
+	var root *NxNode
+
+	nodes := make(map[string]*NxNode)
+	children := make(map[string][]*NxEdge)
+	hasparent := make(map[string]bool)
+	for i := range nt.Nodes {
+		nodes[nt.Nodes[i].Id] = &nt.Nodes[i]
+	}
+	for i := range nt.Edges {
+		e := &nt.Edges[i]
+		if _, ok := nodes[e.Source]; !ok {
+			return fmt.Errorf("edge %s: unknown source node %s", e.Id, e.Source)
+		}
+		if _, ok := nodes[e.Target]; !ok {
+			return fmt.Errorf("edge %s: unknown target node %s", e.Id, e.Target)
+		}
+		if hasparent[e.Target] {
+			return fmt.Errorf("node %s has several parents, networks are not supported", e.Target)
+		}
+		hasparent[e.Target] = true
+		children[e.Source] = append(children[e.Source], e)
+	}
+	for i := range nt.Nodes {
+		n := &nt.Nodes[i]
+		if n.Root || (!hasparent[n.Id] && root == nil) {
+			root = n
+			if n.Root {
+				break
+			}
+		}
+	}
+	if root == nil {
+		return fmt.Errorf("tree %s has no root node", nt.Id)
+	}
+	if err = nxNodeToTree(root, nil, nil, nodes, children, otus, t, &nedges, &nnodes); err != nil {
+		return
+	}
+	if nnodes != len(nt.Nodes) {
+		err = fmt.Errorf("tree %s is not connected", nt.Id)
+	}
+	return
+}
+
+func nxNodeToTree(n *NxNode, edge *NxEdge, parent *tree.Node, nodes map[string]*NxNode, children map[string][]*NxEdge, otus map[string]*Otu, t *tree.Tree, nedges, nnodes *int) (err error) {
+	newNode := t.NewNode()
+	newNode.SetId(*nnodes)
+	(*nnodes)++
+	if parent == nil {
+		t.SetRoot(newNode)
+	} else {
+		e := t.ConnectNodes(parent, newNode)
+		e.SetId(*nedges)
+		(*nedges)++
+		if edge.Length != "" {
+			var l float64
+			if l, err = strconv.ParseFloat(edge.Length, 64); err != nil {
+				return fmt.Errorf("edge %s: malformed length: %s", edge.Id, edge.Length)
+			}
+			e.SetLength(l)
+		}
+		for _, m := range edge.Metas {
+			if k, v, ok := m.keyValue(); ok {
+				if s, err2 := strconv.ParseFloat(v, 64); err2 == nil && k == "support" {
+					e.SetSupport(s)
+				} else {
+					setAttribute(e, k, v)
+				}
+			}
+		}
+	}
+
+	if n.Label != "" {
+		newNode.SetName(n.Label)
+	}
+	if n.Otu != "" {
+		otu, ok := otus[n.Otu]
+		if !ok {
+			return fmt.Errorf("node %s: unknown otu %s", n.Id, n.Otu)
+		}
+		if otu.Label != "" {
+			newNode.SetName(otu.Label)
+		} else if n.Label == "" {
+			newNode.SetName(otu.Id)
+		}
+		for _, m := range otu.Metas {
+			if k, v, ok := m.keyValue(); ok {
+				setAttribute(newNode, k, v)
+			}
+		}
+	}
+	for _, m := range n.Metas {
+		if k, v, ok := m.keyValue(); ok {
+			setAttribute(newNode, k, v)
+		}
+	}
+
+	for _, ce := range children[n.Id] {
+		if err = nxNodeToTree(nodes[ce.Target], ce, newNode, nodes, children, otus, t, nedges, nnodes); err != nil {
+			return
+		}
+	}
+	if len(children[n.Id]) == 0 && newNode.Name() == "" {
+		err = fmt.Errorf("one tip has no name")
+	}
+	return
+}
+
+// Returns the key (property or rel without the namespace prefix)
+// and the value (content, href or text) of the meta annotation
+func (m *Meta) keyValue() (key, value string, ok bool) {
+	switch {
+	case m.Property != "":
+		key = m.Property
+		value = m.Content
+		if value == "" {
+			value = strings.TrimSpace(m.Value)
+		}
+	case m.Rel != "":
+		key = m.Rel
+		value = m.Href
+	default:
+		return "", "", false
+	}
+	if i := strings.LastIndex(key, ":"); i >= 0 {
+		key = key[i+1:]
+	}
+	return key, value, key != ""
+}
+
+// Node or Edge attributes
+type attributeSetter interface {
+	SetAttribute(key, value string)
+	SetFloatAttribute(key string, value float64)
+}
+
+func setAttribute(a attributeSetter, key, value string) {
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		a.SetFloatAttribute(key, f)
+	} else {
+		a.SetAttribute(key, value)
+	}
+}
+
+// WriteNeXML writes the trees of the channel in a NeXML document.
+// All tips are defined in a single otus block, and all trees in
+// a single trees block. Node and edge attributes are written as
+// meta annotations, and branch supports as "support" edge annotations.
+func WriteNeXML(tchan <-chan tree.Trees) (string, error) {
+	var otus bytes.Buffer
+	var trees bytes.Buffer
+	var otuids map[string]string = make(map[string]string)
+	var nmeta int = 0
+	var ntrees int = 0
+
+	for t := range tchan {
+		if t.Err != nil {
+			return "", t.Err
+		}
+		writeTree(t.Tree, ntrees, otuids, &otus, &trees, &nmeta)
+		ntrees++
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<nex:nexml xmlns:nex="http://www.nexml.org/2009" xmlns="http://www.nexml.org/2009"
+           xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+           xmlns:xsd="http://www.w3.org/2001/XMLSchema#"
+           xmlns:gotree="https://github.com/evolbioinfo/gotree#"
+           version="0.9" generator="gotree">
+  <otus id="otus1">
+`)
+	buffer.Write(otus.Bytes())
+	buffer.WriteString("  </otus>\n")
+	if ntrees > 0 {
+		buffer.WriteString("  <trees id=\"trees1\" otus=\"otus1\">\n")
+		buffer.Write(trees.Bytes())
+		buffer.WriteString("  </trees>\n")
+	}
+	buffer.WriteString("</nex:nexml>\n")
+	return buffer.String(), nil
+}
+
+func writeTree(t *tree.Tree, id int, otuids map[string]string, otus, buf *bytes.Buffer, nmeta *int) {
+	var nnodes, nedges int = 0, 0
+	var nodes, edges bytes.Buffer
+	var nodeids map[*tree.Node]int = make(map[*tree.Node]int)
+	prefix := fmt.Sprintf("t%d", id+1)
+
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) bool {
+		nodeid := fmt.Sprintf("%sn%d", prefix, nnodes+1)
+		nodeids[cur] = nnodes
+		nnodes++
+		nodes.WriteString("      <node id=\"" + nodeid + "\"")
+		if cur.Tip() && cur.Name() != "" {
+			otuid, ok := otuids[cur.Name()]
+			if !ok {
+				otuid = fmt.Sprintf("otu%d", len(otuids)+1)
+				otuids[cur.Name()] = otuid
+				otus.WriteString("    <otu id=\"" + otuid + "\" label=\"" + escape(cur.Name()) + "\"/>\n")
+			}
+			nodes.WriteString(" otu=\"" + otuid + "\"")
+		}
+		if cur.Name() != "" {
+			nodes.WriteString(" label=\"" + escape(cur.Name()) + "\"")
+		}
+		if prev == nil {
+			nodes.WriteString(" root=\"" + strconv.FormatBool(t.Rooted()) + "\"")
+		}
+		writeMetas(&nodes, "      ", "/>\n", "</node>\n", cur.Attributes(), nmeta)
+
+		if prev != nil {
+			edges.WriteString(fmt.Sprintf("      <edge id=\"%se%d\" source=\"%sn%d\" target=\"%s\"", prefix, nedges+1, prefix, nodeids[prev]+1, nodeid))
+			nedges++
+			if e.Length() != tree.NIL_LENGTH {
+				edges.WriteString(" length=\"" + e.LengthString() + "\"")
+			}
+			attrs := e.Attributes()
+			if !cur.Tip() && e.Support() != tree.NIL_SUPPORT {
+				attrs = append(attrs, tree.Attribute{Key: "support", Value: e.SupportString()})
+			}
+			writeMetas(&edges, "      ", "/>\n", "</edge>\n", attrs, nmeta)
+		}
+		return true
+	})
+
+	buf.WriteString(fmt.Sprintf("    <tree id=\"tree%d\" xsi:type=\"nex:FloatTree\">\n", id+1))
+	buf.Write(nodes.Bytes())
+	buf.Write(edges.Bytes())
+	buf.WriteString("    </tree>\n")
+}
+
+// Closes the current element, with its meta annotations if any
+func writeMetas(buf *bytes.Buffer, indent, empty, end string, attrs []tree.Attribute, nmeta *int) {
+	if len(attrs) == 0 {
+		buf.WriteString(empty)
+		return
+	}
+	buf.WriteString(">\n")
+	for _, a := range attrs {
+		(*nmeta)++
+		datatype := "xsd:string"
+		if _, err := strconv.ParseFloat(a.Value, 64); err == nil {
+			datatype = "xsd:double"
+		}
+		buf.WriteString(fmt.Sprintf("%s  <meta id=\"meta%d\" xsi:type=\"nex:LiteralMeta\" property=\"gotree:%s\" datatype=\"%s\" content=\"%s\"/>\n",
+			indent, *nmeta, escape(a.Key), datatype, escape(a.Value)))
+	}
+	buf.WriteString(indent + end)
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,188 @@
+package nextstrain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Structs for the output Auspice v2 JSON. Node attributes are
+// kept in maps, since they depend on the comments of the tree.
+type nsOutput struct {
+	Version string     `json:"version"`
+	Meta    nsMeta     `json:"meta"`
+	Tree    *nsOutNode `json:"tree"`
+}
+
+type nsMeta struct {
+	Title     string       `json:"title,omitempty"`
+	Updated   string       `json:"updated"`
+	Panels    []string     `json:"panels"`
+	Colorings []nsColoring `json:"colorings,omitempty"`
+}
+
+type nsColoring struct {
+	Key   string `json:"key"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+}
+
+type nsOutNode struct {
+	Name        string                 `json:"name"`
+	NodeAttrs   map[string]interface{} `json:"node_attrs"`
+	BranchAttrs map[string]interface{} `json:"branch_attrs,omitempty"`
+	Children    []*nsOutNode           `json:"children,omitempty"`
+}
+
+// Attributes that the parser reads as strings, even if they look like numbers
+var stringAttributes = map[string]bool{
+	"age":              true,
+	"clade_membership": true,
+	"country":          true,
+	"division":         true,
+	"epiweek":          true,
+	"gender":           true,
+	"originating_lab":  true,
+	"recency":          true,
+	"region":           true,
+	"submitting_lab":   true,
+}
+
+// WriteNextstrain writes the tree in Auspice v2 JSON format.
+//
+//   - metadata: Optional per-tip attributes (tip name => attribute => value)
+//   - title: Title of the dataset (may be empty)
+//   - updated: Date of the last update of the dataset (yyyy-mm-dd)
+//
+// Each node is given its divergence from the root (div), its date (num_date)
+// if it has a date attribute, and one attribute per other attribute of its
+// comments ([&key=value,...] or [&&NHX:key=value:...]). Unnamed internal
+// nodes are named NODE_xxxxxxx.
+// Branch supports are written in the "support" attribute.
+func WriteNextstrain(t *tree.Tree, metadata map[string]map[string]string, title, updated string) (string, error) {
+	var err error
+	var root *nsOutNode
+	var nodeid int = 0
+	var numeric map[string]bool = make(map[string]bool)
+
+	if root, err = nodeToNextstrain(t.Root(), nil, nil, 0.0, metadata, numeric, &nodeid); err != nil {
+		return "", err
+	}
+
+	out := nsOutput{
+		Version: "v2",
+		Meta: nsMeta{
+			Title:     title,
+			Updated:   updated,
+			Panels:    []string{"tree"},
+			Colorings: colorings(numeric),
+		},
+		Tree: root,
+	}
+
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+func nodeToNextstrain(n *tree.Node, prev *tree.Node, e *tree.Edge, div float64, metadata map[string]map[string]string, numeric map[string]bool, nodeid *int) (ns *nsOutNode, err error) {
+	if e != nil && e.Length() != tree.NIL_LENGTH {
+		div += e.Length()
+	}
+
+	ns = &nsOutNode{
+		Name:      n.Name(),
+		NodeAttrs: map[string]interface{}{"div": div},
+	}
+	if ns.Name == "" {
+		ns.Name = fmt.Sprintf("NODE_%07d", *nodeid)
+		*nodeid++
+	}
+
+	for _, a := range n.Attributes() {
+		if err = addAttribute(ns, a.Key, a.Value, numeric); err != nil {
+			return
+		}
+	}
+	if n.Tip() && metadata != nil {
+		for k, v := range metadata[n.Name()] {
+			if err = addAttribute(ns, k, v, numeric); err != nil {
+				return
+			}
+		}
+	}
+	if e != nil && !n.Tip() && e.Support() != tree.NIL_SUPPORT {
+		addAttribute(ns, "support", e.SupportString(), numeric)
+	}
+
+	for i, child := range n.Neigh() {
+		if child != prev {
+			var c *nsOutNode
+			if c, err = nodeToNextstrain(child, n, n.Edges()[i], div, metadata, numeric, nodeid); err != nil {
+				return
+			}
+			ns.Children = append(ns.Children, c)
+		}
+	}
+	return
+}
+
+// Adds the attribute to the node, converting dates into num_date,
+// and mutations into amino acid branch labels.
+// numeric records for each attribute whether all its values are numbers.
+func addAttribute(ns *nsOutNode, key, value string, numeric map[string]bool) (err error) {
+	switch key {
+	case "div":
+		return
+	case "date", "num_date":
+		var date float64
+		if date, err = tree.ParseDate(value); err != nil {
+			return
+		}
+		ns.NodeAttrs["num_date"] = map[string]interface{}{"value": date}
+		numeric["num_date"] = true
+		return
+	case "accession":
+		ns.NodeAttrs[key] = value
+		return
+	case "mutations":
+		mut := strings.Replace(value, "-", ", ", -1)
+		mut = strings.Replace(mut, ".", ":", -1)
+		ns.BranchAttrs = map[string]interface{}{"labels": map[string]string{"aa": mut}}
+		return
+	}
+
+	isnum, seen := numeric[key]
+	if f, err2 := strconv.ParseFloat(value, 64); err2 == nil && !stringAttributes[key] {
+		ns.NodeAttrs[key] = map[string]interface{}{"value": f}
+		numeric[key] = isnum || !seen
+	} else {
+		ns.NodeAttrs[key] = map[string]interface{}{"value": value}
+		numeric[key] = false
+	}
+	return
+}
+
+// Colorings of the meta block: one per attribute, continuous if
+// all its values are numbers, categorical otherwise.
+func colorings(numeric map[string]bool) (cols []nsColoring) {
+	keys := make([]string, 0, len(numeric))
+	for k := range numeric {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c := nsColoring{Key: k, Title: k, Type: "categorical"}
+		if numeric[k] {
+			c.Type = "continuous"
+		}
+		cols = append(cols, c)
+	}
+	return
+}
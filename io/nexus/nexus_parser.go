@@ -5,10 +5,13 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/evolbioinfo/goalign/align"
 	treeio "github.com/evolbioinfo/gotree/io"
 	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
 )
 
 // Parser represents a parser.
@@ -20,11 +23,18 @@ type Parser struct {
 		n   int    // buffer size (max=1)
 	}
 	translationTable map[string]string // For taxa name translation
+	threads          int               // Number of threads to parse trees
 }
 
 // NewParser returns a new instance of Parser.
 func NewParser(r io.Reader) *Parser {
-	return &Parser{s: NewScanner(r)}
+	return &Parser{s: NewScanner(r), threads: 1}
+}
+
+// Sets the number of threads used to parse the trees
+// of the TREES block (default 1)
+func (p *Parser) SetThreads(threads int) {
+	p.threads = max(threads, 1)
 }
 
 // scan returns the next token from the underlying scanner.
@@ -185,36 +195,70 @@ func (p *Parser) Parse() (*Nexus, error) {
 	}
 	// We initialize tree structures using gotree structure
 	if treenames != nil && treestrings != nil {
-		for i, treestr := range treestrings {
-			t, err := newick.NewParser(strings.NewReader(treestr + ";")).Parse()
-			if err != nil {
-				return nil, err
-			}
-			// We translate taxa labels if needed
-			if p.translationTable != nil {
-				if err2 := t.Rename(p.translationTable); err2 != nil {
-					return nil, err2
-				}
-			}
-			// We check that tax labels are the same as tree taxa
-			if taxlabels != nil {
-				tips := t.Tips()
-				for _, tip := range tips {
-					if _, ok := taxlabels[tip.Name()]; !ok {
-						return nil, fmt.Errorf("Taxa name %s in the tree %d is not defined in the TAXLABELS block", tip.Name(), i)
-					}
-				}
-				if len(tips) != len(taxlabels) {
-					return nil, fmt.Errorf("Some tax names defined in TAXLABELS are not present in the tree %d", i)
-				}
-			}
-			//t.ReinitIndexes()
+		trees, err := p.parseTreeStrings(treestrings, taxlabels)
+		if err != nil {
+			return nil, err
+		}
+		for i, t := range trees {
 			nexus.AddTree(treenames[i], t)
 		}
 	}
 	return nexus, nil
 }
 
+// Parses the newick strings of the TREES block, with p.threads threads.
+// Trees are returned in the input order, and the returned error is the
+// error of the first tree that could not be parsed.
+func (p *Parser) parseTreeStrings(treestrings []string, taxlabels map[string]bool) (trees []*tree.Tree, err error) {
+	var wg sync.WaitGroup
+	var errs []error = make([]error, len(treestrings))
+	var next int64 = -1
+
+	trees = make([]*tree.Tree, len(treestrings))
+	for th := 0; th < min(p.threads, len(treestrings)); th++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt64(&next, 1)); i < len(treestrings); i = int(atomic.AddInt64(&next, 1)) {
+				trees[i], errs[i] = p.parseTreeString(i, treestrings[i], taxlabels)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err = range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Parses the ith newick string of the TREES block
+func (p *Parser) parseTreeString(i int, treestr string, taxlabels map[string]bool) (t *tree.Tree, err error) {
+	if t, err = newick.NewParser(strings.NewReader(treestr + ";")).Parse(); err != nil {
+		return
+	}
+	// We translate taxa labels if needed
+	if p.translationTable != nil {
+		if err = t.Rename(p.translationTable); err != nil {
+			return
+		}
+	}
+	// We check that tax labels are the same as tree taxa
+	if taxlabels != nil {
+		tips := t.Tips()
+		for _, tip := range tips {
+			if _, ok := taxlabels[tip.Name()]; !ok {
+				return nil, fmt.Errorf("Taxa name %s in the tree %d is not defined in the TAXLABELS block", tip.Name(), i)
+			}
+		}
+		if len(tips) != len(taxlabels) {
+			return nil, fmt.Errorf("Some tax names defined in TAXLABELS are not present in the tree %d", i)
+		}
+	}
+	return
+}
+
 // Parse taxa block
 func (p *Parser) parseTaxa() (int64, map[string]bool, error) {
 	taxlabels := make(map[string]bool)
@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/evolbioinfo/gotree/io/fileutils"
 	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/io/nexml"
 	"github.com/evolbioinfo/gotree/io/nextstrain"
 	"github.com/evolbioinfo/gotree/io/nexus"
 	"github.com/evolbioinfo/gotree/io/phyloxml"
@@ -18,6 +20,7 @@ const (
 	FORMAT_NEXUS
 	FORMAT_PHYLOXML
 	FORMAT_NEXTSTRAIN
+	FORMAT_NEXML
 )
 
 func ReadTree(inputfile string, format int) (*tree.Tree, error) {
@@ -82,6 +85,18 @@ func ReadTreeReader(reader *bufio.Reader, format int) (*tree.Tree, error) {
 				return nil, fmt.Errorf("No tree in the input Nextstrain file")
 			}
 		}
+	case FORMAT_NEXML:
+		if n, err5 := nexml.NewParser(reader).Parse(); err5 != nil {
+			return nil, err5
+		} else {
+			reftree, err = n.FirstTree()
+			if err != nil {
+				return nil, err
+			}
+			if reftree == nil {
+				return nil, fmt.Errorf("No tree in the input NeXML file")
+			}
+		}
 	default:
 		return nil, fmt.Errorf("Unsupported tree format: %q", format)
 	}
@@ -96,8 +111,21 @@ func ReadTreeReader(reader *bufio.Reader, format int) (*tree.Tree, error) {
 // the channel
 // Different parsing formats: utils.FORMAT_NEWICK or utils.FORMAT_NEXUS
 func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
+	return ReadMultiTreesThreads(reader, format, 1)
+}
+
+// Same as ReadMultiTrees, but Newick and Nexus trees are parsed
+// concurrently by the given number of threads. Trees are still sent
+// to the output channel in the input order, with their index in the
+// input file as Id.
+func ReadMultiTreesThreads(reader *bufio.Reader, format int, threads int) <-chan tree.Trees {
 	var compTrees chan tree.Trees = make(chan tree.Trees, 10)
 
+	if threads > 1 && format == FORMAT_NEWICK {
+		go readNewickTreesParallel(reader, threads, compTrees)
+		return compTrees
+	}
+
 	go func() {
 		var err error
 		var id int = 0
@@ -133,7 +161,9 @@ func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
 				line, e = fileutils.ReadUntilSemiColon(reader)
 			}
 		case FORMAT_NEXUS:
-			if n, err := nexus.NewParser(reader).Parse(); err != nil {
+			p := nexus.NewParser(reader)
+			p.SetThreads(threads)
+			if n, err := p.Parse(); err != nil {
 				compTrees <- tree.Trees{
 					Tree: nil,
 					Id:   id,
@@ -182,6 +212,23 @@ func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
 				}
 				id++
 			}
+		case FORMAT_NEXML:
+			if n, err4 := nexml.NewParser(reader).Parse(); err4 != nil {
+				compTrees <- tree.Trees{
+					Tree: nil,
+					Id:   id,
+					Err:  err4,
+				}
+			} else {
+				n.IterateTrees(func(t *tree.Tree, err error) {
+					compTrees <- tree.Trees{
+						Tree: t,
+						Id:   id,
+						Err:  err,
+					}
+					id++
+				})
+			}
 
 		default:
 			compTrees <- tree.Trees{
@@ -194,3 +241,93 @@ func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
 	}()
 	return compTrees
 }
+
+// Newick string read from the input, or parsed tree
+type newickJob struct {
+	id     int
+	newick string
+	tree   *tree.Tree
+	err    error
+}
+
+// Reads newick strings sequentially, parses them with several threads, and
+// sends the trees to the output channel in the input order. At most
+// 10*threads trees are read in advance. After an error, no more tree is sent.
+func readNewickTreesParallel(reader *bufio.Reader, threads int, compTrees chan<- tree.Trees) {
+	var jobs chan newickJob = make(chan newickJob, threads)
+	var results chan newickJob = make(chan newickJob, threads)
+	var inflight chan struct{} = make(chan struct{}, 10*threads)
+	var done chan struct{} = make(chan struct{})
+	var wg sync.WaitGroup
+
+	// Reads newick strings
+	go func() {
+		defer close(jobs)
+		id := 0
+		line, e := fileutils.ReadUntilSemiColon(reader)
+		if e != nil {
+			inflight <- struct{}{}
+			jobs <- newickJob{id: id, err: e}
+			return
+		}
+		for e == nil {
+			select {
+			case inflight <- struct{}{}:
+			case <-done:
+				return
+			}
+			jobs <- newickJob{id: id, newick: line}
+			id++
+			line, e = fileutils.ReadUntilSemiColon(reader)
+		}
+	}()
+
+	// Parses newick strings
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if j.err == nil {
+					j.tree, j.err = newick.NewParser(strings.NewReader(j.newick)).Parse()
+					j.newick = ""
+				}
+				results <- j
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Sends trees in the input order
+	pending := make(map[int]newickJob)
+	next := 0
+	stopped := false
+	for r := range results {
+		if stopped {
+			continue
+		}
+		pending[r.id] = r
+		for !stopped {
+			j, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			compTrees <- tree.Trees{
+				Tree: j.tree,
+				Id:   j.id,
+				Err:  j.err,
+			}
+			<-inflight
+			next++
+			if j.err != nil {
+				stopped = true
+				close(done)
+			}
+		}
+	}
+	close(compTrees)
+}
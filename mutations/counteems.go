@@ -85,6 +85,7 @@ func countEEMSiteBranch(t *tree.Tree, prevNode *tree.Node, currentNode *tree.Nod
 				AlignmentSite:   site,
 				BranchIndex:     currentBranch.Id(),
 				ChildNodeName:   currentNode.Name(),
+				ParentNodeName:  prevNode.Name(),
 				ParentCharacter: prevChar,
 				ChildCharacter:  curChar,
 				NumEEM:          1,
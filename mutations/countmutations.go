@@ -113,6 +113,7 @@ func countMutationSiteBranch(t *tree.Tree, prevNode *tree.Node, currentNode *tre
 				AlignmentSite:             site,
 				BranchIndex:               currentBranch.Id(),
 				ChildNodeName:             currentNode.Name(),
+				ParentNodeName:            prevNode.Name(),
 				ParentCharacter:           prevChar,
 				ChildCharacter:            curChar,
 				NumTips:                   ntips,
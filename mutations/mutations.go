@@ -12,6 +12,7 @@ type Mutation struct {
 	AlignmentSite             int    // Index of the site of the alignment
 	BranchIndex               int    // Index of the branch
 	ChildNodeName             string // Name of the parent of the clade
+	ParentNodeName            string // Name of the parent node of the branch
 	ParentCharacter           uint8  // Parent character
 	ChildCharacter            uint8  // Child character
 	NumTips                   int    // Total number of descendent tips
@@ -47,3 +48,32 @@ func (m *MutationList) Append(mapp *MutationList) (err error) {
 	}
 	return
 }
+
+// FilterProbas returns a new MutationList containing only the mutations
+// for which posterior probabilities of both the parent and the child characters
+// are >= minproba.
+//
+// probas: Posterior probability of the reconstructed character at each site of
+// each node (key: node name), as given by asr.MLAsr. Nodes that are not present
+// in the map (e.g. tips) are considered certain.
+func (m *MutationList) FilterProbas(probas map[string][]float64, minproba float64) (filtered *MutationList, err error) {
+	filtered = NewMutationList()
+	for k, v := range m.Mutations {
+		keep := true
+		for _, name := range []string{v.ParentNodeName, v.ChildNodeName} {
+			if p, ok := probas[name]; ok {
+				if v.AlignmentSite >= len(p) {
+					err = fmt.Errorf("no posterior probability for site %d of node %s", v.AlignmentSite, name)
+					return
+				}
+				if p[v.AlignmentSite] < minproba {
+					keep = false
+				}
+			}
+		}
+		if keep {
+			filtered.Mutations[k] = v
+		}
+	}
+	return
+}
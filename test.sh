@@ -367,6 +367,48 @@ ${GOTREE} compare trees -i <(${GOTREE} generate yuletree --seed 10) -c <(${GOTRE
 diff -q -b expected result
 rm -f expected result
 
+# gotree compare treedistance
+echo "->gotree compare treedistance"
+cat > input <<EOF
+((a:1,b:1):1,(c:1,d:1):1,(e:1,f:1):1);
+((a:1,c:1):1,(b:1,d:1):2,(e:1,f:1):1);
+((a:1,b:1):1,(c:1,e:1):1,(d:1,f:1):1);
+EOF
+cat > expected <<EOF
+tree	kf
+0	0.000000
+1	2.645751
+2	2.000000
+EOF
+head -n 1 input | ${GOTREE} compare treedistance -m kf -c input > result
+diff -q -b expected result
+cat > expected <<EOF
+3
+0	0.000000000000	4.000000000000	4.000000000000
+1	4.000000000000	0.000000000000	6.000000000000
+2	4.000000000000	6.000000000000	0.000000000000
+EOF
+${GOTREE} compare treedistance -m ms --matrix -i input > result
+diff -q -b expected result
+rm -f expected result input
+
+# gotree compare quartets
+echo "->gotree compare quartets"
+cat > expected <<EOF
+tree	quartets	shared	diff	unresolved_ref	unresolved_comp	qdist
+0	15	6	9	0	0	0.600000
+1	15	11	0	0	4	0.266667
+2	15	15	0	0	0	0.000000
+EOF
+cat > compared <<EOF
+((a,c),(b,d),(e,f));
+((a,b),(c,d),e,f);
+((a,b),(c,d),(e,f));
+EOF
+echo "((a,b),(c,d),(e,f));" | ${GOTREE} compare quartets -c compared > result
+diff -q -b expected result
+rm -f expected result compared
+
 # gotree compare neighborhood
 echo "->gotree compare neighborhood"
 cat > neigh_ref <<EOF
@@ -624,6 +666,94 @@ diff -q -b expected_comp result
 rm -f expected_comp expected_tree result
 
 
+echo "->gotree compute consensus greedy/strict"
+cat > input <<EOF
+(A:1,B:1,(C:1,(D:1,(E:1,F:1):1):1):1);
+(A:1,B:1,(C:1,(E:1,(D:1,F:1):1):1):1);
+(A:1,C:1,(B:1,(D:1,(E:1,F:1):2):1):1);
+(A:1,D:1,(B:1,(C:1,(E:1,F:1):6):1):1);
+(A:1,E:1,(B:1,(C:1,(D:1,F:1):1):1):1);
+EOF
+cat > expected <<EOF
+(A:1,B:1,(C:1,(D:1,(E:1,F:1)0.6:2)0.6:1)0.4:1);
+(A:1,B:1,C:1,D:1,E:1,F:1);
+EOF
+${GOTREE} compute consensus -i input -m greedy --brlen median > result
+${GOTREE} compute consensus -i input -m strict >> result
+diff -q -b expected result
+rm -f input expected result
+
+
+echo "->gotree compute mcc"
+cat > input <<EOF
+((A:5,B:5):5,(C:1,D:1):9);
+((A:1,B:1)[&rate=0.5]:1,(C:1,D:1)[&rate=1]:1);
+((A:2,B:2)[&rate=1]:2,(C:2,D:2):2);
+((A:2,C:2):2,(B:3,D:3):1);
+((A:3,B:3)[&rate=2]:1,(C:2,D:2)[&rate=3]:2);
+EOF
+cat > expected <<EOF
+((A[&height=0,height_median=0,height_95%_HPD={0,0}]:1,B[&height=0,height_median=0,height_95%_HPD={0,0}]:1)[&posterior=0.75,height=2,height_median=2,height_95%_HPD={1,3},rate=1.1666666666666667,rate_median=1,rate_95%_HPD={0.5,2}]:1,(C[&height=0,height_median=0,height_95%_HPD={0,0}]:1,D[&height=0,height_median=0,height_95%_HPD={0,0}]:1)[&posterior=0.75,height=1.6666666666666667,height_median=2,height_95%_HPD={1,2},rate=2,rate_median=2,rate_95%_HPD={1,3}]:1)[&posterior=1,height=3.5,height_median=4,height_95%_HPD={2,4}];
+EOF
+${GOTREE} compute mcc -i input -b 0.2 > result
+diff -q -b expected result
+rm -f input expected result
+
+
+echo "->gotree compute dating"
+cat > input <<EOF
+((A:0.05,B:0.06):0.05,(C:0.11,D:0.09):0.02);
+EOF
+cat > dates <<EOF
+A	2000
+B	2001
+C	2003-01-01
+D	2001
+EOF
+cat > expected <<EOF
+((A[&date="2000.000000"]:5,B[&date="2001.000000"]:6)[&date="1995.000000"]:5,(C[&date="2003.000000"]:11,D[&date="2001.000000"]:9)[&date="1992.000000"]:2)[&date="1990.000000"];
+EOF
+cat > expected_log <<EOF
+tree	rate	root_date	objective
+0	0.010000	1990.000000	0.000000
+EOF
+${GOTREE} compute dating -i input -d dates --log log > result
+diff -q -b expected result
+diff -q -b expected_log log
+rm -f input dates expected expected_log result log
+
+
+echo "->gotree compute diversity"
+cat > input <<EOF
+((A:1,B:2):1,(C:1,D:3):2);
+EOF
+cat > community <<EOF
+sample	A	B	C	D
+s1	1	0	1	0
+s2	0	2	1	1
+EOF
+cat > expected <<EOF
+tree	sample	ntips	pd	mpd	mntd
+0	s1	2	5	5	5
+0	s2	3	9	6.4	5
+EOF
+cat > expected_unifrac <<EOF
+2
+s1	0.000000000000	0.600000000000
+s2	0.600000000000	0.000000000000
+EOF
+cat > expected_wunifrac <<EOF
+2
+s1	0.000000000000	2.500000000000
+s2	2.500000000000	0.000000000000
+EOF
+${GOTREE} compute diversity -i input -c community --abundance-weighted --unifrac unifrac --weighted-unifrac wunifrac > result
+diff -q -b expected result
+diff -q -b expected_unifrac unifrac
+diff -q -b expected_wunifrac wunifrac
+rm -f input community expected expected_unifrac expected_wunifrac result unifrac wunifrac
+
+
 echo "->gotree compute classical bootstrap"
 cat > expected <<EOF
 (Tip0,(Tip4,(Tip7,Tip2)1)1,((Tip9,(Tip8,Tip3)0.87)1,(Tip1,(Tip6,Tip5)0.65)0.97)0.67);
@@ -724,6 +854,39 @@ diff -q -b <(sort expected) <(sort results)
 rm -f expected input_tree input_align results
 
 
+echo "->gotree compute mutations --probas"
+cat > input_align <<EOF
+>t1
+AAAACG
+>t2
+AAAACT
+>t3
+CCCACG
+>t4
+CCCACG
+>n1
+AAAACG
+>n2
+CCCACG
+>root
+AAAACG
+EOF
+cat > input_tree <<EOF
+((t1:0.1,t2:0.1)n1:0.1,(t3:0.1,t4:0.1)n2:0.1)root;
+EOF
+cat > input_probas <<EOF
+n1	0.981582,0.981582,0.981582,0.999743,0.999611,0.928252
+n2	0.981582,0.981582,0.981582,0.999743,0.992586,0.998423
+root	0.482769,0.482769,0.482769,0.996193,0.992457,0.958878
+EOF
+cat > expected <<EOF
+Tree ID	Site	Branch ID	Node Name	Parent Character	Child Character	Total tips	Same Character Tips
+0	5	2	t2	G	T	1	1
+EOF
+${GOTREE} compute mutations -a input_align -i input_tree --probas input_probas --min-proba 0.9 > results
+diff -q -b <(sort expected) <(sort results)
+rm -f expected input_tree input_align input_probas results
+
 echo "->gotree divide"
 cat > expected1 <<EOF
 ((Tip4,(Tip7,Tip2)),Tip0,((Tip8,(Tip9,Tip3)),((Tip6,Tip5),Tip1)));
@@ -772,6 +935,35 @@ diff -q -b expected result
 rm -f expected result
 
 
+echo "->gotree generate birthdeathtree"
+cat > expected <<EOF
+(Tip0[&date="2020.000000"]:3.4930245016520134,(((Tip4[&date="2020.000000"]:0.9439312824629691,Tip3[&date="2020.000000"]:0.9439312824629691):0.20366353677150895,Tip2[&date="2020.000000"]:1.147594819234478):2.261038863858449,Tip1[&date="2020.000000"]:3.408633683092927):0.08439081855908626);
+EOF
+${GOTREE} generate birthdeathtree --seed 10 -l 5 --death 0.5 --sampling 0.5 --present 2020 > result
+diff -q -b expected result
+# Default death rate is 0, whatever the default of serialbirthdeathtree
+${GOTREE} generate birthdeathtree --seed 10 -l 5 --death 0 > expected
+${GOTREE} generate birthdeathtree --seed 10 -l 5 > result
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree generate coalescenttree"
+cat > expected <<EOF
+(Tip0[&date="0.000000"]:0.6081218175136657,((Tip2[&date="0.000000"]:0.3220802134715507,Tip4[&date="0.000000"]:0.3220802134715507):0.24505921213118387,(Tip1[&date="0.000000"]:0.16037885244182512,Tip3[&date="0.000000"]:0.16037885244182512):0.4067605731609094):0.040982391910931115);
+EOF
+${GOTREE} generate coalescenttree --seed 10 -l 5 --popsize 2 --growth 0.5 > result
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree generate serialbirthdeathtree"
+cat > expected <<EOF
+(((Tip1[&date="2019.843052"]:4.648235689375421,(Tip3[&date="2019.465271"]:1.7208503393867316,Tip0[&date="2020.000000"]:2.2555789345757615):2.5496042829301686):0.6287962228270851,Tip4[&date="2017.673595"]:3.107574014723988):4.68254424860157,Tip2[&date="2010.273084"]:0.3896078092859323);
+EOF
+${GOTREE} generate serialbirthdeathtree --seed 10 -l 5 --present 2020 > result
+diff -q -b expected result
+rm -f expected result
+
+
 echo "->gotree matrix"
 cat > expected.brlen <<EOF
 5
@@ -1117,6 +1309,48 @@ ${GOTREE} generate yuletree --seed 10 | ${GOTREE} reroot midpoint | ${GOTREE} br
 diff -q -b expected result
 rm -f expected result
 
+echo "->gotree reroot minvar"
+cat > input <<EOF
+((A:1,B:1):1,C:2,(D:0.5,E:0.5):3.5);
+EOF
+cat > expected <<EOF
+(((A:1,B:1):1,C:2):1,(D:0.5,E:0.5):2.5);
+EOF
+${GOTREE} reroot minvar -i input --out-scores scores --log log > result
+diff -q -b expected result
+test $(grep -c "true" scores) -eq 1
+test "$(tail -n 1 log)" = "$(printf "0\t0")"
+rm -f input expected result scores log
+
+echo "->gotree reroot mad"
+cat > input <<EOF
+((A:1,B:1):1,C:2,(D:0.5,E:0.5):3.5);
+EOF
+cat > expected <<EOF
+(((A:1,B:1):1,C:2):1,(D:0.5,E:0.5):2.5);
+EOF
+${GOTREE} reroot mad -i input > result
+diff -q -b expected result
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} brlen clear | ${GOTREE} reroot mad 2>/dev/null && exit 1
+rm -f input expected result
+
+echo "->gotree reroot rtt"
+cat > input <<EOF
+((A[&date=2004]:1,B[&date=2006]:2):3,C[&date=2010]:5,D[&date=2006]:3);
+EOF
+cat > expected <<EOF
+((C[&date=2010]:5,D[&date=2006]:3):1,(A[&date=2004]:1,B[&date=2006]:2):2);
+EOF
+${GOTREE} reroot rtt -i input --out-scores scores --log log > result
+diff -q -b expected result
+test $(grep -c "true" scores) -eq 1
+test "$(tail -n 1 log)" = "$(printf "0\t0.5\t1998\t1\t0")"
+${GOTREE} reroot rtt -i input --criterion rms > result 2> log
+diff -q -b expected result
+grep -q "tree 0: rate=0.5, root date=1998" log
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} reroot rtt 2>/dev/null && exit 1
+rm -f input expected result scores log
+
 echo "->gotree resolve"
 cat > expected <<EOF
 ((Tip4,(Tip7,Tip2)),(Tip3,(Tip9,Tip8)),(((Tip6,Tip5),Tip1),Tip0));
@@ -1152,6 +1386,25 @@ diff -q -b expected result
 rm -f expected result
 
 
+echo "->gotree spr"
+cat > expected <<EOF
+((a,b),(e,d),c);
+((a,b),(e,c),d);
+(b,((c,d),a),e);
+(b,(c,d),(e,a));
+EOF
+echo "((a,b),(c,d),e);" | ${GOTREE} spr --radius 1 --unique > result
+diff -q -b expected result
+rm -f expected result
+
+
+echo "->gotree tbr"
+echo "(a,b,(c,(d,(e,f))));" | ${GOTREE} tbr --unique | wc -l | tr -d ' ' > result
+echo "34" > expected
+diff -q -b expected result
+rm -f expected result
+
+
 # echo "->gotree subtree"
 # cat > clade <<EOF
 # clade:Tip2,Tip4,Tip7
@@ -1402,6 +1655,86 @@ ${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw text -w 50 > result
 diff -q -b expected result
 rm -f expected result
 
+echo "->gotree draw cyjs"
+printf "tip\tcountry\nTip1\tFrance\nTip2\t\n" > metadata
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw cyjs --metadata-file metadata > result
+if grep -q "<script src" result; then echo "cyjs output should be self-contained"; exit 1; fi
+grep -q "name: 'dagre'" result
+grep -q '<b>country</b>' result
+rm -f metadata result
+
+echo "->gotree draw tanglegram"
+${GOTREE} generate yuletree --seed 10 -l 10 > tree1
+${GOTREE} rotate rand --seed 10 -i tree1 > tree2
+${GOTREE} draw tanglegram -i tree1 -c tree2 --highlight-diff > result
+if grep -q "<circle" result; then echo "identical trees should not have different splits"; exit 1; fi
+test $(grep -c "</text>" result) -eq 20
+cat tree1 tree2 | ${GOTREE} draw tanglegram --output-format png -o result
+rm -f tree1 tree2 result
+
+echo "->gotree draw branch colors"
+echo "((A[&state=X]:1,B[&state=X]:1)AB[&state=X]:1,((C[&state=Y]:1,D[&state=Y]:0.5)CD[&state=Y]:1,E:2)CDE[&state=Y]:1)root;" > tree1
+${GOTREE} draw svg -i tree1 --branch-colors state --highlight-clade CD=#ff0000 > result
+test $(grep -c "<polygon" result) -eq 1
+grep -q "fill:#ff0000;fill-opacity:0.251" result
+grep -q "stroke: #1f77b4" result
+grep -q ">state</text>" result
+${GOTREE} draw png -c -i tree1 --branch-colors length --highlight-clade CDE -o result
+${GOTREE} draw svg -r -i tree1 --highlight-clade unknown > result 2>/dev/null && { echo "unknown clade should fail"; exit 1; }
+rm -f tree1 result
+
+echo "->gotree draw collapsed clades"
+echo "(((A:1,B:1)AB:1,(C:1,(D:1,E:1)DE:1)CDE:1)ABCDE:1,(F:1,G:1)FG:1,H:1)root;" > tree1
+printf "AB\nClade CDE\tC,E\n" > clades
+${GOTREE} draw svg -i tree1 --collapse-clades clades --clade-label-style bracket > result
+test $(grep -c "<polygon" result) -eq 2
+grep -q ">Clade CDE</text>" result
+if grep -q ">D</text>" result; then echo "collapsed tips should not be drawn"; exit 1; fi
+${GOTREE} draw png -c -i tree1 --collapse-clades clades -o result
+${GOTREE} draw svg -r -i tree1 --collapse-clades clades > result 2>/dev/null && { echo "radial layout should not accept collapsed clades"; exit 1; }
+rm -f tree1 clades result
+
+echo "->gotree draw pdf"
+printf "tip\tcountry\nTip1\tFrance\nTip2\t\n" > metadata
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw pdf --metadata-file metadata -o result
+head -c 5 result | grep -q "%PDF-"
+test $(grep -a -c "/FontFile2" result) -eq 2
+${GOTREE} generate yuletree --seed 10 -n 2 | ${GOTREE} draw pdf -c -o result.pdf
+test -s result.pdf && test -s result_001.pdf
+rm -f metadata result result.pdf result_001.pdf
+
+echo "->gotree draw time axis"
+cat > tree1 <<EOF
+((A[&date=2019.5]:1.5,B[&date=2020.0]:2.0):1.0,(C[&date=2020.5]:2.0,(D[&date=2020.25]:1.0,E[&date=2019.75]:0.5):0.75):1.5);
+EOF
+${GOTREE} draw svg -i tree1 --time-axis --scale-bar -o result
+grep -q ">2017</text>" result && grep -q ">2020</text>" result && grep -q ">0.5</text>" result
+${GOTREE} draw svg -i tree1 -c --time-axis --root-date 2016-01-01 --date-format iso -o result
+grep -q ">2019-01-01</text>" result
+${GOTREE} draw svg -i tree1 -r --time-axis -o result 2>/dev/null && exit 1
+${GOTREE} draw svg -i tree1 --scale-bar --no-branch-lengths -o result 2>/dev/null && exit 1
+rm -f tree1 result
+
+echo "->gotree draw heatmap and bar charts"
+printf "tip\tR1\tR2\nTip1\t0\t1\nTip2\t2\t\nTip3\t1\t0\n" > heatmap
+printf "tip\tMIC\nTip1\t0.5\nTip2\t4\n" > bars
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw svg --heatmap-file heatmap --bar-file bars -o result
+test $(grep -c "<polygon" result) -eq 7
+grep -q ">R2</text>" result && grep -q ">heatmap</text>" result && grep -q ">bars</text>" result
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw png -c -w 400 -H 400 --heatmap-file heatmap -o result
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw svg -r --heatmap-file heatmap -o result 2>/dev/null && exit 1
+printf "tip\tR1\nTip1\tresistant\n" > heatmap
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw svg --heatmap-file heatmap -o result 2>/dev/null && exit 1
+rm -f heatmap bars result
+
+echo "->gotree draw daylight"
+${GOTREE} generate yuletree --seed 10 -l 40 | ${GOTREE} draw svg --daylight -w 400 -H 400 -o result
+test $(grep -c "<line" result) -eq 77
+test $(grep -c "<text" result) -eq 40
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw png --daylight -o result
+${GOTREE} generate yuletree --seed 10 | ${GOTREE} draw svg --daylight --time-axis -o result 2>/dev/null && exit 1
+rm -f result
+
 # echo "->gotree annotate"
 # cat > inferred <<EOF
 # (((((Hylobates_pileatus:0.23988592,(Pongo_pygmaeus_abelii:0.11809071,(Gorilla_gorilla_gorilla:0.13596645,(Homo_sapiens:0.11344407,Pan_troglodytes:0.11665038)0.62:0.02364476)0.78:0.04257513)0.93:0.15711475)0.56:0.03966791,(Macaca_sylvanus:0.06332916,(Macaca_fascicularis_fascicularis:0.07605049,(Macaca_mulatta:0.06998962,Macaca_fuscata:0)0.98:0.08492791)0.47:0.02236558)0.89:0.11208218)0.43:0.0477543,Saimiri_sciureus:0.25824985)0.71:0.14311537,(Tarsius_tarsier:0.62272677,Lemur_sp.:0.40249393)0.35:0)0.62:0.077084225,(Mus_musculus:0.4057381,Bos_taurus:0.65776307)0.62:0.077084225);
@@ -1564,6 +1897,48 @@ ${GOTREE} reformat newick -i nexus -f nexus -o result
 diff -q -b expected result
 rm -f expected result nexus
 
+echo "->gotree reformat nextstrain"
+cat > input <<EOF
+((A[&date=2020.5,country=France]:1,B[&date=2020.75]:2)0.9:1,C:1,D:1);
+EOF
+cat > metadata <<EOF
+strain	country	date
+C	Spain	2020-03-XX
+D		2020.25
+EOF
+cat > expected <<EOF
+((A[&country=France,date=2020.500000]:1,B[&date=2020.750000]:2)NODE_0000001:1,C[&country=Spain]:1,D[&date=2020.250000]:1)NODE_0000000;
+EOF
+${GOTREE} reformat nextstrain -i input --metadata metadata | ${GOTREE} reformat newick -f nextstrain > result
+diff -q -b expected result
+rm -f expected result input metadata
+
+echo "->gotree reformat newick options"
+cat > input <<EOF
+((A[&date="2020",rate=1]:0.123456,B:0.2[&&NHX:S=human])0.95[&posterior=0.9]:0.1,C:1)root;
+EOF
+cat > expected <<EOF
+((A:0.123456[&&NHX:date=2020:rate=1],B:0.2[&&NHX:S=human])0.95:0.1[&&NHX:posterior=0.9],C:1)root;
+((A[&date=2020,rate=1]:0.12,B:0.20[&S=human])[&posterior=0.9]:0.10,C:1.00);
+EOF
+${GOTREE} reformat newick -i input --attributes nhx > result
+${GOTREE} reformat newick -i input --attributes beast --precision 2 --no-supports --no-internal-names >> result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree reformat nexml"
+cat > input <<EOF
+((A[&date=2020]:1,B:2)0.9[&rate=2]:1,C:1);
+((A:1,D:1):1,E:2,F:0.5);
+EOF
+cat > expected <<EOF
+((A[&date=2020]:1,B:2)0.9[&rate=2]:1,C:1);
+((A:1,D:1):1,E:2,F:0.5);
+EOF
+${GOTREE} reformat nexml -i input | ${GOTREE} reformat newick -f nexml > result
+diff -q -b expected result
+rm -f expected result input
+
 echo "->gotree reformat newick 2"
 cat > nexus <<EOF
 #NEXUS
@@ -1743,6 +2118,30 @@ diff -q -b expected result
 rm -f expected result tmp_tree.txt tmp_states.txt
 
 
+echo "->gotree acr joint"
+cat > tmp_states.txt <<EOF
+t1,A
+t2,A
+t3,B
+t4,B
+t5,A
+t8,B
+t9,B
+t10,A
+t12,A
+t13,A
+t15,A
+EOF
+cat > tmp_tree.txt <<EOF
+(t1:0.1,(t2:0.1,((t3:0.1,(t4:0.1,t5:0.1):0.1):0.1,(t8:0.1,((t9:0.1,t10:0.1):0.1,((t12:0.1,t13:0.1):0.1,t15:0.1):0.1):0.1):0.1):0.1):0.1);
+EOF
+cat > expected <<EOF
+(t1[A]:0.1,(t2[A]:0.1,((t3[B]:0.1,(t4[B]:0.1,t5[A]:0.1)[B]:0.1)[B]:0.1,(t8[B]:0.1,((t9[B]:0.1,t10[A]:0.1)[B]:0.1,((t12[A]:0.1,t13[A]:0.1)[A]:0.1,t15[A]:0.1)[A]:0.1)[B]:0.1)[B]:0.1)[B]:0.1)[A]:0.1)[A];
+EOF
+${GOTREE} acr -i tmp_tree.txt --states tmp_states.txt --algo joint --optimize-scaling=false --out-steps /dev/null -o result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_states.txt
+
 echo "->gotree asr acctran"
 cat > tmp_states.txt <<EOF
 11 2
@@ -1793,6 +2192,30 @@ ${GOTREE} asr -i tmp_tree.txt -p -a tmp_states.txt --algo downpass -o result
 diff -q -b expected result
 rm -f expected result tmp_tree.txt tmp_states.txt
 
+echo "->gotree asr ml"
+cat > tmp_states.txt <<EOF
+4 6
+t1 AAAACG
+t2 AAAACT
+t3 CCCACG
+t4 CCCA-G
+EOF
+cat > tmp_tree.txt <<EOF
+((t1:0.1,t2:0.1)n1:0.1,(t3:0.1,t4:0.1)n2:0.1)root;
+EOF
+cat > expected <<EOF
+((t1[AAAACG]:0.1,t2[AAAACT]:0.1)n1[AAAACG]:0.1,(t3[CCCACG]:0.1,t4[CCCA-G]:0.1)n2[CCCACG]:0.1)root[AAAACG];
+EOF
+cat > expected_probas <<EOF
+n1	0.981582,0.981582,0.981582,0.999743,0.999611,0.928252
+n2	0.981582,0.981582,0.981582,0.999743,0.992586,0.998423
+root	0.482769,0.482769,0.482769,0.996193,0.992457,0.958878
+EOF
+${GOTREE} asr -i tmp_tree.txt -p -a tmp_states.txt --algo ml --model jc69 --log /dev/null --out-probas result_probas -o result
+diff -q -b expected result
+diff -q -b expected_probas result_probas
+rm -f expected result expected_probas result_probas tmp_tree.txt tmp_states.txt
+
 echo "->gotree rotate sort"
 cat > expected <<EOF
 (6,(1,2),(5,(3,4)));
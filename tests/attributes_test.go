@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestAttributes(t *testing.T) {
+	intree := "((A[&date=\"2020-07-02\",rate=0.5,hpd={1,2.5}]:1,B[&&NHX:S=human:E=1.1]:2)[comment][&posterior=0.9]:1[&rate=2],C:1);"
+	tr, err := newick.NewParser(strings.NewReader(intree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[string]*tree.Node)
+	for _, n := range tr.Tips() {
+		nodes[n.Name()] = n
+	}
+
+	a := nodes["A"]
+	if attrs := a.Attributes(); len(attrs) != 3 || attrs[0].Key != "date" || attrs[0].Value != "2020-07-02" {
+		t.Errorf("Wrong attributes for A: %v", attrs)
+	}
+	if d, ok := a.Attribute("DATE"); !ok {
+		t.Errorf("Attribute date should be found")
+	} else if date, err := d.Date(); err != nil || date != 2020.5 {
+		t.Errorf("Wrong date: %f (%v)", date, err)
+	}
+	if r, err := a.FloatAttribute("rate"); err != nil || r != 0.5 {
+		t.Errorf("Wrong rate: %f (%v)", r, err)
+	}
+	if h, ok := a.Attribute("hpd"); !ok {
+		t.Errorf("Attribute hpd should be found")
+	} else if v, err := h.Floats(); err != nil || len(v) != 2 || v[0] != 1 || v[1] != 2.5 {
+		t.Errorf("Wrong hpd: %v (%v)", v, err)
+	}
+	if _, err := a.FloatAttribute("country"); err == nil {
+		t.Errorf("Missing attribute should return an error")
+	}
+
+	b := nodes["B"]
+	if s, ok := b.Attribute("S"); !ok || s.Value != "human" {
+		t.Errorf("Wrong NHX attribute S: %v", s)
+	}
+	if e, err := b.FloatAttribute("E"); err != nil || e != 1.1 {
+		t.Errorf("Wrong NHX attribute E: %f (%v)", e, err)
+	}
+
+	internal, _ := a.Parent()
+	if p, err := internal.FloatAttribute("posterior"); err != nil || p != 0.9 {
+		t.Errorf("Wrong posterior: %f (%v)", p, err)
+	}
+	edge, _ := internal.ParentEdge()
+	if r, err := edge.FloatAttribute("rate"); err != nil || r != 2 {
+		t.Errorf("Wrong edge rate: %f (%v)", r, err)
+	}
+
+	a.SetFloatAttribute("rate", 1.5)
+	a.SetAttribute("country", "France")
+	a.DeleteAttribute("hpd")
+	b.SetAttribute("S", "mouse")
+	b.DeleteAttribute("E")
+	internal.DeleteAttribute("posterior")
+	nodes["C"].SetFloatAttribute("rate", 3)
+	edge.SetAttribute("color", "red")
+
+	exp := "((A[&date=\"2020-07-02\",rate=1.5,country=\"France\"]:1,B[&&NHX:S=mouse]:2)[comment]:1[&rate=2,color=\"red\"],C[&rate=3]:1);"
+	if tr.Newick() != exp {
+		t.Errorf("Wrong newick after attribute modification:\n%s\nvs. expected\n%s", tr.Newick(), exp)
+	}
+}
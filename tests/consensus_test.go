@@ -3,7 +3,10 @@ package tests
 import (
 	"bufio"
 	"io"
+	"math"
 	"math/rand"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/evolbioinfo/gotree/io/newick"
@@ -185,3 +188,82 @@ func TestConsensus2(t *testing.T) {
 		t.Error("Strict Consensus of 3 random binary trees (1000 tips) should strongly probably be a star tree")
 	}
 }
+
+func consensusTestTrees(t *testing.T) <-chan tree.Trees {
+	newicks := []string{
+		"(A:1,B:1,(C:1,(D:1,(E:1,F:1):1):1):1);",
+		"(A:1,B:1,(C:1,(E:1,(D:1,F:1):1):1):1);",
+		"(A:1,C:1,(B:1,(D:1,(E:1,F:1):2):1):1);",
+		"(A:1,D:1,(B:1,(C:1,(E:1,F:1):6):1):1);",
+		"(A:1,E:1,(B:1,(C:1,(D:1,F:1):1):1):1);",
+	}
+	trees := make(chan tree.Trees, len(newicks))
+	for i, n := range newicks {
+		tr, err := newick.NewParser(strings.NewReader(n)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees <- tree.Trees{Tree: tr, Id: i}
+	}
+	close(trees)
+	return trees
+}
+
+// Returns the internal bipartitions of the tree (side not containing A)
+// with their supports and lengths
+func consensusSplits(tr *tree.Tree) map[string][2]float64 {
+	splits := make(map[string][2]float64)
+	names := tr.AllTipNames()
+	sort.Strings(names)
+	for _, e := range tr.Edges() {
+		if e.Right().Tip() {
+			continue
+		}
+		// Tip indexes follow the tip name order
+		a := e.TipPresent(0)
+		side := ""
+		for i, n := range names {
+			if e.TipPresent(uint(i)) != a {
+				side += n
+			}
+		}
+		splits[side] = [2]float64{e.Support(), e.Length()}
+	}
+	return splits
+}
+
+func TestGreedyConsensus(t *testing.T) {
+	majority, err := tree.ConsensusMethod(consensusTestTrees(t), tree.CONSENSUS_MAJORITY, 0.5, tree.CONSENSUS_LENGTH_MEAN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	splits := consensusSplits(majority)
+	if len(splits) != 2 {
+		t.Errorf("Majority consensus should have 2 internal branches, it has %d", len(splits))
+	}
+	if s, ok := splits["EF"]; !ok || math.Abs(s[0]-0.6) > 1e-6 || math.Abs(s[1]-3.0) > 1e-6 {
+		t.Errorf("Majority consensus should have branch EF with support 0.6 and mean length 3: %v", s)
+	}
+
+	greedy, err := tree.ConsensusMethod(consensusTestTrees(t), tree.CONSENSUS_GREEDY, 0.0, tree.CONSENSUS_LENGTH_MEDIAN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	splits = consensusSplits(greedy)
+	if len(splits) != 3 {
+		t.Errorf("Greedy consensus should have 3 internal branches, it has %d", len(splits))
+	}
+	for name, exp := range map[string][2]float64{"DEF": {0.6, 1.0}, "EF": {0.6, 2.0}, "CDEF": {0.4, 1.0}} {
+		if s, ok := splits[name]; !ok || math.Abs(s[0]-exp[0]) > 1e-6 || math.Abs(s[1]-exp[1]) > 1e-6 {
+			t.Errorf("Greedy consensus should have branch %s with support %f and median length %f: %v", name, exp[0], exp[1], s)
+		}
+	}
+
+	strict, err := tree.ConsensusMethod(consensusTestTrees(t), tree.CONSENSUS_STRICT, 0.5, tree.CONSENSUS_LENGTH_MEAN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if splits = consensusSplits(strict); len(splits) != 0 {
+		t.Errorf("Strict consensus should be a star tree, it has %d internal branches", len(splits))
+	}
+}
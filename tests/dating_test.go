@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestLeastSquaresDating(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	rate := 0.01
+	for i := 0; i < 5; i++ {
+		timetree, err := tree.RandomSerialBirthDeathTree(50, 1.0, 0.5, 0.2, 1.0, 2020.0, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expdates, err := timetree.NodeDates(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootdate := checkTimeTree(t, timetree, 50, 2020.0)
+
+		// Tree in substitutions per site, with dates given in a map
+		subtree := timetree.Clone()
+		dates := make(map[string]float64)
+		for j, tip := range subtree.Tips() {
+			dates[tip.Name()] = expdates[j]
+			tip.ClearComments()
+		}
+		for _, e := range subtree.Edges() {
+			e.SetLength(e.Length() * rate)
+		}
+		subtree.UnRoot()
+
+		res, err := subtree.LeastSquaresDating(dates, true, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(res.Rate-rate) > 1e-6 {
+			t.Errorf("Estimated rate should be %f, but is %f", rate, res.Rate)
+		}
+		if math.Abs(res.RootDate-rootdate) > 1e-3 {
+			t.Errorf("Estimated root date should be %f, but is %f", rootdate, res.RootDate)
+		}
+		if math.Abs(res.Objective) > 1e-9 {
+			t.Errorf("Least-squares value should be 0, but is %f", res.Objective)
+		}
+		// Output tree is a time tree with dates on all nodes
+		if _, err = subtree.NodeDates(false); err != nil {
+			t.Error(err)
+		}
+		if math.Abs(subtree.SumBranchLengths()-timetree.SumBranchLengths()) > 1e-3 {
+			t.Errorf("Sum of branch lengths of the dated tree should be %f, but is %f", timetree.SumBranchLengths(), subtree.SumBranchLengths())
+		}
+	}
+}
+
+func TestRootDate(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	timetree, err := tree.RandomSerialBirthDeathTree(50, 1.0, 0.5, 0.2, 1.0, 2020.0, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := checkTimeTree(t, timetree, 50, 2020.0)
+	rootdate, err := timetree.RootDate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(rootdate-exp) > 1e-4 {
+		t.Errorf("Root date should be %f, but is %f", exp, rootdate)
+	}
+
+	// The date of the root is taken if given
+	dated, err := newick.NewParser(strings.NewReader("((A[&date=2020-01-01]:1,B:2)[&date=2018.5]:1,C[&date=2020.0]:2)[&date=2017.0];")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rootdate, err = dated.RootDate(); err != nil || rootdate != 2017.0 {
+		t.Errorf("Root date should be 2017.0, but is %f (%v)", rootdate, err)
+	}
+	dated.Root().ClearComments()
+	if rootdate, err = dated.RootDate(); err != nil || math.Abs(rootdate-(2018+2017.5+2018)/3) > 1e-9 {
+		t.Errorf("Root date should be %f, but is %f (%v)", (2018+2017.5+2018)/3, rootdate, err)
+	}
+
+	for _, n := range timetree.Nodes() {
+		n.ClearComments()
+	}
+	if _, err = timetree.RootDate(); err == nil {
+		t.Errorf("Root date should not be computed without dates")
+	}
+}
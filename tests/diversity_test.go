@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestDiversity(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("((A:1,B:2):1,(C:1,D:3):2);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	div, err := tree.NewDiversity(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1 := map[string]float64{"A": 1, "C": 1}
+	s2 := map[string]float64{"B": 2, "C": 1, "D": 1}
+	s3 := map[string]float64{"D": 1}
+
+	check := func(name string, f func() (float64, error), exp float64) {
+		v, err := f()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if math.IsNaN(exp) && !math.IsNaN(v) || !math.IsNaN(exp) && math.Abs(v-exp) > 1e-9 {
+			t.Errorf("%s: expected %f, got %f", name, exp, v)
+		}
+	}
+	check("pd s1", func() (float64, error) { return div.PD(s1) }, 5)
+	check("pd s2", func() (float64, error) { return div.PD(s2) }, 9)
+	check("pd s3", func() (float64, error) { return div.PD(s3) }, 5)
+	check("mpd s1", func() (float64, error) { return div.MPD(s1, false) }, 5)
+	check("mpd s2", func() (float64, error) { return div.MPD(s2, false) }, 6)
+	check("weighted mpd s2", func() (float64, error) { return div.MPD(s2, true) }, 6.4)
+	check("mpd s3", func() (float64, error) { return div.MPD(s3, false) }, math.NaN())
+	check("mntd s1", func() (float64, error) { return div.MNTD(s1, false) }, 5)
+	check("mntd s2", func() (float64, error) { return div.MNTD(s2, false) }, 14.0/3.0)
+	check("weighted mntd s2", func() (float64, error) { return div.MNTD(s2, true) }, 5)
+	check("mntd s3", func() (float64, error) { return div.MNTD(s3, false) }, math.NaN())
+	check("unifrac s1 s2", func() (float64, error) { return div.UniFrac(s1, s2, false) }, 0.6)
+	check("weighted unifrac s1 s2", func() (float64, error) { return div.UniFrac(s1, s2, true) }, 2.5)
+
+	mat, err := div.UniFracMatrix([]map[string]float64{s1, s2, s3}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]float64{{0, 0.6, 0.75}, {0.6, 0, 4.0 / 9.0}, {0.75, 4.0 / 9.0, 0}}
+	for i := range exp {
+		for j := range exp[i] {
+			if math.Abs(mat[i][j]-exp[i][j]) > 1e-9 {
+				t.Errorf("unifrac matrix [%d][%d]: expected %f, got %f", i, j, exp[i][j], mat[i][j])
+			}
+		}
+	}
+
+	if _, err = div.PD(map[string]float64{"E": 1}); err == nil {
+		t.Errorf("expected an error with a tip absent from the tree")
+	}
+}
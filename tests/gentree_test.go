@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"math"
 	"math/rand"
 	"testing"
 
@@ -27,3 +28,100 @@ func BenchmarkBinaryTreeGeneration1000(b *testing.B)   { benchmarkBinaryTreeGene
 func BenchmarkBinaryTreeGeneration10000(b *testing.B)  { benchmarkBinaryTreeGeneration(10000, b) }
 func BenchmarkBinaryTreeGeneration100000(b *testing.B) { benchmarkBinaryTreeGeneration(100000, b) }
 func BenchmarkBinaryTreeGeneration200000(b *testing.B) { benchmarkBinaryTreeGeneration(200000, b) }
+
+// Checks that the given time tree has nbtips tips, and that
+// tip dates are consistent with root-to-tip distances.
+// Returns the date of the root.
+func checkTimeTree(t *testing.T, tr *tree.Tree, nbtips int, present float64) (rootdate float64) {
+	if len(tr.Tips()) != nbtips {
+		t.Errorf("Number of tips should be %d, but is %d", nbtips, len(tr.Tips()))
+	}
+	if !tr.Rooted() {
+		t.Errorf("Generated time tree should be rooted")
+	}
+	dates, err := tr.NodeDates(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dists := make(map[int]float64)
+	maxdate := dates[0]
+	tipidx := 0
+	tr.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) bool {
+		if prev != nil {
+			dists[cur.Id()] = dists[prev.Id()] + e.Length()
+		}
+		if cur.Tip() {
+			d := dates[tipidx] - dists[cur.Id()]
+			if tipidx == 0 {
+				rootdate = d
+			} else if math.Abs(d-rootdate) > 1e-4 {
+				t.Errorf("Tip %s date %f is not consistent with its distance to root %f", cur.Name(), dates[tipidx], dists[cur.Id()])
+			}
+			if dates[tipidx] > maxdate {
+				maxdate = dates[tipidx]
+			}
+			tipidx++
+		}
+		return true
+	})
+	if math.Abs(maxdate-present) > 1e-4 {
+		t.Errorf("Most recent tip date should be %f, but is %f", present, maxdate)
+	}
+	return
+}
+
+func TestBirthDeathTree(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	for i := 0; i < 20; i++ {
+		tr, err := tree.RandomBirthDeathTree(50, 2.0, 1.0, 0.3, 2020.0, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTimeTree(t, tr, 50, 2020.0)
+	}
+	if _, err := tree.RandomBirthDeathTree(50, 1.0, 1.0, 1.0, 0, r); err == nil {
+		t.Errorf("Critical birth-death process should return an error")
+	}
+}
+
+func TestCoalescentTree(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	nbtips := 20
+	popsize := 10.0
+	nrep := 2000
+	meanheight := 0.0
+	for i := 0; i < nrep; i++ {
+		tr, err := tree.RandomCoalescentTree(nbtips, popsize, 0.0, 0.0, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meanheight -= checkTimeTree(t, tr, nbtips, 0.0)
+	}
+	meanheight /= float64(nrep)
+	// Expected TMRCA: 2N(1-1/n)
+	expected := 2.0 * popsize * (1.0 - 1.0/float64(nbtips))
+	if math.Abs(meanheight-expected)/expected > 0.05 {
+		t.Errorf("Mean coalescent tree height should be close to %f, but is %f", expected, meanheight)
+	}
+
+	for i := 0; i < 20; i++ {
+		tr, err := tree.RandomCoalescentTree(nbtips, popsize, 1.0, 0.0, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTimeTree(t, tr, nbtips, 0.0)
+	}
+}
+
+func TestSerialBirthDeathTree(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	for _, removal := range []float64{1.0, 0.5} {
+		for i := 0; i < 20; i++ {
+			tr, err := tree.RandomSerialBirthDeathTree(30, 1.0, 0.5, 0.2, removal, 2020.0, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTimeTree(t, tr, 30, 2020.0)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestMaxCladeCredibility(t *testing.T) {
+	newicks := []string{
+		"((A:5,B:5):5,(C:1,D:1):9);",
+		"((A:1,B:1)[&rate=0.5]:1,(C:1,D:1)[&rate=1]:1);",
+		"((A:2,B:2)[&rate=1]:2,(C:2,D:2):2);",
+		"((A:2,C:2):2,(B:3,D:3):1);",
+		"((A:3,B:3)[&rate=2]:1,(C:2,D:2)[&rate=3]:2);",
+	}
+	trees := make(chan tree.Trees, len(newicks))
+	for i, n := range newicks {
+		tr, err := newick.NewParser(strings.NewReader(n)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees <- tree.Trees{Tree: tr, Id: i}
+	}
+	close(trees)
+
+	mcc, err := tree.MaxCladeCredibility(trees, 0.2, tree.MCC_HEIGHTS_MEDIAN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "((A[&height=0,height_median=0,height_95%_HPD={0,0}]:2,B[&height=0,height_median=0,height_95%_HPD={0,0}]:2)" +
+		"[&posterior=0.75,height=2,height_median=2,height_95%_HPD={1,3},rate=1.1666666666666667,rate_median=1,rate_95%_HPD={0.5,2}]:2," +
+		"(C[&height=0,height_median=0,height_95%_HPD={0,0}]:2,D[&height=0,height_median=0,height_95%_HPD={0,0}]:2)" +
+		"[&posterior=0.75,height=1.6666666666666667,height_median=2,height_95%_HPD={1,2},rate=2,rate_median=2,rate_95%_HPD={1,3}]:2)" +
+		"[&posterior=1,height=3.5,height_median=4,height_95%_HPD={2,4}];"
+	if mcc.Newick() != expected {
+		t.Errorf("MCC tree is not the expected one:\n%s\n%s", mcc.Newick(), expected)
+	}
+}
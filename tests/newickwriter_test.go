@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestNewickWithOptions(t *testing.T) {
+	intree := "((A[&date=\"2020\",rate=1]:0.123456,'B c':0.2[&&NHX:S=human])0.95[&posterior=0.9]:0.1[&rate=2],C:1)root;"
+	tr, err := newick.NewParser(strings.NewReader(intree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts     func(o *tree.NewickOptions)
+		expected string
+	}{
+		{func(o *tree.NewickOptions) {}, intree},
+		{func(o *tree.NewickOptions) { o.Attributes = tree.NEWICK_ATTRIBUTES_BEAST },
+			"((A[&date=2020,rate=1]:0.123456,'B c':0.2[&S=human])0.95[&posterior=0.9]:0.1[&rate=2],C:1)root;"},
+		{func(o *tree.NewickOptions) { o.Attributes = tree.NEWICK_ATTRIBUTES_NHX },
+			"((A:0.123456[&&NHX:date=2020:rate=1],'B c':0.2[&&NHX:S=human])0.95:0.1[&&NHX:posterior=0.9:rate=2],C:1)root;"},
+		{func(o *tree.NewickOptions) { o.Precision = 2 },
+			"((A[&date=\"2020\",rate=1]:0.12,'B c':0.20[&&NHX:S=human])0.95[&posterior=0.9]:0.10[&rate=2],C:1.00)root;"},
+		{func(o *tree.NewickOptions) { o.NoSupports = true; o.NoInternalNames = true },
+			"((A[&date=\"2020\",rate=1]:0.123456,'B c':0.2[&&NHX:S=human])[&posterior=0.9]:0.1[&rate=2],C:1);"},
+		{func(o *tree.NewickOptions) { o.NoInternalNames = true },
+			"((A[&date=\"2020\",rate=1]:0.123456,'B c':0.2[&&NHX:S=human])0.95[&posterior=0.9]:0.1[&rate=2],C:1);"},
+		{func(o *tree.NewickOptions) { o.QuoteNames = true },
+			"(('A'[&date=\"2020\",rate=1]:0.123456,'B c':0.2[&&NHX:S=human])0.95[&posterior=0.9]:0.1[&rate=2],'C':1)'root';"},
+	}
+
+	for i, test := range tests {
+		opts := tree.DefaultNewickOptions()
+		test.opts(&opts)
+		if out := tr.NewickWithOptions(opts); out != test.expected {
+			t.Errorf("Test %d: Wrong newick output:\n%s\nvs. expected\n%s", i, out, test.expected)
+		}
+	}
+
+	tr.Tips()[0].SetName("it's")
+	opts := tree.DefaultNewickOptions()
+	opts.QuoteNames = true
+	tr2, err := newick.NewParser(strings.NewReader(tr.NewickWithOptions(opts))).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr2.Tips()[0].Name() != "it's" {
+		t.Errorf("Quoted name is not parsed back: %s", tr2.Tips()[0].Name())
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/io/nexml"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestNeXMLParse(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<nex:nexml xmlns:nex="http://www.nexml.org/2009" xmlns="http://www.nexml.org/2009"
+  xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:tb="http://purl.org/phylo/treebase/2.0/terms#" version="0.9">
+  <otus id="tax1" label="Taxa">
+    <otu id="t1" label="Homo sapiens">
+      <meta xsi:type="nex:ResourceMeta" rel="tb:identifier.taxon" href="http://purl.uniprot.org/taxonomy/9606"/>
+    </otu>
+    <otu id="t2" label="Pan"/>
+    <otu id="t3" label="Gorilla"/>
+  </otus>
+  <trees otus="tax1" id="Trees1">
+    <tree id="tree1" label="My tree" xsi:type="nex:FloatTree">
+      <node id="n1" root="true"/>
+      <node id="n2"><meta xsi:type="nex:LiteralMeta" property="tb:posterior" content="0.95" datatype="xsd:double"/></node>
+      <node id="n3" otu="t1"/>
+      <node id="n4" otu="t2" label="ignored"/>
+      <node id="n5" otu="t3"/>
+      <rootedge target="n1" length="0.1"/>
+      <edge source="n1" target="n2" id="e1" length="0.2"/>
+      <edge source="n2" target="n3" id="e2" length="0.3"/>
+      <edge source="n2" target="n4" id="e3" length="0.4"/>
+      <edge source="n1" target="n5" id="e4" length="0.5"><meta xsi:type="nex:LiteralMeta" property="tb:rate" content="1.5"/></edge>
+    </tree>
+    <tree id="tree2" xsi:type="nex:IntTree">
+      <node id="m1"/>
+      <node id="m2" otu="t1"/>
+      <node id="m3" otu="t2"/>
+      <node id="m4" otu="t3"/>
+      <edge source="m1" target="m2" id="f1" length="1"/>
+      <edge source="m1" target="m3" id="f2" length="2"/>
+      <edge source="m1" target="m4" id="f3"/>
+    </tree>
+  </trees>
+</nex:nexml>`
+
+	nx, err := nexml.NewParser(strings.NewReader(doc)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"(('Homo sapiens'[&identifier.taxon=\"http://purl.uniprot.org/taxonomy/9606\"]:0.3,Pan:0.4)[&posterior=0.95]:0.2,Gorilla:0.5[&rate=1.5]);",
+		"('Homo sapiens'[&identifier.taxon=\"http://purl.uniprot.org/taxonomy/9606\"]:1,Pan:2,Gorilla);",
+	}
+	i := 0
+	nx.IterateTrees(func(tr *tree.Tree, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(expected) {
+			t.Fatalf("Too many trees parsed")
+		}
+		if tr.Newick() != expected[i] {
+			t.Errorf("Tree %d: Wrong parsed tree:\n%s\nvs. expected\n%s", i, tr.Newick(), expected[i])
+		}
+		i++
+	})
+	if i != len(expected) {
+		t.Errorf("Expected %d trees, got %d", len(expected), i)
+	}
+}
+
+func TestNeXMLWriteParse(t *testing.T) {
+	intrees := []string{
+		"((A[&date=2020,country=\"Fr & co\"]:1,B:2)0.9[&rate=2]:1,'C d':1);",
+		"((A:1,D:1):1[&rate=3],E:2,F:0.5);",
+	}
+	trees := make(chan tree.Trees, len(intrees))
+	for i, s := range intrees {
+		tr, err := newick.NewParser(strings.NewReader(s)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees <- tree.Trees{Tree: tr, Id: i}
+	}
+	close(trees)
+
+	out, err := nexml.WriteNeXML(trees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nx, err := nexml.NewParser(strings.NewReader(out)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nx.Otus) != 1 || len(nx.Otus[0].Otus) != 6 {
+		t.Errorf("There should be one otus block with 6 otus")
+	}
+	i := 0
+	nx.IterateTrees(func(tr *tree.Tree, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr.Newick() != intrees[i] {
+			t.Errorf("Tree %d: Wrong tree after NeXML round trip:\n%s\nvs. expected\n%s", i, tr.Newick(), intrees[i])
+		}
+		i++
+	})
+	if i != len(intrees) {
+		t.Errorf("Expected %d trees, got %d", len(intrees), i)
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/io/nextstrain"
+)
+
+func TestWriteNextstrain(t *testing.T) {
+	intree := "((A[&date=2020-07-02,country=France]:1,B[&date=2020.75]:2)0.9[&mutations=S.D614G]:1,C:1,D:1);"
+	metadata := map[string]map[string]string{
+		"C": {"country": "Spain", "age": "32"},
+	}
+	tr, err := newick.NewParser(strings.NewReader(intree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	json, err := nextstrain.WriteNextstrain(tr, metadata, "Test", "2024-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"version": "v2"`, `"updated": "2024-01-01"`, `"aa": "S:D614G"`, `"name": "NODE_0000001"`} {
+		if !strings.Contains(json, s) {
+			t.Errorf("Nextstrain output should contain %s", s)
+		}
+	}
+
+	ns, err := nextstrain.NewParser(strings.NewReader(json)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := ns.FirstTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.ReinitIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if err = back.ReinitIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.CompareTipIndexes(back); err != nil {
+		t.Error(err)
+	}
+	if tr.SumBranchLengths() != back.SumBranchLengths() {
+		t.Errorf("Sum of branch lengths differ: %f vs. %f", tr.SumBranchLengths(), back.SumBranchLengths())
+	}
+	dates := map[string]string{"A": "&country=France,date=2020.500000", "B": "&date=2020.750000", "C": "&country=Spain"}
+	for _, tip := range back.Tips() {
+		if d, ok := dates[tip.Name()]; ok && (len(tip.Comments()) != 1 || tip.Comments()[0] != d) {
+			t.Errorf("Tip %s should have comment %s, got %v", tip.Name(), d, tip.Comments())
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math/rand"
 	"testing"
 
 	"github.com/evolbioinfo/gotree/hashmap"
@@ -227,3 +228,79 @@ func TestIndexQuartets3(t *testing.T) {
 		t.Error(fmt.Sprintf("There should be 5 quartets in the index, but: %d", l))
 	}
 }
+
+// Topology of the quartet (a,b,c,d) in the tree (bitsets must be up to date):
+// 0: ab|cd, 1: ac|bd, 2: ad|bc, -1: unresolved
+func quartetTopology(tr *tree.Tree, a, b, c, d uint) int {
+	for _, e := range tr.Edges() {
+		if e.Right().Tip() {
+			continue
+		}
+		pa, pb, pc, pd := e.TipPresent(a), e.TipPresent(b), e.TipPresent(c), e.TipPresent(d)
+		switch {
+		case pa == pb && pc == pd && pa != pc:
+			return 0
+		case pa == pc && pb == pd && pa != pb:
+			return 1
+		case pa == pd && pb == pc && pa != pb:
+			return 2
+		}
+	}
+	return -1
+}
+
+// Compares quartet counts with a naive enumeration of all quartets
+func TestQuartetDistance(t *testing.T) {
+	var t1, t2 *tree.Tree
+	var err error
+	var st tree.QuartetStats
+	var ntips int = 14
+	r := rand.New(rand.NewSource(10))
+
+	for i := 0; i < 20; i++ {
+		if t1, err = tree.RandomYuleBinaryTree(ntips, false, r); err != nil {
+			t.Error(err)
+		}
+		if t2, err = tree.RandomYuleBinaryTree(ntips, i%2 == 0, r); err != nil {
+			t.Error(err)
+		}
+		if i%3 == 0 {
+			t1.CollapseShortBranches(0.05, false, false)
+		}
+		if i%4 == 0 {
+			t2.CollapseShortBranches(0.05, false, false)
+		}
+		if st, err = t1.CompareQuartets(t2, 2); err != nil {
+			t.Error(err)
+			return
+		}
+		t1.ReinitIndexes()
+		t2.ReinitIndexes()
+		var total, shared, diff, unresref, unrescomp uint64
+		for a := uint(0); a < uint(ntips); a++ {
+			for b := a + 1; b < uint(ntips); b++ {
+				for c := b + 1; c < uint(ntips); c++ {
+					for d := c + 1; d < uint(ntips); d++ {
+						total++
+						q1 := quartetTopology(t1, a, b, c, d)
+						q2 := quartetTopology(t2, a, b, c, d)
+						switch {
+						case q1 == -1 && q2 != -1:
+							unresref++
+						case q1 != -1 && q2 == -1:
+							unrescomp++
+						case q1 != -1 && q1 == q2:
+							shared++
+						case q1 != q2:
+							diff++
+						}
+					}
+				}
+			}
+		}
+		if st.Total != total || st.Shared != shared || st.Diff != diff || st.UnresolvedRef != unresref || st.UnresolvedComp != unrescomp {
+			t.Error(fmt.Sprintf("Quartet counts are not expected: total=%d/%d, shared=%d/%d, diff=%d/%d, unresolved ref=%d/%d, unresolved comp=%d/%d",
+				st.Total, total, st.Shared, shared, st.Diff, diff, st.UnresolvedRef, unresref, st.UnresolvedComp, unrescomp))
+		}
+	}
+}
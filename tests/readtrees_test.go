@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestReadMultiTreesThreads(t *testing.T) {
+	var newicks bytes.Buffer
+	var expected []string
+	r := rand.New(rand.NewSource(10))
+	for i := 0; i < 500; i++ {
+		// Trees of various sizes, so that parsing times differ
+		tr, err := tree.RandomYuleBinaryTree(5+r.Intn(200), true, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, tr.Newick())
+		newicks.WriteString(tr.Newick() + "\n")
+	}
+
+	for _, threads := range []int{1, 4} {
+		i := 0
+		for tr := range utils.ReadMultiTreesThreads(bufio.NewReader(strings.NewReader(newicks.String())), utils.FORMAT_NEWICK, threads) {
+			if tr.Err != nil {
+				t.Fatal(tr.Err)
+			}
+			if tr.Id != i {
+				t.Errorf("Threads %d: Tree %d has id %d", threads, i, tr.Id)
+			}
+			if tr.Tree.Newick() != expected[i] {
+				t.Errorf("Threads %d: Tree %d is not the expected one", threads, i)
+			}
+			i++
+		}
+		if i != len(expected) {
+			t.Errorf("Threads %d: %d trees read instead of %d", threads, i, len(expected))
+		}
+	}
+}
+
+func TestReadMultiTreesThreadsError(t *testing.T) {
+	var newicks bytes.Buffer
+	for i := 0; i < 100; i++ {
+		if i == 42 {
+			newicks.WriteString("((A,B),(C,D);\n")
+		} else {
+			newicks.WriteString("((A,B),(C,D));\n")
+		}
+	}
+	i := 0
+	for tr := range utils.ReadMultiTreesThreads(bufio.NewReader(strings.NewReader(newicks.String())), utils.FORMAT_NEWICK, 4) {
+		if tr.Id != i {
+			t.Errorf("Tree %d has id %d", i, tr.Id)
+		}
+		if (tr.Err != nil) != (i == 42) {
+			t.Errorf("Tree %d: unexpected error status: %v", i, tr.Err)
+		}
+		i++
+	}
+	if i != 43 {
+		t.Errorf("No tree should be sent after the error, got %d trees", i)
+	}
+}
+
+func TestReadMultiTreesThreadsNexus(t *testing.T) {
+	var nexus bytes.Buffer
+	nexus.WriteString("#NEXUS\nBEGIN TREES;\n")
+	for i := 0; i < 50; i++ {
+		nexus.WriteString(fmt.Sprintf("  TREE tree%d = ((A:%d,B:1),(C:1,D:1));\n", i, i+1))
+	}
+	nexus.WriteString("END;\n")
+	i := 0
+	for tr := range utils.ReadMultiTreesThreads(bufio.NewReader(strings.NewReader(nexus.String())), utils.FORMAT_NEXUS, 4) {
+		if tr.Err != nil {
+			t.Fatal(tr.Err)
+		}
+		exp := fmt.Sprintf("((A:%d,B:1),(C:1,D:1));", i+1)
+		if tr.Id != i || tr.Tree.Newick() != exp {
+			t.Errorf("Tree %d (id %d) is not the expected one: %s vs. %s", i, tr.Id, tr.Tree.Newick(), exp)
+		}
+		i++
+	}
+	if i != 50 {
+		t.Errorf("%d trees read instead of 50", i)
+	}
+}
@@ -2,9 +2,12 @@ package tests
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
+	"strings"
 	"testing"
 
+	"github.com/evolbioinfo/gotree/io/newick"
 	"github.com/evolbioinfo/gotree/tree"
 )
 
@@ -121,3 +124,215 @@ func TestReRootOutgroupRemoveUnRooted(t *testing.T) {
 		}
 	}
 }
+
+// Root-to-tip distances and tip-to-tip distance matrix of a rooted tree,
+// tips being sorted by name
+func rootTipDistances(tr *tree.Tree) (rtt []float64, matrix [][]float64) {
+	dists := make(map[*tree.Node]float64)
+	tr.PreOrder(func(cur, prev *tree.Node, e *tree.Edge) (keep bool) {
+		if prev != nil {
+			dists[cur] = dists[prev] + e.Length()
+		}
+		return true
+	})
+	matrix, tips := tr.ToDistanceMatrix(tree.DISTANCE_METRIC_BRLEN)
+	for _, tip := range tips {
+		rtt = append(rtt, dists[tip])
+	}
+	return
+}
+
+// Variance of the root-to-tip distances
+func minVarScore(tr *tree.Tree) float64 {
+	rtt, _ := rootTipDistances(tr)
+	mean, sq := 0.0, 0.0
+	for _, d := range rtt {
+		mean += d
+		sq += d * d
+	}
+	mean /= float64(len(rtt))
+	return sq/float64(len(rtt)) - mean*mean
+}
+
+// Root mean square of the ancestor deviations of all pairs of tips:
+// (d(i,lca)-d(j,lca))/d(i,j) = (d(i,root)-d(j,root))/d(i,j)
+func madScore(tr *tree.Tree) float64 {
+	rtt, matrix := rootTipDistances(tr)
+	sum, npairs := 0.0, 0
+	for i := range rtt {
+		for j := i + 1; j < len(rtt); j++ {
+			r := (rtt[i] - rtt[j]) / matrix[i][j]
+			sum += r * r
+			npairs++
+		}
+	}
+	return math.Sqrt(sum / float64(npairs))
+}
+
+func TestRerootMinVarMADUltrametric(t *testing.T) {
+	for _, method := range []string{"minvar", "mad"} {
+		tr, err := newick.NewParser(strings.NewReader("(((A:1,B:1):1,C:2):1,(D:0.5,E:0.5):2.5);")).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var best tree.RootScore
+		var scores []tree.RootScore
+		if method == "minvar" {
+			best, scores, err = tr.RerootMinVar()
+		} else {
+			best, scores, err = tr.RerootMAD()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The unrooted tree has 7 branches
+		if len(scores) != 7 {
+			t.Errorf("%s: expected 7 branch scores, got %d", method, len(scores))
+		}
+		if best.Score > 1e-9 {
+			t.Errorf("%s: expected a null score for an ultrametric tree, got %f", method, best.Score)
+		}
+		lengths := make([]float64, 0)
+		for _, e := range tr.Root().Edges() {
+			lengths = append(lengths, e.Length())
+		}
+		if len(lengths) != 2 || math.Abs(lengths[0]+lengths[1]-3.5) > 1e-9 || math.Abs(lengths[0]*lengths[1]-2.5) > 1e-9 {
+			t.Errorf("%s: expected root branches of lengths 1 and 2.5, got %v", method, lengths)
+		}
+	}
+}
+
+/*
+Generates random trees, reroots them with MinVar and MAD, and compares
+the reported scores to the scores computed on the rerooted trees, and
+to the scores of the midpoint rooting
+*/
+func TestRerootMinVarMADScores(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		tr, err := tree.RandomYuleBinaryTree(40, false, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		midpoint := tr.Clone()
+		if err = midpoint.RerootMidPoint(); err != nil {
+			t.Fatal(err)
+		}
+
+		minvar := tr.Clone()
+		best, scores, err := minvar.RerootMinVar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := minVarScore(minvar); math.Abs(s-best.Score) > 1e-9 {
+			t.Errorf("seed %d: expected a MinVar score of %f, got %f", seed, s, best.Score)
+		}
+		if m := minVarScore(midpoint); best.Score > m+1e-9 {
+			t.Errorf("seed %d: MinVar score %f is worse than the midpoint one %f", seed, best.Score, m)
+		}
+		for _, s := range scores {
+			if s.Score < best.Score {
+				t.Errorf("seed %d: branch score %f lower than the chosen one %f", seed, s.Score, best.Score)
+			}
+		}
+
+		mad := tr.Clone()
+		if best, _, err = mad.RerootMAD(); err != nil {
+			t.Fatal(err)
+		}
+		if s := madScore(mad); math.Abs(s-best.Score) > 1e-9 {
+			t.Errorf("seed %d: expected a MAD score of %f, got %f", seed, s, best.Score)
+		}
+		if m := madScore(midpoint); best.Score > m+1e-9 {
+			t.Errorf("seed %d: MAD score %f is worse than the midpoint one %f", seed, best.Score, m)
+		}
+	}
+}
+
+// Root-to-tip regression of a rooted tree against tip dates:
+// slope, date of the root (x-intercept) and R²
+func rttRegression(t *testing.T, tr *tree.Tree) (rate, rootdate, r2 float64) {
+	dists := make(map[*tree.Node]float64)
+	var xs, ys []float64
+	tr.PreOrder(func(cur, prev *tree.Node, e *tree.Edge) (keep bool) {
+		if prev != nil {
+			dists[cur] = dists[prev] + e.Length()
+		}
+		if cur.Tip() {
+			a, ok := cur.Attribute("date")
+			if !ok {
+				t.Fatalf("tip %s has no date", cur.Name())
+			}
+			d, err := a.Date()
+			if err != nil {
+				t.Fatal(err)
+			}
+			xs = append(xs, d)
+			ys = append(ys, dists[cur])
+		}
+		return true
+	})
+	var mx, my, sxx, sxy, syy float64
+	for i := range xs {
+		mx += xs[i] / float64(len(xs))
+		my += ys[i] / float64(len(xs))
+	}
+	for i := range xs {
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		syy += (ys[i] - my) * (ys[i] - my)
+	}
+	rate = sxy / sxx
+	return rate, mx - my/rate, sxy * sxy / (sxx * syy)
+}
+
+/*
+Generates a random time tree (strict clock, rate 1), unroots it, and
+checks that the root-to-tip regression rooting recovers the root. Then
+adds noise to the branch lengths and compares the reported statistics
+to the regression on the rerooted tree
+*/
+func TestRerootRTT(t *testing.T) {
+	timetree, err := tree.RandomSerialBirthDeathTree(50, 1.0, 0.5, 0.2, 1.0, 2020.0, rand.New(rand.NewSource(10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootdate, err := timetree.RootDate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, criterion := range []int{tree.RTT_CRITERION_R2, tree.RTT_CRITERION_RMS} {
+		tr := timetree.Clone()
+		tr.UnRoot()
+		best, scores, err := tr.RerootRTT(criterion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scores) != len(tr.Edges())-1 {
+			t.Errorf("expected one score per branch of the unrooted tree, got %d", len(scores))
+		}
+		if math.Abs(best.Rate-1) > 1e-6 || math.Abs(best.RootDate-rootdate) > 1e-6 || math.Abs(best.R2-1) > 1e-9 || best.RMS > 1e-9 {
+			t.Errorf("criterion %d: expected a perfect regression of rate 1 and root date %f, got %+v", criterion, rootdate, best)
+		}
+	}
+
+	r := rand.New(rand.NewSource(20))
+	for _, e := range timetree.Edges() {
+		e.SetLength(e.Length() * (0.5 + r.Float64()))
+	}
+	for _, criterion := range []int{tree.RTT_CRITERION_R2, tree.RTT_CRITERION_RMS} {
+		tr := timetree.Clone()
+		best, scores, err := tr.RerootRTT(criterion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rate, rootdate, r2 := rttRegression(t, tr)
+		if math.Abs(best.Rate-rate) > 1e-9 || math.Abs(best.RootDate-rootdate) > 1e-6 || math.Abs(best.R2-r2) > 1e-9 {
+			t.Errorf("criterion %d: expected rate %f, root date %f and R2 %f, got %+v", criterion, rate, rootdate, r2, best)
+		}
+		for _, s := range scores {
+			if (criterion == tree.RTT_CRITERION_R2 && s.Rate > 0 && s.R2 > best.R2+1e-12) || (criterion == tree.RTT_CRITERION_RMS && s.RMS < best.RMS-1e-12) {
+				t.Errorf("criterion %d: branch %+v better than the chosen root %+v", criterion, s, best)
+			}
+		}
+	}
+}
@@ -2,7 +2,9 @@ package tests
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"strings"
 	"testing"
 
@@ -781,3 +783,115 @@ func TestNNI3(t *testing.T) {
 		t.Error(fmt.Errorf("The number of NNIS is not expected : %d vs. %d", nnis, (ntips-3)*2))
 	}
 }
+
+// Key identifying the unrooted topology of the tree (bitsets must be up to date)
+func topologyKey(tr *tree.Tree) string {
+	keys := make([]string, 0)
+	for _, e := range tr.Edges() {
+		bs := e.Bitset()
+		var sb strings.Builder
+		for i := uint(0); i < bs.Len(); i++ {
+			if bs.Test(i) != bs.Test(0) {
+				sb.WriteByte('1')
+			} else {
+				sb.WriteByte('0')
+			}
+		}
+		keys = append(keys, sb.String())
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// Applies and undoes all rearrangements, checks that the tree is
+// restored after each undo, and returns the number of distinct
+// neighbor topologies
+func testRearranger(t *testing.T, tr *tree.Tree, r tree.Rearranger) (nbneighbors int) {
+	var err error
+	initnewick := tr.Newick()
+	initlength := tr.SumBranchLengths()
+	if err = tr.ReinitIndexes(); err != nil {
+		t.Error(err)
+	}
+	initkey := topologyKey(tr)
+	neighbors := make(map[string]bool)
+	r.Rearrange(tr, func(re tree.Rearrangement) bool {
+		if err = re.Apply(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if err = tr.CheckTreePostOrder(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if math.Abs(tr.SumBranchLengths()-initlength) > 1e-9 {
+			t.Error(fmt.Errorf("Tree length after rearrangement is not the same as initial tree length: %f vs. %f", tr.SumBranchLengths(), initlength))
+			return false
+		}
+		tr.UpdateBitSet()
+		key := topologyKey(tr)
+		if key == initkey {
+			t.Error(fmt.Errorf("Tree after rearrangement is identical to initial tree: %s", tr.Newick()))
+			return false
+		}
+		neighbors[key] = true
+		if err = re.Undo(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if err = tr.CheckTreePostOrder(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if tr.Newick() != initnewick {
+			t.Error(fmt.Errorf("Tree after undoing rearrangement is not the initial tree: %s vs. %s", tr.Newick(), initnewick))
+			return false
+		}
+		return true
+	})
+	tr.UpdateBitSet()
+	return len(neighbors)
+}
+
+func TestSPR(t *testing.T) {
+	treeString := "(a:1,b:2,(c:3,(d:4,(e:5,f:6):7):8):9);"
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+	}
+	// 2(n-3)(2n-7) neighbors
+	if nb := testRearranger(t, tr, &tree.SPRRearranger{}); nb != 30 {
+		t.Error(fmt.Errorf("The number of SPR neighbors is not expected : %d vs. %d", nb, 30))
+	}
+	// Radius 1 : NNIs
+	if nb := testRearranger(t, tr, &tree.SPRRearranger{MaxRadius: 1}); nb != 6 {
+		t.Error(fmt.Errorf("The number of SPR neighbors with radius 1 is not expected : %d vs. %d", nb, 6))
+	}
+}
+
+func TestSPR2(t *testing.T) {
+	var tr *tree.Tree
+	var err error
+	var ntips int = 30
+	if tr, err = tree.RandomYuleBinaryTree(ntips, false, rand.New(rand.NewSource(10))); err != nil {
+		t.Error(err)
+	}
+	if nb := testRearranger(t, tr, &tree.SPRRearranger{}); nb != 2*(ntips-3)*(2*ntips-7) {
+		t.Error(fmt.Errorf("The number of SPR neighbors is not expected : %d vs. %d", nb, 2*(ntips-3)*(2*ntips-7)))
+	}
+}
+
+func TestTBR(t *testing.T) {
+	treeString := "(a:1,b:2,(c:3,(d:4,(e:5,f:6):7):8):9);"
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+	}
+	if nb := testRearranger(t, tr, &tree.TBRRearranger{}); nb != 34 {
+		t.Error(fmt.Errorf("The number of TBR neighbors is not expected : %d vs. %d", nb, 34))
+	}
+	// Radius 1 : 6 NNIs + 4 moves of both subtrees around the central edge
+	if nb := testRearranger(t, tr, &tree.TBRRearranger{MaxRadius: 1}); nb != 10 {
+		t.Error(fmt.Errorf("The number of TBR neighbors with radius 1 is not expected : %d vs. %d", nb, 10))
+	}
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestTreeDistances(t *testing.T) {
+	var t1, t2 *tree.Tree
+	var err error
+	var d float64
+
+	tree1 := "((a:1,b:1):1,(c:1,d:1):1,(e:1,f:1):1);"
+	tree2 := "((a:1,c:1):1,(b:1,d:1):2,(e:1,f:1):1);"
+	expected := map[int]float64{
+		tree.TREE_DISTANCE_RF:  4,
+		tree.TREE_DISTANCE_MS:  4,
+		tree.TREE_DISTANCE_KF:  math.Sqrt(7),
+		tree.TREE_DISTANCE_PD:  4,
+		tree.TREE_DISTANCE_SPR: 2,
+	}
+
+	for dist, exp := range expected {
+		if t1, err = newick.NewParser(strings.NewReader(tree1)).Parse(); err != nil {
+			t.Error(err)
+		}
+		if t2, err = newick.NewParser(strings.NewReader(tree2)).Parse(); err != nil {
+			t.Error(err)
+		}
+		if d, err = tree.TreeDistance(t1, t2, dist); err != nil {
+			t.Error(err)
+		}
+		if math.Abs(d-exp) > 1e-9 {
+			t.Error(fmt.Errorf("Distance %d is not expected: %f vs. %f", dist, d, exp))
+		}
+		if d, err = tree.TreeDistance(t1, t1.Clone(), dist); err != nil {
+			t.Error(err)
+		}
+		if d != 0 {
+			t.Error(fmt.Errorf("Distance %d between identical trees is not 0: %f", dist, d))
+		}
+	}
+}
+
+func TestTreeDistanceSPR(t *testing.T) {
+	var t1, t2 *tree.Tree
+	var err error
+	var d float64
+
+	// Swapping the two tips at the ends of a caterpillar needs 2 SPR moves
+	if t1, err = newick.NewParser(strings.NewReader("(a,b,(c,(d,(e,(f,(g,h))))));")).Parse(); err != nil {
+		t.Error(err)
+	}
+	if t2, err = newick.NewParser(strings.NewReader("(h,b,(c,(d,(e,(f,(g,a))))));")).Parse(); err != nil {
+		t.Error(err)
+	}
+	if d, err = tree.TreeDistance(t1, t2, tree.TREE_DISTANCE_SPR); err != nil {
+		t.Error(err)
+	}
+	if d != 2 {
+		t.Error(fmt.Errorf("SPR distance is not expected: %f vs. %f", d, 2.0))
+	}
+
+	// Radius 1 SPRs (NNIs) are at distance 1
+	if t1, err = tree.RandomYuleBinaryTree(20, false, rand.New(rand.NewSource(10))); err != nil {
+		t.Error(err)
+	}
+	(&tree.SPRRearranger{MaxRadius: 1}).Rearrange(t1, func(r tree.Rearrangement) bool {
+		if err = r.Apply(); err != nil {
+			t.Error(err)
+			return false
+		}
+		t2 = t1.Clone()
+		if err = r.Undo(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if d, err = tree.TreeDistance(t1.Clone(), t2, tree.TREE_DISTANCE_SPR); err != nil {
+			t.Error(err)
+			return false
+		}
+		if d != 1 {
+			t.Error(fmt.Errorf("SPR distance after an NNI is not expected: %f vs. %f", d, 1.0))
+			return false
+		}
+		return true
+	})
+}
+
+func TestTreeDistanceMatrix(t *testing.T) {
+	var err error
+	var mat [][]float64
+	trees := make([]*tree.Tree, 5)
+	r := rand.New(rand.NewSource(10))
+	for i := range trees {
+		if trees[i], err = tree.RandomYuleBinaryTree(30, false, r); err != nil {
+			t.Error(err)
+		}
+	}
+	for dist := tree.TREE_DISTANCE_RF; dist <= tree.TREE_DISTANCE_SPR; dist++ {
+		if mat, err = tree.TreeDistanceMatrix(trees, dist, 2); err != nil {
+			t.Error(err)
+		}
+		for i := range trees {
+			if mat[i][i] != 0 {
+				t.Error(fmt.Errorf("Distance %d: diagonal of the matrix should be 0: %f", dist, mat[i][i]))
+			}
+			for j := range trees {
+				if mat[i][j] != mat[j][i] {
+					t.Error(fmt.Errorf("Distance %d: matrix is not symmetric", dist))
+				}
+				if i != j {
+					var d float64
+					if d, err = tree.TreeDistance(trees[i], trees[j], dist); err != nil {
+						t.Error(err)
+					}
+					if math.Abs(d-mat[i][j]) > 1e-9 {
+						t.Error(fmt.Errorf("Distance %d: matrix distance is not expected: %f vs. %f", dist, mat[i][j], d))
+					}
+				}
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/evolbioinfo/gotree/io"
 	"github.com/evolbioinfo/gotree/sliceutils"
+	"github.com/fredericlemoine/bitset"
 )
 
 // Given a set of tip names, this function
@@ -261,6 +262,19 @@ func (t *Tree) AddBipartition(n *Node, edges []*Edge, length, support float64) (
 	return e, nil
 }
 
+// Consensus methods
+const (
+	CONSENSUS_MAJORITY = iota // Majority rule consensus, with a minimum frequency cutoff
+	CONSENSUS_STRICT          // Strict consensus
+	CONSENSUS_GREEDY          // Greedy (extended majority rule) consensus
+)
+
+// Consensus branch lengths
+const (
+	CONSENSUS_LENGTH_MEAN   = iota // Mean length over the trees containing the bipartition
+	CONSENSUS_LENGTH_MEDIAN        // Median length over the trees containing the bipartition
+)
+
 // Builds the consensus of trees given in the input channel.
 //   - If the cutoff is 0.5 : The majority rule consensus is computed;
 //   - If tht cutoff is 1   : The strict consensus is computed
@@ -274,9 +288,49 @@ func (t *Tree) AddBipartition(n *Node, edges []*Edge, length, support float64) (
 //   - The tip names are different in the different trees
 //   - Incompatible bipartition are generated to build the consensus (It should not happen since cutoff should be >=0.5)
 func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
-	if cutoff < 0.5 || cutoff > 1 {
-		return nil, errors.New("min frequency for bipartition must be >=0.5 and <=1")
+	return ConsensusMethod(trees, CONSENSUS_MAJORITY, cutoff, CONSENSUS_LENGTH_MEAN)
+}
+
+// Builds the consensus of trees given in the input channel, using the given method:
+//   - CONSENSUS_MAJORITY: Bipartitions present in more than cutoff (>=0.5 and <=1) of the trees are kept;
+//   - CONSENSUS_STRICT: Only bipartitions present in all the trees are kept (cutoff is ignored);
+//   - CONSENSUS_GREEDY: Bipartitions present in at least cutoff (>=0 and <=1) of the trees are
+//     considered by decreasing frequency, and added to the consensus if they are compatible with
+//     all the bipartitions already added. With a cutoff of 0, the consensus is generally fully resolved.
+//
+// In the output consensus tree:
+//  1. Branch supports are computed as the proportion of trees in which the bipartitions are present
+//  2. Branch lengths are computed as the mean (CONSENSUS_LENGTH_MEAN) or median (CONSENSUS_LENGTH_MEDIAN)
+//     length of the same branch over all the trees where it is present
+func ConsensusMethod(trees <-chan Trees, method int, cutoff float64, brlen int) (*Tree, error) {
+	var minCount int
+
+	switch method {
+	case CONSENSUS_MAJORITY:
+		if cutoff < 0.5 || cutoff > 1 {
+			for range trees {
+			}
+			return nil, errors.New("min frequency for bipartition must be >=0.5 and <=1")
+		}
+	case CONSENSUS_STRICT:
+		cutoff = 1.0
+	case CONSENSUS_GREEDY:
+		if cutoff < 0 || cutoff > 1 {
+			for range trees {
+			}
+			return nil, errors.New("min frequency for bipartition must be >=0 and <=1")
+		}
+	default:
+		for range trees {
+		}
+		return nil, errors.New("unknown consensus method")
 	}
+	if brlen != CONSENSUS_LENGTH_MEAN && brlen != CONSENSUS_LENGTH_MEDIAN {
+		for range trees {
+		}
+		return nil, errors.New("unknown consensus branch length method")
+	}
+
 	nbtrees := 0
 	edgeindex := NewEdgeIndex(128, .75)
 	var nodeindex *nodeIndex
@@ -329,15 +383,30 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 		}
 		// We add the edge into the index
 		for _, e := range curtree.Tree.Edges() {
-			edgeindex.AddEdgeCount(e)
+			if brlen == CONSENSUS_LENGTH_MEDIAN {
+				edgeindex.AddEdgeCountLengths(e)
+			} else {
+				edgeindex.AddEdgeCount(e)
+			}
 		}
 		nbtrees++
 	}
 
 	// We take the bipartitions that are present in more than cutoff trees and less
 	// than or equal the number of trees
+	minCount = int(cutoff * float64(nbtrees))
+	if method == CONSENSUS_GREEDY {
+		// At least cutoff trees
+		minCount = int(math.Ceil(cutoff*float64(nbtrees))) - 1
+	}
+	bipartitions := edgeindex.Edges(minCount, nbtrees)
+	if method == CONSENSUS_GREEDY {
+		bipartitions = greedyCompatibleBipartitions(bipartitions)
+	}
+
 	// And we add it to the startree
-	for _, bs := range edgeindex.Edges(int(cutoff*float64(nbtrees)), nbtrees) {
+	for _, bs := range bipartitions {
+		length := consensusLength(bs.val, brlen)
 		names := make([]string, 0, bs.key.Bitset().Count())
 		for _, n := range alltips {
 			if idx, err := startree.TipIndex(n); err != nil {
@@ -355,7 +424,7 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 				if t, ok := nodeindex.GetNode(names[0]); !ok || !t.Tip() {
 					return nil, fmt.Errorf("this taxon name does not exist in the consensus: %s", names[0])
 				} else {
-					t.br[0].SetLength(float64(bs.val.Len) / float64(bs.val.Count))
+					t.br[0].SetLength(length)
 				}
 			} else {
 				return nil, errors.New("this bipartition has a side with no taxa")
@@ -376,8 +445,7 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 			}
 			// We add the bipartition with a support value corresponding to the percentage of
 			// trees in which it appears
-			// TODO: Average branch length : Need to change the data structure
-			startree.AddBipartition(node, edges, float64(bs.val.Len)/float64(bs.val.Count), float64(bs.val.Count)/float64(nbtrees))
+			startree.AddBipartition(node, edges, length, float64(bs.val.Count)/float64(nbtrees))
 		}
 	}
 
@@ -387,6 +455,62 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 	return startree, nil
 }
 
+// Length of a consensus branch, given the method (mean or median)
+func consensusLength(info *EdgeIndexInfo, brlen int) float64 {
+	if brlen == CONSENSUS_LENGTH_MEDIAN && len(info.Lengths) > 0 {
+		lengths := make([]float64, len(info.Lengths))
+		copy(lengths, info.Lengths)
+		sort.Float64s(lengths)
+		return medianSorted(lengths)
+	}
+	return info.Len / float64(info.Count)
+}
+
+// Sorts the bipartitions by decreasing frequency, and returns the bipartitions
+// that are compatible with all the more frequent bipartitions already selected.
+// Ties are broken using the bitsets, so that the result does not depend on the
+// order of the index.
+func greedyCompatibleBipartitions(bipartitions []*KeyValue) (selected []*KeyValue) {
+	sort.SliceStable(bipartitions, func(i, j int) bool {
+		if bipartitions[i].val.Count != bipartitions[j].val.Count {
+			return bipartitions[i].val.Count > bipartitions[j].val.Count
+		}
+		return bitsetLess(bipartitions[i].key.Bitset(), bipartitions[j].key.Bitset())
+	})
+	selected = make([]*KeyValue, 0, len(bipartitions))
+	for _, bs := range bipartitions {
+		compatible := true
+		for _, s := range selected {
+			if !bipartitionsCompatible(bs.key.Bitset(), s.key.Bitset()) {
+				compatible = false
+				break
+			}
+		}
+		if compatible {
+			selected = append(selected, bs)
+		}
+	}
+	return
+}
+
+// Two bipartitions are compatible if at least one of the four
+// intersections of their sides is empty
+func bipartitionsCompatible(b1, b2 *bitset.BitSet) bool {
+	inter := b1.IntersectionCardinality(b2)
+	c1, c2, n := b1.Count(), b2.Count(), b1.Len()
+	return inter == 0 || inter == c1 || inter == c2 || c1+c2-inter == n
+}
+
+// Orders bitsets by their lowest differing bit
+func bitsetLess(b1, b2 *bitset.BitSet) bool {
+	for i := uint(0); i < b1.Len(); i++ {
+		if t1, t2 := b1.Test(i), b2.Test(i); t1 != t2 {
+			return t1
+		}
+	}
+	return false
+}
+
 // CollapseClade takes a list of tips, and collapses the last common ancestor of these tips
 // i.e. it removes all the descendants of that node and considers it as a tip.
 // It returns the collapsed clade as a new Tree
@@ -0,0 +1,290 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute is a key/value pair stored in a comment of a node or of an edge,
+// either in the BEAST format [&key=value,key2=value2] or in the NHX format
+// [&&NHX:key=value:key2=value2].
+//
+// Attributes are kept in the comments as given by the Newick and Nexus parsers,
+// so that they are written back unchanged on output. Keys are case insensitive.
+type Attribute struct {
+	Key   string
+	Value string // Value without enclosing quotes
+}
+
+// Float returns the value of the attribute as a float
+func (a Attribute) Float() (float64, error) {
+	return strconv.ParseFloat(a.Value, 64)
+}
+
+// Int returns the value of the attribute as an int
+func (a Attribute) Int() (int, error) {
+	return strconv.Atoi(a.Value)
+}
+
+// Date returns the value of the attribute as a decimal date,
+// given either as yyyy.xxx or as yyyy-mm-dd
+func (a Attribute) Date() (float64, error) {
+	return ParseDate(a.Value)
+}
+
+// Values returns the elements of a set value {v1,v2,...},
+// or the value itself if it is not a set
+func (a Attribute) Values() (values []string) {
+	if !strings.HasPrefix(a.Value, "{") || !strings.HasSuffix(a.Value, "}") {
+		return []string{a.Value}
+	}
+	for _, v := range splitComment(a.Value[1:len(a.Value)-1], ',') {
+		values = append(values, strings.Trim(strings.TrimSpace(v), "\"'"))
+	}
+	return
+}
+
+// Floats returns the elements of a set value {v1,v2,...} as floats
+// (e.g. a 95% HPD interval)
+func (a Attribute) Floats() (values []float64, err error) {
+	var f float64
+	for _, v := range a.Values() {
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, err
+		}
+		values = append(values, f)
+	}
+	return
+}
+
+// Returns all the attributes of the node comments, in order
+func (n *Node) Attributes() []Attribute {
+	return commentAttributes(n.comment)
+}
+
+// Returns the attribute of the node having the given key, if any
+func (n *Node) Attribute(key string) (Attribute, bool) {
+	return findAttribute(n.comment, key)
+}
+
+// Returns the value of the attribute of the node as a float.
+// Returns an error if the attribute does not exist or is not a number.
+func (n *Node) FloatAttribute(key string) (float64, error) {
+	return floatAttribute(n.comment, key)
+}
+
+// Sets the value of the attribute of the node, given as a string.
+// If the attribute already exists, its value is replaced in
+// place, otherwise it is added to the first attribute comment of the
+// node, or to a new one.
+func (n *Node) SetAttribute(key, value string) {
+	setAttribute(&n.comment, key, value, true)
+}
+
+// Sets the value of the attribute of the node, given as a float
+func (n *Node) SetFloatAttribute(key string, value float64) {
+	setAttribute(&n.comment, key, strconv.FormatFloat(value, 'f', -1, 64), false)
+}
+
+// Removes the attribute from the node comments, if it exists.
+// A comment with no remaining attribute is removed.
+func (n *Node) DeleteAttribute(key string) {
+	deleteAttribute(&n.comment, key)
+}
+
+// Returns all the attributes of the edge comments, in order
+func (e *Edge) Attributes() []Attribute {
+	return commentAttributes(e.comment)
+}
+
+// Returns the attribute of the edge having the given key, if any
+func (e *Edge) Attribute(key string) (Attribute, bool) {
+	return findAttribute(e.comment, key)
+}
+
+// Returns the value of the attribute of the edge as a float.
+// Returns an error if the attribute does not exist or is not a number.
+func (e *Edge) FloatAttribute(key string) (float64, error) {
+	return floatAttribute(e.comment, key)
+}
+
+// Sets the value of the attribute of the edge, given as a string.
+// If the attribute already exists, its value is replaced in
+// place, otherwise it is added to the first attribute comment of the
+// edge, or to a new one.
+func (e *Edge) SetAttribute(key, value string) {
+	setAttribute(&e.comment, key, value, true)
+}
+
+// Sets the value of the attribute of the edge, given as a float
+func (e *Edge) SetFloatAttribute(key string, value float64) {
+	setAttribute(&e.comment, key, strconv.FormatFloat(value, 'f', -1, 64), false)
+}
+
+// Removes the attribute from the edge comments, if it exists.
+// A comment with no remaining attribute is removed.
+func (e *Edge) DeleteAttribute(key string) {
+	deleteAttribute(&e.comment, key)
+}
+
+// Splits an attribute comment into its raw fields. nhx is true if
+// the comment is in the NHX format, and ok is false if the
+// comment does not contain attributes.
+func attributeFields(comment string) (fields []string, nhx, ok bool) {
+	switch {
+	case strings.HasPrefix(comment, "&&NHX"):
+		body := strings.TrimPrefix(strings.TrimPrefix(comment, "&&NHX"), ":")
+		if body != "" {
+			fields = strings.Split(body, ":")
+		}
+		return fields, true, true
+	case strings.HasPrefix(comment, "&"):
+		body := strings.TrimPrefix(comment, "&")
+		if body != "" {
+			fields = splitComment(body, ',')
+		}
+		return fields, false, true
+	}
+	return nil, false, false
+}
+
+// Joins raw fields into an attribute comment
+func joinAttributeFields(fields []string, nhx bool) string {
+	if nhx {
+		return "&&NHX:" + strings.Join(fields, ":")
+	}
+	return "&" + strings.Join(fields, ",")
+}
+
+// Parses a raw field key=value. ok is false if the field has no value.
+func parseAttributeField(field string) (a Attribute, ok bool) {
+	k, v, found := strings.Cut(field, "=")
+	k = strings.TrimSpace(k)
+	if !found || k == "" {
+		return a, false
+	}
+	return Attribute{Key: k, Value: strings.Trim(strings.TrimSpace(v), "\"'")}, true
+}
+
+func commentAttributes(comments []string) (attrs []Attribute) {
+	for _, c := range comments {
+		fields, _, ok := attributeFields(c)
+		if !ok {
+			continue
+		}
+		for _, f := range fields {
+			if a, ok := parseAttributeField(f); ok {
+				attrs = append(attrs, a)
+			}
+		}
+	}
+	return
+}
+
+func findAttribute(comments []string, key string) (Attribute, bool) {
+	for _, a := range commentAttributes(comments) {
+		if strings.EqualFold(a.Key, key) {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+func floatAttribute(comments []string, key string) (v float64, err error) {
+	a, ok := findAttribute(comments, key)
+	if !ok {
+		return 0, fmt.Errorf("attribute %s not found", key)
+	}
+	if v, err = a.Float(); err != nil {
+		err = errors.New("attribute " + key + " is not a number: " + a.Value)
+	}
+	return
+}
+
+// Sets the value of the attribute. If quote is true, the value is
+// enclosed in double quotes in the BEAST format.
+func setAttribute(comments *[]string, key, value string, quote bool) {
+	first := -1
+	for i, c := range *comments {
+		fields, nhx, ok := attributeFields(c)
+		if !ok {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		for j, f := range fields {
+			if a, ok := parseAttributeField(f); ok && strings.EqualFold(a.Key, key) {
+				fields[j] = a.Key + "=" + formatAttributeValue(value, nhx, quote)
+				(*comments)[i] = joinAttributeFields(fields, nhx)
+				return
+			}
+		}
+	}
+	if first < 0 {
+		*comments = append(*comments, "&"+key+"="+formatAttributeValue(value, false, quote))
+		return
+	}
+	fields, nhx, _ := attributeFields((*comments)[first])
+	fields = append(fields, key+"="+formatAttributeValue(value, nhx, quote))
+	(*comments)[first] = joinAttributeFields(fields, nhx)
+}
+
+func formatAttributeValue(value string, nhx, quote bool) string {
+	if quote && !nhx {
+		return "\"" + value + "\""
+	}
+	return value
+}
+
+func deleteAttribute(comments *[]string, key string) {
+	out := (*comments)[:0]
+	for _, c := range *comments {
+		fields, nhx, ok := attributeFields(c)
+		if !ok {
+			out = append(out, c)
+			continue
+		}
+		kept := fields[:0]
+		for _, f := range fields {
+			if a, ok := parseAttributeField(f); !ok || !strings.EqualFold(a.Key, key) {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) == len(fields) {
+			out = append(out, c)
+		} else if len(kept) > 0 {
+			out = append(out, joinAttributeFields(kept, nhx))
+		}
+	}
+	*comments = out
+}
+
+// Splits a comment on separators that are not enclosed
+// in braces or in double quotes
+func splitComment(comment string, sep rune) (fields []string) {
+	depth, start := 0, 0
+	quoted := false
+	for i, c := range comment {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case '{':
+			if !quoted {
+				depth++
+			}
+		case '}':
+			if !quoted {
+				depth--
+			}
+		case sep:
+			if depth == 0 && !quoted {
+				fields = append(fields, comment[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, comment[start:])
+}
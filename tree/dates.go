@@ -2,7 +2,6 @@ package tree
 
 import (
 	"fmt"
-	"regexp"
 	"sort"
 	"strconv"
 	"time"
@@ -50,6 +49,49 @@ func (t *Tree) NodeDates(onlytips bool) (ndates []float64, err error) {
 	return
 }
 
+// RootDate returns the date of the root of a time tree (branch lengths
+// in years): the date of the root if it has a [&date=] comment, otherwise
+// the mean over all dated nodes of their date minus their distance to the root.
+// Returns an error if no node has a date, or if a date is malformed.
+func (t *Tree) RootDate() (rootdate float64, err error) {
+	var date float64
+
+	if _, ok := t.Root().Attribute("date"); ok {
+		return t.Root().date()
+	}
+
+	dists := make(map[*Node]float64)
+	ndates := 0
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		keep = true
+		if prev != nil {
+			length := e.Length()
+			if length == NIL_LENGTH {
+				length = 0.0
+			}
+			dists[cur] = dists[prev] + length
+		}
+		if _, ok := cur.Attribute("date"); ok {
+			if date, err = cur.date(); err != nil {
+				keep = false
+				return
+			}
+			rootdate += date - dists[cur]
+			ndates++
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	if ndates == 0 {
+		err = fmt.Errorf("no node with a date found")
+		return
+	}
+	rootdate /= float64(ndates)
+	return
+}
+
 // LTTData describes a Lineage to Time data point
 func (t *Tree) LTT() (lttdata []LTTData) {
 	var lttdatadup []LTTData
@@ -206,7 +248,7 @@ func cutTreeMinDateRecur(cur, prev *Node, e *Edge, mindate float64, dates []floa
 		prev.delNeighbor(cur)
 		cur.delNeighbor(prev)
 		tmpedge = tmptree.ConnectNodes(tmpnode, cur)
-		tmpnode.AddComment(fmt.Sprintf("&date=\"%f\"", mindate))
+		tmpnode.SetAttribute("date", fmt.Sprintf("%f", mindate))
 		tmpedge.SetLength(e.Length() * (dates[cur.Id()] - mindate) / (dates[cur.Id()] - dates[prev.Id()]))
 		//tmptree.ReinitIndexes()
 		forest = append(forest, tmptree)
@@ -229,33 +271,34 @@ func cutTreeMinDateRecur(cur, prev *Node, e *Edge, mindate float64, dates []floa
 
 // Parses the date in the field "&date=" from the comments in the newick format
 func (n *Node) date() (date float64, err error) {
-	var pattern *regexp.Regexp
-	var matches []string
+	a, ok := n.Attribute("date")
+	if !ok {
+		err = fmt.Errorf("no date found: %s", n.CommentsString())
+	} else if date, err = a.Date(); err != nil {
+		err = fmt.Errorf("one of the node date year is malformed: %s", n.CommentsString())
+	}
+	return
+}
+
+// Parses a date given either in the decimal form yyyy.xxx,
+// or in the form yyyy-mm-dd. In the latter case, the date is
+// converted into the decimal form.
+func ParseDate(s string) (date float64, err error) {
 	var timeformat string = "2006-01-02"
 	var fdate time.Time
 	var year, nextyear time.Time
 
-	pattern = regexp.MustCompile(`(?i)&date=\"{0,1}(.+?)([,"]|$)`)
-
-	for _, c := range n.Comments() {
-		matches = pattern.FindStringSubmatch(c)
-		if len(matches) < 2 {
-			err = fmt.Errorf("no date found: %s", c)
-		} else if date, err = strconv.ParseFloat(matches[1], 64); err != nil {
-			// If the parsing of the date on the decimal form yyyy.xxx does not work
-			// We try to parse date on the form yyyy-mm-dd
-			if fdate, err = time.Parse(timeformat, matches[1]); err != nil {
-				err = fmt.Errorf("one of the node date year is malformed: %s", c)
-			} else {
-				year = time.Date(fdate.Year(), 1, 1, 0, 0, 0, 0, fdate.Location())
-				nextyear = time.Date(fdate.Year()+1, 1, 1, 0, 0, 0, 0, fdate.Location())
-				duration := fdate.Sub(year)
-				total := nextyear.Sub(year)
-				date = float64(fdate.Year()) + (duration.Hours() / total.Hours())
-			}
+	if date, err = strconv.ParseFloat(s, 64); err != nil {
+		// If the parsing of the date on the decimal form yyyy.xxx does not work
+		// We try to parse date on the form yyyy-mm-dd
+		if fdate, err = time.Parse(timeformat, s); err != nil {
+			err = fmt.Errorf("malformed date: %s", s)
 		} else {
-			err = nil
-			break
+			year = time.Date(fdate.Year(), 1, 1, 0, 0, 0, 0, fdate.Location())
+			nextyear = time.Date(fdate.Year()+1, 1, 1, 0, 0, 0, 0, fdate.Location())
+			duration := fdate.Sub(year)
+			total := nextyear.Sub(year)
+			date = float64(fdate.Year()) + (duration.Hours() / total.Hours())
 		}
 	}
 	return
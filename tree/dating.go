@@ -0,0 +1,338 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Result of a least-squares dating
+type DatingResult struct {
+	Rate      float64 // Estimated substitution rate (per unit of time)
+	RootDate  float64 // Estimated date of the root
+	Objective float64 // Weighted least-squares value
+}
+
+// Node of the structure used by the least-squares dating.
+// Nodes are stored in pre-order, index 0 being the root.
+type lsdNode struct {
+	parent int
+	b, w   float64 // length and weight of the branch to the parent
+	dated  bool    // true if the node has a fixed date
+	date   float64 // fixed date of the node
+	node   *Node   // corresponding node of the tree (nil for a root placed on an edge)
+}
+
+// Solution of the least-squares problem: node dates are c/rate + e.
+// The other slices are reused between successive resolutions.
+type lsdSolution struct {
+	c, e      []float64
+	rate      float64
+	objective float64
+
+	ac, aw, beta []float64 // affine expression of each scaled date
+	d, nc, nw    []float64 // sums over the children of each node
+}
+
+// Dates the tree with a strict molecular clock, by least squares, as LSD
+// (To et al. 2016) does without temporal constraints.
+//
+// Branch lengths are considered as numbers of substitutions per site, and
+// the rate and the node dates minimizing sum_i w_i(b_i - rate*(t_i - t_a(i)))^2 are
+// estimated, where b_i is the length of branch i, t_i the date of its child node
+// and t_a(i) the date of its parent node.
+//
+//   - dates: Dates of the tips. If nil, tip dates are read from [&date=...] comments.
+//     Tips without date are dated as internal nodes, but at least two different
+//     dates are required.
+//   - rootsearch: If true, the root position minimizing the objective is searched on
+//     all branches of the tree, and the tree is rerooted there. Otherwise the tree is
+//     considered rooted at its current root.
+//   - seqlen: If > 0, the variance of each branch length b is taken as (b+1/seqlen)/seqlen
+//     and branches are weighted by the inverse of their variance. Otherwise
+//     all weights are 1 (ordinary least squares).
+//
+// The estimation does not enforce temporal constraints: dates of internal nodes are
+// then set to be not more recent than the dates of their children. At the end, branch
+// lengths are expressed in units of time, and all nodes are annotated with their
+// date, in the [&date=...] comment format.
+func (t *Tree) LeastSquaresDating(dates map[string]float64, rootsearch bool, seqlen float64) (res DatingResult, err error) {
+	var nodes []lsdNode
+	var sol lsdSolution
+
+	if dates == nil {
+		dates = make(map[string]float64)
+		for _, tip := range t.Tips() {
+			if d, err2 := tip.date(); err2 == nil {
+				dates[tip.Name()] = d
+			}
+		}
+	}
+	if err = checkDatingDates(t, dates); err != nil {
+		return
+	}
+
+	if rootsearch {
+		if err = t.rerootLeastSquares(dates, seqlen); err != nil {
+			return
+		}
+	}
+
+	nodes = lsdNodesFromNode(t.Root(), dates, seqlen)
+	if err = sol.solve(nodes); err != nil {
+		return
+	}
+	res = DatingResult{Rate: sol.rate, RootDate: sol.c[0]/sol.rate + sol.e[0], Objective: sol.objective}
+	applyLeastSquaresDating(nodes, sol)
+	return
+}
+
+// At least two tips must have different dates
+func checkDatingDates(t *Tree, dates map[string]float64) error {
+	first := true
+	var d0 float64
+	for _, tip := range t.Tips() {
+		if d, ok := dates[tip.Name()]; ok {
+			if first {
+				d0, first = d, false
+			} else if d != d0 {
+				return nil
+			}
+		}
+	}
+	return errors.New("at least two tips with different dates are required for dating")
+}
+
+func lsdWeight(b, seqlen float64) float64 {
+	if seqlen <= 0 {
+		return 1.0
+	}
+	return seqlen / (b + 1.0/seqlen)
+}
+
+// Builds the dating structure from the tree rooted at the given node
+func lsdNodesFromNode(root *Node, dates map[string]float64, seqlen float64) (nodes []lsdNode) {
+	nodes = make([]lsdNode, 0)
+	lsdNodesRecur(root, nil, nil, -1, dates, seqlen, &nodes)
+	return
+}
+
+// Builds the dating structure from the tree rooted on the given edge.
+// Returns the index of the right node of the edge, the left one being
+// at index 1. The position of the root is then set with setLsdRootPosition.
+func lsdNodesFromEdge(e *Edge, dates map[string]float64, seqlen float64) (nodes []lsdNode, right int) {
+	nodes = make([]lsdNode, 1)
+	nodes[0] = lsdNode{parent: -1}
+	lsdNodesRecur(e.Left(), e.Right(), e, 0, dates, seqlen, &nodes)
+	right = len(nodes)
+	lsdNodesRecur(e.Right(), e.Left(), e, 0, dates, seqlen, &nodes)
+	return
+}
+
+// Places the root at distance x*length from the left node of the edge
+func setLsdRootPosition(nodes []lsdNode, right int, length, x, seqlen float64) {
+	nodes[1].b = x * length
+	nodes[1].w = lsdWeight(nodes[1].b, seqlen)
+	nodes[right].b = (1 - x) * length
+	nodes[right].w = lsdWeight(nodes[right].b, seqlen)
+}
+
+func lsdNodesRecur(cur, prev *Node, e *Edge, parent int, dates map[string]float64, seqlen float64, nodes *[]lsdNode) {
+	idx := len(*nodes)
+	n := lsdNode{parent: parent, node: cur}
+	if e != nil {
+		n.b = math.Max(e.Length(), 0)
+		n.w = lsdWeight(n.b, seqlen)
+	}
+	if cur.Tip() {
+		n.date, n.dated = dates[cur.Name()]
+	}
+	*nodes = append(*nodes, n)
+	for i, child := range cur.neigh {
+		if child != prev {
+			lsdNodesRecur(child, cur, cur.br[i], idx, dates, seqlen, nodes)
+		}
+	}
+}
+
+// Solves the least-squares dating problem.
+//
+// Scaled dates (rate*date) are affine functions of the rate. From the tips,
+// each scaled date is expressed as an affine function of the scaled date of
+// its parent, which gives the root scaled date, and then all scaled dates.
+// The objective is then a quadratic function of the rate.
+func (sol *lsdSolution) solve(nodes []lsdNode) (err error) {
+	n := len(nodes)
+	if len(sol.c) != n {
+		sol.c, sol.e = make([]float64, n), make([]float64, n)
+		sol.ac, sol.aw, sol.beta = make([]float64, n), make([]float64, n), make([]float64, n)
+		sol.d, sol.nc, sol.nw = make([]float64, n), make([]float64, n), make([]float64, n)
+	}
+	sol.rate, sol.objective = 0, 0
+	for k := range nodes {
+		sol.d[k], sol.nc[k], sol.nw[k] = 0, 0, 0
+	}
+
+	// scaled date of node k = ac[k] + rate*aw[k] + beta[k]*scaled date of its parent
+	for k := n - 1; k >= 0; k-- {
+		nd := &nodes[k]
+		if nd.dated {
+			sol.ac[k], sol.aw[k], sol.beta[k] = 0, nd.date, 0
+		} else {
+			d := nd.w + sol.d[k]
+			if d == 0 {
+				return errors.New("cannot date the tree: not enough dated tips")
+			}
+			sol.ac[k], sol.aw[k] = (nd.w*nd.b+sol.nc[k])/d, sol.nw[k]/d
+			sol.beta[k] = nd.w / d
+		}
+		if p := nd.parent; p >= 0 {
+			sol.d[p] += nd.w * (1 - sol.beta[k])
+			sol.nc[p] -= nd.w * (nd.b - sol.ac[k])
+			sol.nw[p] += nd.w * sol.aw[k]
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		sol.c[k], sol.e[k] = sol.ac[k], sol.aw[k]
+		if p := nodes[k].parent; p >= 0 {
+			sol.c[k] += sol.beta[k] * sol.c[p]
+			sol.e[k] += sol.beta[k] * sol.e[p]
+		}
+	}
+
+	// Residual of branch k: r - rate*s
+	var rs, ss float64
+	for k := 1; k < n; k++ {
+		p := nodes[k].parent
+		r := nodes[k].b - (sol.c[k] - sol.c[p])
+		s := sol.e[k] - sol.e[p]
+		rs += nodes[k].w * r * s
+		ss += nodes[k].w * s * s
+	}
+	if ss == 0 {
+		return errors.New("cannot date the tree: not enough dated tips")
+	}
+	sol.rate = rs / ss
+	if sol.rate <= 0 {
+		return fmt.Errorf("cannot date the tree: estimated rate is not positive (%f)", sol.rate)
+	}
+	for k := 1; k < n; k++ {
+		p := nodes[k].parent
+		r := nodes[k].b - (sol.c[k] - sol.c[p]) - sol.rate*(sol.e[k]-sol.e[p])
+		sol.objective += nodes[k].w * r * r
+	}
+	return
+}
+
+// Objective of the dating with the root placed on the edge at x
+func (sol *lsdSolution) edgeObjective(nodes []lsdNode, right int, length, x, seqlen float64) float64 {
+	setLsdRootPosition(nodes, right, length, x, seqlen)
+	if err := sol.solve(nodes); err != nil {
+		return math.Inf(1)
+	}
+	return sol.objective
+}
+
+// Searches the root position minimizing the least-squares objective,
+// and reroots the tree there
+func (t *Tree) rerootLeastSquares(dates map[string]float64, seqlen float64) (err error) {
+	const nbrefined = 10
+	const goldenratio = 0.6180339887498949
+
+	t.UnRoot()
+	edges := t.Edges()
+	objectives := make([]float64, len(edges))
+	sol := &lsdSolution{}
+	// Edges are first compared using a few positions on each of them
+	for i, e := range edges {
+		nodes, right := lsdNodesFromEdge(e, dates, seqlen)
+		objectives[i] = math.Inf(1)
+		for _, x := range []float64{0.1, 0.3, 0.5, 0.7, 0.9} {
+			objectives[i] = math.Min(objectives[i], sol.edgeObjective(nodes, right, math.Max(e.Length(), 0), x, seqlen))
+		}
+	}
+
+	// The position on the best edges is optimized by golden section search
+	order := make([]int, len(edges))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return objectives[order[i]] < objectives[order[j]] })
+	var bestedge *Edge
+	bestx, bestobj := 0.5, math.Inf(1)
+	for _, i := range order[:min(nbrefined, len(order))] {
+		e := edges[i]
+		nodes, right := lsdNodesFromEdge(e, dates, seqlen)
+		l := math.Max(e.Length(), 0)
+		f := func(x float64) float64 { return sol.edgeObjective(nodes, right, l, x, seqlen) }
+		a, b := 0.0, 1.0
+		x1, x2 := b-goldenratio*(b-a), a+goldenratio*(b-a)
+		f1, f2 := f(x1), f(x2)
+		for b-a > 1e-6 {
+			if f1 < f2 {
+				b, x2, f2 = x2, x1, f1
+				x1 = b - goldenratio*(b-a)
+				f1 = f(x1)
+			} else {
+				a, x1, f1 = x1, x2, f2
+				x2 = a + goldenratio*(b-a)
+				f2 = f(x2)
+			}
+		}
+		for _, x := range []float64{(a + b) / 2.0, 0.5} {
+			if fx := f(x); fx < bestobj {
+				bestedge, bestx, bestobj = e, x, fx
+			}
+		}
+	}
+	if bestedge == nil {
+		return errors.New("cannot date the tree: no root position gives a positive rate")
+	}
+
+	// New root on the best edge, at bestx*length from its left node
+	l := math.Max(bestedge.Length(), 0)
+	s := bestedge.Support()
+	node1, node2 := bestedge.Left(), bestedge.Right()
+	newroot := t.NewNode()
+	node1.delNeighbor(node2)
+	node2.delNeighbor(node1)
+	e1 := t.ConnectNodes(newroot, node1)
+	e2 := t.ConnectNodes(newroot, node2)
+	e1.SetLength(bestx * l)
+	e2.SetLength((1 - bestx) * l)
+	e1.SetSupport(s)
+	e2.SetSupport(s)
+	if err = t.Reroot(newroot); err != nil {
+		return
+	}
+	t.ReinitInternalIndexes()
+	return
+}
+
+// Sets node dates and branch lengths (in time units) of the tree
+func applyLeastSquaresDating(nodes []lsdNode, sol lsdSolution) {
+	dates := make([]float64, len(nodes))
+	for k, nd := range nodes {
+		dates[k] = sol.c[k]/sol.rate + sol.e[k]
+		if nd.dated {
+			dates[k] = nd.date
+		}
+	}
+	// Internal nodes are not more recent than their children
+	for k := len(nodes) - 1; k >= 0; k-- {
+		if p := nodes[k].parent; p >= 0 && dates[p] > dates[k] {
+			dates[p] = dates[k]
+		}
+	}
+	for k, nd := range nodes {
+		nd.node.SetAttribute("date", fmt.Sprintf("%f", dates[k]))
+		// Node ids follow the pre-order, as in parsed trees
+		nd.node.SetId(k)
+		if k > 0 {
+			e, _ := nd.node.ParentEdge()
+			e.SetLength(dates[k] - dates[nodes[k].parent])
+		}
+	}
+}
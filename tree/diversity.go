@@ -0,0 +1,236 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Diversity computes phylogenetic diversity metrics of samples
+// (tip sets with abundances) on a tree. A sample is given as a map
+// from tip names to abundances (1 for presence/absence data); tips
+// that are absent from the map, or with an abundance of 0, are not
+// in the sample.
+type Diversity struct {
+	tree    *Tree
+	edges   []*Edge        // edges of the tree, in post-order
+	tips    map[string]int // tip name => index in dists
+	dists   [][]float64    // patristic distances between tips
+	tiplist []*Node        // tips, in the order of dists
+}
+
+// NewDiversity prepares the given tree to compute the diversity of
+// samples. All the branches of the tree must have a length.
+// Faith's PD and UniFrac use the branches up to the root of the tree.
+func NewDiversity(t *Tree) (d *Diversity, err error) {
+	d = &Diversity{tree: t, edges: make([]*Edge, 0)}
+	t.PostOrder(func(cur, prev *Node, e *Edge) (keep bool) {
+		if e != nil {
+			if e.Length() == NIL_LENGTH {
+				err = errors.New("all the branches of the tree must have a length")
+				return false
+			}
+			d.edges = append(d.edges, e)
+		}
+		return true
+	})
+	if err != nil {
+		return
+	}
+	d.dists, d.tiplist = t.ToDistanceMatrix(DISTANCE_METRIC_BRLEN)
+	d.tips = make(map[string]int, len(d.tiplist))
+	for i, tip := range d.tiplist {
+		if _, ok := d.tips[tip.Name()]; ok {
+			err = fmt.Errorf("tip %s is present several times in the tree", tip.Name())
+			return
+		}
+		d.tips[tip.Name()] = i
+	}
+	return
+}
+
+// PD returns the Faith's phylogenetic diversity of the sample: the sum of
+// the lengths of the branches connecting its tips to the root.
+func (d *Diversity) PD(sample map[string]float64) (pd float64, err error) {
+	var ab []float64
+	if ab, _, err = d.edgeAbundances(sample); err != nil {
+		return
+	}
+	for i, e := range d.edges {
+		if ab[i] > 0 {
+			pd += e.Length()
+		}
+	}
+	return
+}
+
+// MPD returns the mean pairwise distance between the tips of the sample.
+// If weighted is true, each pair of tips is weighted by the product of
+// their abundances. Returns NaN if there are less than 2 tips in the sample.
+func (d *Diversity) MPD(sample map[string]float64, weighted bool) (mpd float64, err error) {
+	var idx []int
+	var w []float64
+	if idx, w, err = d.sampleTips(sample, weighted); err != nil {
+		return
+	}
+	sum, sumw := 0.0, 0.0
+	for i := range idx {
+		for j := i + 1; j < len(idx); j++ {
+			sum += w[i] * w[j] * d.dists[idx[i]][idx[j]]
+			sumw += w[i] * w[j]
+		}
+	}
+	if sumw == 0 {
+		return math.NaN(), nil
+	}
+	return sum / sumw, nil
+}
+
+// MNTD returns the mean distance between each tip of the sample and its
+// nearest other tip of the sample. If weighted is true, each tip is weighted
+// by its abundance. Returns NaN if there are less than 2 tips in the sample.
+func (d *Diversity) MNTD(sample map[string]float64, weighted bool) (mntd float64, err error) {
+	var idx []int
+	var w []float64
+	if idx, w, err = d.sampleTips(sample, weighted); err != nil {
+		return
+	}
+	if len(idx) < 2 {
+		return math.NaN(), nil
+	}
+	sum, sumw := 0.0, 0.0
+	for i := range idx {
+		min := math.Inf(1)
+		for j := range idx {
+			if i != j {
+				min = math.Min(min, d.dists[idx[i]][idx[j]])
+			}
+		}
+		sum += w[i] * min
+		sumw += w[i]
+	}
+	return sum / sumw, nil
+}
+
+// UniFrac returns the UniFrac distance between the two samples.
+//   - unweighted: fraction of the length of the branches leading to tips of
+//     either sample that lead to tips of only one of them;
+//   - weighted: sum over the branches of their length times the absolute
+//     difference of the proportions of each sample below them (not normalized).
+//
+// Returns NaN if both samples are empty (unweighted) or if one of them is
+// empty (weighted).
+func (d *Diversity) UniFrac(sample1, sample2 map[string]float64, weighted bool) (dist float64, err error) {
+	var ab1, ab2 []float64
+	var tot1, tot2 float64
+	if ab1, tot1, err = d.edgeAbundances(sample1); err != nil {
+		return
+	}
+	if ab2, tot2, err = d.edgeAbundances(sample2); err != nil {
+		return
+	}
+	return d.unifrac(ab1, ab2, tot1, tot2, weighted), nil
+}
+
+// UniFracMatrix returns the UniFrac distances between all pairs of samples
+// (see UniFrac).
+func (d *Diversity) UniFracMatrix(samples []map[string]float64, weighted bool) (mat [][]float64, err error) {
+	abs := make([][]float64, len(samples))
+	tots := make([]float64, len(samples))
+	for i, s := range samples {
+		if abs[i], tots[i], err = d.edgeAbundances(s); err != nil {
+			return
+		}
+	}
+	mat = make([][]float64, len(samples))
+	for i := range samples {
+		mat[i] = make([]float64, len(samples))
+	}
+	for i := range samples {
+		for j := i; j < len(samples); j++ {
+			mat[i][j] = d.unifrac(abs[i], abs[j], tots[i], tots[j], weighted)
+			mat[j][i] = mat[i][j]
+		}
+	}
+	return
+}
+
+func (d *Diversity) unifrac(ab1, ab2 []float64, tot1, tot2 float64, weighted bool) float64 {
+	if weighted {
+		if tot1 == 0 || tot2 == 0 {
+			return math.NaN()
+		}
+		sum := 0.0
+		for i, e := range d.edges {
+			sum += e.Length() * math.Abs(ab1[i]/tot1-ab2[i]/tot2)
+		}
+		return sum
+	}
+	unique, total := 0.0, 0.0
+	for i, e := range d.edges {
+		if ab1[i] > 0 || ab2[i] > 0 {
+			total += e.Length()
+			if ab1[i] == 0 || ab2[i] == 0 {
+				unique += e.Length()
+			}
+		}
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	return unique / total
+}
+
+// Abundance of the sample below each edge (same order as d.edges),
+// and total abundance of the sample
+func (d *Diversity) edgeAbundances(sample map[string]float64) (ab []float64, total float64, err error) {
+	if err = d.checkSample(sample); err != nil {
+		return
+	}
+	ab = make([]float64, 0, len(d.edges))
+	below := make(map[*Node]float64)
+	d.tree.PostOrder(func(cur, prev *Node, e *Edge) (keep bool) {
+		if cur.Tip() {
+			below[cur] = sample[cur.Name()]
+		}
+		if e != nil {
+			below[prev] += below[cur]
+			ab = append(ab, below[cur])
+		}
+		return true
+	})
+	for _, a := range sample {
+		total += a
+	}
+	return
+}
+
+// Indices (in d.dists) of the tips present in the sample, and their weights
+func (d *Diversity) sampleTips(sample map[string]float64, weighted bool) (idx []int, w []float64, err error) {
+	if err = d.checkSample(sample); err != nil {
+		return
+	}
+	for i, tip := range d.tiplist {
+		if a := sample[tip.Name()]; a > 0 {
+			idx = append(idx, i)
+			if weighted {
+				w = append(w, a)
+			} else {
+				w = append(w, 1)
+			}
+		}
+	}
+	return
+}
+
+func (d *Diversity) checkSample(sample map[string]float64) error {
+	for name, a := range sample {
+		if _, ok := d.tips[name]; !ok {
+			return fmt.Errorf("tip %s is not present in the tree", name)
+		}
+		if a < 0 || math.IsNaN(a) {
+			return fmt.Errorf("abundance of tip %s must be positive: %f", name, a)
+		}
+	}
+	return nil
+}
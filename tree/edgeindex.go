@@ -18,8 +18,9 @@ type EdgeIndex struct {
 
 // Value stored in the HashMap
 type EdgeIndexInfo struct {
-	Count int     // Number of occurences of the branch
-	Len   float64 // Mean length of branches occurences
+	Count   int       // Number of occurences of the branch
+	Len     float64   // Mean length of branches occurences
+	Lengths []float64 // All lengths of branch occurences (only filled by AddEdgeCountLengths)
 }
 
 // KeyValue Pair stored in the HashMap
@@ -58,7 +59,7 @@ func (em *EdgeIndex) AddEdgeCount(e *Edge) error {
 	}
 	v, ok := em.hash.Value(e)
 	if !ok {
-		em.hash.PutValue(e, &EdgeIndexInfo{1, e.Length()})
+		em.hash.PutValue(e, &EdgeIndexInfo{Count: 1, Len: e.Length()})
 	} else {
 		v.(*EdgeIndexInfo).Count++
 		v.(*EdgeIndexInfo).Len += e.Length()
@@ -66,6 +67,18 @@ func (em *EdgeIndex) AddEdgeCount(e *Edge) error {
 	return nil
 }
 
+// Same as AddEdgeCount, but also keeps all the
+// lengths of the edge occurences, in the Lengths field
+// of the value (to compute median branch lengths for example)
+func (em *EdgeIndex) AddEdgeCountLengths(e *Edge) error {
+	if err := em.AddEdgeCount(e); err != nil {
+		return err
+	}
+	v, _ := em.hash.Value(e)
+	v.(*EdgeIndexInfo).Lengths = append(v.(*EdgeIndexInfo).Lengths, e.Length())
+	return nil
+}
+
 // Adds the edge in the map, with given value.
 // If the edge already exists in the index
 // The old value is erased
@@ -74,7 +87,7 @@ func (em *EdgeIndex) PutEdgeValue(e *Edge, count int, length float64) error {
 		io.LogError(errors.New("Bitset not initialized"))
 		return errors.New("Bitset not initialized")
 	}
-	em.hash.PutValue(e, &EdgeIndexInfo{count, length})
+	em.hash.PutValue(e, &EdgeIndexInfo{Count: count, Len: length})
 	return nil
 }
 
@@ -0,0 +1,257 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Methods to set node heights of the MCC tree
+const (
+	MCC_HEIGHTS_KEEP   = iota // Keeps node heights of the selected tree
+	MCC_HEIGHTS_MEAN          // Sets node heights to the mean height of the clades
+	MCC_HEIGHTS_MEDIAN        // Sets node heights to the median height of the clades
+)
+
+// Information about a clade, collected over all the sampled trees
+type cladeInfo struct {
+	count   int                  // Number of trees having this clade
+	heights []float64            // Heights of the clade in these trees
+	attrs   map[string][]float64 // Values of the numeric comments of the clade
+}
+
+// Selects the maximum clade credibility (MCC) tree from a sample of rooted trees
+// (e.g. a BEAST posterior sample), as TreeAnnotator does.
+//
+//   - burnin: Fraction of the first trees to discard (>=0 and <1)
+//   - heights: MCC_HEIGHTS_KEEP, MCC_HEIGHTS_MEAN, or MCC_HEIGHTS_MEDIAN
+//
+// The MCC tree is the sampled tree that maximizes the product of the
+// posterior probabilities of its clades. Its nodes are then annotated with
+// a BEAST-style comment [&posterior=...,height=...,height_median=...,height_95%_HPD={...}],
+// as well as with the mean, median and 95% HPD of each numeric comment
+// ([&key=value,...]) of the same clades in the sampled trees.
+//
+// Node heights are computed as distances to the most recent tip.
+func MaxCladeCredibility(trees <-chan Trees, burnin float64, heights int) (mcc *Tree, err error) {
+	var sample []*Tree
+	var clades map[string]*cladeInfo
+	var score, bestscore float64
+
+	if burnin < 0 || burnin >= 1 {
+		for range trees {
+		}
+		return nil, errors.New("burnin must be >=0 and <1")
+	}
+	if heights != MCC_HEIGHTS_KEEP && heights != MCC_HEIGHTS_MEAN && heights != MCC_HEIGHTS_MEDIAN {
+		for range trees {
+		}
+		return nil, errors.New("unknown mcc height method")
+	}
+
+	for t := range trees {
+		if t.Err != nil {
+			/* We empty the channel if needed */
+			for range trees {
+			}
+			return nil, t.Err
+		}
+		sample = append(sample, t.Tree)
+	}
+	sample = sample[int(burnin*float64(len(sample))):]
+	if len(sample) == 0 {
+		return nil, errors.New("no tree remaining after burnin")
+	}
+
+	clades = make(map[string]*cladeInfo)
+	for i, t := range sample {
+		if err = t.ReinitIndexes(); err != nil {
+			return
+		}
+		if !t.Rooted() {
+			return nil, fmt.Errorf("tree %d is not rooted", i)
+		}
+		if i > 0 {
+			if err = sample[0].CompareTipIndexes(t); err != nil {
+				return
+			}
+		}
+		addTreeClades(t, clades)
+	}
+
+	// Tree with the highest product of clade credibilities
+	bestscore = math.Inf(-1)
+	for _, t := range sample {
+		score = 0.0
+		for _, n := range t.Nodes() {
+			if !n.Tip() {
+				score += math.Log(float64(clades[cladeKey(n)].count) / float64(len(sample)))
+			}
+		}
+		if score > bestscore {
+			bestscore = score
+			mcc = t
+		}
+	}
+
+	annotateMCC(mcc, clades, len(sample), heights)
+	return
+}
+
+// Key of the clade below the given node: the bitset of its parent
+// edge, or the empty string for the root
+func cladeKey(n *Node) string {
+	if e, err := n.ParentEdge(); err == nil {
+		return bitsetKey(e.Bitset())
+	}
+	return ""
+}
+
+// Node heights, i.e. distance to the most recent tip
+func nodeHeights(t *Tree) (heights map[*Node]float64) {
+	depths := make(map[*Node]float64)
+	maxdepth := 0.0
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) bool {
+		depths[cur] = 0
+		if prev != nil {
+			depths[cur] = depths[prev] + math.Max(e.Length(), 0)
+		}
+		maxdepth = math.Max(maxdepth, depths[cur])
+		return true
+	})
+	heights = make(map[*Node]float64, len(depths))
+	for n, d := range depths {
+		heights[n] = maxdepth - d
+	}
+	return
+}
+
+// Adds the clades of the tree to the clade index, with their
+// heights and numeric comments
+func addTreeClades(t *Tree, clades map[string]*cladeInfo) {
+	heights := nodeHeights(t)
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) bool {
+		key := cladeKey(cur)
+		info, ok := clades[key]
+		if !ok {
+			info = &cladeInfo{attrs: make(map[string][]float64)}
+			clades[key] = info
+		}
+		info.count++
+		info.heights = append(info.heights, heights[cur])
+		for k, v := range numericComments(cur, e) {
+			info.attrs[k] = append(info.attrs[k], v)
+		}
+		return true
+	})
+}
+
+// Returns the numeric attributes of the node and of its parent edge.
+// Attributes computed by MaxCladeCredibility (posterior, height, ...)
+// are ignored.
+func numericComments(n *Node, e *Edge) (values map[string]float64) {
+	values = make(map[string]float64)
+	attrs := n.Attributes()
+	if e != nil {
+		attrs = append(attrs, e.Attributes()...)
+	}
+	for _, a := range attrs {
+		if a.Key == "posterior" || strings.HasPrefix(a.Key, "height") {
+			continue
+		}
+		if f, err := a.Float(); err == nil {
+			values[a.Key] = f
+		}
+	}
+	return
+}
+
+// Annotates the nodes of the mcc tree, and sets its node heights
+func annotateMCC(mcc *Tree, clades map[string]*cladeInfo, nbtrees int, heights int) {
+	newheights := make(map[*Node]float64)
+	mcc.PreOrder(func(cur *Node, prev *Node, e *Edge) bool {
+		info := clades[cladeKey(cur)]
+		fields := make([]string, 0)
+		if !cur.Tip() {
+			fields = append(fields, "posterior="+formatAnnotation(float64(info.count)/float64(nbtrees)))
+		}
+		mean, median := summaryAnnotation(&fields, "height", info.heights)
+		switch heights {
+		case MCC_HEIGHTS_MEAN:
+			newheights[cur] = mean
+		case MCC_HEIGHTS_MEDIAN:
+			newheights[cur] = median
+		}
+		keys := make([]string, 0, len(info.attrs))
+		for k := range info.attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			summaryAnnotation(&fields, k, info.attrs[k])
+		}
+		cur.ClearComments()
+		if e != nil {
+			e.ClearComments()
+		}
+		cur.AddComment("&" + strings.Join(fields, ","))
+		return true
+	})
+
+	if heights != MCC_HEIGHTS_KEEP {
+		for _, e := range mcc.Edges() {
+			e.SetLength(math.Max(newheights[e.Left()]-newheights[e.Right()], 0))
+		}
+	}
+}
+
+// Adds mean, median and 95% HPD annotations of the values to the fields,
+// and returns the mean and the median
+func summaryAnnotation(fields *[]string, name string, values []float64) (mean, median float64) {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	for _, v := range sorted {
+		mean += v
+	}
+	mean /= float64(len(sorted))
+	median = medianSorted(sorted)
+	lo, hi := hpdInterval(sorted, 0.95)
+	*fields = append(*fields,
+		name+"="+formatAnnotation(mean),
+		name+"_median="+formatAnnotation(median),
+		name+"_95%_HPD={"+formatAnnotation(lo)+","+formatAnnotation(hi)+"}")
+	return
+}
+
+func formatAnnotation(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Median of sorted values
+func medianSorted(sorted []float64) float64 {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2.0
+	}
+	return sorted[mid]
+}
+
+// Highest posterior density interval of sorted values: the shortest
+// interval containing the given proportion of the values
+func hpdInterval(sorted []float64, proba float64) (lo, hi float64) {
+	n := len(sorted)
+	k := max(int(math.Ceil(proba*float64(n))), 1)
+	lo, hi = sorted[0], sorted[n-1]
+	width := math.Inf(1)
+	for i := 0; i+k-1 < n; i++ {
+		if w := sorted[i+k-1] - sorted[i]; w < width {
+			width = w
+			lo, hi = sorted[i], sorted[i+k-1]
+		}
+	}
+	return
+}
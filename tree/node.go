@@ -6,6 +6,7 @@ import (
 	"fmt"
 	mathrand "math/rand"
 	"strconv"
+	"strings"
 )
 
 // Node structure
@@ -92,6 +93,15 @@ func (n *Node) NameQuoted() string {
 	return n.name
 }
 
+// Returns the name of the node enclosed in single quotes,
+// single quotes inside the name being doubled
+func quoteName(name string) string {
+	if len(name) == 0 {
+		return ""
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
 // Returns the Id of the node. Id==NIL_ID means that
 // it has not been set yet.
 func (n *Node) Id() int {
@@ -244,6 +254,12 @@ func (n *Node) RotateNeighbors(rand *mathrand.Rand) {
 // Recursive function that outputs newick representation
 // from the current node
 func (n *Node) Newick(parent *Node, newick *bytes.Buffer) {
+	n.NewickWithOptions(parent, newick, DefaultNewickOptions())
+}
+
+// Recursive function that outputs newick representation
+// from the current node, following the given writer options
+func (n *Node) NewickWithOptions(parent *Node, newick *bytes.Buffer, opts NewickOptions) {
 	if len(n.neigh) > 0 {
 		if len(n.neigh) > 1 || parent == nil {
 			newick.WriteString("(")
@@ -254,30 +270,24 @@ func (n *Node) Newick(parent *Node, newick *bytes.Buffer) {
 				if nbchild > 0 {
 					newick.WriteString(",")
 				}
-				child.Newick(n, newick)
-				if n.br[i].support != NIL_SUPPORT && child.Name() == "" {
-					newick.WriteString(strconv.FormatFloat(n.br[i].support, 'f', -1, 64))
+				child.NewickWithOptions(n, newick, opts)
+				if !opts.NoSupports && n.br[i].support != NIL_SUPPORT && (child.Name() == "" || (opts.NoInternalNames && !child.Tip())) {
+					newick.WriteString(strconv.FormatFloat(n.br[i].support, 'f', opts.Precision, 64))
 					if n.br[i].pvalue != NIL_PVALUE {
-						newick.WriteString(fmt.Sprintf("/%s", strconv.FormatFloat(n.br[i].pvalue, 'f', -1, 64)))
+						newick.WriteString(fmt.Sprintf("/%s", strconv.FormatFloat(n.br[i].pvalue, 'f', opts.Precision, 64)))
 					}
 				}
-				if len(child.comment) != 0 {
-					for _, c := range child.comment {
-						newick.WriteString("[")
-						newick.WriteString(c)
-						newick.WriteString("]")
-					}
+				if opts.Attributes != NEWICK_ATTRIBUTES_NHX {
+					writeNewickComments(newick, child.comment, opts)
 				}
 				if n.br[i].length != NIL_LENGTH {
 					newick.WriteString(":")
-					newick.WriteString(strconv.FormatFloat(n.br[i].length, 'f', -1, 64))
+					newick.WriteString(strconv.FormatFloat(n.br[i].length, 'f', opts.Precision, 64))
 				}
-				if len(n.br[i].comment) != 0 {
-					for _, c := range n.br[i].comment {
-						newick.WriteString("[")
-						newick.WriteString(c)
-						newick.WriteString("]")
-					}
+				if opts.Attributes == NEWICK_ATTRIBUTES_NHX {
+					writeNewickComments(newick, append(append([]string{}, child.comment...), n.br[i].comment...), opts)
+				} else {
+					writeNewickComments(newick, n.br[i].comment, opts)
 				}
 				nbchild++
 			}
@@ -286,5 +296,11 @@ func (n *Node) Newick(parent *Node, newick *bytes.Buffer) {
 			newick.WriteString(")")
 		}
 	}
-	newick.WriteString(n.NameQuoted())
+	if !opts.NoInternalNames || n.Tip() {
+		if opts.QuoteNames {
+			newick.WriteString(quoteName(n.name))
+		} else {
+			newick.WriteString(n.NameQuoted())
+		}
+	}
 }
@@ -0,0 +1,438 @@
+package tree
+
+import (
+	"errors"
+	"sync"
+)
+
+// Quartet comparison between two trees
+type QuartetStats struct {
+	Id             int    // Identifier of the compared tree
+	Total          uint64 // Total number of quartets (n choose 4)
+	Shared         uint64 // Number of quartets resolved identically in both trees
+	Diff           uint64 // Number of quartets resolved differently in both trees
+	UnresolvedRef  uint64 // Number of quartets resolved in the compared tree but not in the reference tree
+	UnresolvedComp uint64 // Number of quartets resolved in the reference tree but not in the compared tree
+	Err            error  // Wether an error occured or not in the computation
+}
+
+// Normalized quartet distance: number of quartets that do not have
+// the same topology in both trees, divided by the total number of quartets.
+//
+// Quartets unresolved in both trees are considered as having the same topology.
+func (qs QuartetStats) Distance() float64 {
+	if qs.Total == 0 {
+		return 0
+	}
+	return float64(qs.Diff+qs.UnresolvedRef+qs.UnresolvedComp) / float64(qs.Total)
+}
+
+// Internal representation of a tree used to count quartets:
+// nodes are indexed in post order (root is the last node).
+type quartetTree struct {
+	ntips    int
+	parent   []int   // Index of the parent node (-1 for the root)
+	children [][]int // Index of the children nodes
+	tipid    []int   // Index of the tip in the tip index (-1 for internal nodes)
+	size     []int64 // Number of tips under each node
+}
+
+func newQuartetTree(t *Tree) (qt *quartetTree) {
+	qt = &quartetTree{ntips: len(t.Tips())}
+	var recur func(cur, prev *Node) int
+	recur = func(cur, prev *Node) int {
+		ch := make([]int, 0, cur.Nneigh())
+		for _, n := range cur.neigh {
+			if n != prev {
+				ch = append(ch, recur(n, cur))
+			}
+		}
+		id := len(qt.parent)
+		qt.parent = append(qt.parent, -1)
+		qt.children = append(qt.children, ch)
+		qt.size = append(qt.size, 0)
+		if cur.Tip() {
+			qt.tipid = append(qt.tipid, cur.tipid)
+			qt.size[id] = 1
+		} else {
+			qt.tipid = append(qt.tipid, -1)
+		}
+		for _, c := range ch {
+			qt.parent[c] = id
+			qt.size[id] += qt.size[c]
+		}
+		return id
+	}
+	recur(t.Root(), nil)
+	return
+}
+
+// Number of neighbors of node i
+func (qt *quartetTree) degree(i int) int {
+	if qt.parent[i] == -1 {
+		return len(qt.children[i])
+	}
+	return len(qt.children[i]) + 1
+}
+
+// Number of quartets resolved in the tree.
+//
+// Each resolved quartet ab|cd is counted from the node joining a and b
+// (a and b in two different subtrees i and j, c and d in a third subtree k),
+// and from the node joining c and d.
+func (qt *quartetTree) resolvedQuartets() uint64 {
+	var total int64
+	n := int64(qt.ntips)
+	sizes := make([]int64, 0)
+	for i := range qt.parent {
+		if qt.degree(i) < 3 {
+			continue
+		}
+		sizes = sizes[:0]
+		for _, c := range qt.children[i] {
+			sizes = append(sizes, qt.size[c])
+		}
+		if qt.parent[i] != -1 {
+			sizes = append(sizes, n-qt.size[i])
+		}
+		var sumpairs int64
+		for _, s := range sizes {
+			sumpairs += pairs(s)
+		}
+		for _, s := range sizes {
+			total += pairs(s) * (pairs(n-s) - (sumpairs - pairs(s)))
+		}
+	}
+	return uint64(total / 2)
+}
+
+// Labels all tips with the index of the subtree of node u containing them.
+// Returns the number of subtrees
+func (qt *quartetTree) labelSubtrees(u int, labels []int) (d int) {
+	var label func(cur, l int)
+	label = func(cur, l int) {
+		if qt.tipid[cur] != -1 {
+			labels[qt.tipid[cur]] = l
+		}
+		for _, c := range qt.children[cur] {
+			label(c, l)
+		}
+	}
+	for _, c := range qt.children[u] {
+		label(c, d)
+		d++
+	}
+	if qt.parent[u] != -1 {
+		// Tips of the parent side
+		prev, cur := u, qt.parent[u]
+		for cur != -1 {
+			if qt.tipid[cur] != -1 {
+				labels[qt.tipid[cur]] = d
+			}
+			for _, c := range qt.children[cur] {
+				if c != prev {
+					label(c, d)
+				}
+			}
+			prev, cur = cur, qt.parent[cur]
+		}
+		d++
+	}
+	return
+}
+
+// Counts quartets having the same topology (shared) and a different
+// topology (diff) in both trees, both trees having the same tip index.
+//
+// For each pair of internal nodes (u1 of t1, u2 of t2), we consider the
+// matrix M[k][k'] giving the number of tips in subtree k of u1 and in subtree k'
+// of u2. A shared quartet ab|cd has c and d in the same cell, and a and b in
+// different rows and columns, different from the ones of c and d. Each shared
+// quartet is counted twice (from ab and from cd). A quartet ab|cd in t1
+// and ac|bd in t2 is counted 4 times in the same way.
+//
+// Complexity is O(n^2) for binary trees. Nodes of t1 are split among cpus go routines.
+func quartetCounts(t1, t2 *quartetTree, cpus int) (shared, diff uint64) {
+	if cpus < 1 {
+		cpus = 1
+	}
+	sharedsums := make([]int64, cpus)
+	diffsums := make([]int64, cpus)
+	var wg sync.WaitGroup
+	for cpu := 0; cpu < cpus; cpu++ {
+		wg.Add(1)
+		go func(cpu int) {
+			defer wg.Done()
+			sharedsums[cpu], diffsums[cpu] = quartetCountsFrom(t1, t2, cpu, cpus)
+		}(cpu)
+	}
+	wg.Wait()
+	var sharedsum, diffsum int64
+	for cpu := 0; cpu < cpus; cpu++ {
+		sharedsum += sharedsums[cpu]
+		diffsum += diffsums[cpu]
+	}
+	return uint64(sharedsum / 2), uint64(diffsum / 4)
+}
+
+// Sums of shared and diff counts over nodes u1 of t1 such that u1%step == start
+func quartetCountsFrom(t1, t2 *quartetTree, start, step int) (sharedsum, diffsum int64) {
+	n := int64(t1.ntips)
+	labels := make([]int, t1.ntips)
+	var cnt []int64
+	var m []int64
+	w := &quartetWorkspace{}
+	for u1 := start; u1 < len(t1.parent); u1 += step {
+		if t1.degree(u1) < 3 {
+			continue
+		}
+		d1 := t1.labelSubtrees(u1, labels)
+		// Number of tips of each subtree of u1 under each node of t2
+		if cap(cnt) < d1*len(t2.parent) {
+			cnt = make([]int64, d1*len(t2.parent))
+		}
+		cnt = cnt[:d1*len(t2.parent)]
+		for i := range cnt {
+			cnt[i] = 0
+		}
+		for v := range t2.parent {
+			if t2.tipid[v] != -1 {
+				cnt[v*d1+labels[t2.tipid[v]]]++
+			}
+			if p := t2.parent[v]; p != -1 {
+				for k := 0; k < d1; k++ {
+					cnt[p*d1+k] += cnt[v*d1+k]
+				}
+			}
+		}
+		root := len(t2.parent) - 1
+		r := cnt[root*d1 : (root+1)*d1]
+
+		for u2 := range t2.parent {
+			if t2.degree(u2) < 3 {
+				continue
+			}
+			// Building matrix M (d1 x d2), column-major
+			d2 := t2.degree(u2)
+			if cap(m) < d1*d2 {
+				m = make([]int64, d1*d2)
+			}
+			m = m[:d1*d2]
+			for y, c := range t2.children[u2] {
+				copy(m[y*d1:(y+1)*d1], cnt[c*d1:(c+1)*d1])
+			}
+			if t2.parent[u2] != -1 {
+				y := d2 - 1
+				for k := 0; k < d1; k++ {
+					m[y*d1+k] = r[k] - cnt[u2*d1+k]
+				}
+			}
+			s, d := w.quartetCountsMatrix(m, r, d1, d2, n)
+			sharedsum += s
+			diffsum += d
+		}
+	}
+	return
+}
+
+// Buffers used to compute quartet counts from an intersection matrix
+type quartetWorkspace struct {
+	c, sr, colc, colq, colsq []int64 // column sums and sums over rows of each column
+	sc, rowc, rowq, rowsq    []int64 // sums over columns of each row
+	rownz, colnz             [][]int // non zero columns of each row / non zero rows of each column
+	h                        []int64 // M.M^T (d1 x d1) if d1 <= d2, M^T.M (d2 x d2) otherwise
+}
+
+// Resizes (and reinitializes) the buffers for a d1 x d2 matrix
+func (w *quartetWorkspace) reset(d1, d2 int) {
+	w.c, w.sr, w.colc, w.colq, w.colsq = resetInt64(w.c, d2), resetInt64(w.sr, d2), resetInt64(w.colc, d2), resetInt64(w.colq, d2), resetInt64(w.colsq, d2)
+	w.sc, w.rowc, w.rowq, w.rowsq = resetInt64(w.sc, d1), resetInt64(w.rowc, d1), resetInt64(w.rowq, d1), resetInt64(w.rowsq, d1)
+	w.rownz, w.colnz = resetIntLists(w.rownz, d1), resetIntLists(w.colnz, d2)
+	w.h = resetInt64(w.h, min(d1, d2)*min(d1, d2))
+}
+
+func resetInt64(s []int64, l int) []int64 {
+	if cap(s) < l {
+		return make([]int64, l)
+	}
+	s = s[:l]
+	for i := range s {
+		s[i] = 0
+	}
+	return s
+}
+
+func resetIntLists(s [][]int, l int) [][]int {
+	for len(s) < l {
+		s = append(s, make([]int, 0, 4))
+	}
+	s = s[:l]
+	for i := range s {
+		s[i] = s[i][:0]
+	}
+	return s
+}
+
+// Computes the shared and diff counts for a given intersection matrix m
+// (d1 x d2, column-major, m[y*d1+k]), r being the row sums.
+func (w *quartetWorkspace) quartetCountsMatrix(m, r []int64, d1, d2 int, n int64) (shared, diff int64) {
+	w.reset(d1, d2)
+	c, sr, colc, colq, colsq := w.c, w.sr, w.colc, w.colq, w.colsq // sum_i C(r_i - M[i][k'], 2), C(M[i][k'], 2), M[i][k'](M[i][k'] - r_i), M[i][k']^2
+	sc, rowc, rowq, rowsq := w.sc, w.rowc, w.rowq, w.rowsq         // sum_j C(c_j - M[k][j], 2), C(M[k][j], 2), M[k][j](M[k][j] - c_j), M[k][j]^2
+	rownz, colnz := w.rownz, w.colnz
+	var summ int64
+
+	for y := 0; y < d2; y++ {
+		for k := 0; k < d1; k++ {
+			v := m[y*d1+k]
+			c[y] += v
+			if v != 0 {
+				rownz[k] = append(rownz[k], y)
+				colnz[y] = append(colnz[y], k)
+			}
+		}
+	}
+	for y := 0; y < d2; y++ {
+		for k := 0; k < d1; k++ {
+			v := m[y*d1+k]
+			sr[y] += pairs(r[k] - v)
+			sc[k] += pairs(c[y] - v)
+			rowc[k] += pairs(v)
+			colc[y] += pairs(v)
+			summ += pairs(v)
+			rowq[k] += v * (v - c[y])
+			colq[y] += v * (v - r[k])
+			rowsq[k] += v * v
+			colsq[y] += v * v
+		}
+	}
+	// Products of rows (or columns) of M, using the smallest dimension
+	if d1 <= d2 {
+		for y := 0; y < d2; y++ {
+			for _, k := range colnz[y] {
+				for _, j := range colnz[y] {
+					w.h[k*d1+j] += m[y*d1+k] * m[y*d1+j]
+				}
+			}
+		}
+	} else {
+		for k := 0; k < d1; k++ {
+			for _, y := range rownz[k] {
+				for _, x := range rownz[k] {
+					w.h[y*d2+x] += m[y*d1+k] * m[x*d1+k]
+				}
+			}
+		}
+	}
+
+	for y := 0; y < d2; y++ {
+		for _, k := range colnz[y] {
+			v := m[y*d1+k]
+			// Shared: c and d in cell (k,y)
+			if v > 1 {
+				nout := n - r[k] - c[y] + v
+				p := pairs(nout) -
+					(sr[y] - pairs(r[k]-v)) -
+					(sc[k] - pairs(c[y]-v)) +
+					(summ - rowc[k] - colc[y] + pairs(v))
+				shared += pairs(v) * p
+			}
+			// Diff: d in cell (k,y), b in column y, c in row k,
+			// and a neither in the rows nor in the columns of b and c
+			// g = sum_j sum_x M[j][y] M[k][x] M[j][x]
+			var g int64
+			if d1 <= d2 {
+				for _, j := range colnz[y] {
+					g += m[y*d1+j] * w.h[k*d1+j]
+				}
+			} else {
+				for _, x := range rownz[k] {
+					g += m[x*d1+k] * w.h[y*d2+x]
+				}
+			}
+			t := g - v*(rowsq[k]+colsq[y]) + v*v*v
+			alpha := c[y] - v
+			beta := r[k] - v
+			kk := n - r[k] - c[y] + v
+			diff += v * (kk*alpha*beta +
+				beta*(colq[y]-v*(v-r[k])) +
+				alpha*(rowq[k]-v*(v-c[y])) +
+				t)
+		}
+	}
+	return
+}
+
+// Number of pairs in a set of size n
+func pairs(n int64) int64 {
+	return n * (n - 1) / 2
+}
+
+// Compares the quartets of two trees having the same set of tips.
+//
+// It does not enumerate quartets, and runs in O(n^2) for binary trees,
+// using cpus go routines.
+func (t *Tree) CompareQuartets(t2 *Tree, cpus int) (stats QuartetStats, err error) {
+	if err = t.UpdateTipIndex(); err != nil {
+		return
+	}
+	if err = t2.UpdateTipIndex(); err != nil {
+		return
+	}
+	if err = t.CompareTipIndexes(t2); err != nil {
+		return
+	}
+	qt := newQuartetTree(t)
+	stats = compareQuartetTrees(qt, qt.resolvedQuartets(), newQuartetTree(t2), cpus)
+	return
+}
+
+// Compares the quartets of qt1 (having res1 resolved quartets) and qt2
+func compareQuartetTrees(qt1 *quartetTree, res1 uint64, qt2 *quartetTree, cpus int) (stats QuartetStats) {
+	n := uint64(qt1.ntips)
+	if n >= 4 {
+		stats.Total = n * (n - 1) / 2 * (n - 2) / 3 * (n - 3) / 4
+	}
+	res2 := qt2.resolvedQuartets()
+	stats.Shared, stats.Diff = quartetCounts(qt1, qt2, cpus)
+	stats.UnresolvedRef = res2 - stats.Shared - stats.Diff
+	stats.UnresolvedComp = res1 - stats.Shared - stats.Diff
+	return
+}
+
+// This function compares quartets of a reference tree with a set of trees given in the input channel.
+//
+// Like Compare, it returns almost immediately, computations being done in background.
+// The returned channel is closed at the end of the computations. Each comparison
+// uses cpus go routines.
+func CompareQuartets(refTree *Tree, compTrees <-chan Trees, cpus int) (<-chan QuartetStats, error) {
+	if refTree == nil {
+		return nil, errors.New("Tree 1 in comparison is null")
+	}
+	if err := refTree.UpdateTipIndex(); err != nil {
+		return nil, err
+	}
+	qtref := newQuartetTree(refTree)
+	resref := qtref.resolvedQuartets()
+
+	stats := make(chan QuartetStats)
+	go func() {
+		for treeV := range compTrees {
+			inerr := treeV.Err
+			if inerr == nil {
+				if inerr = treeV.Tree.UpdateTipIndex(); inerr == nil {
+					inerr = refTree.CompareTipIndexes(treeV.Tree)
+				}
+			}
+			st := QuartetStats{}
+			if inerr == nil {
+				st = compareQuartetTrees(qtref, resref, newQuartetTree(treeV.Tree), cpus)
+			}
+			st.Id, st.Err = treeV.Id, inerr
+			stats <- st
+		}
+		close(stats)
+	}()
+
+	return stats, nil
+}
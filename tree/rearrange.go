@@ -232,3 +232,273 @@ func (n *nni) Undo() (err error) {
 
 	return
 }
+
+// SPRRearranger lists all Subtree Pruning and Regrafting moves of a tree.
+//
+// For each node p having 3 neighbors, and each neighbor s of p, the subtree
+// containing s is pruned (with p), and regrafted on every edge of the remaining
+// tree located at most at MaxRadius edges from the pruning point
+// (MaxRadius<=0: no limit). Regrafting at radius 1 is equivalent to an NNI.
+//
+// Applies only to nodes of degree 3 (binary parts of the tree).
+// The same neighbor tree may be listed several times.
+type SPRRearranger struct {
+	MaxRadius int
+}
+
+func (sprr *SPRRearranger) Rearrange(t *Tree, f func(r Rearrangement) bool) {
+	for _, e := range t.Edges() {
+		for _, ps := range [][2]*Node{{e.Left(), e.Right()}, {e.Right(), e.Left()}} {
+			p, s := ps[0], ps[1]
+			if p.Nneigh() != 3 {
+				continue
+			}
+			for _, target := range relocationTargets(p, s, sprr.MaxRadius) {
+				if !f(&spr{relocation: relocation{t: t, p: p, s: s, f: target}}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// TBRRearranger lists all Tree Bisection and Reconnection moves of a tree.
+//
+// For each edge e=(u,v), the tree is bisected by removing e, and the two
+// subtrees are reconnected through e, between every pair of edges (one in each
+// subtree) located at most at MaxRadius edges from the bisection point
+// (MaxRadius<=0: no limit). The original attachment point of a subtree is
+// also considered, such that all SPR moves are also listed.
+//
+// Applies only to nodes of degree 3 (binary parts of the tree).
+// The same neighbor tree may be listed several times.
+type TBRRearranger struct {
+	MaxRadius int
+}
+
+func (tbrr *TBRRearranger) Rearrange(t *Tree, f func(r Rearrangement) bool) {
+	for _, e := range t.Edges() {
+		u, v := e.Left(), e.Right()
+		// nil: original attachment point
+		utargets := []*Edge{nil}
+		vtargets := []*Edge{nil}
+		if u.Nneigh() == 3 {
+			utargets = append(utargets, relocationTargets(u, v, tbrr.MaxRadius)...)
+		}
+		if v.Nneigh() == 3 {
+			vtargets = append(vtargets, relocationTargets(v, u, tbrr.MaxRadius)...)
+		}
+		for _, ut := range utargets {
+			for _, vt := range vtargets {
+				if ut == nil && vt == nil {
+					continue
+				}
+				r := &tbr{}
+				if ut != nil {
+					r.relocations = append(r.relocations, &relocation{t: t, p: u, s: v, f: ut})
+				}
+				if vt != nil {
+					r.relocations = append(r.relocations, &relocation{t: t, p: v, s: u, f: vt})
+				}
+				if !f(r) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Subtree pruning and regrafting: a single relocation
+type spr struct {
+	relocation
+}
+
+// Tree bisection and reconnection: one relocation in each subtree
+type tbr struct {
+	relocations []*relocation
+}
+
+func (r *tbr) Apply() (err error) {
+	for _, rel := range r.relocations {
+		if err = rel.Apply(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *tbr) Undo() (err error) {
+	for i := len(r.relocations) - 1; i >= 0; i-- {
+		if err = r.relocations[i].Undo(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// A relocation moves node p (of degree 3) on another edge f, while keeping
+// p connected to its neighbor s:
+//
+//	a          s                        s
+//	 \         |                        |
+//	  +---p----+ ... x---y   =>  a---b  x---p---y
+//	 /
+//	b
+//
+// The two edges p-a and p-b are merged into a-b, and the edge x-y
+// is split into x-p and p-y. f must not be in the subtree containing s.
+type relocation struct {
+	t    *Tree
+	p, s *Node
+	f    *Edge
+	// If the relocation has already been applied
+	applied bool
+	// State of modified nodes and edges before applying the relocation
+	savedNodes []savedNode
+	savedEdges []savedEdge
+	// Edges inverted after the relocation, to keep edges oriented from the root
+	inverted []*Edge
+}
+
+type savedNode struct {
+	n     *Node
+	neigh []*Node
+	br    []*Edge
+}
+
+type savedEdge struct {
+	e           *Edge
+	left, right *Node
+	length      float64
+}
+
+func (r *relocation) Apply() (err error) {
+	if r.applied {
+		return
+	}
+	var si int
+	var a, b, x, y *Node
+	var ea, eb *Edge
+
+	if si, err = r.p.NodeIndex(r.s); err != nil {
+		err = fmt.Errorf("cannot apply relocation with unconnected nodes p and s")
+		return
+	}
+	if r.p.Nneigh() != 3 {
+		err = fmt.Errorf("cannot apply relocation on a node that does not have 3 neighbors")
+		return
+	}
+	ai, bi := (si+1)%3, (si+2)%3
+	a, ea = r.p.neigh[ai], r.p.br[ai]
+	b, eb = r.p.neigh[bi], r.p.br[bi]
+	x, y = r.f.left, r.f.right
+	if r.f == ea || r.f == eb {
+		err = fmt.Errorf("cannot relocate a node on one of its adjacent edges")
+		return
+	}
+
+	r.save([]*Node{a, b, r.p, x, y}, []*Edge{ea, eb, r.f})
+
+	// Pruning: a is connected to b via ea
+	a.neigh[slotIndex(a, r.p, ea)] = b
+	bslot := slotIndex(b, r.p, eb)
+	b.neigh[bslot], b.br[bslot] = a, ea
+	ea.left, ea.right = a, b
+	if ea.length != NIL_LENGTH && eb.length != NIL_LENGTH {
+		ea.length += eb.length
+	}
+
+	// Regrafting: x is connected to p via f, and p to y via eb
+	x.neigh[slotIndex(x, y, r.f)] = r.p
+	yslot := slotIndex(y, x, r.f)
+	y.neigh[yslot], y.br[yslot] = r.p, eb
+	r.p.neigh[ai], r.p.br[ai] = x, r.f
+	r.p.neigh[bi], r.p.br[bi] = y, eb
+	r.f.left, r.f.right = x, r.p
+	eb.left, eb.right = r.p, y
+	if r.f.length != NIL_LENGTH {
+		r.f.length /= 2.0
+		eb.length = r.f.length
+	}
+
+	// Edges are reoriented from the root
+	r.inverted = r.inverted[:0]
+	r.t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		if prev != nil && e.left != prev {
+			e.Inverse()
+			r.inverted = append(r.inverted, e)
+		}
+		return true
+	})
+	r.applied = true
+	return
+}
+
+func (r *relocation) Undo() (err error) {
+	if !r.applied {
+		return
+	}
+	for _, e := range r.inverted {
+		e.Inverse()
+	}
+	for _, sn := range r.savedNodes {
+		copy(sn.n.neigh, sn.neigh)
+		copy(sn.n.br, sn.br)
+	}
+	for _, se := range r.savedEdges {
+		se.e.left, se.e.right, se.e.length = se.left, se.right, se.length
+	}
+	r.applied = false
+	return
+}
+
+// Saves the state of the given nodes and edges
+func (r *relocation) save(nodes []*Node, edges []*Edge) {
+	r.savedNodes = r.savedNodes[:0]
+	r.savedEdges = r.savedEdges[:0]
+	for _, n := range nodes {
+		r.savedNodes = append(r.savedNodes, savedNode{
+			n:     n,
+			neigh: append([]*Node(nil), n.neigh...),
+			br:    append([]*Edge(nil), n.br...),
+		})
+	}
+	for _, e := range edges {
+		r.savedEdges = append(r.savedEdges, savedEdge{e: e, left: e.left, right: e.right, length: e.length})
+	}
+}
+
+// Index of the neighbor next, connected via edge e, in the list of neighbors of n
+func slotIndex(n, next *Node, e *Edge) int {
+	for i := range n.neigh {
+		if n.neigh[i] == next && n.br[i] == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns all the edges on which p can be relocated, i.e. all edges
+// that are not in the subtree containing s, and that are not adjacent to p,
+// located at most at maxradius edges from p (maxradius<=0: no limit)
+func relocationTargets(p, s *Node, maxradius int) (targets []*Edge) {
+	targets = make([]*Edge, 0)
+	for _, n := range p.neigh {
+		if n != s {
+			relocationTargetsRecur(n, p, 1, maxradius, &targets)
+		}
+	}
+	return
+}
+
+func relocationTargetsRecur(cur, prev *Node, radius, maxradius int, targets *[]*Edge) {
+	if maxradius > 0 && radius > maxradius {
+		return
+	}
+	for i, n := range cur.neigh {
+		if n != prev {
+			*targets = append(*targets, cur.br[i])
+			relocationTargetsRecur(n, cur, radius+1, maxradius, targets)
+		}
+	}
+}
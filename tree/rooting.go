@@ -0,0 +1,473 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// RootScore is the best root position on a branch, for a given rooting
+// method, and the score of the tree rooted at this position (the lower,
+// the better).
+type RootScore struct {
+	Edge     *Edge
+	Position float64 // Distance from the left node of the branch to the root
+	Score    float64
+}
+
+// MinVarRootScores computes, for each branch of the tree, the root
+// position minimizing the variance of the root-to-tip distances
+// (minimum variance rooting, Mai et al. 2017), and this variance.
+//
+// The tree is unrooted first, scores are given for each branch of the
+// unrooted tree, in the order of t.Edges().
+// All branches must have a length.
+func (t *Tree) MinVarRootScores() (scores []RootScore, err error) {
+	var tips []*Node
+	var dists map[*Node][]float64
+
+	if tips, dists, err = t.rootingDistances(); err != nil {
+		return
+	}
+	n := float64(len(tips))
+	edges := t.Edges()
+	scores = make([]RootScore, 0, len(edges))
+	for _, e := range edges {
+		// Root-to-tip distances are c+x for left tips, c-x for right tips,
+		// x being the position of the root from the left node
+		var na, nb, suma, sumb, sumsq float64
+		for k := range tips {
+			if e.TipPresent(uint(k)) {
+				c := dists[e.Right()][k] + e.Length()
+				nb++
+				sumb += c
+				sumsq += c * c
+			} else {
+				c := dists[e.Left()][k]
+				na++
+				suma += c
+				sumsq += c * c
+			}
+		}
+		sum := suma + sumb
+		x := (sum*(na-nb) - n*(suma-sumb)) / (4 * na * nb)
+		x = math.Max(0, math.Min(e.Length(), x))
+		mean := (sum + (na-nb)*x) / n
+		variance := (sumsq+2*x*(suma-sumb)+n*x*x)/n - mean*mean
+		scores = append(scores, RootScore{e, x, math.Max(0, variance)})
+	}
+	return
+}
+
+// MADRootScores computes, for each branch of the tree, the root position
+// minimizing the mean ancestor deviation (MAD rooting, Tria et al. 2017),
+// and the corresponding score: the root mean square, over all pairs of
+// tips, of the relative deviation of their last common ancestor from the
+// middle of their path.
+//
+// The tree is unrooted first, scores are given for each branch of the
+// unrooted tree, in the order of t.Edges().
+// All branches must have a length.
+func (t *Tree) MADRootScores() (scores []RootScore, err error) {
+	var tips []*Node
+	var dists map[*Node][]float64
+
+	if tips, dists, err = t.rootingDistances(); err != nil {
+		return
+	}
+	npairs := float64(len(tips)*(len(tips)-1)) / 2.0
+	if npairs == 0 {
+		err = errors.New("cannot compute MAD scores with less than 2 tips")
+		return
+	}
+
+	// Sum of squared deviations of the pairs of tips whose last common
+	// ancestor is the given node, for each neighbor of the node
+	// being its parent (nil: no parent)
+	deviations := make(map[*Node]map[*Node]float64)
+	for _, c := range t.Nodes() {
+		if !c.Tip() {
+			deviations[c] = madNodeDeviations(c, tips, dists[c])
+		}
+	}
+	// Sum over all the pairs of tips, for the current root
+	base := 0.0
+	t.PreOrder(func(cur, prev *Node, e *Edge) (keep bool) {
+		if !cur.Tip() {
+			base += deviations[cur][prev]
+		}
+		return true
+	})
+
+	sc := make(map[*Edge]RootScore)
+	var recur func(cur, prev *Node, delta float64)
+	recur = func(cur, prev *Node, delta float64) {
+		for i, child := range cur.neigh {
+			if child == prev {
+				continue
+			}
+			e := cur.br[i]
+			// Deviations of the pairs whose ancestor changes when
+			// rooting on e: cur's parent becomes child
+			d := delta + deviations[cur][child] - deviations[cur][prev]
+			x, cross := madCrossingPairs(e, tips, dists)
+			sc[e] = RootScore{e, x, math.Sqrt(math.Max(0, base+d+cross) / npairs)}
+			if !child.Tip() {
+				recur(child, cur, d)
+			}
+		}
+	}
+	recur(t.Root(), nil, 0)
+
+	edges := t.Edges()
+	scores = make([]RootScore, 0, len(edges))
+	for _, e := range edges {
+		scores = append(scores, sc[e])
+	}
+	return
+}
+
+// RerootMinVar reroots the tree at the position minimizing the variance
+// of the root-to-tip distances (see MinVarRootScores), and returns the
+// score of the chosen root, as well as the scores of all the branches of
+// the unrooted tree.
+func (t *Tree) RerootMinVar() (best RootScore, scores []RootScore, err error) {
+	if scores, err = t.MinVarRootScores(); err != nil {
+		return
+	}
+	best = bestRootScore(scores)
+	t.rerootEdge(best.Edge, best.Position)
+	return
+}
+
+// RerootMAD reroots the tree at the position minimizing the mean
+// ancestor deviation (see MADRootScores), and returns the score of the
+// chosen root, as well as the scores of all the branches of the unrooted
+// tree.
+func (t *Tree) RerootMAD() (best RootScore, scores []RootScore, err error) {
+	if scores, err = t.MADRootScores(); err != nil {
+		return
+	}
+	best = bestRootScore(scores)
+	t.rerootEdge(best.Edge, best.Position)
+	return
+}
+
+// Unroots the tree, and computes the distances from each node to each
+// tip, tips being ordered by their tip index
+func (t *Tree) rootingDistances() (tips []*Node, dists map[*Node][]float64, err error) {
+	t.UnRoot()
+	if err = t.ReinitIndexes(); err != nil {
+		return
+	}
+	if len(t.Edges()) == 0 {
+		err = errors.New("cannot root a tree without branches")
+		return
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == NIL_LENGTH {
+			err = errors.New("all the branches of the tree must have a length")
+			return
+		}
+	}
+
+	tips = t.SortedTips()
+	dists = make(map[*Node][]float64)
+	for _, n := range t.Nodes() {
+		dists[n] = make([]float64, len(tips))
+	}
+	var recur func(cur, prev *Node, k int, d float64)
+	recur = func(cur, prev *Node, k int, d float64) {
+		dists[cur][k] = d
+		for i, next := range cur.neigh {
+			if next != prev {
+				recur(next, cur, k, d+cur.br[i].Length())
+			}
+		}
+	}
+	for k, tip := range tips {
+		recur(tip, nil, k, 0)
+	}
+	return
+}
+
+// Sum of squared ancestor deviations of the pairs of tips separated by
+// node c (d(k,c)-d(m,c))/d(k,m), for each neighbor of c being the parent
+// of c: pairs of tips in the subtree of the parent are excluded. The key
+// nil gives the sum over all the pairs of tips separated by c.
+func madNodeDeviations(c *Node, tips []*Node, cdists []float64) (dev map[*Node]float64) {
+	groups := make([][]int, len(c.neigh))
+	for k := range tips {
+		g := -1
+		for i, e := range c.br {
+			if e.Left() == c && e.TipPresent(uint(k)) {
+				g = i
+			} else if e.Right() == c && !e.TipPresent(uint(k)) {
+				g = i
+			}
+		}
+		groups[g] = append(groups[g], k)
+	}
+
+	pairs := make([][]float64, len(groups))
+	for g := range groups {
+		pairs[g] = make([]float64, len(groups))
+	}
+	total := 0.0
+	for g := range groups {
+		for h := g + 1; h < len(groups); h++ {
+			s := 0.0
+			for _, k := range groups[g] {
+				for _, m := range groups[h] {
+					if sum := cdists[k] + cdists[m]; sum > 0 {
+						r := (cdists[k] - cdists[m]) / sum
+						s += r * r
+					}
+				}
+			}
+			pairs[g][h], pairs[h][g] = s, s
+			total += s
+		}
+	}
+
+	dev = map[*Node]float64{nil: total}
+	for g, p := range c.neigh {
+		dev[p] = total
+		for h := range groups {
+			dev[p] -= pairs[g][h]
+		}
+	}
+	return
+}
+
+// Best root position on branch e for the pairs of tips separated by e,
+// and the sum of their squared ancestor deviations at this position
+func madCrossingPairs(e *Edge, tips []*Node, dists map[*Node][]float64) (x, dev float64) {
+	var left, right []int
+	for k := range tips {
+		if e.TipPresent(uint(k)) {
+			right = append(right, k)
+		} else {
+			left = append(left, k)
+		}
+	}
+	l := e.Length()
+	ld, rd := dists[e.Left()], dists[e.Right()]
+	// The deviation of a pair is (2(a+x)-d)/d, a being the distance of
+	// the left tip to the left node, and d the length of the path
+	num, den := 0.0, 0.0
+	for _, k := range left {
+		for _, m := range right {
+			if d := ld[k] + l + rd[m]; d > 0 {
+				num += (d - 2*ld[k]) / (d * d)
+				den += 2 / (d * d)
+			}
+		}
+	}
+	if den > 0 {
+		x = math.Max(0, math.Min(l, num/den))
+	}
+	for _, k := range left {
+		for _, m := range right {
+			if d := ld[k] + l + rd[m]; d > 0 {
+				r := (2*(ld[k]+x) - d) / d
+				dev += r * r
+			}
+		}
+	}
+	return
+}
+
+// Root score with the lowest score (the first one in case of ties)
+func bestRootScore(scores []RootScore) (best RootScore) {
+	for i, s := range scores {
+		if i == 0 || s.Score < best.Score {
+			best = s
+		}
+	}
+	return
+}
+
+// Reroots the tree on a new node placed on the given branch, at the given
+// distance from its left node
+func (t *Tree) rerootEdge(e *Edge, position float64) {
+	root := t.NewNode()
+	length := e.Length()
+	support := e.Support()
+
+	lnode := e.Left()
+	rnode := e.Right()
+	lnode.delNeighbor(rnode)
+	rnode.delNeighbor(lnode)
+
+	ne := t.ConnectNodes(root, lnode)
+	ne2 := t.ConnectNodes(root, rnode)
+	ne.SetLength(position)
+	ne2.SetLength(length - position)
+	ne.SetSupport(support)
+	ne2.SetSupport(support)
+
+	t.reroot_nocheck(root)
+	t.ReinitInternalIndexes()
+}
+
+// Criteria of the root-to-tip regression rooting
+const (
+	RTT_CRITERION_R2  = iota // Maximizes the correlation (R² with a positive rate)
+	RTT_CRITERION_RMS        // Minimizes the residual mean squares
+)
+
+// RTTRootScore is the best root position on a branch for the root-to-tip
+// regression against tip dates, and the statistics of the regression
+// with the tree rooted at this position.
+type RTTRootScore struct {
+	Edge     *Edge
+	Position float64 // Distance from the left node of the branch to the root
+	Rate     float64 // Slope of the regression (substitutions per unit of time)
+	RootDate float64 // Date at which the regression line crosses 0 (date of the root)
+	R2       float64 // Coefficient of determination
+	RMS      float64 // Residual mean squares
+}
+
+// RTTRootScores computes, for each branch of the tree, the root position
+// giving the best root-to-tip regression against tip dates, given in the
+// [&date=] comments of the tips, as TempEst does: maximizing R² with a
+// positive rate (RTT_CRITERION_R2), or minimizing the residual mean
+// squares (RTT_CRITERION_RMS).
+//
+// The tree is unrooted first, scores are given for each branch of the
+// unrooted tree, in the order of t.Edges().
+// All branches must have a length, and all tips a date.
+func (t *Tree) RTTRootScores(criterion int) (scores []RTTRootScore, err error) {
+	var tips []*Node
+	var dists map[*Node][]float64
+
+	if criterion != RTT_CRITERION_R2 && criterion != RTT_CRITERION_RMS {
+		err = fmt.Errorf("unknown root-to-tip regression criterion: %d", criterion)
+		return
+	}
+	if tips, dists, err = t.rootingDistances(); err != nil {
+		return
+	}
+	n := float64(len(tips))
+	if n < 3 {
+		err = errors.New("cannot compute a root-to-tip regression with less than 3 tips")
+		return
+	}
+	dates := make([]float64, len(tips))
+	meandate := 0.0
+	for k, tip := range tips {
+		if dates[k], err = tip.date(); err != nil {
+			err = fmt.Errorf("tip %s: %v", tip.Name(), err)
+			return
+		}
+		meandate += dates[k] / n
+	}
+	stt := 0.0
+	for k := range tips {
+		stt += (dates[k] - meandate) * (dates[k] - meandate)
+	}
+	if stt == 0 {
+		err = errors.New("cannot compute a root-to-tip regression with identical tip dates")
+		return
+	}
+
+	edges := t.Edges()
+	scores = make([]RTTRootScore, 0, len(edges))
+	c := make([]float64, len(tips))
+	s := make([]float64, len(tips))
+	for _, e := range edges {
+		// Root-to-tip distances are c+s*x, s being 1 for left tips, -1
+		// for right tips, and x the position of the root from the left node
+		meanc, means := 0.0, 0.0
+		for k := range tips {
+			if e.TipPresent(uint(k)) {
+				c[k], s[k] = dists[e.Right()][k]+e.Length(), -1
+			} else {
+				c[k], s[k] = dists[e.Left()][k], 1
+			}
+			meanc += c[k] / n
+			means += s[k] / n
+		}
+		// Centered cross products
+		var a, b, p, q, r float64
+		for k := range tips {
+			tc, cc, sc := dates[k]-meandate, c[k]-meanc, s[k]-means
+			a += tc * cc
+			b += tc * sc
+			p += sc * sc
+			q += cc * sc
+			r += cc * cc
+		}
+		// Residual sum of squares: (p-b²/stt)x² + 2(q-ab/stt)x + r-a²/stt
+		// R²: (a+bx)²/(stt(px²+2qx+r))
+		stats := func(x float64) RTTRootScore {
+			sxy := a + b*x
+			syy := p*x*x + 2*q*x + r
+			rs := RTTRootScore{Edge: e, Position: x, Rate: sxy / stt}
+			rs.RMS = math.Max(0, syy-sxy*sxy/stt) / (n - 2)
+			if syy > 0 {
+				rs.R2 = sxy * sxy / (stt * syy)
+			}
+			if rs.Rate != 0 {
+				rs.RootDate = meandate - (meanc+means*x)/rs.Rate
+			} else {
+				rs.RootDate = math.NaN()
+			}
+			return rs
+		}
+
+		candidates := []float64{0, e.Length()}
+		if criterion == RTT_CRITERION_RMS {
+			if den := p - b*b/stt; den > 0 {
+				candidates = append(candidates, -(q-a*b/stt)/den)
+			}
+		} else if den := b*q - a*p; den != 0 {
+			candidates = append(candidates, (a*q-b*r)/den)
+		}
+		var best RTTRootScore
+		for i, x := range candidates {
+			if x < 0 || x > e.Length() {
+				continue
+			}
+			if rs := stats(x); i == 0 || rs.better(best, criterion) {
+				best = rs
+			}
+		}
+		scores = append(scores, best)
+	}
+	return
+}
+
+// RerootRTT reroots the tree at the position giving the best root-to-tip
+// regression against tip dates (see RTTRootScores), and returns the
+// regression statistics of the chosen root, as well as those of all the
+// branches of the unrooted tree.
+func (t *Tree) RerootRTT(criterion int) (best RTTRootScore, scores []RTTRootScore, err error) {
+	if scores, err = t.RTTRootScores(criterion); err != nil {
+		return
+	}
+	for i, s := range scores {
+		if i == 0 || s.better(best, criterion) {
+			best = s
+		}
+	}
+	t.rerootEdge(best.Edge, best.Position)
+	return
+}
+
+// Signed correlation coefficient of the regression
+func (s RTTRootScore) correlation() float64 {
+	if s.Rate < 0 {
+		return -math.Sqrt(s.R2)
+	}
+	return math.Sqrt(s.R2)
+}
+
+// Whether s is strictly better than s2 for the given criterion
+func (s RTTRootScore) better(s2 RTTRootScore, criterion int) bool {
+	if criterion == RTT_CRITERION_RMS {
+		return s.RMS < s2.RMS
+	}
+	return s.correlation() > s2.correlation()
+}
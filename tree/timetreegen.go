@@ -0,0 +1,322 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"strconv"
+
+	"github.com/fredericlemoine/gostats"
+)
+
+// Maximum number of forward simulations attempted before giving up
+// when the simulated process goes extinct too early.
+const maxSimulationAttempts = 100000
+
+// Node of a simulated time tree, before conversion into a Tree.
+// Times are given backward (distance from the present), so that
+// the parent of a node is always older than the node itself.
+type simNode struct {
+	age      float64
+	children []*simNode
+	sampled  bool
+}
+
+// Generates a random rooted binary tree following a constant rate
+// birth-death process with incomplete extant sampling.
+//
+//   - nbtips: Number of sampled tips
+//   - birth: Birth (speciation) rate
+//   - death: Death (extinction) rate, must be lower than birth
+//   - sampling: Probability for an extant lineage to be sampled, in ]0,1]
+//   - present: Date of the present, given to all tips in the [&date=...] comment
+//
+// The reconstructed tree is simulated exactly, conditioned on the number of
+// sampled tips with a uniform prior on the time of origin, using the coalescent
+// point process representation of the birth-death process (Lambert & Stadler 2013).
+// Branch lengths are in time units.
+func RandomBirthDeathTree(nbtips int, birth, death, sampling, present float64, rand *mathrand.Rand) (*Tree, error) {
+	if nbtips < 2 {
+		return nil, errors.New("Cannot create a birth-death tree with less than 2 tips")
+	}
+	if birth <= 0 || death < 0 {
+		return nil, errors.New("Birth rate must be > 0 and death rate must be >= 0")
+	}
+	if death >= birth {
+		return nil, errors.New("Birth rate must be greater than death rate")
+	}
+	if sampling <= 0 || sampling > 1 {
+		return nil, errors.New("Sampling probability must be in ]0,1]")
+	}
+
+	r := birth - death
+	c := sampling * birth / r
+	// Age of a node from F(t)=1/x, with F(t) = 1 + c(e^{rt}-1)
+	ageFromX := func(x float64) float64 {
+		return math.Log1p((1.0/x-1.0)/c) / r
+	}
+
+	// x = 1/F(origin): density proportional to (1-x)^(n-1)/(1-(1-c)x)
+	// sampled by rejection from a Beta(1,n) proposal
+	var x float64
+	for {
+		x = 1.0 - math.Pow(rand.Float64(), 1.0/float64(nbtips))
+		if x <= 0 {
+			continue
+		}
+		acc := 1.0 / (1.0 - (1.0-c)*x)
+		if c < 1 {
+			acc *= c
+		}
+		if rand.Float64() < acc {
+			break
+		}
+	}
+
+	// Node depths are iid, conditioned on being younger than the origin
+	depths := make([]float64, nbtips-1)
+	for i := range depths {
+		u := rand.Float64()
+		depths[i] = ageFromX(1.0 - u*(1.0-x))
+	}
+
+	tips := make([]*simNode, nbtips)
+	for i := range tips {
+		tips[i] = &simNode{age: 0, sampled: true}
+	}
+	root := coalescentPointProcessRecur(tips, depths)
+
+	return simNodeToTree(root, present, rand)
+}
+
+// Builds the tree of a coalescent point process: the node joining
+// tips[i] and tips[i+1] has age depths[i] and the deepest node
+// separates the left tips from the right tips.
+func coalescentPointProcessRecur(tips []*simNode, depths []float64) *simNode {
+	if len(tips) == 1 {
+		return tips[0]
+	}
+	max := 0
+	for i, d := range depths {
+		if d > depths[max] {
+			max = i
+		}
+	}
+	return &simNode{
+		age: depths[max],
+		children: []*simNode{
+			coalescentPointProcessRecur(tips[:max+1], depths[:max]),
+			coalescentPointProcessRecur(tips[max+1:], depths[max+1:]),
+		},
+	}
+}
+
+// Generates a random rooted binary tree following the Kingman coalescent.
+//
+//   - nbtips: Number of tips, all sampled at the present
+//   - popsize: Effective population size at present (in time units)
+//   - growth: Exponential growth rate of the population (0: constant size),
+//     the population size at age t being popsize*exp(-growth*t)
+//   - present: Date of the present, given to all tips in the [&date=...] comment
+//
+// Branch lengths are in time units.
+func RandomCoalescentTree(nbtips int, popsize, growth, present float64, rand *mathrand.Rand) (*Tree, error) {
+	if nbtips < 2 {
+		return nil, errors.New("Cannot create a coalescent tree with less than 2 tips")
+	}
+	if popsize <= 0 {
+		return nil, errors.New("Population size must be > 0")
+	}
+	if growth < 0 {
+		return nil, errors.New("Growth rate must be >= 0")
+	}
+	gsr := gostats.New(rand)
+
+	lineages := make([]*simNode, nbtips)
+	for i := range lineages {
+		lineages[i] = &simNode{age: 0, sampled: true}
+	}
+	age := 0.0
+	for k := len(lineages); k > 1; k-- {
+		pairs := float64(k*(k-1)) / 2.0
+		if growth == 0 {
+			age += gsr.Exp(pairs / popsize)
+		} else {
+			// Inversion of the cumulative coalescence rate
+			age = math.Log(math.Exp(growth*age)+gsr.Exp(1.0)*popsize*growth/pairs) / growth
+		}
+		i := rand.Intn(k)
+		j := rand.Intn(k - 1)
+		if j >= i {
+			j++
+		}
+		n := &simNode{age: age, children: []*simNode{lineages[i], lineages[j]}}
+		lineages[i] = n
+		lineages[j] = lineages[k-1]
+		lineages = lineages[:k-1]
+	}
+	return simNodeToTree(lineages[0], present, rand)
+}
+
+// Generates a random rooted tree following a constant rate birth-death
+// process with serial sampling through time (fossilized birth-death).
+//
+//   - nbtips: Number of sampled tips
+//   - birth: Birth (speciation) rate
+//   - death: Death (extinction) rate
+//   - sampling: Sampling rate of lineages through time
+//   - removal: Probability that a lineage is removed when sampled. If < 1,
+//     sampled ancestors are possible, and are output as tips attached by
+//     a branch of length 0
+//   - present: Date of the last sampling event
+//
+// The process is simulated forward in time from a single lineage, and is
+// stopped as soon as nbtips lineages are sampled (the process is restarted
+// if it goes extinct before). Unsampled lineages are then pruned, and the
+// tree is rooted at the most recent common ancestor of sampled tips.
+// Each tip carries its sampling date in the [&date=...] comment.
+// Branch lengths are in time units.
+func RandomSerialBirthDeathTree(nbtips int, birth, death, sampling, removal, present float64, rand *mathrand.Rand) (*Tree, error) {
+	if nbtips < 2 {
+		return nil, errors.New("Cannot create a birth-death tree with less than 2 tips")
+	}
+	if birth <= 0 || death < 0 || sampling <= 0 {
+		return nil, errors.New("Birth and sampling rates must be > 0 and death rate must be >= 0")
+	}
+	if removal < 0 || removal > 1 {
+		return nil, errors.New("Removal probability must be in [0,1]")
+	}
+	gsr := gostats.New(rand)
+	total := birth + death + sampling
+
+	for attempt := 0; attempt < maxSimulationAttempts; attempt++ {
+		// Times are forward here, they are converted into ages at the end
+		origin := &simNode{}
+		lineages := []*simNode{origin}
+		var samples []*simNode
+		time := 0.0
+		for len(lineages) > 0 && len(samples) < nbtips {
+			time += gsr.Exp(total * float64(len(lineages)))
+			i := rand.Intn(len(lineages))
+			l := lineages[i]
+			u := rand.Float64() * total
+			switch {
+			case u < birth:
+				c1, c2 := &simNode{age: time}, &simNode{age: time}
+				l.age = time
+				l.children = []*simNode{c1, c2}
+				lineages[i] = c1
+				lineages = append(lineages, c2)
+			case u < birth+death:
+				l.age = time
+				lineages[i] = lineages[len(lineages)-1]
+				lineages = lineages[:len(lineages)-1]
+			default:
+				if rand.Float64() < removal {
+					l.age = time
+					l.sampled = true
+					samples = append(samples, l)
+					lineages[i] = lineages[len(lineages)-1]
+					lineages = lineages[:len(lineages)-1]
+				} else {
+					s, c := &simNode{age: time, sampled: true}, &simNode{age: time}
+					l.age = time
+					l.children = []*simNode{s, c}
+					samples = append(samples, s)
+					lineages[i] = c
+				}
+			}
+		}
+		if len(samples) < nbtips {
+			continue
+		}
+		// Remaining lineages end at the last sampling time, unsampled
+		for _, l := range lineages {
+			l.age = time
+		}
+		root := pruneSimNode(origin)
+		setSimNodeAges(root, time)
+		return simNodeToTree(root, present, rand)
+	}
+	return nil, fmt.Errorf("cannot simulate a tree with %d sampled tips after %d attempts", nbtips, maxSimulationAttempts)
+}
+
+// Removes unsampled subtrees and nodes having a single child.
+// Returns nil if no sampled node is below n.
+func pruneSimNode(n *simNode) *simNode {
+	if n.sampled {
+		return n
+	}
+	children := n.children[:0]
+	for _, c := range n.children {
+		if p := pruneSimNode(c); p != nil {
+			children = append(children, p)
+		}
+	}
+	n.children = children
+	switch len(children) {
+	case 0:
+		return nil
+	case 1:
+		return children[0]
+	}
+	return n
+}
+
+// Converts forward times into ages relative to the given end time
+func setSimNodeAges(n *simNode, end float64) {
+	n.age = end - n.age
+	for _, c := range n.children {
+		setSimNodeAges(c, end)
+	}
+}
+
+// Converts a simulated tree into a Tree. Tip names are
+// randomly assigned, and tips are annotated with their
+// date (present - age) in the [&date=...] comment.
+func simNodeToTree(root *simNode, present float64, rand *mathrand.Rand) (*Tree, error) {
+	t := NewTree()
+	nbtips := 0
+	var count func(n *simNode)
+	count = func(n *simNode) {
+		if len(n.children) == 0 {
+			nbtips++
+		}
+		for _, c := range n.children {
+			count(c)
+		}
+	}
+	count(root)
+	if nbtips < 2 {
+		return nil, errors.New("Cannot create a tree with less than 2 tips")
+	}
+	names := rand.Perm(nbtips)
+	tipid, nodeid, edgeid := 0, 0, 0
+
+	var build func(sn *simNode, parent *simNode, prev *Node) *Node
+	build = func(sn *simNode, parent *simNode, prev *Node) *Node {
+		// Node and edge ids follow the pre-order, as in parsed trees
+		n := t.NewNode()
+		n.SetId(nodeid)
+		nodeid++
+		if prev != nil {
+			e := t.ConnectNodes(prev, n)
+			e.SetId(edgeid)
+			edgeid++
+			e.SetLength(parent.age - sn.age)
+		}
+		if len(sn.children) == 0 {
+			n.SetName("Tip" + strconv.Itoa(names[tipid]))
+			n.SetAttribute("date", fmt.Sprintf("%f", present-sn.age))
+			tipid++
+		}
+		for _, c := range sn.children {
+			build(c, sn, n)
+		}
+		return n
+	}
+	t.SetRoot(build(root, nil, nil))
+	t.ReinitIndexes()
+	return t, nil
+}
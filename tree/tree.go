@@ -422,21 +422,79 @@ func (t *Tree) String() string {
 	return t.Newick()
 }
 
+// Formats of the node and edge attributes in the Newick writer
+const (
+	NEWICK_ATTRIBUTES_RAW   = iota // Comments are written as they are
+	NEWICK_ATTRIBUTES_BEAST        // Attributes are merged into [&key=value,...] comments
+	NEWICK_ATTRIBUTES_NHX          // Attributes are merged into [&&NHX:key=value:...] comments, after branch lengths
+)
+
+// Options of the Newick writer
+type NewickOptions struct {
+	Attributes      int  // NEWICK_ATTRIBUTES_RAW, NEWICK_ATTRIBUTES_BEAST, or NEWICK_ATTRIBUTES_NHX
+	Precision       int  // Number of decimals of lengths and supports (-1: as many as needed)
+	NoSupports      bool // Branch supports are not written
+	NoInternalNames bool // Internal node names are not written
+	QuoteNames      bool // All names are quoted (otherwise, only names with special characters)
+}
+
+// Returns the options of the default Newick writer
+func DefaultNewickOptions() NewickOptions {
+	return NewickOptions{Attributes: NEWICK_ATTRIBUTES_RAW, Precision: -1}
+}
+
 // Returns a newick string representation of this tree
 func (t *Tree) Newick() string {
+	return t.NewickWithOptions(DefaultNewickOptions())
+}
+
+// Returns a newick string representation of this tree,
+// following the given writer options.
+//
+// In NEWICK_ATTRIBUTES_NHX mode, attributes of a node and of its
+// parent edge are written in a single NHX comment, after the branch
+// length, as expected by tools such as ETE.
+// In NEWICK_ATTRIBUTES_BEAST and NEWICK_ATTRIBUTES_NHX modes, comments
+// that do not contain attributes are written as they are.
+func (t *Tree) NewickWithOptions(opts NewickOptions) string {
 	var buffer bytes.Buffer
-	t.root.Newick(nil, &buffer)
-	if len(t.root.comment) != 0 {
-		for _, c := range t.root.comment {
-			buffer.WriteString("[")
-			buffer.WriteString(c)
-			buffer.WriteString("]")
-		}
-	}
+	t.root.NewickWithOptions(nil, &buffer, opts)
+	writeNewickComments(&buffer, t.root.comment, opts)
 	buffer.WriteString(";")
 	return buffer.String()
 }
 
+// Writes the comments in the newick buffer, merging
+// attributes in a single comment, depending on the options
+func writeNewickComments(buffer *bytes.Buffer, comments []string, opts NewickOptions) {
+	var fields []string
+	for _, c := range comments {
+		if _, _, ok := attributeFields(c); ok && opts.Attributes != NEWICK_ATTRIBUTES_RAW {
+			continue
+		}
+		buffer.WriteString("[")
+		buffer.WriteString(c)
+		buffer.WriteString("]")
+	}
+	if opts.Attributes == NEWICK_ATTRIBUTES_RAW {
+		return
+	}
+	for _, a := range commentAttributes(comments) {
+		if opts.Attributes == NEWICK_ATTRIBUTES_NHX {
+			fields = append(fields, a.Key+"="+a.Value)
+		} else if strings.ContainsAny(a.Value, ",=[]:; \"'") && !strings.HasPrefix(a.Value, "{") {
+			fields = append(fields, a.Key+"=\""+a.Value+"\"")
+		} else {
+			fields = append(fields, a.Key+"="+a.Value)
+		}
+	}
+	if len(fields) > 0 {
+		buffer.WriteString("[")
+		buffer.WriteString(joinAttributeFields(fields, opts.Attributes == NEWICK_ATTRIBUTES_NHX))
+		buffer.WriteString("]")
+	}
+}
+
 // returns a Nexus string representation of this tree
 func (t *Tree) Nexus() string {
 	newick := t.Newick()
@@ -0,0 +1,401 @@
+package tree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/fredericlemoine/bitset"
+)
+
+const (
+	TREE_DISTANCE_RF  = iota // Robinson-Foulds distance: number of non trivial splits present in only one tree
+	TREE_DISTANCE_MS         // Matching split distance (Bogdanowicz & Giaro, 2012)
+	TREE_DISTANCE_KF         // Kuhner-Felsenstein branch score distance (Kuhner & Felsenstein, 1994)
+	TREE_DISTANCE_PD         // Path difference distance (Steel & Penny, 1993)
+	TREE_DISTANCE_SPR        // Approximation of the SPR distance (de Oliveira Martins et al., 2008)
+)
+
+// Information on a tree needed to compute tree distances, computed once per tree
+type treeDistanceData struct {
+	ntips   uint
+	splits  []*bitset.BitSet   // Unique non trivial splits, normalized such that the first tip is not in the set
+	lengths map[string]float64 // Split (all splits, including trivial ones) => sum of branch lengths
+	paths   [][]float64        // Topological distance matrix between tips, in the order of the tip index
+}
+
+// Computes the distance between two trees having the same tips.
+//
+// distance can be:
+//   - TREE_DISTANCE_RF : Robinson-Foulds distance
+//   - TREE_DISTANCE_MS : Matching split distance
+//   - TREE_DISTANCE_KF : Kuhner-Felsenstein branch score distance
+//   - TREE_DISTANCE_PD : Path difference distance (topological)
+//   - TREE_DISTANCE_SPR : Approximation of the SPR distance
+func TreeDistance(t1, t2 *Tree, distance int) (dist float64, err error) {
+	var d1, d2 *treeDistanceData
+	if d1, err = newTreeDistanceData(t1, distance); err != nil {
+		return
+	}
+	if d2, err = newTreeDistanceData(t2, distance); err != nil {
+		return
+	}
+	if err = t1.CompareTipIndexes(t2); err != nil {
+		return
+	}
+	return treeDistance(d1, d2, distance)
+}
+
+// Computes the all-vs-all distance matrix between all the given trees,
+// which must have the same tips. Distances are computed using cpus go routines.
+//
+// See TreeDistance for the available distances.
+func TreeDistanceMatrix(trees []*Tree, distance int, cpus int) (matrix [][]float64, err error) {
+	data := make([]*treeDistanceData, len(trees))
+	for i, t := range trees {
+		if data[i], err = newTreeDistanceData(t, distance); err != nil {
+			return
+		}
+		if i > 0 {
+			if err = trees[0].CompareTipIndexes(t); err != nil {
+				err = fmt.Errorf("tree %d: %v", i, err)
+				return
+			}
+		}
+	}
+
+	matrix = make([][]float64, len(trees))
+	for i := range trees {
+		matrix[i] = make([]float64, len(trees))
+	}
+
+	pairs := make(chan [2]int, 100)
+	go func() {
+		for i := range trees {
+			for j := i + 1; j < len(trees); j++ {
+				pairs <- [2]int{i, j}
+			}
+		}
+		close(pairs)
+	}()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for cpu := 0; cpu < cpus; cpu++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pairs {
+				d, inerr := treeDistance(data[p[0]], data[p[1]], distance)
+				if inerr != nil {
+					mutex.Lock()
+					err = inerr
+					mutex.Unlock()
+					continue
+				}
+				matrix[p[0]][p[1]] = d
+				matrix[p[1]][p[0]] = d
+			}
+		}()
+	}
+	wg.Wait()
+	return
+}
+
+func newTreeDistanceData(t *Tree, distance int) (d *treeDistanceData, err error) {
+	if t == nil {
+		err = errors.New("tree in distance computation is nil")
+		return
+	}
+	if err = t.ReinitIndexes(); err != nil {
+		return
+	}
+	d = &treeDistanceData{
+		ntips:   uint(len(t.tipIndex)),
+		splits:  make([]*bitset.BitSet, 0),
+		lengths: make(map[string]float64),
+	}
+	seen := make(map[string]bool)
+	for _, e := range t.Edges() {
+		bs := e.Bitset().Clone()
+		if bs.Test(0) {
+			bs = bs.Complement()
+		}
+		key := bitsetKey(bs)
+		length := 0.0
+		if e.Length() != NIL_LENGTH {
+			length = e.Length()
+		}
+		d.lengths[key] += length
+		if c := bs.Count(); c > 1 && c < d.ntips-1 && !seen[key] {
+			seen[key] = true
+			d.splits = append(d.splits, bs)
+		}
+	}
+	if distance == TREE_DISTANCE_PD {
+		// Tips are sorted by name, as in the tip index
+		d.paths, _ = t.ToDistanceMatrix(DISTANCE_METRIC_NONE)
+	}
+	return
+}
+
+func treeDistance(d1, d2 *treeDistanceData, distance int) (dist float64, err error) {
+	switch distance {
+	case TREE_DISTANCE_RF:
+		dist = float64(rfDistance(d1, d2))
+	case TREE_DISTANCE_MS:
+		dist = float64(matchingSplitDistance(d1, d2))
+	case TREE_DISTANCE_KF:
+		dist = kfDistance(d1, d2)
+	case TREE_DISTANCE_PD:
+		dist = pathDifferenceDistance(d1, d2)
+	case TREE_DISTANCE_SPR:
+		dist = float64(sprApproxDistance(d1, d2))
+	default:
+		err = fmt.Errorf("unknown tree distance %d", distance)
+	}
+	return
+}
+
+// Number of non trivial splits present in only one of the trees
+func rfDistance(d1, d2 *treeDistanceData) (dist int) {
+	common := 0
+	for _, s := range d1.splits {
+		if _, ok := d2.lengths[bitsetKey(s)]; ok {
+			common++
+		}
+	}
+	return len(d1.splits) + len(d2.splits) - 2*common
+}
+
+// Square root of the sum of squared differences of lengths of all the splits,
+// a split absent from a tree having a length of 0
+func kfDistance(d1, d2 *treeDistanceData) float64 {
+	sum := 0.0
+	for k, l1 := range d1.lengths {
+		l2 := d2.lengths[k]
+		sum += (l1 - l2) * (l1 - l2)
+	}
+	for k, l2 := range d2.lengths {
+		if _, ok := d1.lengths[k]; !ok {
+			sum += l2 * l2
+		}
+	}
+	return math.Sqrt(sum)
+}
+
+// Square root of the sum of squared differences of topological distances
+// between all pairs of tips
+func pathDifferenceDistance(d1, d2 *treeDistanceData) float64 {
+	sum := 0.0
+	for i := range d1.paths {
+		for j := i + 1; j < len(d1.paths); j++ {
+			diff := d1.paths[i][j] - d2.paths[i][j]
+			sum += diff * diff
+		}
+	}
+	return math.Sqrt(sum)
+}
+
+// Matching split distance: cost of the minimum weight perfect matching between
+// the splits of the two trees, the cost of matching two splits A1|B1 and A2|B2
+// being n - max(|A1∩A2|+|B1∩B2|, |A1∩B2|+|B1∩A2|).
+//
+// If a tree has less splits, it is completed with empty splits (∅|X).
+func matchingSplitDistance(d1, d2 *treeDistanceData) int {
+	n := len(d1.splits)
+	if len(d2.splits) > n {
+		n = len(d2.splits)
+	}
+	cost := make([][]int, n)
+	for i := 0; i < n; i++ {
+		cost[i] = make([]int, n)
+		for j := 0; j < n; j++ {
+			switch {
+			case i < len(d1.splits) && j < len(d2.splits):
+				diff := int(d1.splits[i].SymmetricDifferenceCardinality(d2.splits[j]))
+				cost[i][j] = min(diff, int(d1.ntips)-diff)
+			case i < len(d1.splits):
+				c := int(d1.splits[i].Count())
+				cost[i][j] = min(c, int(d1.ntips)-c)
+			case j < len(d2.splits):
+				c := int(d2.splits[j].Count())
+				cost[i][j] = min(c, int(d2.ntips)-c)
+			}
+		}
+	}
+	return hungarian(cost)
+}
+
+// Cost of the minimum weight perfect matching of the given square cost matrix
+// (Hungarian algorithm with potentials, O(n^3))
+func hungarian(cost [][]int) int {
+	n := len(cost)
+	inf := math.MaxInt / 2
+	u := make([]int, n+1)
+	v := make([]int, n+1)
+	p := make([]int, n+1) // p[j]: row matched to column j (1-based, 0: none)
+	way := make([]int, n+1)
+	minv := make([]int, n+1)
+	used := make([]bool, n+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j] = inf
+			used[j] = false
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], inf, 0
+			for j := 1; j <= n; j++ {
+				if !used[j] {
+					cur := cost[i0-1][j-1] - u[i0] - v[j]
+					if cur < minv[j] {
+						minv[j], way[j] = cur, j0
+					}
+					if minv[j] < delta {
+						delta, j1 = minv[j], j
+					}
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+	total := 0
+	for j := 1; j <= n; j++ {
+		if p[j] != 0 {
+			total += cost[p[j]-1][j-1]
+		}
+	}
+	return total
+}
+
+// Approximation of the SPR distance, after the heuristic of de Oliveira Martins et al. (2008):
+// While the two trees restricted to the remaining tips have incompatible splits,
+// the smallest set of tips explaining the incompatibility of two splits (and the most
+// frequent one in case of ties) is removed, which counts for one SPR move.
+//
+// This is a heuristic estimate, and not a bound of the SPR distance: the removed tip
+// sets are not necessarily subtrees common to both trees, and their inner topologies
+// are not compared, so the true distance may be larger or smaller.
+func sprApproxDistance(d1, d2 *treeDistanceData) (dist int) {
+	active := bitset.New(d1.ntips)
+	for i := uint(0); i < d1.ntips; i++ {
+		active.Set(i)
+	}
+	for {
+		s1 := restrictSplits(d1.splits, active)
+		s2 := restrictSplits(d2.splits, active)
+		// Candidate tip sets, with their number of occurrences
+		candidates := make(map[string]*bitset.BitSet)
+		counts := make(map[string]int)
+		for _, a := range s1 {
+			b := active.Difference(a)
+			for _, c := range s2 {
+				d := active.Difference(c)
+				// Smallest intersections of the 4 sets (none empty if incompatible)
+				inters := []*bitset.BitSet{a.Intersection(c), a.Intersection(d), b.Intersection(c), b.Intersection(d)}
+				minsize := d1.ntips
+				for _, in := range inters {
+					minsize = min(minsize, in.Count())
+				}
+				if minsize == 0 {
+					continue
+				}
+				for _, in := range inters {
+					if in.Count() == minsize {
+						key := bitsetKey(in)
+						candidates[key] = in
+						counts[key]++
+					}
+				}
+			}
+		}
+		// Candidate giving the smallest number of incompatible splits
+		// once removed, then the smallest, then the most frequent
+		var best *bitset.BitSet
+		var bestkey string
+		var bestscore int
+		for key, c := range candidates {
+			score := incompatibleSplits(d1.splits, d2.splits, active.Difference(c))
+			if best == nil || score < bestscore ||
+				(score == bestscore && (c.Count() < best.Count() ||
+					(c.Count() == best.Count() && (counts[key] > counts[bestkey] ||
+						(counts[key] == counts[bestkey] && key < bestkey))))) {
+				best, bestkey, bestscore = c, key, score
+			}
+		}
+		if best == nil {
+			return
+		}
+		active.InPlaceDifference(best)
+		dist++
+	}
+}
+
+// Number of pairs of incompatible splits of the two trees restricted to the active tips
+func incompatibleSplits(splits1, splits2 []*bitset.BitSet, active *bitset.BitSet) (nb int) {
+	s1 := restrictSplits(splits1, active)
+	s2 := restrictSplits(splits2, active)
+	for _, a := range s1 {
+		for _, c := range s2 {
+			// a and c are normalized: they are compatible if one includes the other, or if they are disjoint
+			inter := a.IntersectionCardinality(c)
+			if inter != 0 && inter != a.Count() && inter != c.Count() {
+				nb++
+			}
+		}
+	}
+	return
+}
+
+// Restricts the splits to the active tips, and keeps the unique non trivial ones,
+// normalized such that the first active tip is not in the set
+func restrictSplits(splits []*bitset.BitSet, active *bitset.BitSet) (restricted []*bitset.BitSet) {
+	first, _ := active.NextSet(0)
+	nactive := active.Count()
+	seen := make(map[string]bool)
+	for _, s := range splits {
+		r := s.Intersection(active)
+		if r.Test(first) {
+			r = active.Difference(r)
+		}
+		if c := r.Count(); c > 1 && c < nactive-1 {
+			key := bitsetKey(r)
+			if !seen[key] {
+				seen[key] = true
+				restricted = append(restricted, r)
+			}
+		}
+	}
+	return
+}
+
+// Key identifying the content of a bitset
+func bitsetKey(bs *bitset.BitSet) string {
+	words := bs.Bytes()
+	key := make([]byte, 8*len(words))
+	for i, w := range words {
+		binary.LittleEndian.PutUint64(key[8*i:], w)
+	}
+	return string(key)
+}